@@ -2,46 +2,139 @@ package goback
 
 import (
 	"bytes"
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
+	"pocket-lang/types"
 	"pocket-lang/xform"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type Generator struct {
-	input         Nod
-	buf           *bytes.Buffer
-	tmpVarCounter int
-}
-
+	input          Nod
+	buf            *bytes.Buffer
+	tmpVarCounter  int
+	assertOperands [2]string    // how the PNT_ASSERT_OPERANDs of the current assert are generated
+	within         []Nod        // the values and statements being generated, innermost last
+	errs           []string     // the internal compiler errors so far
+	usesBig        bool         // whether the code refers to math/big, which it then imports
+	read           map[Nod]bool // the var defs the code reads, see VarDefsRead
+}
+
+// Generate makes a go program of the code, which it prepares first. The
+// program is checked to parse and type check against the runtime lib, and
+// comes formatted. A node it doesn't know how to generate, or a program that
+// doesn't check, is a bug in the compiler, and panics with an internal
+// compiler error.
 func Generate(code Nod) string {
 
 	preparer := &Preparer{&xform.Xformer{}}
 	preparer.Prepare(code)
 
-	fmt.Println("Prepared code:\n", PrettyPrint(code))
+	Debugf(LOGCH_GEN, "prepared code:\n%s", LazyTree(code))
 
 	generator := &Generator{
 		buf:   &bytes.Buffer{},
 		input: code,
+		read:  VarDefsRead(preparer.SearchForNodeType(NT_VAR_GETTER)),
 	}
 
 	generator.genSourceFile(code)
+	generator.panicOnInternalErrors()
+
+	return checkAndFormat(generator.buf.String())
+}
 
+// generates a single value from a tree that was already prepared
+func GenerateValue(n Nod) string {
+	generator := &Generator{
+		buf:   &bytes.Buffer{},
+		input: n,
+	}
+	generator.genValue(n)
+	generator.panicOnInternalErrors()
 	return generator.buf.String()
 }
 
-func (g *Generator) genSourceFile(input Nod) {
-	g.WS("package main\n\n")
+// notes that n can't be generated as what, e.g. a value. It's located at
+// where it or the nearest value or statement it's in was parsed.
+func (g *Generator) internalError(n Nod, what string) {
+	loc := nearestLoc(n, map[Nod]bool{})
+	for ndx := len(g.within) - 1; loc == nil && ndx >= 0; ndx-- {
+		loc = nearestLoc(g.within[ndx], map[Nod]bool{})
+	}
+	where := "?"
+	if loc != nil {
+		where = loc.StringDebug()
+	}
+	g.errs = append(g.errs, "internal compiler error: the go backend can't generate "+
+		preparedNodeTypeName(n.NodeType)+" as "+what+", at "+where)
+}
+
+func (g *Generator) panicOnInternalErrors() {
+	if len(g.errs) > 0 {
+		panic(strings.Join(g.errs, "\n"))
+	}
+}
 
-	if fmtImport := NodGetChildOrNil(input, PNTR_GOIMPORTS); fmtImport != nil {
-		g.WS("import \"fmt\"\n\n")
+func preparedNodeTypeName(nt int) string {
+	if name, ok := PreparedNodeTypeNames[nt]; ok {
+		return name
 	}
+	return NodeTypeName(nt)
+}
+
+// where n or, failing that, the nearest node under it was parsed
+func nearestLoc(n Nod, seen map[Nod]bool) *types.SourceLocation {
+	if n.Loc != nil || seen[n] {
+		return n.Loc
+	}
+	seen[n] = true
+	ets := []int{}
+	for et := range n.Out {
+		ets = append(ets, et)
+	}
+	sort.Ints(ets)
+	for _, et := range ets {
+		if loc := nearestLoc(n.Out[et].Out, seen); loc != nil {
+			return loc
+		}
+	}
+	return nil
+}
 
-	units := NodGetChildList(input)
+// GenerateTestMain generates the _test.go file for the code Generate made of
+// a test build. Test builds have no main of their own, so it declares an
+// empty one, and its TestMain runs the tests instead, reporting on stdout.
+func GenerateTestMain(tests []TestDef) string {
+	g := &Generator{buf: &bytes.Buffer{}}
+	g.WS("package main\n\n")
+	g.WS("import \"os\"\nimport \"testing\"\n\n")
+	g.WS("func main() {}\n\n")
+	g.WS("func TestMain(m *testing.M) {\n")
+	g.WS("names := []string{")
+	for _, test := range tests {
+		g.genLiteralStringRaw(test.Name)
+		g.WS(", ")
+	}
+	g.WS("}\n")
+	g.WS("tests := []func(){")
+	for _, test := range tests {
+		g.WS(test.FuncName)
+		g.WS(", ")
+	}
+	g.WS("}\n")
+	g.WS("if P__run_tests(os.Stdout, names, tests) > 0 {\nos.Exit(1)\n}\n")
+	g.WS("}\n")
+	return g.buf.String()
+}
 
-	for _, unit := range units {
+func (g *Generator) genSourceFile(input Nod) {
+	// the units go first, since what's imported depends on what they use
+	header := g.buf
+	g.buf = &bytes.Buffer{}
+	for _, unit := range NodGetChildList(input) {
 		if unit.NodeType == NT_FUNCDEF {
 			g.genFuncDef(unit)
 		} else if unit.NodeType == NT_CLASSDEF {
@@ -51,6 +144,28 @@ func (g *Generator) genSourceFile(input Nod) {
 		}
 		g.WS("\n")
 	}
+	body := g.buf
+	g.buf = header
+
+	g.WS("package main\n\n")
+	imports := []string{}
+	if importsNod := NodGetChildOrNil(input, PNTR_GOIMPORTS); importsNod != nil {
+		for _, imp := range NodGetChildList(importsNod) {
+			imports = append(imports, imp.Data.(string))
+		}
+	}
+	if g.usesBig {
+		imports = append(imports, "math/big")
+	}
+	for _, imp := range imports {
+		g.WS("import ")
+		g.genLiteralStringRaw(imp)
+		g.WS("\n")
+	}
+	if len(imports) > 0 {
+		g.WS("\n")
+	}
+	g.buf.Write(body.Bytes())
 }
 
 func (g *Generator) genClassDef(n Nod) {
@@ -66,8 +181,17 @@ func (g *Generator) genClassDef(n Nod) {
 		g.WS(" *")
 		g.WS(g.getStaticZoneName(clsName))
 		g.WS(" = ")
+		ctor := StaticConstructorOrNil(staticZone)
+		if ctor != nil {
+			g.WS(g.getConstructorName(g.getStaticZoneName(clsName), ctor))
+			g.WS("(")
+		}
 		g.WS(g.getDefaultConstructorName(g.getStaticZoneName(clsName)))
-		g.WS("()\n")
+		g.WS("()")
+		if ctor != nil {
+			g.WS(")")
+		}
+		g.WS("\n")
 	}
 }
 
@@ -75,6 +199,11 @@ func (g *Generator) getDefaultConstructorName(clsName string) string {
 	return "New" + clsName
 }
 
+// e.g. NewPoint_new, for the go func that calls a constructor method
+func (g *Generator) getConstructorName(clsName string, ctor Nod) string {
+	return g.getDefaultConstructorName(clsName) + "_" + NodGetChild(ctor, NTR_FUNCDEF_NAME).Data.(string)
+}
+
 func (g *Generator) genClassDefNamed(n Nod, clsName string) {
 	g.WS("type ")
 	g.WS(clsName)
@@ -89,8 +218,13 @@ func (g *Generator) genClassDefNamed(n Nod, clsName string) {
 	}
 	g.WS("}\n")
 
-	// generate the default constructor
+	// generate the default constructor, and those that call constructor methods
 	g.genClassDefaultConstructor(n, clsName)
+	for _, unit := range clsUnits {
+		if unit.NodeType == NT_FUNCDEF && NodHasChild(unit, PNTR_CONSTRUCTOR) {
+			g.genClassConstructor(unit, clsName)
+		}
+	}
 
 	// generate all the methods
 	for _, unit := range clsUnits {
@@ -127,6 +261,36 @@ func (g *Generator) genClassDefaultConstructor(n Nod, clsName string) {
 	g.WS("}\n")
 }
 
+// calls the constructor method on an object that has its default values and
+// config args, e.g. NewPoint_new(NewPoint(), 1)
+func (g *Generator) genClassConstructor(ctor Nod, clsName string) {
+	g.WS("func ")
+	g.WS(g.getConstructorName(clsName, ctor))
+	g.WS("(rv *")
+	g.WS(clsName)
+	inType := NodGetChildOrNil(ctor, NTR_FUNCDEF_INTYPE)
+	passed := ""
+	if inType != nil && inType.NodeType == NT_PARAMETER {
+		g.WS(", ")
+		g.genParameter(inType)
+		passed = NodGetChild(inType, NTR_VARDEF_NAME).Data.(string)
+	} else if inType != nil && inType.NodeType == NT_LIT_LIST {
+		g.WS(", ")
+		g.genParameterList(inType)
+		passed = "args"
+	}
+	g.WS(") *")
+	g.WS(clsName)
+	g.WS(" {\n")
+	g.WS("rv.")
+	g.WS(NodGetChild(ctor, NTR_FUNCDEF_NAME).Data.(string))
+	g.WS("(")
+	g.WS(passed)
+	g.WS(")\n")
+	g.WS("return rv\n")
+	g.WS("}\n")
+}
+
 func (g *Generator) genClassField(n Nod) {
 	pkFieldName := NodGetChild(n, NTR_VARDEF_NAME).Data.(string)
 	g.WS(g.convertToGoFieldName(pkFieldName))
@@ -239,6 +403,9 @@ func (g *Generator) genArgUnpacking(inTypeDef Nod) {
 		g.genType(typeNod)
 		g.WS(")")
 		g.WS("\n")
+		if varDef := NodGetChildOrNil(param, NTR_VARDEF); varDef == nil || !g.read[varDef] {
+			g.genMarkUsed(NodGetChild(param, NTR_VARDEF_NAME).Data.(string))
+		}
 	}
 }
 
@@ -286,6 +453,17 @@ func (g *Generator) genVarDef(n Nod) {
 		g.genType(NodGetChild(n, NTR_TYPE))
 	}
 	g.WS("\n")
+	if !g.read[n] {
+		g.genMarkUsed(varName)
+	}
+}
+
+// go rejects locals that are never read, which pocket only warns about, so
+// those get a use made up
+func (g *Generator) genMarkUsed(varName string) {
+	g.WS("_ = ")
+	g.WS(varName)
+	g.WS("\n")
 }
 
 func (g *Generator) getGenTypeBase(n Nod) string {
@@ -302,12 +480,23 @@ func (g *Generator) getGenTypeBase(n Nod) string {
 		TY_LIST:   "[]interface{}",
 		TY_SET:    "map[interface{}]bool",
 		TY_MAP:    "map[interface{}]interface{}",
+		TY_I8:     "int8",
+		TY_I16:    "int16",
+		TY_I32:    "int32",
+		TY_I64:    "int64",
+		TY_U8:     "uint8",
+		TY_U16:    "uint16",
+		TY_U32:    "uint32",
+		TY_U64:    "uint64",
+		TY_F32:    "float32",
+		TY_BIGINT: "*big.Int",
 	}
 	if val, ok := lut[n.Data.(int)]; ok {
+		g.usesBig = g.usesBig || n.Data.(int) == TY_BIGINT
 		return val
-	} else {
-		return "<basetype>"
 	}
+	g.internalError(n, "a base type")
+	return ""
 }
 
 func (g *Generator) getGenResult(printRoutine func(subGenerator *Generator)) string {
@@ -315,6 +504,8 @@ func (g *Generator) getGenResult(printRoutine func(subGenerator *Generator)) str
 		buf: &bytes.Buffer{},
 	}
 	printRoutine(subg)
+	g.errs = append(g.errs, subg.errs...)
+	g.usesBig = g.usesBig || subg.usesBig
 	return subg.buf.String()
 }
 
@@ -336,7 +527,7 @@ func (g *Generator) genType(n Nod) {
 	} else if n.NodeType == NT_TYPECALL {
 		g.genTypeCall(n)
 	} else {
-		g.WS("<type>")
+		g.internalError(n, "a type")
 	}
 }
 
@@ -348,7 +539,7 @@ func (g *Generator) genTypeCall(n Nod) {
 		g.genType(arg)
 		return
 	}
-	g.WS("<typecall>")
+	g.internalError(n, "a type")
 }
 
 func (g *Generator) genTypeFuncDef(n Nod) {
@@ -376,6 +567,8 @@ func isReceiverCallType(nt int) bool {
 }
 
 func (g *Generator) genImperativeUnit(n Nod) {
+	g.within = append(g.within, n)
+	defer func() { g.within = g.within[:len(g.within)-1] }()
 	if n.NodeType == NT_VARASSIGN {
 		g.genVarAssign(n)
 	} else if isReceiverCallType(n.NodeType) {
@@ -398,8 +591,10 @@ func (g *Generator) genImperativeUnit(n Nod) {
 		g.genDuckFieldWrite(n)
 	} else if n.NodeType == PNT_DUCK_METHOD_CALL {
 		g.genDuckMethodCall(n)
+	} else if n.NodeType == NT_ASSERT {
+		g.genAssert(n)
 	} else {
-		g.WS("command")
+		g.internalError(n, "a statement")
 	}
 	g.WS("\n")
 }
@@ -440,6 +635,46 @@ func (g *Generator) genDuckFieldWrite(n Nod) {
 	g.WS(")")
 }
 
+func (g *Generator) genAssert(n Nod) {
+	// the sides of a comparison go in temps so a failure can show them, except
+	// for literals, which have to stay inline to take the type of the other side
+	inits := []string{}
+	args := ""
+	if NodHasChild(n, PNTR_ASSERT_LEFT) {
+		for ndx, side := range []int{PNTR_ASSERT_LEFT, PNTR_ASSERT_RIGHT} {
+			operand := NodGetChild(n, side)
+			text := g.getGenResult(func(subg *Generator) { subg.genValue(operand) })
+			if !isLiteralOperand(operand) {
+				tmpVarName := g.getTempVarName()
+				inits = append(inits, tmpVarName+" := "+text+"; ")
+				text = tmpVarName
+			}
+			g.assertOperands[ndx] = text
+			args += ", " + text
+		}
+	}
+
+	g.WS("if ")
+	g.WS(strings.Join(inits, ""))
+	g.WS("!(")
+	g.genValue(NodGetChild(n, NTR_ASSERT_COND))
+	g.WS(") {\n")
+	g.WS("P__assert_failed(")
+	g.genLiteralStringRaw(n.Loc.StringDebug())
+	g.WS(", ")
+	g.genLiteralStringRaw(AssertedComparison(n))
+	g.WS(args)
+	g.WS(")\n}")
+}
+
+func isLiteralOperand(n Nod) bool {
+	nt := n.NodeType
+	if nt == NT_NEGOP || nt == NT_POSOP {
+		return isLiteralOperand(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	}
+	return nt == NT_LIT_INT || nt == NT_LIT_FLOAT || nt == NT_LIT_STRING || nt == NT_LIT_BOOL
+}
+
 func (g *Generator) genPass(n Nod) {
 	g.WS("")
 }
@@ -523,8 +758,10 @@ func (g *Generator) genLValue(n Nod, varDef Nod) {
 	// prepend "self." to simple class variables
 	if varDef != nil && NodHasChild(varDef, NTR_VARDEF_SCOPE) {
 		if NodGetChild(varDef, NTR_VARDEF_SCOPE).Data.(int) == VSCOPE_CLASSFIELD &&
-			n.NodeType == NT_IDENTIFIER {
+			(n.NodeType == NT_IDENTIFIER || n.NodeType == NT_IDENTIFIER_RESOLVED) {
 			g.WS("self.")
+			g.WS(g.convertToGoFieldName(n.Data.(string)))
+			return
 		}
 	}
 	if n.NodeType == NT_DOTOP {
@@ -543,8 +780,10 @@ func (g *Generator) genLValue(n Nod, varDef Nod) {
 		g.WS(n.Data.(string))
 	} else if n.NodeType == NT_COLLECTION_INDEXOR {
 		g.genCollectionIndexor(n)
+	} else if n.NodeType == PNT_LIST_INDEXOR {
+		g.genListIndexorChecked(n)
 	} else {
-		g.WS("lvalue")
+		g.internalError(n, "an assignment target")
 	}
 }
 
@@ -568,7 +807,7 @@ func (g *Generator) genReceiverCall(n Nod) {
 
 	arg := NodGetChildOrNil(n, NTR_RECEIVERCALL_ARG)
 
-	g.genArg(arg)
+	g.genArg(n, arg)
 }
 
 func (g *Generator) genReceiverCallBase(n Nod) {
@@ -590,20 +829,48 @@ func (g *Generator) genReceiverCallMethod(n Nod) {
 
 	arg := NodGetChildOrNil(n, NTR_RECEIVERCALL_ARG)
 
-	g.genArg(arg)
+	g.genArg(n, arg)
 
 }
 
-func (g *Generator) genArg(arg Nod) {
+func (g *Generator) genArg(call Nod, arg Nod) {
+	g.WS("(")
+	g.genArgValue(call, arg)
+	g.WS(")")
+}
+
+// what's passed to the func a call is linked to, without the parens
+func (g *Generator) genArgValue(call Nod, arg Nod) {
 	if arg == nil || arg.NodeType == NT_EMPTYARGLIST {
-		g.WS("()")
+		return
+	} else if params := g.calleeParamList(call); params != nil && arg.NodeType == NT_LIT_LIST &&
+		len(NodGetChildList(arg)) == len(params) {
+		// funcs with several parameters unpack them from args, each as its own type
+		g.WS("[]interface{}{")
+		for ndx, ele := range NodGetChildList(arg) {
+			g.genType(NodGetChild(params[ndx], NTR_TYPE))
+			g.WS("(")
+			g.genValue(ele)
+			g.WS("), ")
+		}
+		g.WS("}")
 	} else {
-		g.WS("(")
 		g.genValue(arg)
-		g.WS(")")
 	}
 }
 
+// the parameters of the func a call is linked to, if it takes more than one
+func (g *Generator) calleeParamList(call Nod) []Nod {
+	fDef := NodGetChildOrNil(call, NTR_FUNCDEF)
+	if fDef == nil {
+		return nil
+	}
+	if inType := NodGetChildOrNil(fDef, NTR_FUNCDEF_INTYPE); inType != nil && inType.NodeType == NT_LIT_LIST {
+		return NodGetChildList(inType)
+	}
+	return nil
+}
+
 func (g *Generator) genListIndexor(n Nod) {
 	args := NodGetChildList(NodGetChild(n, NTR_RECEIVERCALL_ARG))
 	g.genValue(args[0])
@@ -623,6 +890,8 @@ func (g *Generator) genListConcat(n Nod) {
 }
 
 func (g *Generator) genValue(n Nod) {
+	g.within = append(g.within, n)
+	defer func() { g.within = g.within[:len(g.within)-1] }()
 	nt := n.NodeType
 	if nt == NT_LIT_INT {
 		g.genLiteralInt(n)
@@ -630,6 +899,8 @@ func (g *Generator) genValue(n Nod) {
 		g.genLiteralFloat(n)
 	} else if nt == NT_LIT_STRING {
 		g.genLiteralString(n)
+	} else if nt == NT_LIT_FSTRING {
+		g.genLiteralFString(n)
 	} else if nt == NT_LIT_BOOL {
 		g.genLiteralBool(n)
 	} else if nt == NT_VAR_GETTER {
@@ -646,10 +917,26 @@ func (g *Generator) genValue(n Nod) {
 		g.genReceiverCallMethod(n)
 	} else if nt == NT_COLLECTION_INDEXOR {
 		g.genCollectionIndexor(n)
+	} else if nt == PNT_LIST_INDEXOR {
+		g.genListIndexorChecked(n)
+	} else if nt == PNT_LIST_SLICE {
+		g.genListSlice(n)
+	} else if nt == PNT_STRING_INDEXOR {
+		g.genStringIndexor(n)
+	} else if nt == PNT_STRING_SLICE {
+		g.genStringSlice(n)
+	} else if nt == NT_RANGEOP || nt == NT_RANGEEXCLOP {
+		g.genRange(n)
+	} else if nt == PNT_NUMERIC_CONVERT {
+		g.genNumericConvert(n)
+	} else if nt == PNT_BIGINT_BINOP {
+		g.genBigintBinop(n)
 	} else if nt == NT_OBJINIT {
 		g.genObjInitDefault(n)
 	} else if nt == PNT_WRAP_OBJ_INIT {
 		g.genObjInitClassWrapper(n)
+	} else if nt == PNT_CONSTRUCT {
+		g.genConstruct(n)
 	} else if nt == NT_DOTOP {
 		panic("desyntax error")
 	} else if nt == NT_OBJFIELD_ACCESSOR {
@@ -668,12 +955,16 @@ func (g *Generator) genValue(n Nod) {
 		g.genDuckMethodCall(n)
 	} else if n.NodeType == NT_REFERENCEOP {
 		g.genReferenceOp(n)
+	} else if n.NodeType == NT_NEGOP || n.NodeType == NT_POSOP || n.NodeType == NT_NOTOP {
+		g.genUnaryOp(n)
 	} else if n.NodeType == NT_FUNCDEF {
 		g.genValueFuncDef(n)
 	} else if n.NodeType == NT_CLASSDEF {
 		g.genValueClassDef(n)
+	} else if n.NodeType == PNT_ASSERT_OPERAND {
+		g.WS(g.assertOperands[n.Data.(int)])
 	} else {
-		g.WS("value")
+		g.internalError(n, "a value")
 	}
 }
 
@@ -700,6 +991,38 @@ func (g *Generator) genReferenceOp(n Nod) {
 	g.genLValue(NodGetChild(n, NTR_RECEIVERCALL_ARG), nil)
 }
 
+func (g *Generator) genUnaryOp(n Nod) {
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	argType := NodGetChildOrNil(arg, NTR_TYPE)
+	isDuck := argType == nil || argType.NodeType != NT_TYPEBASE
+	if isDuck || argType.Data.(int) == TY_BIGINT {
+		lut := map[int]string{
+			NT_NEGOP: "neg",
+			NT_POSOP: "pos",
+			NT_NOTOP: "not",
+		}
+		if isDuck {
+			g.WS("P__duck_")
+		} else {
+			g.WS("P__bigint_")
+		}
+		g.WS(lut[n.NodeType])
+		g.WS("(")
+		g.genValue(arg)
+		g.WS(")")
+		return
+	}
+	lut := map[int]string{
+		NT_NEGOP: "-",
+		NT_POSOP: "+",
+		NT_NOTOP: "!",
+	}
+	g.WS("(")
+	g.WS(lut[n.NodeType])
+	g.genValue(arg)
+	g.WS(")")
+}
+
 func (g *Generator) genObjFieldAccessor(n Nod) {
 	obj := NodGetChild(n, NTR_RECEIVERCALL_BASE)
 	fieldName := NodGetChild(n, NTR_OBJFIELD_ACCESSOR_NAME).Data.(string)
@@ -709,6 +1032,15 @@ func (g *Generator) genObjFieldAccessor(n Nod) {
 }
 
 func (g *Generator) genPseudoCollectionLen(n Nod) {
+	base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+	if baseType := NodGetChildOrNil(base, NTR_TYPE); baseType != nil &&
+		baseType.NodeType == NT_TYPEBASE && baseType.Data.(int) == TY_STRING {
+		// strings are measured in runes, not bytes
+		g.WS("P__str_len(")
+		g.genValue(base)
+		g.WS(")")
+		return
+	}
 	g.WS("len(")
 	g.genValue(NodGetChild(n, NTR_RECEIVERCALL_BASE))
 	g.WS(")")
@@ -737,6 +1069,78 @@ func (g *Generator) genCollectionIndexor(n Nod) {
 	g.WS("]")
 }
 
+func (g *Generator) genListIndexorChecked(n Nod) {
+	base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+
+	g.genValue(base)
+	g.WS("[P__index(len(")
+	g.genValue(base)
+	g.WS("), ")
+	g.genValue(arg)
+	g.WS(", ")
+	g.genLiteralStringRaw(n.Loc.StringDebug())
+	g.WS(")]")
+}
+
+func (g *Generator) genListSlice(n Nod) {
+	base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+
+	g.WS("P__slice(")
+	g.genValue(base)
+	g.WS(", ")
+	g.genRangeArgs(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	g.WS(", ")
+	g.genLiteralStringRaw(n.Loc.StringDebug())
+	g.WS(").(")
+	g.genType(NodGetChild(base, NTR_TYPE))
+	g.WS(")")
+}
+
+func (g *Generator) genStringIndexor(n Nod) {
+	g.WS("P__str_index(")
+	g.genValue(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	g.WS(", ")
+	g.genValue(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	g.WS(", ")
+	g.genLiteralStringRaw(n.Loc.StringDebug())
+	g.WS(")")
+}
+
+func (g *Generator) genStringSlice(n Nod) {
+	g.WS("P__str_slice(")
+	g.genValue(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	g.WS(", ")
+	g.genRangeArgs(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	g.WS(", ")
+	g.genLiteralStringRaw(n.Loc.StringDebug())
+	g.WS(")")
+}
+
+func (g *Generator) genRange(n Nod) {
+	g.WS("P__range(")
+	g.genRangeArgs(n)
+	g.WS(")")
+}
+
+func (g *Generator) genRangeArgs(n Nod) {
+	// <start>, <end>, <step>, <inclusive>
+	g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
+	g.WS(", ")
+	g.genValue(NodGetChild(n, NTR_BINOP_RIGHT))
+	g.WS(", ")
+	if step := NodGetChildOrNil(n, NTR_RANGE_STEP); step != nil {
+		g.genValue(step)
+	} else {
+		g.WS("1")
+	}
+	if n.NodeType == NT_RANGEEXCLOP {
+		g.WS(", false")
+	} else {
+		g.WS(", true")
+	}
+}
+
 func (g *Generator) genObjInitDefault(n Nod) {
 	clsDef := NodGetChild(n, NTR_RECEIVERCALL_BASE)
 	clsName := NodGetChild(clsDef, NTR_CLASSDEF_NAME).Data.(string)
@@ -744,6 +1148,20 @@ func (g *Generator) genObjInitDefault(n Nod) {
 	g.WS(rv)
 }
 
+func (g *Generator) genConstruct(n Nod) {
+	clsDef := NodGetChild(n, NTR_CLASSDEF)
+	ctor := NodGetChild(n, NTR_FUNCDEF)
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	g.WS(g.getConstructorName(NodGetChild(clsDef, NTR_CLASSDEF_NAME).Data.(string), ctor))
+	g.WS("(")
+	g.genValue(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	if arg.NodeType != NT_EMPTYARGLIST {
+		g.WS(", ")
+		g.genArgValue(n, arg)
+	}
+	g.WS(")")
+}
+
 type ObjInitGenerator struct {
 	*Generator
 	wrappedValue Nod
@@ -835,7 +1253,7 @@ func (g *ObjInitGenerator) genOrderedArgsWithValues(values []Nod) {
 		}
 	}
 
-	fmt.Println("isConfig?", g.isConfig, "clsFieldsFound", PrettyPrintNodes(clsFieldsOrdered))
+	Tracef(LOGCH_GEN, "isConfig? %v clsFieldsFound %s", g.isConfig, LazyTrees(clsFieldsOrdered))
 
 	fieldNames := []string{}
 	for ndx := range values {
@@ -883,12 +1301,125 @@ func (g *Generator) isBinaryInlineDuckOpType(nt int) bool {
 }
 
 func (g *Generator) genLiteralInt(n Nod) {
+	if ty := NodGetChildOrNil(n, NTR_TYPE); ty != nil && ty.NodeType == NT_TYPEBASE && ty.Data.(int) == TY_BIGINT {
+		g.usesBig = true
+		g.WS("big.NewInt(")
+		g.WS(strconv.Itoa(n.Data.(int)))
+		g.WS(")")
+		return
+	}
+	if n.Data.(int) < 0 {
+		// so that e.g. 3 - -2 doesn't become 3--2
+		g.WS("(" + strconv.Itoa(n.Data.(int)) + ")")
+		return
+	}
 	// g.WS("int64(")
 	g.WS(strconv.Itoa(n.Data.(int)))
 	// g.WS(")")
 }
 
+func (g *Generator) genNumericConvert(n Nod) {
+	toType := n.Data.(int)
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	fromType := 0
+	if ty := NodGetChildOrNil(arg, NTR_TYPE); ty != nil && ty.NodeType == NT_TYPEBASE {
+		fromType = ty.Data.(int)
+	}
+	// only looked up where it's written, since a bigint type means importing math/big
+	toTypeBase := NodNewData(NT_TYPEBASE, toType)
+
+	if fromType == toType {
+		g.genValue(arg)
+	} else if folded, ok := foldNumericLiteralConvert(arg, toType); ok {
+		// go rejects lossy conversions of constants, so do them here
+		g.WS(g.getGenTypeBase(toTypeBase))
+		g.WS("(")
+		g.WS(folded)
+		g.WS(")")
+	} else if toType == TY_BIGINT {
+		// handles strings too, for literals that don't fit in an int
+		g.WS("P__to_bigint(")
+		g.genValue(arg)
+		g.WS(")")
+	} else if fromType == TY_BIGINT {
+		g.WS(g.getGenTypeBase(toTypeBase))
+		if IsFloatType(toType) {
+			g.WS("(P__bigint_to_float(")
+			g.genValue(arg)
+			g.WS("))")
+		} else {
+			g.WS("((")
+			g.genValue(arg)
+			g.WS(").Int64())")
+		}
+	} else {
+		g.WS(g.getGenTypeBase(toTypeBase))
+		g.WS("(")
+		g.genValue(arg)
+		g.WS(")")
+	}
+}
+
+func foldNumericLiteralConvert(arg Nod, toType int) (string, bool) {
+	if !IsIntegerType(toType) || toType == TY_BIGINT {
+		return "", false
+	}
+	var v int64
+	if arg.NodeType == NT_LIT_INT {
+		v = int64(arg.Data.(int))
+	} else if arg.NodeType == NT_LIT_FLOAT {
+		v = int64(arg.Data.(float64))
+	} else {
+		return "", false
+	}
+	switch toType {
+	case TY_I8:
+		v = int64(int8(v))
+	case TY_I16:
+		v = int64(int16(v))
+	case TY_I32:
+		v = int64(int32(v))
+	case TY_U8:
+		v = int64(uint8(v))
+	case TY_U16:
+		v = int64(uint16(v))
+	case TY_U32:
+		v = int64(uint32(v))
+	case TY_U64:
+		return strconv.FormatUint(uint64(v), 10), true
+	}
+	return strconv.FormatInt(v, 10), true
+}
+
+func (g *Generator) genBigintBinop(n Nod) {
+	// *big.Int has no operators, so ops go through the runtime
+	op := n.Data.(int)
+	isComparison := op == NT_GTOP || op == NT_LTOP || op == NT_GTEQOP ||
+		op == NT_LTEQOP || op == NT_EQOP
+	if isComparison {
+		g.WS("(P__bigint_cmp(")
+	} else {
+		g.WS("P__bigint_arith(")
+	}
+	g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
+	g.WS(", ")
+	g.genValue(NodGetChild(n, NTR_BINOP_RIGHT))
+	if isComparison {
+		g.WS(") ")
+		g.WS(g.getBinaryInlineOpSymbol(op))
+		g.WS(" 0)")
+	} else {
+		g.WS(", ")
+		g.genLiteralStringRaw(g.getIntegerBinaryOpSymbol(op))
+		g.WS(")")
+	}
+}
+
 func (g *Generator) genLiteralFloat(n Nod) {
+	if n.Data.(float64) < 0 {
+		g.WS("(" + strconv.FormatFloat(n.Data.(float64), 'g', -1, 64) + ")")
+		return
+	}
 	g.WS(strconv.FormatFloat(n.Data.(float64), 'g', -1, 64))
 }
 
@@ -906,16 +1437,36 @@ func (g *Generator) genLiteralString(n Nod) {
 }
 
 func (g *Generator) genLiteralStringRaw(s string) {
-	g.WS("\"")
-	g.WS(s)
-	g.WS("\"")
+	g.WS(strconv.Quote(s))
+}
+
+func (g *Generator) genLiteralFString(n Nod) {
+	// f'a {x} b' -> fmt.Sprintf("a %v b", x)
+	format := ""
+	args := []Nod{}
+	for _, piece := range NodGetChildList(n) {
+		if piece.NodeType == NT_LIT_STRING {
+			format += strings.Replace(piece.Data.(string), "%", "%%", -1)
+		} else {
+			format += "%v"
+			args = append(args, piece)
+		}
+	}
+	g.WS("fmt.Sprintf(")
+	g.genLiteralStringRaw(format)
+	for _, arg := range args {
+		g.WS(", ")
+		g.genValue(arg)
+	}
+	g.WS(")")
 }
 
 func isBinaryInlineOpType(nType int) bool {
 	return nType == NT_ADDOP || nType == NT_GTOP || nType == NT_LTOP ||
 		nType == NT_GTEQOP || nType == NT_LTEQOP || nType == NT_EQOP ||
 		nType == NT_SUBOP || nType == NT_MULOP || nType == NT_DIVOP ||
-		nType == NT_OROP || nType == NT_ANDOP || nType == NT_MODOP
+		nType == NT_OROP || nType == NT_ANDOP || nType == NT_MODOP ||
+		nType == NT_POWOP || nType == NT_XOROP || nType == NT_SHLOP || nType == NT_SHROP
 
 }
 
@@ -933,11 +1484,46 @@ func (g *Generator) getBinaryInlineOpSymbol(nType int) string {
 		NT_OROP:   "||",
 		NT_ANDOP:  "&&",
 		NT_MODOP:  "%",
+		NT_POWOP:  "**",
+		NT_XOROP:  "^",
+		NT_SHLOP:  "<<",
+		NT_SHROP:  ">>",
 	}
 	return lut[nType]
 }
 
+func (g *Generator) getIntegerBinaryOpSymbol(nType int) string {
+	// & and | are bitwise on ints
+	if nType == NT_OROP {
+		return "|"
+	} else if nType == NT_ANDOP {
+		return "&"
+	}
+	return g.getBinaryInlineOpSymbol(nType)
+}
+
 func (g *Generator) genBinaryInlineOp(n Nod) {
+	isInteger := false
+	if ty := NodGetChildOrNil(n, NTR_TYPE); ty != nil && ty.NodeType == NT_TYPEBASE {
+		isInteger = IsIntegerType(ty.Data.(int))
+	}
+	if n.NodeType == NT_POWOP {
+		// go has no power operator
+		g.WS("P__ipow(")
+		g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
+		g.WS(", ")
+		g.genValue(NodGetChild(n, NTR_BINOP_RIGHT))
+		g.WS(")")
+		return
+	}
+	if isInteger {
+		g.WS("(")
+		g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
+		g.WS(g.getIntegerBinaryOpSymbol(n.NodeType))
+		g.genValue(NodGetChild(n, NTR_BINOP_RIGHT))
+		g.WS(")")
+		return
+	}
 	g.WS("(")
 	g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
 	g.WS(g.getBinaryInlineOpSymbol(n.NodeType))
@@ -945,7 +1531,7 @@ func (g *Generator) genBinaryInlineOp(n Nod) {
 	g.WS(")")
 }
 
-func (g *Generator) getGenDuckOpName(nt int) string {
+func (g *Generator) getGenDuckOpName(n Nod) string {
 	lut := map[int]string{
 		NT_ADDOP:  "add",
 		NT_SUBOP:  "sub",
@@ -957,20 +1543,24 @@ func (g *Generator) getGenDuckOpName(nt int) string {
 		NT_LTOP:   "lt",
 		NT_LTEQOP: "lteq",
 		NT_EQOP:   "defeq",
+		NT_POWOP:  "pow",
+		NT_XOROP:  "xor",
+		NT_SHLOP:  "shl",
+		NT_SHROP:  "shr",
 	}
-	if val, ok := lut[nt]; ok {
+	if val, ok := lut[n.Data.(int)]; ok {
 		return val
-	} else {
-		return "someop(nt " + strconv.Itoa(nt) + ")"
 	}
+	g.internalError(n, "a duck typed op")
+	return ""
 }
 
-func (g *Generator) getGenFullDuckOpName(nt int) string {
-	return "P__duck_" + g.getGenDuckOpName(nt)
+func (g *Generator) getGenFullDuckOpName(n Nod) string {
+	return "P__duck_" + g.getGenDuckOpName(n)
 }
 
 func (g *Generator) genDuckOp(n Nod) {
-	g.WS(g.getGenFullDuckOpName(n.Data.(int)))
+	g.WS(g.getGenFullDuckOpName(n))
 	g.WS("(")
 	g.genValue(NodGetChild(n, NTR_BINOP_LEFT))
 	g.WS(",")
@@ -0,0 +1,76 @@
+package goback
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"sync"
+)
+
+// The generated program is parsed and type checked along with the runtime
+// lib before it's built, so a generator bug shows up as an internal compiler
+// error pointing into the generated source rather than as a confusing build
+// failure later on. The standard packages it imports are loaded once and
+// shared between checks.
+
+type sharedImporter struct {
+	mu  sync.Mutex
+	imp types.Importer
+}
+
+func (s *sharedImporter) Import(path string) (*types.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.imp.Import(path)
+}
+
+var stdImporter = &sharedImporter{imp: importer.Default()}
+
+// checks that src is a go program that type checks, and formats it
+func checkAndFormat(src string) string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "out.go", src, parser.ParseComments)
+	if err != nil {
+		panic(generatedCodeError(src, []string{err.Error()}))
+	}
+	lib, err := parser.ParseFile(fset, "lib.go", runtimeLib(), 0)
+	if err != nil {
+		panic(err)
+	}
+
+	errs := []string{}
+	conf := types.Config{
+		Importer: stdImporter,
+		Error:    func(err error) { errs = append(errs, err.Error()) },
+	}
+	conf.Check("main", fset, []*ast.File{file, lib}, nil)
+	if len(errs) > 0 {
+		panic(generatedCodeError(src, errs))
+	}
+
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, fset, file); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+// the errors found in the generated source, each with the line it's about
+func generatedCodeError(src string, errs []string) string {
+	lines := strings.Split(src, "\n")
+	msgs := []string{}
+	for _, e := range errs {
+		var line, col int
+		if _, err := fmt.Sscanf(e, "out.go:%d:%d:", &line, &col); err == nil && line >= 1 && line <= len(lines) {
+			e += "\n\t" + strings.TrimSpace(lines[line-1])
+		}
+		msgs = append(msgs, e)
+	}
+	return "internal compiler error: the generated go doesn't check:\n" + strings.Join(msgs, "\n")
+}
@@ -4,6 +4,9 @@ import (
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 	"pocket-lang/xform"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 const (
@@ -17,6 +20,13 @@ const (
 	PNT_DUCK_METHOD_CALL
 
 	PNT_WRAP_OBJ_INIT
+	// calls the constructor in NTR_FUNCDEF on the new object in
+	// NTR_RECEIVERCALL_BASE with NTR_RECEIVERCALL_ARG, then gives the object.
+	// The object's class is in NTR_CLASSDEF
+	PNT_CONSTRUCT
+	// flags a method as a constructor, which its name no longer shows once
+	// overloads are mangled
+	PNTR_CONSTRUCTOR
 
 	//// structure inherits from OBJFIELDACCESS
 	PNT_PSEUD_COLLECTION_LEN
@@ -25,23 +35,309 @@ const (
 	// inherits structure from NT_BINOP
 	PNT_PSEUD_LIST_CONCAT
 
+	//// structure inherits from NT_COLLECTION_INDEXOR
+	PNT_LIST_INDEXOR
+	PNT_LIST_SLICE
+	PNT_STRING_INDEXOR
+	PNT_STRING_SLICE
+	//// end
+
 	// duck annotation flags
 	PNTR_TYPE_INDEXABLE
+
+	// list of go packages to import, under PNTR_GOIMPORTS
+	PNT_GOIMPORTS
+
+	// numeric conversion of NTR_RECEIVERCALL_ARG into the type in .Data
+	PNT_NUMERIC_CONVERT
+	// inherits structure from NT_BINOP, the original op type is in .Data
+	PNT_BIGINT_BINOP
+
+	// the sides of an asserted comparison, evaluated before it so that a failure
+	// can show them. The comparison reads them back through PNT_ASSERT_OPERANDs,
+	// with .Data 0 for the left and 1 for the right
+	PNTR_ASSERT_LEFT
+	PNTR_ASSERT_RIGHT
+	PNT_ASSERT_OPERAND
 )
 
+// the names of the node types above, see NodeTypeNames in common
+var PreparedNodeTypeNames = map[int]string{
+	PNTR_GOIMPORTS:             "PNTR_GOIMPORTS",
+	PNT_DUCK_BINOP:             "PNT_DUCK_BINOP",
+	PNT_DUCK_FIELD_READ:        "PNT_DUCK_FIELD_READ",
+	PNT_DUCK_FIELD_WRITE:       "PNT_DUCK_FIELD_WRITE",
+	PNTR_DUCK_FIELD_WRITE_OBJ:  "PNTR_DUCK_FIELD_WRITE_OBJ",
+	PNTR_DUCK_FIELD_WRITE_NAME: "PNTR_DUCK_FIELD_WRITE_NAME",
+	PNTR_DUCK_FIELD_WRITE_VAL:  "PNTR_DUCK_FIELD_WRITE_VAL",
+	PNT_DUCK_METHOD_CALL:       "PNT_DUCK_METHOD_CALL",
+	PNT_WRAP_OBJ_INIT:          "PNT_WRAP_OBJ_INIT",
+	PNT_CONSTRUCT:              "PNT_CONSTRUCT",
+	PNTR_CONSTRUCTOR:           "PNTR_CONSTRUCTOR",
+	PNT_PSEUD_COLLECTION_LEN:   "PNT_PSEUD_COLLECTION_LEN",
+	PNT_PSEUD_LIST_CONCAT:      "PNT_PSEUD_LIST_CONCAT",
+	PNT_LIST_INDEXOR:           "PNT_LIST_INDEXOR",
+	PNT_LIST_SLICE:             "PNT_LIST_SLICE",
+	PNT_STRING_INDEXOR:         "PNT_STRING_INDEXOR",
+	PNT_STRING_SLICE:           "PNT_STRING_SLICE",
+	PNTR_TYPE_INDEXABLE:        "PNTR_TYPE_INDEXABLE",
+	PNT_GOIMPORTS:              "PNT_GOIMPORTS",
+	PNT_NUMERIC_CONVERT:        "PNT_NUMERIC_CONVERT",
+	PNT_BIGINT_BINOP:           "PNT_BIGINT_BINOP",
+	PNTR_ASSERT_LEFT:           "PNTR_ASSERT_LEFT",
+	PNTR_ASSERT_RIGHT:          "PNTR_ASSERT_RIGHT",
+	PNT_ASSERT_OPERAND:         "PNT_ASSERT_OPERAND",
+}
+
 type Preparer struct {
 	*xform.Xformer
 }
 
 func (p *Preparer) Prepare(code Nod) {
 	p.Root = code
+	p.markConstructors()
+	p.lowerOperatorMethods()
+	p.mangleOverloads()
 	p.checkForPrintStatements()
+	p.checkForInterpolatedStrings()
 	p.createExplicitIndexors()
 	p.rewritePseudoFields()
 	p.createListConcats()
 	p.rewriteDuckedOps()
+	p.rewriteConversionCalls()
+	p.rewriteNumericOps()
 	p.serializeKeywordArgs()
 	p.createObjInitWrappers()
+	p.splitAssertedComparisons()
+}
+
+func (p *Preparer) markConstructors() {
+	classes := p.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_CLASSDEF || n.NodeType == NT_CLASSDEFPARTIAL
+	})
+	for _, cls := range classes {
+		for _, unit := range NodGetChildList(cls) {
+			if unit.NodeType == NT_FUNCDEF && IsConstructorName(NodGetChild(unit, NTR_FUNCDEF_NAME).Data.(string)) {
+				NodSetChild(unit, PNTR_CONSTRUCTOR, NodNew(NT_EMPTYARGLIST))
+			}
+		}
+	}
+}
+
+// the constructor a static zone runs when it's set up, or nil
+func StaticConstructorOrNil(staticZone Nod) Nod {
+	for _, unit := range NodGetChildList(staticZone) {
+		if unit.NodeType == NT_FUNCDEF && NodHasChild(unit, PNTR_CONSTRUCTOR) {
+			return unit
+		}
+	}
+	return nil
+}
+
+func (p *Preparer) lowerOperatorMethods() {
+	// ops, indexors and .len the solver linked to a class's operator methods
+	// become calls to them, e.g. v + w -> v.add(w)
+	linked := p.SearchRoot(func(n Nod) bool {
+		if !NodHasChild(n, NTR_FUNCDEF) {
+			return false
+		}
+		_, isOp := OperatorMethodNames[n.NodeType]
+		return isOp || n.NodeType == NT_OBJFIELD_ACCESSOR || isIndexMethodCall(n)
+	})
+	for _, n := range linked {
+		if name, isOp := OperatorMethodNames[n.NodeType]; isOp {
+			left := NodGetChild(n, NTR_BINOP_LEFT)
+			right := NodGetChild(n, NTR_BINOP_RIGHT)
+			NodRemoveChild(n, NTR_BINOP_LEFT)
+			NodRemoveChild(n, NTR_BINOP_RIGHT)
+			NodSetChild(n, NTR_RECEIVERCALL_BASE, left)
+			NodSetChild(n, NTR_RECEIVERCALL_ARG, right)
+			NodSetChild(n, NTR_RECEIVERCALL_METHOD_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, name))
+		} else if n.NodeType == NT_OBJFIELD_ACCESSOR {
+			NodRemoveChild(n, NTR_OBJFIELD_ACCESSOR_NAME)
+			NodSetChild(n, NTR_RECEIVERCALL_ARG, NodNew(NT_EMPTYARGLIST))
+			NodSetChild(n, NTR_RECEIVERCALL_METHOD_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, LEN_METHOD_NAME))
+		} else {
+			NodSetChild(n, NTR_RECEIVERCALL_METHOD_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, INDEX_METHOD_NAME))
+		}
+		n.NodeType = NT_RECEIVERCALL_METHOD
+	}
+
+	// objects with a str method are printed, and interpolated, as what it returns
+	printed := []Nod{}
+	for _, printCall := range p.SearchRoot(isPrintCall) {
+		if arg := NodGetChildOrNil(printCall, NTR_RECEIVERCALL_ARG); arg != nil {
+			printed = append(printed, arg)
+		}
+	}
+	for _, fString := range p.SearchRoot(func(n Nod) bool { return n.NodeType == NT_LIT_FSTRING }) {
+		printed = append(printed, NodGetChildList(fString)...)
+	}
+	for _, val := range printed {
+		if strDef := strMethodOrNil(NodGetChildOrNil(val, NTR_TYPE)); strDef != nil {
+			call := NodNew(NT_RECEIVERCALL_METHOD)
+			p.Replace(val, call)
+			NodSetChild(call, NTR_RECEIVERCALL_BASE, val)
+			NodSetChild(call, NTR_RECEIVERCALL_ARG, NodNew(NT_EMPTYARGLIST))
+			NodSetChild(call, NTR_RECEIVERCALL_METHOD_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, STR_METHOD_NAME))
+			NodSetChild(call, NTR_FUNCDEF, strDef)
+			NodSetChild(call, NTR_TYPE, NodNewData(NT_TYPEBASE, TY_STRING))
+		}
+	}
+}
+
+// obj[i] linked to obj's index method, where a call to a func named index
+// has the func's name as its base
+func isIndexMethodCall(n Nod) bool {
+	return n.NodeType == NT_RECEIVERCALL &&
+		NodGetChild(n, NTR_RECEIVERCALL_BASE).NodeType != NT_IDENTIFIER_RESOLVED &&
+		NodGetChild(NodGetChild(n, NTR_FUNCDEF), NTR_FUNCDEF_NAME).Data.(string) == INDEX_METHOD_NAME
+}
+
+// the str method of an object's class that takes nothing, if there is one
+func strMethodOrNil(ty Nod) Nod {
+	if ty == nil || ty.NodeType != NT_CLASSDEF {
+		return nil
+	}
+	for _, mDef := range NodGetChildList(NodGetChild(ty, NTR_FUNCTABLE)) {
+		if NodGetChild(mDef, NTR_FUNCDEF_NAME).Data.(string) == STR_METHOD_NAME && len(FuncDefParams(mDef)) == 0 {
+			return mDef
+		}
+	}
+	return nil
+}
+
+func (p *Preparer) mangleOverloads() {
+	// go has no overloading, so funcs that share a name get the types they
+	// take added to it, e.g. add_int_int, along with the calls linked to them
+	renamed := map[Nod]string{}
+	for _, fTable := range p.SearchRoot(func(n Nod) bool { return n.NodeType == NT_FUNCTABLE }) {
+		byName := map[string][]Nod{}
+		names := []string{} // in order, so the renaming is the same every time
+		for _, fDef := range NodGetChildList(fTable) {
+			name := NodGetChild(fDef, NTR_FUNCDEF_NAME).Data.(string)
+			if byName[name] == nil {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], fDef)
+		}
+		// a mangled name can come out the same as a func's own, e.g. a user's
+		// add_int_int, or as another's, as f(a list(int)) and f(a list, b int)
+		// are both f_list_int, so taken ones get a number added
+		taken := map[string]bool{}
+		for _, name := range names {
+			taken[name] = true
+		}
+		for _, name := range names {
+			if len(byName[name]) < 2 {
+				continue
+			}
+			for _, fDef := range byName[name] {
+				mangled := name + "_" + overloadSuffix(fDef)
+				for n := 2; taken[mangled]; n++ {
+					mangled = name + "_" + overloadSuffix(fDef) + "_" + strconv.Itoa(n)
+				}
+				taken[mangled] = true
+				renamed[fDef] = mangled
+			}
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+
+	calls := p.SearchRoot(func(n Nod) bool {
+		return NodHasChild(n, NTR_FUNCDEF) && renamed[NodGetChild(n, NTR_FUNCDEF)] != ""
+	})
+	for _, call := range calls {
+		name := renamed[NodGetChild(call, NTR_FUNCDEF)]
+		if call.NodeType == NT_RECEIVERCALL_METHOD {
+			NodGetChild(call, NTR_RECEIVERCALL_METHOD_NAME).Data = name
+		} else if call.NodeType == NT_OBJINIT {
+			// the class is its base, and the constructor goes by its funcdef
+			continue
+		} else if base := NodGetChildOrNil(call, NTR_RECEIVERCALL_BASE); base != nil {
+			base.Data = name
+		}
+	}
+	for fDef, name := range renamed {
+		NodGetChild(fDef, NTR_FUNCDEF_NAME).Data = name
+	}
+}
+
+// the types a func takes, as part of a go identifier
+func overloadSuffix(fDef Nod) string {
+	params := FuncDefParams(fDef)
+	if len(params) == 0 {
+		return "void"
+	}
+	names := []string{}
+	for _, param := range params {
+		ty := NodGetChildOrNil(NodGetChild(param, NTR_VARDEF), NTR_TYPE)
+		if ty == nil {
+			names = append(names, "any")
+			continue
+		}
+		name := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, DypeString(ty))
+		names = append(names, strings.Trim(name, "_"))
+	}
+	return strings.Join(names, "_")
+}
+
+func (p *Preparer) splitAssertedComparisons() {
+	// goes last, so the comparisons are already rewritten for their operand types
+	asserts := p.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_ASSERT && isComparison(NodGetChild(n, NTR_ASSERT_COND))
+	})
+	for _, assert := range asserts {
+		cond := NodGetChild(assert, NTR_ASSERT_COND)
+		left := NodGetChild(cond, NTR_BINOP_LEFT)
+		right := NodGetChild(cond, NTR_BINOP_RIGHT)
+		NodRemoveChild(cond, NTR_BINOP_LEFT)
+		NodRemoveChild(cond, NTR_BINOP_RIGHT)
+		NodSetChild(cond, NTR_BINOP_LEFT, NodNewData(PNT_ASSERT_OPERAND, 0))
+		NodSetChild(cond, NTR_BINOP_RIGHT, NodNewData(PNT_ASSERT_OPERAND, 1))
+		NodSetChild(assert, PNTR_ASSERT_LEFT, left)
+		NodSetChild(assert, PNTR_ASSERT_RIGHT, right)
+	}
+}
+
+func isComparisonOpType(nt int) bool {
+	return nt == NT_GTOP || nt == NT_LTOP || nt == NT_GTEQOP || nt == NT_LTEQOP || nt == NT_EQOP
+}
+
+// comparisons keep their op in .Data once they're rewritten for bigints or ducks
+func isComparison(n Nod) bool {
+	return isComparisonOpType(comparisonOpType(n))
+}
+
+func comparisonOpType(n Nod) int {
+	if n.NodeType == PNT_BIGINT_BINOP || n.NodeType == PNT_DUCK_BINOP {
+		return n.Data.(int)
+	}
+	return n.NodeType
+}
+
+var comparisonSpellings = map[int]string{
+	NT_GTOP:   ">",
+	NT_LTOP:   "<",
+	NT_GTEQOP: ">=",
+	NT_LTEQOP: "<=",
+	NT_EQOP:   "=",
+}
+
+// the comparison a prepared assert makes, as it's written in pocket, or "" if
+// it isn't a comparison
+func AssertedComparison(assert Nod) string {
+	if !NodHasChild(assert, PNTR_ASSERT_LEFT) {
+		return ""
+	}
+	return comparisonSpellings[comparisonOpType(NodGetChild(assert, NTR_ASSERT_COND))]
 }
 
 func (p *Preparer) createListConcats() {
@@ -107,7 +403,14 @@ func (p *Preparer) createObjInitWrapped(objInit Nod) Nod {
 		NodRemoveChild(objInit, NTR_RECEIVERCALL_CFG_ARG)
 	}
 	initArg := NodGetChildOrNil(objInit, NTR_RECEIVERCALL_ARG)
-	if initArg != nil {
+	if ctor := NodGetChildOrNil(objInit, NTR_FUNCDEF); ctor != nil {
+		// the constructor runs last, after the defaults and config args
+		rv = p.wrapConstruct(rv, clsDef, ctor, initArg)
+		NodRemoveChild(objInit, NTR_FUNCDEF)
+		if initArg != nil {
+			NodRemoveChild(objInit, NTR_RECEIVERCALL_ARG)
+		}
+	} else if initArg != nil {
 		// optimization: don't wrap if empty arg list
 		if initArg.NodeType != NT_EMPTYARGLIST {
 			rv = p.wrapObjInit(rv, clsDef, initArg, false)
@@ -127,6 +430,39 @@ func (p *Preparer) wrapObjInit(objInit Nod, clsDef Nod, arg Nod, isConfig bool)
 	return nn
 }
 
+func (p *Preparer) wrapConstruct(objInit Nod, clsDef Nod, ctor Nod, arg Nod) Nod {
+	nn := NodNew(PNT_CONSTRUCT)
+	NodSetChild(nn, NTR_RECEIVERCALL_BASE, objInit)
+	NodSetChild(nn, NTR_RECEIVERCALL_ARG, constructorArg(arg, FuncDefParams(ctor)))
+	NodSetChild(nn, NTR_FUNCDEF, ctor)
+	NodSetChild(nn, NTR_CLASSDEF, clsDef)
+	return nn
+}
+
+// a constructor's args as a plain call's, with keyword args put in the order
+// of its params
+func constructorArg(arg Nod, params []Nod) Nod {
+	if arg == nil {
+		return NodNew(NT_EMPTYARGLIST)
+	} else if arg.NodeType != NT_KWARGS {
+		return arg
+	}
+	byName := map[string]Nod{}
+	for _, kwarg := range NodGetChildList(arg) {
+		byName[NodGetChild(kwarg, NTR_VAR_NAME).Data.(string)] = NodGetChild(kwarg, NTR_VARASSIGN_VALUE)
+	}
+	values := []Nod{}
+	for _, param := range params {
+		values = append(values, byName[NodGetChild(param, NTR_VARDEF_NAME).Data.(string)])
+	}
+	if len(values) == 0 {
+		return NodNew(NT_EMPTYARGLIST)
+	} else if len(values) == 1 {
+		return values[0]
+	}
+	return NodNewChildList(NT_LIT_LIST, values)
+}
+
 func (p *Preparer) serializeKeywordArgs() {
 	kwargCalls := p.SearchRoot(func(n Nod) bool {
 		if isReceiverCallType(n.NodeType) {
@@ -155,39 +491,158 @@ func (p *Preparer) serializeKeywordArgs() {
 	}
 }
 
+func isPrintCall(n Nod) bool {
+	if isReceiverCallType(n.NodeType) {
+		base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+		if base.NodeType == NT_IDENTIFIER || base.NodeType == NT_IDENTIFIER_RESOLVED ||
+			base.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
+			if base.Data.(string) == "print" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (p *Preparer) checkForPrintStatements() {
-	printCalls := p.SearchRoot(func(n Nod) bool {
+	printCalls := p.SearchRoot(isPrintCall)
+
+	for _, printCall := range printCalls {
+		NodGetChild(printCall, NTR_RECEIVERCALL_BASE).Data = "fmt.Println"
+	}
+
+	if len(printCalls) > 0 {
+		p.addGoImport("fmt")
+	}
+}
+
+func (p *Preparer) addGoImport(pkg string) {
+	imports := NodGetChildOrNil(p.Root, PNTR_GOIMPORTS)
+	if imports == nil {
+		imports = NodNew(PNT_GOIMPORTS)
+		NodSetChild(p.Root, PNTR_GOIMPORTS, imports)
+	}
+	for _, imp := range NodGetChildList(imports) {
+		if imp.Data.(string) == pkg {
+			return
+		}
+	}
+	NodSetOutList(imports, append(NodGetChildList(imports), NodNewData(NT_IDENTIFIER, pkg)))
+}
+
+func (p *Preparer) getNumericTypeOrZero(n Nod) int {
+	// returns the numeric TY_ of a value, or 0 if it isn't plainly numeric
+	if ty := NodGetChildOrNil(n, NTR_TYPE); ty != nil && ty.NodeType == NT_TYPEBASE {
+		if IsNumericType(ty.Data.(int)) {
+			return ty.Data.(int)
+		}
+	}
+	return 0
+}
+
+func (p *Preparer) newNumericConvert(val Nod, toType int) Nod {
+	rv := NodNew(PNT_NUMERIC_CONVERT)
+	rv.Data = toType
+	NodSetChild(rv, NTR_RECEIVERCALL_ARG, val)
+	NodSetChild(rv, NTR_TYPE, NodNewData(NT_TYPEBASE, toType))
+	return rv
+}
+
+func (p *Preparer) rewriteConversionCalls() {
+	// rewrite u8(x) etc. -> explicit numeric conversions
+	p.SearchReplaceAll(func(n Nod) bool {
 		if isReceiverCallType(n.NodeType) {
 			base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
-			if base.NodeType == NT_IDENTIFIER || base.NodeType == NT_IDENTIFIER_RESOLVED ||
-				base.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
-				if base.Data.(string) == "print" {
-					return true
-				}
+			if base.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
+				_, ok := ConversionFuncType(base.Data.(string))
+				return ok
 			}
 		}
 		return false
+	}, func(n Nod) Nod {
+		toType, _ := ConversionFuncType(NodGetChild(n, NTR_RECEIVERCALL_BASE).Data.(string))
+		return p.newNumericConvert(NodGetChild(n, NTR_RECEIVERCALL_ARG), toType)
 	})
+}
 
-	for _, printCall := range printCalls {
-		NodGetChild(printCall, NTR_RECEIVERCALL_BASE).Data = "fmt.Println"
+func (p *Preparer) rewriteNumericOps() {
+	// Go won't mix numeric types in an op, so convert the operands
+	// to their promoted type first, e.g. i8 + i32 -> i32(a) + b
+	ops := p.SearchRoot(func(n Nod) bool {
+		// shift counts don't need to match the shifted type
+		if isBinaryInlineOpType(n.NodeType) && !isShiftOpType(n.NodeType) {
+			return p.getNumericTypeOrZero(NodGetChild(n, NTR_BINOP_LEFT)) != 0 &&
+				p.getNumericTypeOrZero(NodGetChild(n, NTR_BINOP_RIGHT)) != 0
+		}
+		return false
+	})
+
+	for _, op := range ops {
+		left := NodGetChild(op, NTR_BINOP_LEFT)
+		right := NodGetChild(op, NTR_BINOP_RIGHT)
+		leftType := p.getNumericTypeOrZero(left)
+		rightType := p.getNumericTypeOrZero(right)
+		promoted, ok := NumericPromote(leftType, rightType)
+		if !ok {
+			panic("can't mix " + NumericTypeName(leftType) + " and " + NumericTypeName(rightType) +
+				" without a conversion, at " + op.Loc.StringDebug())
+		}
+		wrap := func(n Nod) Nod { return p.newNumericConvert(n, promoted) }
+		if leftType != promoted {
+			p.Replace2(left, wrap)
+		}
+		if rightType != promoted {
+			p.Replace2(right, wrap)
+		}
+		if promoted == TY_BIGINT {
+			op.Data = op.NodeType
+			op.NodeType = PNT_BIGINT_BINOP
+		}
 	}
+}
 
-	if len(printCalls) > 0 {
-		NodSetChild(p.Root, PNTR_GOIMPORTS, NodNewData(NT_IDENTIFIER, "fmt"))
+func isShiftOpType(nt int) bool {
+	return nt == NT_SHLOP || nt == NT_SHROP
+}
+
+func (p *Preparer) checkForInterpolatedStrings() {
+	// interpolated strings are built with fmt.Sprintf
+	fStrings := p.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_LIT_FSTRING
+	})
+
+	if len(fStrings) > 0 {
+		p.addGoImport("fmt")
 	}
 }
 
 func (p *Preparer) isIndexableType(n Nod) bool {
+	return p.isTypeOfBase(n, TY_LIST, TY_MAP)
+}
+
+func (p *Preparer) isListType(n Nod) bool {
+	return p.isTypeOfBase(n, TY_LIST)
+}
+
+func (p *Preparer) isStringType(n Nod) bool {
+	return p.isTypeOfBase(n, TY_STRING)
+}
+
+func (p *Preparer) isTypeOfBase(n Nod, bts ...int) bool {
 	if n.NodeType == NT_TYPEBASE {
 		bt := n.Data.(int)
-		return bt == TY_LIST || bt == TY_MAP
+		for _, allowed := range bts {
+			if bt == allowed {
+				return true
+			}
+		}
+		return false
 	} else if n.NodeType == NT_CLASSDEF || n.NodeType == NT_FUNCDEF {
 		return false
 	} else if n.NodeType == NT_TYPECALL {
-		return p.isIndexableType(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+		return p.isTypeOfBase(NodGetChild(n, NTR_RECEIVERCALL_BASE), bts...)
 	} else if n.NodeType == DYPE_UNION {
-		return p.isIndexableTypeUnion(n)
+		return p.isTypeOfBaseUnion(n, bts...)
 	} else if n.NodeType == DYPE_ALL || n.NodeType == DYPE_EMPTY {
 		return false
 	} else {
@@ -195,11 +650,11 @@ func (p *Preparer) isIndexableType(n Nod) bool {
 	}
 }
 
-func (p *Preparer) isIndexableTypeUnion(n Nod) bool {
+func (p *Preparer) isTypeOfBaseUnion(n Nod, bts ...int) bool {
 	// assumption: simplified, non-nested union
 	args := NodGetChildList(n)
 	for _, arg := range args {
-		if !p.isIndexableType(arg) {
+		if !p.isTypeOfBase(arg, bts...) {
 			return false
 		}
 	}
@@ -211,8 +666,8 @@ func (p *Preparer) createExplicitIndexors() {
 		if isReceiverCallType(n.NodeType) {
 			base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
 			if base.NodeType == NT_VAR_GETTER {
-				vDef := NodGetChild(base, NTR_VARDEF)
-				if p.isIndexableType(NodGetChild(vDef, NTR_TYPE)) {
+				vDefType := NodGetChild(NodGetChild(base, NTR_VARDEF), NTR_TYPE)
+				if p.isIndexableType(vDefType) || p.isStringType(vDefType) {
 					return true
 				}
 			}
@@ -229,11 +684,30 @@ func (p *Preparer) createExplicitIndexors() {
 			listEles := NodGetChildList(arg)
 			if len(listEles) == 1 {
 				p.Replace(arg, listEles[0])
+				arg = listEles[0]
 			}
 		}
 
-		// annotate the type as explicitly indexable
+		// lists and strings get bounds checked (and can be sliced), maps don't
 		base := NodGetChild(listCall, NTR_RECEIVERCALL_BASE)
+		vDefType := NodGetChild(NodGetChild(base, NTR_VARDEF), NTR_TYPE)
+		isSlice := arg.NodeType == NT_RANGEOP || arg.NodeType == NT_RANGEEXCLOP
+		if p.isStringType(vDefType) {
+			if isSlice {
+				listCall.NodeType = PNT_STRING_SLICE
+			} else {
+				listCall.NodeType = PNT_STRING_INDEXOR
+			}
+			continue
+		} else if p.isListType(vDefType) {
+			if isSlice {
+				listCall.NodeType = PNT_LIST_SLICE
+			} else {
+				listCall.NodeType = PNT_LIST_INDEXOR
+			}
+		}
+
+		// annotate the type as explicitly indexable
 		NodSetChild(NodGetChild(base, NTR_TYPE), PNTR_TYPE_INDEXABLE, NodNew(NT_EMPTYARGLIST))
 	}
 
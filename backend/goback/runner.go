@@ -1,40 +1,118 @@
 package goback
 
 import (
-	"fmt"
+	"bytes"
+	_ "embed"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	. "pocket-lang/frontend/pocket/common"
 	"strings"
 	"time"
 )
 
+// the runtime lib's source, built in so generating and running code works
+// from any directory
+//
+//go:embed runtime.go
+var runtimeLibSource string
+
+// what a generated program did when it ran
+type Execution struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// builds generated source in dir and runs it with the given stdin. Unlike
+// RunFile nothing is shared between runs, so several can go at once.
+func BuildAndRun(src string, dir string, stdin string) Execution {
+	srcPath := filepath.Join(dir, "out.go")
+	libPath := filepath.Join(dir, "lib.go")
+	binPath := filepath.Join(dir, "out")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	copyRuntimeLib(libPath)
+	if output, err := exec.Command("go", "build", "-o", binPath, srcPath, libPath).CombinedOutput(); err != nil {
+		panic("generated code doesn't build:\n" + string(output))
+	}
+	return runBinary(binPath, stdin)
+}
+
+// like BuildAndRun, for a test build and the test main generated for it
+func BuildAndRunTests(src string, testMain string, dir string) Execution {
+	srcPath := filepath.Join(dir, "out.go")
+	testPath := filepath.Join(dir, "out_test.go")
+	libPath := filepath.Join(dir, "lib.go")
+	binPath := filepath.Join(dir, "out.test")
+	if err := ioutil.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(testPath, []byte(testMain), 0644); err != nil {
+		panic(err)
+	}
+	copyRuntimeLib(libPath)
+	cmd := exec.Command("go", "test", "-c", "-o", binPath, srcPath, libPath, testPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		panic("generated code doesn't build:\n" + string(output))
+	}
+	return runBinary(binPath, "")
+}
+
+func runBinary(binPath string, stdin string) Execution {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(binPath)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err := cmd.Run()
+	rv := Execution{Stdout: stdout.String(), Stderr: stderr.String()}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		rv.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		panic(err)
+	}
+	return rv
+}
+
 func RunFile(filePath string) string {
 
 	gopath := "../outexec"
 	cleanr(gopath)
-	fmt.Println("cleaned target directory", gopath)
+	Debugf(LOGCH_RUN, "cleaned target directory %s", gopath)
 
 	dst := gopath + "/out.go"
-	copyFile(filePath, dst)
-	fmt.Println("copied source file from", filePath, "to", dst)
+	if err := copyFile(filePath, dst); err != nil {
+		panic(err)
+	}
+	Debugf(LOGCH_RUN, "copied source file from %s to %s", filePath, dst)
 
 	// copy runtime libs
 	outLibPath := "../outexec/lib.go"
-	copyRuntimeLib("./backend/goback/runtime.go", outLibPath)
-	fmt.Println("created runtime lib at", outLibPath)
+	copyRuntimeLib(outLibPath)
+	Debugf(LOGCH_RUN, "created runtime lib at %s", outLibPath)
+
+	// building first tells a program that doesn't build from one that fails
+	build := exec.Command("go", "build", "-o", "out", "out.go", "lib.go")
+	build.Dir = gopath
+	if output, err := build.CombinedOutput(); err != nil {
+		panic("generated code doesn't build:\n" + string(output))
+	}
+	binPath := gopath + "/out"
 
-	fmt.Println("running file", filePath)
+	Infof(LOGCH_RUN, "running file %s", filePath)
 	startClock := NowAsUnixMilli()
-	output, _ := exec.Command("go", "run", dst, outLibPath).CombinedOutput()
+	output, err := exec.Command(binPath).CombinedOutput()
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		panic(err)
+	}
 
-	fmt.Println("Output:")
-	fmt.Println(string(output))
-	fmt.Println("Run done.")
+	Debugf(LOGCH_RUN, "output:\n%s", output)
 
 	endClock := NowAsUnixMilli()
-	fmt.Println("pocket execution time:", (endClock - startClock), "ms")
+	Infof(LOGCH_RUN, "pocket execution time: %d ms", endClock-startClock)
 
 	return string(output)
 }
@@ -48,18 +126,15 @@ func cleanr(path string) {
 	os.MkdirAll(path, 0700)
 }
 
-func copyRuntimeLib(src string, dst string) {
-	dat, err := ioutil.ReadFile(src)
-	if err != nil {
+func copyRuntimeLib(dst string) {
+	if err := ioutil.WriteFile(dst, []byte(runtimeLib()), 0644); err != nil {
 		panic(err)
 	}
-	fmt.Println("input lib file:")
-	iconts := string(dat)
-
-	// clean it up a bit
-	iconts = strings.Replace(iconts, "goback", "main", 1)
+}
 
-	ioutil.WriteFile(dst, ([]byte)(iconts), 0644)
+// the runtime lib's source, as a file of the generated program's package
+func runtimeLib() string {
+	return strings.Replace(runtimeLibSource, "goback", "main", 1)
 }
 
 // copy the src file to dst. Any existing file will be overwritten and will not
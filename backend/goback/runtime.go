@@ -1,9 +1,14 @@
 package goback
 
 import (
+	"fmt"
+	"io"
 	"math"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -144,6 +149,30 @@ var __pk_dot_asym_defeq = map[uint32]map[uint32]func(duck, duck) duck{
 	},
 }
 
+var __pk_dot_asym_pow = map[uint32]map[uint32]func(duck, duck) duck{
+	DTY_INT: map[uint32]func(duck, duck) duck{
+		DTY_INT: func(a duck, b duck) duck { return P__ipow(a.(int), b.(int)) },
+	},
+}
+
+var __pk_dot_asym_xor = map[uint32]map[uint32]func(duck, duck) duck{
+	DTY_INT: map[uint32]func(duck, duck) duck{
+		DTY_INT: func(a duck, b duck) duck { return a.(int) ^ b.(int) },
+	},
+}
+
+var __pk_dot_asym_shl = map[uint32]map[uint32]func(duck, duck) duck{
+	DTY_INT: map[uint32]func(duck, duck) duck{
+		DTY_INT: func(a duck, b duck) duck { return a.(int) << b.(int) },
+	},
+}
+
+var __pk_dot_asym_shr = map[uint32]map[uint32]func(duck, duck) duck{
+	DTY_INT: map[uint32]func(duck, duck) duck{
+		DTY_INT: func(a duck, b duck) duck { return a.(int) >> b.(int) },
+	},
+}
+
 func goty_to_dty(k reflect.Kind) uint32 {
 	if k == reflect.Int {
 		return DTY_INT
@@ -209,6 +238,37 @@ func P__duck_lteq(a duck, b duck) bool {
 func P__duck_defeq(a duck, b duck) bool {
 	return P__duck_primbinop(a, b, __pk_dot_asym_defeq).(bool)
 }
+func P__duck_pow(a duck, b duck) duck {
+	return P__duck_primbinop(a, b, __pk_dot_asym_pow)
+}
+func P__duck_xor(a duck, b duck) duck {
+	return P__duck_primbinop(a, b, __pk_dot_asym_xor)
+}
+func P__duck_shl(a duck, b duck) duck {
+	return P__duck_primbinop(a, b, __pk_dot_asym_shl)
+}
+func P__duck_shr(a duck, b duck) duck {
+	return P__duck_primbinop(a, b, __pk_dot_asym_shr)
+}
+func P__duck_neg(a duck) duck {
+	switch t := a.(type) {
+	case int:
+		return -t
+	case float64:
+		return -t
+	}
+	panic("unsupported type")
+}
+func P__duck_pos(a duck) duck {
+	switch a.(type) {
+	case int, float64:
+		return a
+	}
+	panic("unsupported type")
+}
+func P__duck_not(a duck) bool {
+	return !a.(bool)
+}
 func __pk_duck_ftoa(f float64) string {
 	return strconv.FormatFloat(f, 'g', -1, 64)
 }
@@ -245,3 +305,211 @@ func P__duck_method_call(obj duck, name string, arg duck) duck {
 	}
 	return outvals[0].Interface()
 }
+
+func P__range(a int, b int, step int, inclusive bool) []int {
+	if step == 0 {
+		panic("range step cannot be 0")
+	}
+	rv := []int{}
+	if step > 0 {
+		for i := a; i < b || (inclusive && i == b); i += step {
+			rv = append(rv, i)
+		}
+	} else {
+		for i := a; i > b || (inclusive && i == b); i += step {
+			rv = append(rv, i)
+		}
+	}
+	return rv
+}
+
+func P__index(length int, i int, loc string) int {
+	// negative indices count back from the end
+	ndx := i
+	if ndx < 0 {
+		ndx += length
+	}
+	if ndx < 0 || ndx >= length {
+		panic("index " + strconv.Itoa(i) + " out of range [0:" + strconv.Itoa(length) + "] at " + loc)
+	}
+	return ndx
+}
+
+func P__slice_indices(length int, a int, b int, step int, inclusive bool, loc string) []int {
+	if a < 0 {
+		a += length
+	}
+	if b < 0 {
+		b += length
+	}
+	ndxs := P__range(a, b, step, inclusive)
+	for _, ndx := range ndxs {
+		P__index(length, ndx, loc)
+	}
+	return ndxs
+}
+
+func P__slice(coll duck, a int, b int, step int, inclusive bool, loc string) duck {
+	cv := reflect.ValueOf(coll)
+	rv := reflect.MakeSlice(cv.Type(), 0, 0)
+	for _, ndx := range P__slice_indices(cv.Len(), a, b, step, inclusive, loc) {
+		rv = reflect.Append(rv, cv.Index(ndx))
+	}
+	return rv.Interface()
+}
+
+func P__str_len(s string) int {
+	return len([]rune(s))
+}
+
+func P__str_index(s string, i int, loc string) string {
+	runes := []rune(s)
+	return string(runes[P__index(len(runes), i, loc)])
+}
+
+func P__str_slice(s string, a int, b int, step int, inclusive bool, loc string) string {
+	runes := []rune(s)
+	rv := []rune{}
+	for _, ndx := range P__slice_indices(len(runes), a, b, step, inclusive, loc) {
+		rv = append(rv, runes[ndx])
+	}
+	return string(rv)
+}
+
+func P__to_bigint(v duck) *big.Int {
+	switch t := v.(type) {
+	case *big.Int:
+		return t
+	case string:
+		rv, ok := new(big.Int).SetString(t, 0)
+		if !ok {
+			panic("invalid bigint '" + t + "'")
+		}
+		return rv
+	case float32:
+		rv, _ := big.NewFloat(float64(t)).Int(nil)
+		return rv
+	case float64:
+		rv, _ := big.NewFloat(t).Int(nil)
+		return rv
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(val.Uint())
+	}
+	panic("can't convert to bigint: " + val.Kind().String())
+}
+
+func P__bigint_to_float(a *big.Int) float64 {
+	rv, _ := new(big.Float).SetInt(a).Float64()
+	return rv
+}
+
+func P__bigint_arith(a *big.Int, b *big.Int, op string) *big.Int {
+	rv := new(big.Int)
+	switch op {
+	case "+":
+		return rv.Add(a, b)
+	case "-":
+		return rv.Sub(a, b)
+	case "*":
+		return rv.Mul(a, b)
+	case "/":
+		// truncated like the fixed size ints
+		return rv.Quo(a, b)
+	case "%":
+		return rv.Rem(a, b)
+	case "**":
+		if b.Sign() < 0 {
+			panic("negative exponent " + b.String() + " in integer power")
+		}
+		return rv.Exp(a, b, nil)
+	case "&":
+		return rv.And(a, b)
+	case "|":
+		return rv.Or(a, b)
+	case "^":
+		return rv.Xor(a, b)
+	}
+	panic("unsupported bigint op " + op)
+}
+
+func P__bigint_cmp(a *big.Int, b *big.Int) int {
+	return a.Cmp(b)
+}
+
+type __pk_integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+func P__ipow[T __pk_integer](base T, exp T) T {
+	if exp < 0 {
+		panic("negative exponent " + strconv.FormatInt(int64(exp), 10) + " in integer power")
+	}
+	var rv T = 1
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			rv *= base
+		}
+		base *= base
+	}
+	return rv
+}
+
+func P__bigint_neg(a *big.Int) *big.Int {
+	return new(big.Int).Neg(a)
+}
+
+func P__bigint_pos(a *big.Int) *big.Int {
+	return a
+}
+
+// op is "" when the assert isn't a comparison, otherwise its sides follow
+func P__assert_failed(loc string, op string, sides ...duck) {
+	msg := "assertion failed at " + loc
+	if op != "" {
+		msg += ": left " + op + " right\n    left:  " + __pk_assert_operand(sides[0]) +
+			"\n    right: " + __pk_assert_operand(sides[1])
+	}
+	panic(msg)
+}
+
+func __pk_assert_operand(v duck) string {
+	if s, ok := v.(string); ok {
+		return "'" + s + "'"
+	}
+	return fmt.Sprint(v)
+}
+
+// runs each test, reporting to out how it went, and returns how many failed
+func P__run_tests(out io.Writer, names []string, tests []func()) int {
+	failed := 0
+	for ndx, test := range tests {
+		start := time.Now()
+		failure := __pk_run_test(test)
+		elapsed := fmt.Sprintf("%.2fms", float64(time.Since(start).Microseconds())/1000)
+		if failure == "" {
+			fmt.Fprintf(out, "PASS %s (%s)\n", names[ndx], elapsed)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL %s (%s)\n", names[ndx], elapsed)
+		fmt.Fprintln(out, "    "+strings.Replace(failure, "\n", "\n    ", -1))
+	}
+	fmt.Fprintf(out, "%d passed, %d failed\n", len(tests)-failed, failed)
+	return failed
+}
+
+// a failed assert or any other panic fails a test, and is why it failed
+func __pk_run_test(test func()) (failure string) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = fmt.Sprint(r)
+		}
+	}()
+	test()
+	return ""
+}
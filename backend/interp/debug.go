@@ -0,0 +1,123 @@
+package interp
+
+import (
+	"io"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"strings"
+)
+
+// Hook is called before each statement that came from the source, with the
+// running funcs, innermost last. A debugger pauses the program by not
+// returning until the user resumes it.
+type Hook func(stmt Nod, stack []*StackFrame)
+
+// StackFrame is a running func, as a debugger sees it
+type StackFrame struct {
+	f *frame
+}
+
+// a variable or field, by its pocket name
+type Variable struct {
+	Name  string
+	Value interface{}
+}
+
+// RunHooked runs code like Run, calling hook before each statement
+func RunHooked(code Nod, out io.Writer, hook Hook) {
+	it := newInterpreter(code, out)
+	it.hook = hook
+	it.runSourceFile(code)
+}
+
+func (it *Interpreter) callHook(stmt Nod) {
+	stack := []*StackFrame{}
+	for _, f := range it.stack {
+		stack = append(stack, &StackFrame{f})
+	}
+	it.hook(stmt, stack)
+}
+
+func (sf *StackFrame) FuncName() string {
+	if name := NodGetChildOrNil(sf.f.def, NTR_FUNCDEF_NAME); name != nil {
+		return name.Data.(string)
+	}
+	return "func"
+}
+
+// the object a method was called on, or nil. Pocket methods name its fields
+// directly, self is only how the generated code gets at them.
+func (sf *StackFrame) Self() *Object {
+	return sf.f.self
+}
+
+// the func's params and locals, in the order they were declared
+func (sf *StackFrame) Locals() []Variable {
+	varDefs := []Nod{}
+	if inType := NodGetChildOrNil(sf.f.def, NTR_FUNCDEF_INTYPE); inType != nil {
+		params := []Nod{inType}
+		if inType.NodeType == NT_LIT_LIST {
+			params = NodGetChildList(inType)
+		}
+		for _, param := range params {
+			if param.NodeType == NT_PARAMETER {
+				varDefs = append(varDefs, NodGetChild(param, NTR_VARDEF))
+			}
+		}
+	}
+	if varTable := NodGetChildOrNil(sf.f.def, NTR_VARTABLE); varTable != nil {
+		varDefs = append(varDefs, NodGetChildList(varTable)...)
+	}
+
+	rv := []Variable{}
+	seen := map[Nod]bool{}
+	for _, varDef := range varDefs {
+		val, ok := sf.f.vars[varDef]
+		name := NodGetChildOrNil(varDef, NTR_VARDEF_NAME)
+		if !ok || seen[varDef] || name == nil || isHiddenVarName(name.Data.(string)) {
+			continue
+		}
+		seen[varDef] = true
+		rv = append(rv, Variable{name.Data.(string), val})
+	}
+	return rv
+}
+
+// the variables the compiler made up, e.g. the index of a for loop
+func isHiddenVarName(name string) bool {
+	return name == "self" || strings.HasPrefix(name, "__pk") || strings.HasPrefix(name, "_pk_")
+}
+
+// finds a variable the func can see: its own, then those of the funcs it's
+// nested in, then the fields of self
+func (sf *StackFrame) Lookup(name string) (interface{}, bool) {
+	for f := sf.f; f != nil; f = f.parent {
+		for _, v := range (&StackFrame{f}).Locals() {
+			if v.Name == name {
+				return v.Value, true
+			}
+		}
+	}
+	if self := sf.f.self; self != nil {
+		if val, ok := self.fields[name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+func (o *Object) ClassName() string {
+	if name := NodGetChildOrNil(o.cls, NTR_CLASSDEF_NAME); name != nil {
+		return name.Data.(string)
+	}
+	return "static"
+}
+
+// the fields in the order the class declares them
+func (o *Object) Fields() []Variable {
+	rv := []Variable{}
+	for _, name := range o.names {
+		rv = append(rv, Variable{name, o.fields[name]})
+	}
+	return rv
+}
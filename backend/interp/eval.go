@@ -0,0 +1,260 @@
+package interp
+
+import (
+	"fmt"
+	"pocket-lang/backend/goback"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+)
+
+func (it *Interpreter) eval(f *frame, n Nod) interface{} {
+	nt := n.NodeType
+	if nt == NT_LIT_INT {
+		return it.evalLiteralInt(n)
+	} else if nt == NT_LIT_FLOAT {
+		return coerce(n.Data.(float64), NodGetChildOrNil(n, NTR_TYPE))
+	} else if nt == NT_LIT_STRING || nt == NT_LIT_BOOL {
+		return n.Data
+	} else if nt == NT_LIT_FSTRING {
+		return it.evalLiteralFString(f, n)
+	} else if nt == NT_VAR_GETTER {
+		name := NodGetChild(n, NTR_VAR_NAME).Data.(string)
+		return it.getVar(f, NodGetChild(n, NTR_VARDEF), name)
+	} else if nt == NT_LIT_LIST {
+		rv := []interface{}{}
+		for _, ele := range NodGetChildList(n) {
+			rv = append(rv, it.eval(f, ele))
+		}
+		return rv
+	} else if nt == NT_LIT_SET {
+		rv := map[interface{}]bool{}
+		for _, ele := range NodGetChildList(n) {
+			rv[it.eval(f, ele)] = true
+		}
+		return rv
+	} else if nt == NT_LIT_MAP {
+		rv := map[interface{}]interface{}{}
+		for _, kvpair := range NodGetChildList(n) {
+			rv[it.eval(f, NodGetChild(kvpair, NTR_KVPAIR_KEY))] = it.eval(f, NodGetChild(kvpair, NTR_KVPAIR_VAL))
+		}
+		return rv
+	} else if nt == NT_RECEIVERCALL || nt == NT_RECEIVERCALL_METHOD {
+		return it.evalReceiverCall(f, n)
+	} else if nt == NT_COLLECTION_INDEXOR {
+		return it.evalCollectionIndexor(f, n)
+	} else if nt == goback.PNT_LIST_INDEXOR {
+		list := toList(it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)))
+		i := toInt(it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_ARG)))
+		return list[goback.P__index(len(list), i, n.Loc.StringDebug())]
+	} else if nt == goback.PNT_LIST_SLICE {
+		list := toList(it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)))
+		a, b, step, inclusive := it.evalRangeArgs(f, NodGetChild(n, NTR_RECEIVERCALL_ARG))
+		return goback.P__slice(list, a, b, step, inclusive, n.Loc.StringDebug())
+	} else if nt == goback.PNT_STRING_INDEXOR {
+		s := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)).(string)
+		i := toInt(it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_ARG)))
+		return goback.P__str_index(s, i, n.Loc.StringDebug())
+	} else if nt == goback.PNT_STRING_SLICE {
+		s := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)).(string)
+		a, b, step, inclusive := it.evalRangeArgs(f, NodGetChild(n, NTR_RECEIVERCALL_ARG))
+		return goback.P__str_slice(s, a, b, step, inclusive, n.Loc.StringDebug())
+	} else if nt == NT_RANGEOP || nt == NT_RANGEEXCLOP {
+		return toList(goback.P__range(it.evalRangeArgs(f, n)))
+	} else if nt == goback.PNT_NUMERIC_CONVERT {
+		return Convert(it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_ARG)), n.Data.(int))
+	} else if nt == goback.PNT_BIGINT_BINOP {
+		return it.evalBigintBinop(f, n)
+	} else if nt == NT_OBJINIT {
+		return it.newObject(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	} else if nt == goback.PNT_WRAP_OBJ_INIT {
+		return it.evalObjInitWrapper(f, n)
+	} else if nt == goback.PNT_CONSTRUCT {
+		obj := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)).(*Object)
+		arg := it.evalArg(f, NodGetChild(n, NTR_RECEIVERCALL_ARG))
+		it.callFunc(&Closure{def: NodGetChild(n, NTR_FUNCDEF)}, obj, arg)
+		return obj
+	} else if nt == NT_OBJFIELD_ACCESSOR || nt == goback.PNT_DUCK_FIELD_READ {
+		obj := it.evalObject(f, NodGetChild(n, NTR_RECEIVERCALL_BASE))
+		return obj.getField(NodGetChild(n, NTR_OBJFIELD_ACCESSOR_NAME).Data.(string))
+	} else if nt == goback.PNT_PSEUD_COLLECTION_LEN {
+		return it.evalCollectionLen(f, n)
+	} else if nt == goback.PNT_PSEUD_LIST_CONCAT {
+		left := toList(it.eval(f, NodGetChild(n, NTR_BINOP_LEFT)))
+		right := toList(it.eval(f, NodGetChild(n, NTR_BINOP_RIGHT)))
+		return append(left, right...)
+	} else if nt == goback.PNT_DUCK_BINOP {
+		return it.evalDuckOp(f, n)
+	} else if isBinaryInlineOpType(nt) {
+		return it.evalBinaryInlineOp(f, n)
+	} else if nt == goback.PNT_DUCK_METHOD_CALL {
+		return it.evalDuckMethodCall(f, n)
+	} else if nt == NT_REFERENCEOP {
+		return it.evalReferenceOp(f, n)
+	} else if nt == NT_NEGOP || nt == NT_POSOP || nt == NT_NOTOP {
+		return it.evalUnaryOp(f, n)
+	} else if nt == NT_FUNCDEF {
+		return &Closure{def: n, env: f}
+	} else if nt == NT_CLASSDEF {
+		// a class used as a value is its static zone
+		return it.statics[NodGetChild(n, NTR_CLASSDEF_STATICZONE)]
+	} else if nt == goback.PNT_ASSERT_OPERAND {
+		return f.assertOperands[n.Data.(int)]
+	}
+	panic("can't evaluate " + PrettyPrint(n))
+}
+
+func (it *Interpreter) evalLiteralInt(n Nod) interface{} {
+	return coerce(n.Data.(int), NodGetChildOrNil(n, NTR_TYPE))
+}
+
+func (it *Interpreter) evalLiteralFString(f *frame, n Nod) string {
+	rv := ""
+	for _, piece := range NodGetChildList(n) {
+		if piece.NodeType == NT_LIT_STRING {
+			rv += piece.Data.(string)
+		} else {
+			rv += fmt.Sprint(it.eval(f, piece))
+		}
+	}
+	return rv
+}
+
+func (it *Interpreter) evalRangeArgs(f *frame, n Nod) (int, int, int, bool) {
+	// <start>, <end>, <step>, <inclusive>
+	a := toInt(it.eval(f, NodGetChild(n, NTR_BINOP_LEFT)))
+	b := toInt(it.eval(f, NodGetChild(n, NTR_BINOP_RIGHT)))
+	step := 1
+	if stepNod := NodGetChildOrNil(n, NTR_RANGE_STEP); stepNod != nil {
+		step = toInt(it.eval(f, stepNod))
+	}
+	return a, b, step, n.NodeType != NT_RANGEEXCLOP
+}
+
+func (it *Interpreter) evalCollectionIndexor(f *frame, n Nod) interface{} {
+	coll := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	key := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	if set, ok := coll.(map[interface{}]bool); ok {
+		return set[key]
+	}
+	return coll.(map[interface{}]interface{})[key]
+}
+
+func (it *Interpreter) evalCollectionLen(f *frame, n Nod) int {
+	coll := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	switch c := coll.(type) {
+	case string:
+		// strings are measured in runes, not bytes
+		return goback.P__str_len(c)
+	case []interface{}:
+		return len(c)
+	case []int:
+		return len(c)
+	case map[interface{}]bool:
+		return len(c)
+	case map[interface{}]interface{}:
+		return len(c)
+	}
+	panic(fmt.Sprintf("no length for %v", coll))
+}
+
+func (it *Interpreter) evalReferenceOp(f *frame, n Nod) interface{} {
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	if fDef := NodGetChildOrNil(arg, NTR_FUNCDEF); fDef != nil {
+		return &Closure{def: fDef}
+	}
+	return it.eval(f, arg)
+}
+
+func (it *Interpreter) evalReceiverCall(f *frame, n Nod) interface{} {
+	if n.NodeType == NT_RECEIVERCALL_METHOD {
+		return it.evalReceiverCallMethod(f, n)
+	}
+
+	base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+	arg := it.evalArg(f, NodGetChildOrNil(n, NTR_RECEIVERCALL_ARG))
+
+	if name, ok := base.Data.(string); ok && name == "fmt.Println" {
+		// print calls were renamed by the prepare pass
+		if arg == nil {
+			fmt.Fprintln(it.out)
+		} else {
+			fmt.Fprintln(it.out, arg)
+		}
+		return nil
+	}
+
+	if fDef := NodGetChildOrNil(n, NTR_FUNCDEF); fDef != nil {
+		return it.callFunc(&Closure{def: fDef}, nil, arg)
+	}
+
+	if base.NodeType == NT_VAR_GETTER {
+		if cl, ok := it.eval(f, base).(*Closure); ok && cl != nil {
+			return it.callFunc(cl, nil, arg)
+		}
+	}
+	panic("can't call " + PrettyPrint(base))
+}
+
+func (it *Interpreter) evalArg(f *frame, arg Nod) interface{} {
+	if arg == nil || arg.NodeType == NT_EMPTYARGLIST {
+		return nil
+	}
+	return it.eval(f, arg)
+}
+
+func (it *Interpreter) evalReceiverCallMethod(f *frame, n Nod) interface{} {
+	obj := it.evalObject(f, NodGetChild(n, NTR_RECEIVERCALL_BASE))
+	arg := it.evalArg(f, NodGetChildOrNil(n, NTR_RECEIVERCALL_ARG))
+	name := NodGetChild(n, NTR_RECEIVERCALL_METHOD_NAME).Data.(string)
+	return it.callFunc(&Closure{def: obj.getMethod(name)}, obj, arg)
+}
+
+func (it *Interpreter) evalDuckMethodCall(f *frame, n Nod) interface{} {
+	// same as a method call, but only known at runtime to be an object
+	return it.evalReceiverCallMethod(f, n)
+}
+
+func (it *Interpreter) evalObjInitWrapper(f *frame, n Nod) interface{} {
+	obj := it.eval(f, NodGetChild(n, NTR_RECEIVERCALL_BASE)).(*Object)
+	arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	clsDef := NodGetChild(n, NTR_CLASSDEF)
+	isConfig := NodGetChild(n, NTR_PRAGMAPAINT).Data.(bool)
+
+	if arg.NodeType == NT_EMPTYARGLIST {
+		return obj
+	} else if arg.NodeType == NT_KWARGS {
+		for _, kwarg := range NodGetChildList(arg) {
+			name := NodGetChild(kwarg, NTR_VAR_NAME).Data.(string)
+			obj.setField(name, it.eval(f, NodGetChild(kwarg, NTR_VARASSIGN_VALUE)))
+		}
+		return obj
+	}
+
+	values := []Nod{arg}
+	if arg.NodeType == NT_LIT_LIST {
+		values = NodGetChildList(arg)
+	}
+
+	// ordered args fill the config or non-config fields in declaration order
+	clsFieldsOrdered := []Nod{}
+	for _, unit := range NodGetChildList(clsDef) {
+		if unit.NodeType == NT_CLASSFIELD && isConfigField(unit) == isConfig {
+			clsFieldsOrdered = append(clsFieldsOrdered, unit)
+		}
+	}
+	if len(values) > len(clsFieldsOrdered) {
+		panic("too many arguments to ordered object initializer")
+	}
+	for ndx, val := range values {
+		name := NodGetChild(clsFieldsOrdered[ndx], NTR_VARDEF_NAME).Data.(string)
+		obj.setField(name, it.eval(f, val))
+	}
+	return obj
+}
+
+func isConfigField(clsField Nod) bool {
+	if pragmaPaint := NodGetChildOrNil(clsField, NTR_PRAGMAPAINT); pragmaPaint != nil {
+		return NodHasChild(pragmaPaint, NT_MODF_CONFIG)
+	}
+	return false
+}
@@ -0,0 +1,422 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+	"pocket-lang/backend/goback"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"pocket-lang/xform"
+)
+
+// Interpreter walks the solved ASG directly instead of generating go code.
+// It runs the same prepare pass as the go backend, so both backends see
+// identical indexors, duck ops and numeric conversions.
+type Interpreter struct {
+	out     io.Writer
+	statics map[Nod]*Object // static zone singletons, keyed by their CLASSDEFPARTIAL
+	stack   []*frame        // the running funcs, innermost last
+	hook    Hook
+}
+
+type frame struct {
+	def      Nod                 // the FUNCDEF being run
+	vars     map[Nod]interface{} // keyed by VARDEF
+	parent   *frame              // defining frame, for closures
+	self     *Object
+	returned interface{}
+
+	assertOperands [2]interface{} // the sides of the assert being checked
+}
+
+type control int
+
+const (
+	ctlNext control = iota
+	ctlBreak
+	ctlReturn
+)
+
+// a function value, created by @f or an anonymous func
+type Closure struct {
+	def Nod
+	env *frame
+}
+
+func Run(code Nod, out io.Writer) {
+	RunCollectLocals(code, out)
+}
+
+// like Run, but also returns main's local variables by name once it's done
+func RunCollectLocals(code Nod, out io.Writer) map[string]interface{} {
+	return frameLocals(newInterpreter(code, out).runSourceFile(code))
+}
+
+// State is what running main leaves behind: its variables, and the static
+// zones by class name. The repl carries it from one input to the next.
+type State struct {
+	Locals  map[string]interface{}
+	Statics map[string]*Object
+}
+
+func NewState() *State {
+	return &State{Locals: map[string]interface{}{}, Statics: map[string]*Object{}}
+}
+
+// RunResuming runs main from its skip'th statement on, as if the ones before
+// had already run and left state behind, and updates state once it's done.
+// Static zones that aren't in state yet are set up first. The solver and
+// prepare keep main's statements one for one, so skip counts them as parsed.
+func RunResuming(code Nod, skip int, state *State, out io.Writer) {
+	it := newInterpreter(code, out)
+	mainDef, ok := it.loadSourceFile(code, state.Statics)["main"]
+	if !ok {
+		panic("no main function")
+	}
+	f := it.enterFunc(&Closure{def: mainDef}, nil, nil)
+	defer it.leaveFunc()
+	for varDef := range f.vars {
+		if name := NodGetChildOrNil(varDef, NTR_VARDEF_NAME); name != nil {
+			if val, ok := state.Locals[name.Data.(string)]; ok {
+				f.vars[varDef] = coerce(val, NodGetChildOrNil(varDef, NTR_TYPE))
+			}
+		}
+	}
+	stmts := NodGetChildList(NodGetChild(mainDef, NTR_FUNCDEF_CODE))
+	for _, stmt := range stmts[skip:] {
+		if it.execImperativeUnit(f, stmt) != ctlNext {
+			break
+		}
+	}
+	state.Locals = frameLocals(f)
+}
+
+// a frame's variables by name
+func frameLocals(f *frame) map[string]interface{} {
+	rv := map[string]interface{}{}
+	for varDef, val := range f.vars {
+		if name := NodGetChildOrNil(varDef, NTR_VARDEF_NAME); name != nil {
+			rv[name.Data.(string)] = val
+		}
+	}
+	return rv
+}
+
+// RunTests runs the tests of a test build, see xform.XformTests, reporting
+// like the generated test main does. It returns how many failed.
+func RunTests(code Nod, tests []TestDef, out io.Writer) int {
+	it := newInterpreter(code, out)
+	funcDefs := it.loadSourceFile(code, map[string]*Object{})
+	names, funcs := []string{}, []func(){}
+	for _, test := range tests {
+		def := funcDefs[test.FuncName]
+		names = append(names, test.Name)
+		funcs = append(funcs, func() { it.callFunc(&Closure{def: def}, nil, nil) })
+	}
+	return goback.P__run_tests(out, names, funcs)
+}
+
+// prepares code for running
+func newInterpreter(code Nod, out io.Writer) *Interpreter {
+	preparer := &goback.Preparer{Xformer: &xform.Xformer{}}
+	preparer.Prepare(code)
+
+	return &Interpreter{
+		out:     out,
+		statics: map[Nod]*Object{},
+	}
+}
+
+func (it *Interpreter) runSourceFile(code Nod) *frame {
+	mainDef, ok := it.loadSourceFile(code, map[string]*Object{})["main"]
+	if !ok {
+		panic("no main function")
+	}
+	return it.callFuncFrame(&Closure{def: mainDef}, nil, nil)
+}
+
+// sets up the static zones and returns the top level funcs by name. The
+// zones in statics, by class name, are kept as they are, and the new ones
+// are added to it.
+func (it *Interpreter) loadSourceFile(code Nod, statics map[string]*Object) map[string]Nod {
+	funcDefs := map[string]Nod{}
+	staticZones := []Nod{}
+	units := NodGetChildList(code)
+	for _, unit := range units {
+		if unit.NodeType == NT_FUNCDEF {
+			funcDefs[NodGetChild(unit, NTR_FUNCDEF_NAME).Data.(string)] = unit
+		} else if unit.NodeType == NT_CLASSDEF {
+			// static zones exist before main runs, like the generated singletons
+			if staticZone := NodGetChildOrNil(unit, NTR_CLASSDEF_STATICZONE); staticZone != nil {
+				clsName := NodGetChild(unit, NTR_CLASSDEF_NAME).Data.(string)
+				if obj, ok := statics[clsName]; ok {
+					it.statics[staticZone] = obj
+					continue
+				}
+				it.statics[staticZone] = it.newObject(staticZone)
+				statics[clsName] = it.statics[staticZone]
+				staticZones = append(staticZones, staticZone)
+			}
+		} else {
+			panic("unknown source unit type")
+		}
+	}
+	// constructors run once every static zone exists, so they can use each other
+	for _, staticZone := range staticZones {
+		if ctor := goback.StaticConstructorOrNil(staticZone); ctor != nil {
+			it.callFunc(&Closure{def: ctor}, it.statics[staticZone], nil)
+		}
+	}
+	return funcDefs
+}
+
+func (it *Interpreter) callFunc(cl *Closure, self *Object, arg interface{}) interface{} {
+	f := it.callFuncFrame(cl, self, arg)
+	if placeholder := NodGetChildOrNil(cl.def, NTR_RETURNVAL_PLACEHOLDER); placeholder != nil {
+		return coerce(f.returned, NodGetChildOrNil(placeholder, NTR_TYPE))
+	}
+	return f.returned
+}
+
+// runs the function and returns its finished frame
+func (it *Interpreter) callFuncFrame(cl *Closure, self *Object, arg interface{}) *frame {
+	f := it.enterFunc(cl, self, arg)
+	defer it.leaveFunc()
+	it.execImperative(f, NodGetChild(cl.def, NTR_FUNCDEF_CODE))
+	return f
+}
+
+// pushes a frame for the function with its params bound, before its code runs
+func (it *Interpreter) enterFunc(cl *Closure, self *Object, arg interface{}) *frame {
+	def := cl.def
+	f := &frame{
+		def:    def,
+		vars:   map[Nod]interface{}{},
+		parent: cl.env,
+		self:   self,
+	}
+	if self == nil && cl.env != nil {
+		f.self = cl.env.self
+	}
+	if selfDef := NodGetChildOrNil(def, NTR_METHOD_SELFDEF); selfDef != nil {
+		f.vars[selfDef] = self
+	}
+
+	if varTable := NodGetChildOrNil(def, NTR_VARTABLE); varTable != nil {
+		for _, varDef := range NodGetChildList(varTable) {
+			if NodGetChild(varDef, NTR_VARDEF_SCOPE).Data.(int) == VSCOPE_FUNCLOCAL {
+				f.vars[varDef] = zeroValue(NodGetChildOrNil(varDef, NTR_TYPE))
+			}
+		}
+	}
+
+	if inType := NodGetChildOrNil(def, NTR_FUNCDEF_INTYPE); inType != nil {
+		if inType.NodeType == NT_PARAMETER {
+			it.bindParam(f, inType, arg)
+		} else if inType.NodeType == NT_LIT_LIST {
+			// multiple params arrive as one list, like the generated args []interface{}
+			args := arg.([]interface{})
+			for ndx, param := range NodGetChildList(inType) {
+				it.bindParam(f, param, args[ndx])
+			}
+		}
+	}
+
+	it.stack = append(it.stack, f)
+	return f
+}
+
+func (it *Interpreter) leaveFunc() {
+	it.stack = it.stack[:len(it.stack)-1]
+}
+
+func (it *Interpreter) bindParam(f *frame, param Nod, arg interface{}) {
+	varDef := NodGetChild(param, NTR_VARDEF)
+	f.vars[varDef] = coerce(arg, NodGetChildOrNil(param, NTR_TYPE))
+}
+
+func isClassFieldVarDef(varDef Nod) bool {
+	if scope := NodGetChildOrNil(varDef, NTR_VARDEF_SCOPE); scope != nil {
+		return scope.Data.(int) == VSCOPE_CLASSFIELD
+	}
+	return false
+}
+
+func (it *Interpreter) getVar(f *frame, varDef Nod, name string) interface{} {
+	if isClassFieldVarDef(varDef) {
+		return f.self.getField(name)
+	}
+	for fr := f; fr != nil; fr = fr.parent {
+		if val, ok := fr.vars[varDef]; ok {
+			return val
+		}
+	}
+	panic("unbound variable " + name)
+}
+
+func (it *Interpreter) setVar(f *frame, varDef Nod, name string, val interface{}) {
+	if isClassFieldVarDef(varDef) {
+		f.self.setField(name, val)
+		return
+	}
+	val = coerce(val, NodGetChildOrNil(varDef, NTR_TYPE))
+	for fr := f; fr != nil; fr = fr.parent {
+		if _, ok := fr.vars[varDef]; ok {
+			fr.vars[varDef] = val
+			return
+		}
+	}
+	f.vars[varDef] = val
+}
+
+func isReceiverCallType(nt int) bool {
+	return nt == NT_RECEIVERCALL || nt == NT_RECEIVERCALL_CMD || nt == NT_RECEIVERCALL_METHOD
+}
+
+func (it *Interpreter) execImperative(f *frame, n Nod) control {
+	for _, stmt := range NodGetChildList(n) {
+		if ctl := it.execImperativeUnit(f, stmt); ctl != ctlNext {
+			return ctl
+		}
+	}
+	return ctlNext
+}
+
+func (it *Interpreter) execImperativeUnit(f *frame, n Nod) control {
+	nt := n.NodeType
+	if it.hook != nil && n.Loc != nil && nt != NT_IMPERATIVE {
+		it.callHook(n)
+	}
+	if nt == NT_VARASSIGN {
+		it.execVarAssign(f, n)
+	} else if isReceiverCallType(nt) {
+		it.evalReceiverCall(f, n)
+	} else if nt == NT_RETURN {
+		if val := NodGetChildOrNil(n, NTR_RETURN_VALUE); val != nil {
+			f.returned = it.eval(f, val)
+		}
+		return ctlReturn
+	} else if nt == NT_LOOP {
+		return it.execLoop(f, n)
+	} else if nt == NT_WHILE {
+		return it.execWhile(f, n)
+	} else if nt == NT_IF {
+		return it.execIf(f, n)
+	} else if nt == NT_BREAK {
+		return ctlBreak
+	} else if nt == NT_IMPERATIVE {
+		return it.execImperative(f, n)
+	} else if nt == NT_PASS {
+		// nothing to do
+	} else if nt == goback.PNT_DUCK_FIELD_WRITE {
+		obj := it.evalObject(f, NodGetChild(n, goback.PNTR_DUCK_FIELD_WRITE_OBJ))
+		name := NodGetChild(n, goback.PNTR_DUCK_FIELD_WRITE_NAME).Data.(string)
+		obj.setField(name, it.eval(f, NodGetChild(n, goback.PNTR_DUCK_FIELD_WRITE_VAL)))
+	} else if nt == goback.PNT_DUCK_METHOD_CALL {
+		it.evalDuckMethodCall(f, n)
+	} else if nt == NT_ASSERT {
+		it.execAssert(f, n)
+	} else {
+		panic("can't execute " + PrettyPrint(n))
+	}
+	return ctlNext
+}
+
+// like the generated code, the sides of a comparison are evaluated once, first
+func (it *Interpreter) execAssert(f *frame, n Nod) {
+	op := goback.AssertedComparison(n)
+	if op != "" {
+		f.assertOperands = [2]interface{}{
+			it.eval(f, NodGetChild(n, goback.PNTR_ASSERT_LEFT)),
+			it.eval(f, NodGetChild(n, goback.PNTR_ASSERT_RIGHT)),
+		}
+	}
+	if it.eval(f, NodGetChild(n, NTR_ASSERT_COND)).(bool) {
+		return
+	}
+	if op == "" {
+		goback.P__assert_failed(n.Loc.StringDebug(), "")
+	}
+	goback.P__assert_failed(n.Loc.StringDebug(), op, f.assertOperands[0], f.assertOperands[1])
+}
+
+func (it *Interpreter) execLoop(f *frame, n Nod) control {
+	body := NodGetChild(n, NTR_LOOP_BODY)
+	count := -1
+	if loopArg := NodGetChildOrNil(n, NTR_LOOP_ARG); loopArg != nil {
+		count = toInt(it.eval(f, loopArg))
+	}
+	for i := 0; count < 0 || i < count; i++ {
+		ctl := it.execImperative(f, body)
+		if ctl == ctlBreak {
+			break
+		} else if ctl == ctlReturn {
+			return ctl
+		}
+	}
+	return ctlNext
+}
+
+func (it *Interpreter) execWhile(f *frame, n Nod) control {
+	for it.eval(f, NodGetChild(n, NTR_WHILE_COND)).(bool) {
+		ctl := it.execImperative(f, NodGetChild(n, NTR_WHILE_BODY))
+		if ctl == ctlBreak {
+			break
+		} else if ctl == ctlReturn {
+			return ctl
+		}
+	}
+	return ctlNext
+}
+
+func (it *Interpreter) execIf(f *frame, n Nod) control {
+	if it.eval(f, NodGetChild(n, NTR_IF_COND)).(bool) {
+		return it.execImperative(f, NodGetChild(n, NTR_IF_BODY_TRUE))
+	} else if elseBody := NodGetChildOrNil(n, NTR_IF_BODY_FALSE); elseBody != nil {
+		return it.execImperative(f, elseBody)
+	}
+	return ctlNext
+}
+
+func (it *Interpreter) execVarAssign(f *frame, n Nod) {
+	lvalue := NodGetChild(n, NTR_VAR_NAME)
+	varDef := NodGetChildOrNil(n, NTR_VARDEF)
+	val := it.eval(f, NodGetChild(n, NTR_VARASSIGN_VALUE))
+
+	nt := lvalue.NodeType
+	if nt == NT_IDENTIFIER || nt == NT_IDENTIFIER_RESOLVED || nt == NT_IDENTIFIER_FUNC_NOSCOPE {
+		if varDef == nil {
+			panic("assignment to unresolved variable " + lvalue.Data.(string))
+		}
+		it.setVar(f, varDef, lvalue.Data.(string), val)
+	} else if nt == NT_OBJFIELD_ACCESSOR {
+		obj := it.evalObject(f, NodGetChild(lvalue, NTR_RECEIVERCALL_BASE))
+		obj.setField(NodGetChild(lvalue, NTR_OBJFIELD_ACCESSOR_NAME).Data.(string), val)
+	} else if nt == NT_COLLECTION_INDEXOR {
+		coll := it.eval(f, NodGetChild(lvalue, NTR_RECEIVERCALL_BASE))
+		key := it.eval(f, NodGetChild(lvalue, NTR_RECEIVERCALL_ARG))
+		if set, ok := coll.(map[interface{}]bool); ok {
+			set[key] = val.(bool)
+		} else {
+			coll.(map[interface{}]interface{})[key] = val
+		}
+	} else if nt == goback.PNT_LIST_INDEXOR {
+		list := it.eval(f, NodGetChild(lvalue, NTR_RECEIVERCALL_BASE)).([]interface{})
+		i := toInt(it.eval(f, NodGetChild(lvalue, NTR_RECEIVERCALL_ARG)))
+		list[goback.P__index(len(list), i, lvalue.Loc.StringDebug())] = val
+	} else {
+		panic("can't assign to " + PrettyPrint(lvalue))
+	}
+}
+
+func (it *Interpreter) evalObject(f *frame, n Nod) *Object {
+	val := it.eval(f, n)
+	obj, ok := val.(*Object)
+	if !ok {
+		panic(fmt.Sprintf("not an object: %v", val))
+	}
+	if obj == nil {
+		panic("nil object dereference at " + n.Loc.StringDebug())
+	}
+	return obj
+}
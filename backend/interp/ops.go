@@ -0,0 +1,303 @@
+package interp
+
+import (
+	"math/big"
+	"pocket-lang/backend/goback"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"reflect"
+)
+
+func isBinaryInlineOpType(nt int) bool {
+	return nt == NT_ADDOP || nt == NT_GTOP || nt == NT_LTOP ||
+		nt == NT_GTEQOP || nt == NT_LTEQOP || nt == NT_EQOP ||
+		nt == NT_SUBOP || nt == NT_MULOP || nt == NT_DIVOP ||
+		nt == NT_OROP || nt == NT_ANDOP || nt == NT_MODOP ||
+		nt == NT_POWOP || nt == NT_XOROP || nt == NT_SHLOP || nt == NT_SHROP
+}
+
+func isComparisonOpType(nt int) bool {
+	return nt == NT_GTOP || nt == NT_LTOP || nt == NT_GTEQOP || nt == NT_LTEQOP || nt == NT_EQOP
+}
+
+var duckOps = map[int]func(a, b interface{}) interface{}{
+	NT_ADDOP:  func(a, b interface{}) interface{} { return goback.P__duck_add(a, b) },
+	NT_SUBOP:  func(a, b interface{}) interface{} { return goback.P__duck_sub(a, b) },
+	NT_MULOP:  func(a, b interface{}) interface{} { return goback.P__duck_mul(a, b) },
+	NT_DIVOP:  func(a, b interface{}) interface{} { return goback.P__duck_div(a, b) },
+	NT_MODOP:  func(a, b interface{}) interface{} { return goback.P__duck_mod(a, b) },
+	NT_GTOP:   func(a, b interface{}) interface{} { return goback.P__duck_gt(a, b) },
+	NT_GTEQOP: func(a, b interface{}) interface{} { return goback.P__duck_gteq(a, b) },
+	NT_LTOP:   func(a, b interface{}) interface{} { return goback.P__duck_lt(a, b) },
+	NT_LTEQOP: func(a, b interface{}) interface{} { return goback.P__duck_lteq(a, b) },
+	NT_EQOP:   func(a, b interface{}) interface{} { return goback.P__duck_defeq(a, b) },
+	NT_POWOP:  func(a, b interface{}) interface{} { return goback.P__duck_pow(a, b) },
+	NT_XOROP:  func(a, b interface{}) interface{} { return goback.P__duck_xor(a, b) },
+	NT_SHLOP:  func(a, b interface{}) interface{} { return goback.P__duck_shl(a, b) },
+	NT_SHROP:  func(a, b interface{}) interface{} { return goback.P__duck_shr(a, b) },
+}
+
+func (it *Interpreter) evalDuckOp(f *frame, n Nod) interface{} {
+	op, ok := duckOps[n.Data.(int)]
+	if !ok {
+		panic("unsupported duck op at " + n.Loc.StringDebug())
+	}
+	return op(it.eval(f, NodGetChild(n, NTR_BINOP_LEFT)), it.eval(f, NodGetChild(n, NTR_BINOP_RIGHT)))
+}
+
+var bigintOpSymbols = map[int]string{
+	NT_ADDOP: "+",
+	NT_SUBOP: "-",
+	NT_MULOP: "*",
+	NT_DIVOP: "/",
+	NT_MODOP: "%",
+	NT_POWOP: "**",
+	NT_XOROP: "^",
+	NT_OROP:  "|",
+	NT_ANDOP: "&",
+}
+
+func (it *Interpreter) evalBigintBinop(f *frame, n Nod) interface{} {
+	op := n.Data.(int)
+	a := goback.P__to_bigint(it.eval(f, NodGetChild(n, NTR_BINOP_LEFT)))
+	b := goback.P__to_bigint(it.eval(f, NodGetChild(n, NTR_BINOP_RIGHT)))
+	if isComparisonOpType(op) {
+		return compareOrdered(goback.P__bigint_cmp(a, b), 0, op)
+	}
+	return goback.P__bigint_arith(a, b, bigintOpSymbols[op])
+}
+
+func (it *Interpreter) evalBinaryInlineOp(f *frame, n Nod) interface{} {
+	nt := n.NodeType
+	a := it.eval(f, NodGetChild(n, NTR_BINOP_LEFT))
+
+	// && and || short circuit, & and | on ints don't
+	if ab, ok := a.(bool); ok {
+		if nt == NT_ANDOP && !ab {
+			return false
+		} else if nt == NT_OROP && ab {
+			return true
+		}
+	}
+	b := it.eval(f, NodGetChild(n, NTR_BINOP_RIGHT))
+
+	if _, ok := a.(bool); ok {
+		if nt == NT_ANDOP || nt == NT_OROP {
+			return b.(bool)
+		} else if nt == NT_EQOP {
+			return a == b
+		}
+	}
+	if as, ok := a.(string); ok {
+		return stringOp(as, b.(string), nt)
+	}
+	if ab, ok := a.(*big.Int); ok {
+		if isComparisonOpType(nt) {
+			return compareOrdered(ab.Cmp(goback.P__to_bigint(b)), 0, nt)
+		}
+		return goback.P__bigint_arith(ab, goback.P__to_bigint(b), bigintOpSymbols[nt])
+	}
+	if isNumber(a) && isNumber(b) {
+		return numericOp(a, b, nt)
+	}
+	if nt == NT_EQOP {
+		return a == b
+	}
+	panic("unsupported operands at " + n.Loc.StringDebug())
+}
+
+func stringOp(a, b string, nt int) interface{} {
+	if nt == NT_ADDOP {
+		return a + b
+	}
+	cmp := 0
+	if a < b {
+		cmp = -1
+	} else if a > b {
+		cmp = 1
+	}
+	return compareOrdered(cmp, 0, nt)
+}
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type float interface {
+	~float32 | ~float64
+}
+
+func compareOrdered[T integer | float](a, b T, nt int) bool {
+	switch nt {
+	case NT_GTOP:
+		return a > b
+	case NT_GTEQOP:
+		return a >= b
+	case NT_LTOP:
+		return a < b
+	case NT_LTEQOP:
+		return a <= b
+	case NT_EQOP:
+		return a == b
+	}
+	panic("not a comparison")
+}
+
+func integerOp[T integer](a, b T, nt int) interface{} {
+	switch nt {
+	case NT_ADDOP:
+		return a + b
+	case NT_SUBOP:
+		return a - b
+	case NT_MULOP:
+		return a * b
+	case NT_DIVOP:
+		return a / b
+	case NT_MODOP:
+		return a % b
+	case NT_POWOP:
+		return goback.P__ipow(a, b)
+	case NT_XOROP:
+		return a ^ b
+	case NT_ANDOP:
+		return a & b
+	case NT_OROP:
+		return a | b
+	}
+	return compareOrdered(a, b, nt)
+}
+
+func floatOp[T float](a, b T, nt int) interface{} {
+	switch nt {
+	case NT_ADDOP:
+		return a + b
+	case NT_SUBOP:
+		return a - b
+	case NT_MULOP:
+		return a * b
+	case NT_DIVOP:
+		return a / b
+	}
+	return compareOrdered(a, b, nt)
+}
+
+func shiftOp[T integer](a T, b int, nt int) interface{} {
+	if nt == NT_SHLOP {
+		return a << b
+	}
+	return a >> b
+}
+
+func numericOp(a, b interface{}, nt int) interface{} {
+	if nt == NT_SHLOP || nt == NT_SHROP {
+		// the result has the type of the shifted value
+		return dispatchShift(a, toInt(b), nt)
+	}
+	// both sides have the same static type after prepare, except for
+	// literals, which take on the type of the other side as in go
+	at, bt := reflect.TypeOf(a), reflect.TypeOf(b)
+	if at != bt {
+		if isDefaultNumberType(at) {
+			a = reflect.ValueOf(a).Convert(bt).Interface()
+		} else {
+			b = reflect.ValueOf(b).Convert(at).Interface()
+		}
+	}
+	switch av := a.(type) {
+	case int:
+		return integerOp(av, b.(int), nt)
+	case int8:
+		return integerOp(av, b.(int8), nt)
+	case int16:
+		return integerOp(av, b.(int16), nt)
+	case int32:
+		return integerOp(av, b.(int32), nt)
+	case int64:
+		return integerOp(av, b.(int64), nt)
+	case uint8:
+		return integerOp(av, b.(uint8), nt)
+	case uint16:
+		return integerOp(av, b.(uint16), nt)
+	case uint32:
+		return integerOp(av, b.(uint32), nt)
+	case uint64:
+		return integerOp(av, b.(uint64), nt)
+	case float32:
+		return floatOp(av, b.(float32), nt)
+	case float64:
+		return floatOp(av, b.(float64), nt)
+	}
+	panic("unsupported numeric type " + at.String())
+}
+
+func isDefaultNumberType(t reflect.Type) bool {
+	return t.Kind() == reflect.Int || t.Kind() == reflect.Float64
+}
+
+func dispatchShift(a interface{}, b int, nt int) interface{} {
+	switch av := a.(type) {
+	case int:
+		return shiftOp(av, b, nt)
+	case int8:
+		return shiftOp(av, b, nt)
+	case int16:
+		return shiftOp(av, b, nt)
+	case int32:
+		return shiftOp(av, b, nt)
+	case int64:
+		return shiftOp(av, b, nt)
+	case uint8:
+		return shiftOp(av, b, nt)
+	case uint16:
+		return shiftOp(av, b, nt)
+	case uint32:
+		return shiftOp(av, b, nt)
+	case uint64:
+		return shiftOp(av, b, nt)
+	}
+	panic("can only shift integers")
+}
+
+func negate[T integer | float](a T) T {
+	return -a
+}
+
+func (it *Interpreter) evalUnaryOp(f *frame, n Nod) interface{} {
+	argNod := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+	a := it.eval(f, argNod)
+	if n.NodeType == NT_NOTOP {
+		return !a.(bool)
+	} else if n.NodeType == NT_POSOP {
+		if !isNumber(a) {
+			panic("unsupported type")
+		}
+		return a
+	}
+	switch av := a.(type) {
+	case *big.Int:
+		return goback.P__bigint_neg(av)
+	case int:
+		return negate(av)
+	case int8:
+		return negate(av)
+	case int16:
+		return negate(av)
+	case int32:
+		return negate(av)
+	case int64:
+		return negate(av)
+	case uint8:
+		return negate(av)
+	case uint16:
+		return negate(av)
+	case uint32:
+		return negate(av)
+	case uint64:
+		return negate(av)
+	case float32:
+		return negate(av)
+	case float64:
+		return negate(av)
+	}
+	panic("unsupported type")
+}
@@ -0,0 +1,192 @@
+package interp
+
+import (
+	"fmt"
+	"math/big"
+	"pocket-lang/backend/goback"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"reflect"
+)
+
+// Object is an instance of a class or of a static zone
+type Object struct {
+	cls    Nod // CLASSDEF or CLASSDEFPARTIAL
+	names  []string
+	fields map[string]interface{}
+	types  map[string]Nod
+}
+
+func (it *Interpreter) newObject(cls Nod) *Object {
+	obj := &Object{
+		cls:    cls,
+		fields: map[string]interface{}{},
+		types:  map[string]Nod{},
+	}
+	for _, unit := range NodGetChildList(cls) {
+		if unit.NodeType == NT_CLASSFIELD {
+			name := NodGetChild(unit, NTR_VARDEF_NAME).Data.(string)
+			ty := NodGetChildOrNil(NodGetChild(unit, NTR_VARDEF), NTR_TYPE)
+			obj.names = append(obj.names, name)
+			obj.types[name] = ty
+			obj.fields[name] = zeroValue(ty)
+		}
+	}
+	// default values are set after all fields exist, like the default constructor
+	f := &frame{vars: map[Nod]interface{}{}, self: obj}
+	for _, unit := range NodGetChildList(cls) {
+		if unit.NodeType == NT_CLASSFIELD && NodHasChild(unit, NTR_VARASSIGN_VALUE) {
+			name := NodGetChild(unit, NTR_VARDEF_NAME).Data.(string)
+			obj.setField(name, it.eval(f, NodGetChild(unit, NTR_VARASSIGN_VALUE)))
+		}
+	}
+	return obj
+}
+
+func (o *Object) getField(name string) interface{} {
+	val, ok := o.fields[name]
+	if !ok {
+		panic("unknown field " + name)
+	}
+	return val
+}
+
+func (o *Object) setField(name string, val interface{}) {
+	ty, ok := o.types[name]
+	if !ok {
+		panic("unknown field " + name)
+	}
+	o.fields[name] = coerce(val, ty)
+}
+
+func (o *Object) getMethod(name string) Nod {
+	for _, unit := range NodGetChildList(o.cls) {
+		if unit.NodeType == NT_FUNCDEF && NodGetChild(unit, NTR_FUNCDEF_NAME).Data.(string) == name {
+			return unit
+		}
+	}
+	panic("unknown method " + name)
+}
+
+// prints like a pointer to a go struct, e.g. &{1 2}
+func (o *Object) Format(s fmt.State, verb rune) {
+	if o == nil {
+		fmt.Fprint(s, "<nil>")
+		return
+	}
+	fmt.Fprint(s, "&{")
+	for ndx, name := range o.names {
+		if ndx > 0 {
+			fmt.Fprint(s, " ")
+		}
+		if inner, ok := o.fields[name].(*Object); ok && inner != nil {
+			// go only expands the outermost struct pointer
+			fmt.Fprintf(s, "%p", inner)
+		} else {
+			fmt.Fprint(s, o.fields[name])
+		}
+	}
+	fmt.Fprint(s, "}")
+}
+
+var goTypes = map[int]reflect.Type{
+	TY_INT:    reflect.TypeOf(int(0)),
+	TY_I8:     reflect.TypeOf(int8(0)),
+	TY_I16:    reflect.TypeOf(int16(0)),
+	TY_I32:    reflect.TypeOf(int32(0)),
+	TY_I64:    reflect.TypeOf(int64(0)),
+	TY_U8:     reflect.TypeOf(uint8(0)),
+	TY_U16:    reflect.TypeOf(uint16(0)),
+	TY_U32:    reflect.TypeOf(uint32(0)),
+	TY_U64:    reflect.TypeOf(uint64(0)),
+	TY_F32:    reflect.TypeOf(float32(0)),
+	TY_FLOAT:  reflect.TypeOf(float64(0)),
+	TY_BOOL:   reflect.TypeOf(false),
+	TY_STRING: reflect.TypeOf(""),
+}
+
+// the value a variable of type ty holds before its first assignment
+func zeroValue(ty Nod) interface{} {
+	if ty == nil {
+		return nil
+	}
+	if ty.NodeType == NT_TYPEBASE {
+		bt := ty.Data.(int)
+		if goType, ok := goTypes[bt]; ok {
+			return reflect.Zero(goType).Interface()
+		}
+		switch bt {
+		case TY_LIST:
+			return []interface{}(nil)
+		case TY_SET:
+			return map[interface{}]bool(nil)
+		case TY_MAP:
+			return map[interface{}]interface{}(nil)
+		case TY_BIGINT:
+			return (*big.Int)(nil)
+		}
+	} else if ty.NodeType == NT_TYPECALL {
+		return zeroValue(NodGetChild(ty, NTR_RECEIVERCALL_BASE))
+	} else if ty.NodeType == DYPE_UNION && NodHasChild(ty, goback.PNTR_TYPE_INDEXABLE) {
+		return []interface{}(nil)
+	} else if ty.NodeType == NT_CLASSDEF {
+		return (*Object)(nil)
+	}
+	return nil
+}
+
+// converts numbers to the declared numeric type, other values pass through
+func coerce(val interface{}, ty Nod) interface{} {
+	if val == nil || ty == nil || ty.NodeType != NT_TYPEBASE {
+		return val
+	}
+	if bt := ty.Data.(int); IsNumericType(bt) && isNumber(val) {
+		return Convert(val, bt)
+	}
+	return val
+}
+
+func isNumber(val interface{}) bool {
+	switch val.(type) {
+	case *big.Int:
+		return true
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Convert converts a number with go conversion semantics, e.g. int8(300) wraps
+func Convert(val interface{}, toType int) interface{} {
+	if toType == TY_BIGINT {
+		return goback.P__to_bigint(val)
+	}
+	goType := goTypes[toType]
+	if b, ok := val.(*big.Int); ok {
+		if IsFloatType(toType) {
+			val = goback.P__bigint_to_float(b)
+		} else {
+			val = b.Int64()
+		}
+	}
+	return reflect.ValueOf(val).Convert(goType).Interface()
+}
+
+func toInt(val interface{}) int {
+	return Convert(val, TY_INT).(int)
+}
+
+func toList(val interface{}) []interface{} {
+	if ints, ok := val.([]int); ok {
+		rv := make([]interface{}, len(ints))
+		for ndx, i := range ints {
+			rv[ndx] = i
+		}
+		return rv
+	}
+	return val.([]interface{})
+}
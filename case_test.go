@@ -1,13 +1,115 @@
 package main
 
 import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
 	"pocket-lang/pktest"
+	"strings"
 	"testing"
 )
 
+var interpret = flag.Bool("interp", false, "run the cases with the interpreter instead of the go backend")
+
+// each case of each file in testcases is a subtest, e.g.
+// go test -run 'TestRunAll/ops.pkt/unary_minus' -interp
 func TestRunAll(t *testing.T) {
-	cases := pktest.ListDirFiles("./testcases")
-	for _, cse := range cases {
-		pktest.RunCase(cse)
+	paths, _ := filepath.Glob("testcases/*.pkt")
+	if len(paths) == 0 {
+		t.Fatal("no cases found")
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) { runCaseFile(t, path, *interpret) })
+	}
+}
+
+// only the run -interp picks updates the expected outputs
+func runCaseFile(t *testing.T, path string, interpretCases bool) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := pktest.ParseCases(string(dat))
+	results := make([]pktest.Result, len(cases))
+	t.Cleanup(func() {
+		// the cases are done by now
+		if !*update || interpretCases != *interpret || !t.Failed() {
+			return
+		}
+		if err := ioutil.WriteFile(path, []byte(pktest.UpdateCases(string(dat), cases, results)), 0644); err != nil {
+			t.Error(err)
+		}
+	})
+	for ndx, c := range cases {
+		ndx, c := ndx, c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			results[ndx] = c.Run(interpretCases)
+			if msg := c.Check(results[ndx]); msg != "" {
+				t.Error(msg)
+			}
+		})
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	src := "double func(a int) int: a * 2\n\n" +
+		"test 'doubles'\n    assert double(2) = 4\n\n" +
+		"test 'compares'\n    x : 5\n    assert double(x) < 8\n\n" +
+		"test 'checks'\n    assert 1 > 2 | 2 > 3\n\n" +
+		"test 'indexes'\n    l : ['a']\n    print(l[0] + 'b')\n    assert l[0] + 'b' = 'ac'\n"
+	want := "PASS doubles (-ms)\n" +
+		"FAIL compares (-ms)\n    assertion failed at line 8 col 11: left < right\n        left:  10\n        right: 8\n" +
+		"FAIL checks (-ms)\n    assertion failed at line 11 col 11\n" +
+		"ab\nFAIL indexes (-ms)\n    assertion failed at line 16 col 11: left = right\n        left:  'ab'\n        right: 'ac'\n" +
+		"1 passed, 3 failed"
+	for _, interpret := range []bool{true, false} {
+		r := pktest.RunTests(src, interpret)
+		if r.Err != "" {
+			t.Fatal(r.Err)
+		}
+		if got := pktest.SanitizeTestReport(r.Output); got != want || r.ExitCode != 1 {
+			t.Errorf("interpret %v: exit code %d, report differs:\n%s", interpret, r.ExitCode, pktest.UnifiedDiff(want, got))
+		}
+	}
+}
+
+func TestParseCases(t *testing.T) {
+	src := "# adds\nmain func\n    print(1 + 1)\n>>>2>>>\n" +
+		"main func\n    print(x)\n>>>error: unknown variable 'x'>>>\n" +
+		"main func\n    print(1)\n>>>\nexit: 3\nstdin: a\nstdin: b\n1\n>>>\n"
+	cases := pktest.ParseCases(src)
+	if len(cases) != 3 {
+		t.Fatal("expected 3 cases, got", len(cases))
+	}
+	if cases[0].Name != "adds" || cases[0].Want != "2" {
+		t.Error("bad first case", cases[0].Name, cases[0].Want)
+	}
+	if cases[1].Name != "case 2" || cases[1].WantErr != "unknown variable 'x'" {
+		t.Error("bad second case", cases[1].Name, cases[1].WantErr)
+	}
+	if c := cases[2]; c.WantExit != 3 || c.Stdin != "a\nb\n" || c.Want != "1" {
+		t.Errorf("bad third case %+v", c)
+	}
+
+	if msg := cases[1].Check(pktest.Result{Err: "At line 2: unknown variable 'x'"}); msg != "" {
+		t.Error("error should match:", msg)
+	}
+	if msg := cases[2].Check(pktest.Result{Output: "1\n"}); !strings.Contains(msg, "exit code 0, expected 3") {
+		t.Error("exit code should mismatch:", msg)
+	}
+
+	results := []pktest.Result{{Output: "2\n"}, {Err: "unknown variable 'x'"}, {Output: "7\n", ExitCode: 1}}
+	want := strings.Replace(src, "exit: 3\nstdin: a\nstdin: b\n1", "exit: 1\nstdin: a\nstdin: b\n7", 1)
+	if got := pktest.UpdateCases(src, cases, results); got != want {
+		t.Errorf("expected update\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	want := "--- want\n+++ got\n@@ -2,5 +2,5 @@\n b\n c\n d\n-e\n+E\n f\n"
+	if got := pktest.UnifiedDiff("a\nb\nc\nd\ne\nf", "a\nb\nc\nd\nE\nf"); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
 	}
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	"testing"
+)
+
+func checkSource(src string) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	xform.XformOldSolve(pocket.Parse(pocket.Tokenize(src)))
+	return ""
+}
+
+func TestCheckCallsAndReturns(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"main func\n    sub[13, 5]\n\nsub func(x, y)\n    print x\n", ""},
+		{"main func\n    sub{y: 5, x: 13}\n\nsub func(x, y)\n    print x\n", ""},
+		{"main func\n    sub [1, 2]\n\nsub func(l)\n    print l\n", ""},
+		{"main func\n    sub 1\n\nsub func(x float)\n    print x\n", ""},
+		{"main func\n    print sub(1)\n\nsub func(x int) int\n    if x > 0\n        return 1\n    else\n        return 2\n", ""},
+		{"main func\n    print sub(1)\n\nsub func(x int) int\n    loop\n        return x\n", ""},
+
+		{"main func\n    sub[13, 5, 1]\n\nsub func(x, y)\n    print x\n",
+			"sub takes 2 arguments but is given 3 arguments, at line 2 col 8"},
+		{"main func\n    sub 13\n\nsub func(x, y)\n    print x\n",
+			"sub takes 2 arguments but is given 1 argument, at line 2 col 8"},
+		{"main func\n    sub{x: 13}\n\nsub func(x, y)\n    print x\n",
+			"sub is missing 'y', at line 2 col 8"},
+		{"main func\n    sub 'a'\n\nsub func(x int)\n    print x\n",
+			"sub takes int for 'x' but is given string, at line 2 col 12"},
+		{"main func\n    sub 300\n\nsub func(x u8)\n    print x\n",
+			"sub takes u8 for 'x' but is given int, at line 2 col 12"},
+		{"main func\n    print sub(1)\n\nsub func(x int) string\n    return x\n",
+			"sub is declared string but returns int, at line 5 col 11"},
+		{"main func\n    print sub(1)\n\nsub func(x int) int\n    if x > 0\n        return 1\n",
+			"sub doesn't return a value on every path, at line 4 col 9"},
+		{"main func\n    print sub(1)\n\nsub func(x int)\n    loop\n        if x > 0\n            break\n        return x\n",
+			"sub doesn't return a value on every path, at line 4 col 9"},
+		{"main func\n    sub(1)\n\nsub func(x int) void\n    return x\n",
+			"sub is declared void but returns a value, at line 5 col 11"},
+		{"main func\n    print sub(1)\n\nsub func(x int)\n    if x > 0\n        return 1\n    return 'a'\n",
+			"sub returns string here but int at line 6 col 17, at line 7 col 11"},
+	}
+	for _, c := range cases {
+		if got := checkSource(c.src); got != c.want {
+			t.Errorf("for\n%s\nexpected %q, got %q", c.src, c.want, got)
+		}
+	}
+}
+
+func TestCheckFlow(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogOutput(buf)
+	defer SetLogOutput(nil)
+	defer SetLogLevels("")
+	if err := SetLogLevels("check:warn"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		src      string
+		want     string
+		warnings string
+	}{
+		{"main func\n    y : 1\n    if y > 0\n        x : 1\n    else\n        x : 2\n    print x\n", "", ""},
+		{"main func\n    y : 1\n    loop\n        x : y\n        break\n    print x\n", "", ""},
+		{"main func\n    for i in [1, 2]\n        print i\n", "", ""},
+
+		{"main func\n    y : 1\n    if y > 0\n        x : 1\n    print x\n",
+			"'x' might be used before it's assigned, at line 5 col 12", ""},
+		{"main func\n    y : 1\n    while y < 3\n        x : y\n        y +: 1\n    print x\n",
+			"'x' might be used before it's assigned, at line 6 col 12", ""},
+		{"main func\n    print sub(1)\n\nsub func(x int) int\n    return x\n    print 3\n",
+			"", "[check:warn] unreachable code, at line 6 col 10\n"},
+		{"main func\n    loop\n        print 1\n        break\n        print 2\n    print 3\n",
+			"", "[check:warn] unreachable code, at line 5 col 14\n"},
+		{"main func\n    y : 1\n    print 3\n\nsub func(x int)\n    print 4\n",
+			"", "[check:warn] 'y' is assigned but never used, at line 2 col 6\n" +
+				"[check:warn] parameter 'x' is never used, at line 5 col 9\n"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		if got := checkSource(c.src); got != c.want {
+			t.Errorf("for\n%s\nexpected %q, got %q", c.src, c.want, got)
+		}
+		if c.want == "" && buf.String() != c.warnings {
+			t.Errorf("for\n%s\nexpected the warnings %q, got %q", c.src, c.warnings, buf.String())
+		}
+	}
+}
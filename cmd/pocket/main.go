@@ -0,0 +1,313 @@
+package main
+
+// pocket run <file>   runs a program with the interpreter
+// pocket repl         starts an interactive session
+// pocket lsp          serves the language server protocol over stdio
+// pocket fmt [--check] [files]
+//                     rewrites files in canonical layout, or stdin to stdout
+// pocket test [--go] [files or dirs]
+//                     runs the test blocks of .pk files, with the interpreter
+//                     or, with --go, through a generated go test main
+// pocket debug <file> steps through a program, type help at the prompt
+// pocket dump [--stage=parse|prepare|desugar|solve|goprepare] [--format=dot|json]
+//             [--func=name] <file>
+//                     prints the ASG after a compiler stage, for graphviz or as json
+// pocket explain-type <file>:<line>:<col>
+//                     shows which solver rules gave the expression there its type
+//
+// Any command takes --log=<channel>:<level>,... to show the compiler's
+// diagnostics on stderr, e.g. --log=solve:debug,parse:trace, or --log=debug
+// for every channel.
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"pocket-lang/backend/interp"
+	"pocket-lang/debugger"
+	"pocket-lang/dump"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	"pocket-lang/lsp"
+	. "pocket-lang/parse"
+	"pocket-lang/pktest"
+	"pocket-lang/repl"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	os.Args = takeLogFlag(os.Args)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "run":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		runFile(os.Args[2])
+	case "repl":
+		repl.Run(os.Stdin, os.Stdout)
+	case "lsp":
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "fmt":
+		formatFiles(os.Args[2:])
+	case "test":
+		testFiles(os.Args[2:])
+	case "debug":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		debugFile(os.Args[2])
+	case "dump":
+		dumpFile(os.Args[2:])
+	case "explain-type":
+		if len(os.Args) != 3 {
+			usage()
+		}
+		explainType(os.Args[2])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: pocket [--log=spec] run <file> | pocket repl | pocket lsp | pocket fmt [--check] [files] | pocket test [--go] [files] | pocket debug <file> | pocket dump [--stage=s] [--format=dot|json] [--func=name] <file> | pocket explain-type <file>:<line>:<col>")
+	os.Exit(2)
+}
+
+// sets up logging from a --log flag anywhere in args, and returns the rest
+func takeLogFlag(args []string) []string {
+	rest := []string{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--log=") {
+			if err := SetLogLevels(strings.TrimPrefix(arg, "--log=")); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return rest
+}
+
+// parse, check and type errors panic with what to tell the user, rather
+// than a go stack trace
+func exitOnPanic() {
+	if r := recover(); r != nil {
+		fmt.Fprintln(os.Stderr, r)
+		os.Exit(1)
+	}
+}
+
+// solves src, telling the user on stderr what the checks warned about,
+// whatever the log levels
+func compile(src string) Nod {
+	code, warnings := xform.XformWithWarnings(pocket.Parse(pocket.Tokenize(src)))
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "warning: "+w.String())
+	}
+	return code
+}
+
+func runFile(path string) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer exitOnPanic()
+	code := compile(string(dat))
+
+	interp.Run(code, os.Stdout)
+}
+
+func debugFile(path string) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer exitOnPanic()
+	code := compile(string(dat))
+
+	debugger.Run(code, string(dat), os.Stdin, os.Stdout)
+}
+
+func dumpFile(args []string) {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	stage := flags.String("stage", "solve", "the stage to dump the ASG after: "+strings.Join(dump.Stages, ", "))
+	format := flags.String("format", "dot", "dot or json")
+	funcName := flags.String("func", "", "only dump the func or method of this name")
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "dot" && *format != "json") {
+		usage()
+	}
+	dat, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer exitOnPanic()
+	graph := dump.NewGraph(dump.Compile(string(dat), *stage), *funcName)
+	if *format == "json" {
+		fmt.Print(graph.JSON())
+	} else {
+		fmt.Print(graph.Dot())
+	}
+}
+
+// explains the type of the outermost expression starting at file:line:col,
+// and the type error if the program has one
+func explainType(arg string) {
+	parts := strings.Split(arg, ":")
+	if len(parts) < 3 {
+		usage()
+	}
+	path := strings.Join(parts[:len(parts)-2], ":")
+	line, err1 := strconv.Atoi(parts[len(parts)-2])
+	col, err2 := strconv.Atoi(parts[len(parts)-1])
+	if err1 != nil || err2 != nil {
+		usage()
+	}
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	defer exitOnPanic()
+	prov, err := xform.XformWithProvenance(pocket.Parse(pocket.Tokenize(string(dat))))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	nods := prov.NodesAt(line, col)
+	if len(nods) == 0 {
+		fmt.Fprintf(os.Stderr, "nothing with a type at %d:%d\n", line, col)
+		os.Exit(1)
+	}
+	fmt.Print(prov.Explain(nods[0]))
+}
+
+// with --check, lists the files that aren't formatted instead of rewriting
+// them, and fails if there are any
+func formatFiles(args []string) {
+	check := len(args) > 0 && args[0] == "--check"
+	if check {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		dat, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		out, err := format("<stdin>", string(dat))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if check && out != string(dat) {
+			fmt.Println("<stdin>")
+			os.Exit(1)
+		} else if !check {
+			fmt.Print(out)
+		}
+		return
+	}
+
+	failed := false
+	for _, path := range args {
+		dat, err := ioutil.ReadFile(path)
+		if err == nil {
+			var out string
+			if out, err = format(path, string(dat)); err == nil && out != string(dat) {
+				if check {
+					fmt.Println(path)
+					failed = true
+				} else {
+					err = ioutil.WriteFile(path, []byte(out), 0644)
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// test case files are formatted a source section at a time
+func format(path string, src string) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", path, r)
+		}
+	}()
+	if strings.HasSuffix(path, ".pkt") {
+		return pktest.FormatCase(src), nil
+	}
+	return pocket.Format(src), nil
+}
+
+// reports on each file's tests, and fails if any of them did. Directories
+// are searched for .pk files, the current one if none are given.
+func testFiles(args []string) {
+	interpret := !(len(args) > 0 && args[0] == "--go")
+	if !interpret {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	failed := false
+	paths := []string{}
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err == nil && (path == arg || strings.HasSuffix(path, ".pk")) && !info.IsDir() {
+				paths = append(paths, path)
+			}
+			return err
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	for _, path := range paths {
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		result := pktest.RunTests(string(dat), interpret)
+		if result.Err != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, result.Err)
+			failed = true
+		} else if result.Output != "" {
+			fmt.Println(path)
+			fmt.Print(result.Output)
+			fmt.Fprint(os.Stderr, result.Stderr)
+		}
+		failed = failed || result.ExitCode != 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"pocket-lang/debugger"
+	"pocket-lang/frontend/pocket"
+	"pocket-lang/frontend/pocket/xform"
+	"pocket-lang/pktest"
+	"strings"
+	"testing"
+)
+
+func TestDebugSession(t *testing.T) {
+	src := "Point class\n    x int\n    y int\n    sum func int\n        return x + y\n\n" +
+		"double func(a int) int\n    b : a * 2\n    return b\n\n" +
+		"main func\n    p : Point{x: 1, y: 2}\n    n : double(p.x)\n    for i in 0..1\n        print(n + i)\n    print(p.sum())\n"
+	commands := "next\nstep\nstep\nwhere\nlocals\nfinish\np p\np p.y\np p.z\np b\np i\n" +
+		"break 15\nb 5\nc\np i\ndelete 15\nc\nlocals\nbt\nc\n"
+	want := "stopped at line 12 in main: p : Point{x: 1, y: 2}\n" +
+		"stopped at line 13 in main: n : double(p.x)\n" +
+		"stopped at line 8 in double: b : a * 2\n" +
+		"stopped at line 9 in double: return b\n" +
+		"  double\n  main\n" +
+		"a = 1\nb = 2\n" +
+		"stopped at line 15 in main: print(n + i)\n" +
+		"p = Point{x: 1, y: 2}\np.y = 2\nPoint has no field z\nno variable b\ni = 0\n" +
+		"breakpoint at line 15: print(n + i)\nbreakpoint at line 5: return x + y\n" +
+		"2\nstopped at line 15 in main: print(n + i)\ni = 1\n" +
+		"3\nstopped at line 5 in sum: return x + y\nx = 1\ny = 2\n  sum\n  main\n" +
+		"3\nprogram exited\n"
+
+	code := xform.Xform(pocket.Parse(pocket.Tokenize(src)))
+	out := &bytes.Buffer{}
+	debugger.Run(code, src, strings.NewReader(commands), out)
+	if got := strings.ReplaceAll(out.String(), "(pdb) ", ""); got != want {
+		t.Errorf("session differs:\n%s", pktest.UnifiedDiff(want, got))
+	}
+}
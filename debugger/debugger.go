@@ -0,0 +1,259 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"pocket-lang/backend/interp"
+	. "pocket-lang/parse"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Session steps through a program on the interpreter. The interpreter calls
+// back before each statement, the session decides whether to stop there and
+// if it does, reads commands until one resumes the program. Only pocket names
+// are shown: funcs, variables and fields as the source spells them.
+type Session struct {
+	lines  []string // the source, to show the line stopped at
+	in     *bufio.Scanner
+	out    io.Writer
+	breaks map[int]bool
+
+	mode     int
+	depth    int // of the stack when stepping started
+	lastStmt Nod
+	lastLine int
+}
+
+// how the program resumed
+const (
+	modeStep     = iota // stop at the next line, in any func
+	modeNext            // stop at the next line of this func or its callers
+	modeFinish          // stop once this func returned
+	modeContinue        // stop at a breakpoint
+)
+
+// the panic that ends the program when the user quits
+type quit struct{}
+
+func Run(code Nod, src string, in io.Reader, out io.Writer) {
+	s := &Session{
+		lines:  strings.Split(src, "\n"),
+		in:     bufio.NewScanner(in),
+		out:    out,
+		breaks: map[int]bool{},
+		mode:   modeStep,
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(quit); ok {
+				return
+			}
+			fmt.Fprintln(out, "program panicked:", r)
+			return
+		}
+		fmt.Fprintln(out, "program exited")
+	}()
+	interp.RunHooked(code, out, s.hook)
+}
+
+func (s *Session) hook(stmt Nod, stack []*interp.StackFrame) {
+	line := stmt.Loc.Line + 1 // locations count from 0
+	if !s.shouldStop(stmt, line, len(stack)) {
+		return
+	}
+	s.lastStmt, s.lastLine = stmt, line
+	fmt.Fprintf(s.out, "stopped at line %d in %s: %s\n", line, stack[len(stack)-1].FuncName(), s.sourceLine(line))
+	for {
+		fmt.Fprint(s.out, "(pdb) ")
+		if !s.in.Scan() {
+			fmt.Fprintln(s.out)
+			panic(quit{})
+		}
+		if s.command(strings.Fields(s.in.Text()), stack) {
+			s.depth = len(stack)
+			return
+		}
+	}
+}
+
+func (s *Session) shouldStop(stmt Nod, line int, depth int) bool {
+	// a statement can start several times on a line, stop once per line
+	// unless it's run again, like a loop body
+	moved := line != s.lastLine || depth != s.depth || stmt == s.lastStmt
+	if s.breaks[line] && moved {
+		return true
+	} else if s.mode == modeStep {
+		return moved
+	} else if s.mode == modeNext {
+		return moved && depth <= s.depth
+	} else if s.mode == modeFinish {
+		return depth < s.depth
+	}
+	return false
+}
+
+// runs a command, returns whether it resumed the program
+func (s *Session) command(args []string, stack []*interp.StackFrame) bool {
+	if len(args) == 0 {
+		return false
+	}
+	top := stack[len(stack)-1]
+	switch args[0] {
+	case "s", "step":
+		s.mode = modeStep
+		return true
+	case "n", "next":
+		s.mode = modeNext
+		return true
+	case "f", "finish":
+		s.mode = modeFinish
+		return true
+	case "c", "continue":
+		s.mode = modeContinue
+		return true
+	case "b", "break":
+		if line, ok := s.lineArg(args); ok {
+			s.breaks[line] = true
+			fmt.Fprintf(s.out, "breakpoint at line %d: %s\n", line, s.sourceLine(line))
+		} else if len(args) == 1 {
+			s.listBreaks()
+		}
+	case "d", "delete":
+		if line, ok := s.lineArg(args); ok {
+			delete(s.breaks, line)
+		}
+	case "l", "locals":
+		vars := top.Locals()
+		if self := top.Self(); self != nil {
+			vars = append(vars, self.Fields()...)
+		}
+		for _, v := range vars {
+			fmt.Fprintf(s.out, "%s = %s\n", v.Name, FormatValue(v.Value))
+		}
+	case "p", "print":
+		if len(args) != 2 {
+			fmt.Fprintln(s.out, "usage: print <name>[.field...]")
+		} else if val, err := lookupPath(top, args[1]); err != "" {
+			fmt.Fprintln(s.out, err)
+		} else {
+			fmt.Fprintf(s.out, "%s = %s\n", args[1], FormatValue(val))
+		}
+	case "w", "where", "bt":
+		for ndx := len(stack) - 1; ndx >= 0; ndx-- {
+			fmt.Fprintf(s.out, "  %s\n", stack[ndx].FuncName())
+		}
+	case "list":
+		for line := s.lastLine - 2; line <= s.lastLine+2; line++ {
+			if line >= 1 && line <= len(s.lines) {
+				marker := "  "
+				if line == s.lastLine {
+					marker = "->"
+				}
+				fmt.Fprintf(s.out, "%s %3d  %s\n", marker, line, strings.TrimRight(s.lines[line-1], "\r"))
+			}
+		}
+	case "q", "quit":
+		panic(quit{})
+	case "h", "help":
+		fmt.Fprint(s.out, help)
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, try help\n", args[0])
+	}
+	return false
+}
+
+const help = `step, s            run to the next line
+next, n            run to the next line of this func
+finish, f          run until this func returns
+continue, c        run to the next breakpoint
+break, b [line]    stop at a line, or list the breakpoints
+delete, d <line>   remove a breakpoint
+locals, l          show the variables of this func, and its object's fields
+print, p <name>    show a variable, or a field of one, e.g. p pt.x
+where, bt          show the funcs being run
+list               show the source around this line
+quit, q            end the program
+`
+
+func (s *Session) lineArg(args []string) (int, bool) {
+	if len(args) != 2 {
+		return 0, false
+	}
+	line, err := strconv.Atoi(args[1])
+	if err != nil || line < 1 || line > len(s.lines) {
+		fmt.Fprintln(s.out, "no line", args[1])
+		return 0, false
+	}
+	return line, true
+}
+
+func (s *Session) listBreaks() {
+	lines := []int{}
+	for line := range s.breaks {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		fmt.Fprintf(s.out, "breakpoint at line %d: %s\n", line, s.sourceLine(line))
+	}
+}
+
+func (s *Session) sourceLine(line int) string {
+	if line < 1 || line > len(s.lines) {
+		return ""
+	}
+	return strings.TrimSpace(s.lines[line-1])
+}
+
+// finds a variable and follows the fields after it, e.g. pt.x
+func lookupPath(frame *interp.StackFrame, path string) (interface{}, string) {
+	names := strings.Split(path, ".")
+	val, ok := frame.Lookup(names[0])
+	if !ok {
+		return nil, "no variable " + names[0]
+	}
+	for _, name := range names[1:] {
+		obj, ok := val.(*interp.Object)
+		if !ok || obj == nil {
+			return nil, fmt.Sprintf("%s isn't an object", path[:strings.Index(path, "."+name)])
+		}
+		found := false
+		for _, field := range obj.Fields() {
+			if field.Name == name {
+				val, found = field.Value, true
+			}
+		}
+		if !found {
+			return nil, fmt.Sprintf("%s has no field %s", obj.ClassName(), name)
+		}
+	}
+	return val, ""
+}
+
+// shows a value as pocket would write it, objects field by field
+func FormatValue(val interface{}) string {
+	if obj, ok := val.(*interp.Object); ok {
+		if obj == nil {
+			return "nil"
+		}
+		fields := []string{}
+		for _, field := range obj.Fields() {
+			fields = append(fields, field.Name+": "+FormatValue(field.Value))
+		}
+		return obj.ClassName() + "{" + strings.Join(fields, ", ") + "}"
+	} else if str, ok := val.(string); ok {
+		return "'" + str + "'"
+	} else if list, ok := val.([]interface{}); ok {
+		elems := []string{}
+		for _, elem := range list {
+			elems = append(elems, FormatValue(elem))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	} else if _, ok := val.(*interp.Closure); ok {
+		return "func"
+	}
+	return fmt.Sprint(val)
+}
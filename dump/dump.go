@@ -0,0 +1,233 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"pocket-lang/backend/goback"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	xformbase "pocket-lang/xform"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the stages the ASG can be dumped after, in the order the compiler runs them
+var Stages = append(append([]string{"parse"}, xform.Stages...), "goprepare")
+
+// Compile parses src and runs the compiler up to and including stage
+func Compile(src string, stage string) Nod {
+	root := pocket.Parse(pocket.Tokenize(src))
+	if stage == "parse" {
+		return root
+	} else if stage == "goprepare" {
+		xform.Xform(root)
+		preparer := &goback.Preparer{Xformer: &xformbase.Xformer{}}
+		preparer.Prepare(root)
+		return root
+	}
+	return xform.XformUntil(root, stage)
+}
+
+// Graph is the ASG flattened into numbered nodes, so that back-links and
+// cycles, like a variable's NTR_VARDEF, become plain references
+type Graph struct {
+	Root  int          `json:"root"`
+	Nodes []*GraphNode `json:"nodes"`
+
+	ids map[Nod]int
+}
+
+type GraphNode struct {
+	ID    int         `json:"id"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data,omitempty"`
+	Line  int         `json:"line,omitempty"`
+	Col   int         `json:"col,omitempty"`
+	Edges []GraphEdge `json:"edges,omitempty"`
+	// outside the func the graph was cut down to, so its edges aren't followed
+	Stub bool `json:"stub,omitempty"`
+}
+
+type GraphEdge struct {
+	Label string `json:"label"` // the NTR_ name, or [n] for list elements
+	To    int    `json:"to"`
+}
+
+// the nodes a func's subgraph doesn't follow edges out of, since they lead
+// to the rest of the program
+var stubTypes = map[int]bool{
+	NT_TOPLEVEL:        true,
+	NT_FUNCDEF:         true,
+	NT_CLASSDEF:        true,
+	NT_CLASSDEFPARTIAL: true,
+	NT_NAMESPACE:       true,
+}
+
+// NewGraph collects the nodes reachable from root. With a funcName, only the
+// func or method of that name and what it reaches without going through
+// other funcs, classes or namespaces.
+func NewGraph(root Nod, funcName string) *Graph {
+	g := &Graph{ids: map[Nod]int{}}
+	stubs := false
+	if funcName != "" {
+		root = findFunc(root, funcName)
+		stubs = true
+	}
+	g.Root = g.add(root, stubs)
+	return g
+}
+
+func findFunc(root Nod, name string) Nod {
+	for _, unit := range NodGetChildList(root) {
+		if unit.NodeType == NT_FUNCDEF && funcDefName(unit) == name {
+			return unit
+		}
+		if unit.NodeType == NT_CLASSDEF || unit.NodeType == NT_CLASSDEFPARTIAL {
+			for _, member := range NodGetChildList(unit) {
+				if member.NodeType == NT_FUNCDEF && funcDefName(member) == name {
+					return member
+				}
+			}
+		}
+	}
+	panic("no func named " + name)
+}
+
+func funcDefName(funcDef Nod) string {
+	if name := NodGetChildOrNil(funcDef, NTR_FUNCDEF_NAME); name != nil {
+		if s, ok := name.Data.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// what a stub is labeled with, the name of the func or class it stands for
+func stubName(n Nod) interface{} {
+	if n.NodeType == NT_FUNCDEF {
+		return funcDefName(n)
+	} else if name := NodGetChildOrNil(n, NTR_CLASSDEF_NAME); name != nil {
+		return name.Data
+	}
+	return nil
+}
+
+func (g *Graph) add(n Nod, stubs bool) int {
+	if id, ok := g.ids[n]; ok {
+		return id
+	}
+	gn := &GraphNode{
+		ID:   len(g.Nodes),
+		Type: nodeTypeName(n.NodeType),
+	}
+	g.ids[n] = gn.ID
+	g.Nodes = append(g.Nodes, gn)
+	if n.Loc != nil {
+		gn.Line, gn.Col = n.Loc.Line+1, n.Loc.Column+1
+	}
+	if stubs && stubTypes[n.NodeType] && gn.ID != 0 {
+		gn.Stub = true
+		gn.Data = stubName(n)
+		return gn.ID
+	}
+
+	if data, ok := n.Data.(Nod); ok && data != nil {
+		// dypes and knowledge keep a node in .Data
+		gn.Data = map[string]int{"node": g.add(data, stubs)}
+	} else {
+		gn.Data = nodeData(n)
+	}
+	for _, edge := range sortedEdges(n) {
+		gn.Edges = append(gn.Edges, GraphEdge{nodeTypeName(edge.EdgeType), g.add(edge.Out, stubs)})
+	}
+	return gn.ID
+}
+
+// a node's out edges ordered by type, so dumps of the same tree are the same
+func sortedEdges(n Nod) []*Edge {
+	edges := []*Edge{}
+	for _, edge := range n.Out {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].EdgeType < edges[j].EdgeType })
+	return edges
+}
+
+func nodeTypeName(nt int) string {
+	if name, ok := goback.PreparedNodeTypeNames[nt]; ok {
+		return name
+	}
+	return NodeTypeName(nt)
+}
+
+// the .Data of a node that isn't another node, with types by their names
+func nodeData(n Nod) interface{} {
+	switch data := n.Data.(type) {
+	case nil:
+		return nil
+	case string, bool:
+		return data
+	case int:
+		if n.NodeType == NT_TYPE || n.NodeType == NT_TYPEBASE {
+			return TypeName(data)
+		}
+		return data
+	}
+	return fmt.Sprint(n.Data)
+}
+
+func (g *Graph) JSON() string {
+	dat, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return string(dat) + "\n"
+}
+
+// Dot renders the graph for graphviz, e.g. pocket dump prog.pk | dot -Tsvg
+func (g *Graph) Dot() string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("digraph asg {\n")
+	buf.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	buf.WriteString("\tedge [fontname=\"monospace\", fontsize=10];\n")
+	for _, gn := range g.Nodes {
+		label := gn.Type
+		ref, isRef := gn.Data.(map[string]int)
+		if gn.Data != nil && !isRef {
+			label += "\n" + dataLabel(gn.Data)
+		}
+		if gn.Line > 0 {
+			label += fmt.Sprintf("\n@%d:%d", gn.Line, gn.Col)
+		}
+		style := ""
+		if gn.Stub {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(buf, "\tn%d [label=%s%s];\n", gn.ID, dotQuote(label), style)
+		if isRef {
+			fmt.Fprintf(buf, "\tn%d -> n%d [label=\"data\", style=dotted];\n", gn.ID, ref["node"])
+		}
+		for _, edge := range gn.Edges {
+			fmt.Fprintf(buf, "\tn%d -> n%d [label=%s];\n", gn.ID, edge.To, dotQuote(edge.Label))
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func dataLabel(data interface{}) string {
+	if s, ok := data.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(data)
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return "\"" + strings.ReplaceAll(s, "\n", "\\n") + "\""
+}
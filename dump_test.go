@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"pocket-lang/backend/goback"
+	"pocket-lang/dump"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"strings"
+	"testing"
+)
+
+// every node type constant needs a name for the dumps to show
+func TestNodeTypeNames(t *testing.T) {
+	names := map[string]bool{}
+	for _, name := range NodeTypeNames {
+		names[name] = true
+	}
+	for _, name := range goback.PreparedNodeTypeNames {
+		names[name] = true
+	}
+	for _, path := range []string{"frontend/pocket/common/nodes.go", "frontend/pocket/common/dype.go", "backend/goback/prepare.go"} {
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the first const block of each file holds its node types
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.CONST {
+				for _, spec := range gen.Specs {
+					for _, ident := range spec.(*ast.ValueSpec).Names {
+						if !names[ident.Name] {
+							t.Errorf("%s: %s has no entry in the node type names", path, ident.Name)
+						}
+					}
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestDumpGraph(t *testing.T) {
+	src := "Rectangle class\n    width\n    height\n    area func\n        return width * height\n\n" +
+		"main func\n    print(1)\n"
+	root := dump.Compile(src, "parse")
+
+	dot := dump.NewGraph(root, "area").Dot()
+	for _, want := range []string{"n0 [label=\"NT_FUNCDEF\\n@4:", "n0 -> n1 [label=\"NTR_FUNCDEF_NAME\"]", "[label=\"NT_RETURN\\n@5:"} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected %s in\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, "\"main\"") {
+		t.Error("the area func's graph shouldn't have main in it:\n" + dot)
+	}
+
+	// back-links become references to nodes already in the graph, and edges
+	// out of the func end at stubs
+	funcDef := NodNew(NT_FUNCDEF)
+	NodSetChild(funcDef, NTR_FUNCDEF_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, "f"))
+	varDef := NodNewChild(NT_VARDEF, NTR_VARDEF_NAME, NodNewData(NT_IDENTIFIER, "x"))
+	NodSetChild(funcDef, NTR_VARTABLE, NodNewChildList(NT_VARTABLE, []Nod{varDef}))
+	use := NodNewData(NT_IDENTIFIER_RESOLVED, "x")
+	NodSetChild(use, NTR_VARDEF, varDef)
+	call := NodNewChild(NT_RECEIVERCALL, NTR_RECEIVERCALL_ARG, use)
+	other := NodNewChild(NT_FUNCDEF, NTR_FUNCDEF_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, "g"))
+	NodSetChild(other, NTR_FUNCDEF_CODE, NodNew(NT_IMPERATIVE))
+	NodSetChild(call, NTR_FUNCDEF, other)
+	NodSetChild(funcDef, NTR_FUNCDEF_CODE, NodNewChildList(NT_IMPERATIVE, []Nod{call}))
+	NodSetChild(funcDef, NTR_FUNCDEF, funcDef)
+	top := NodNewChildList(NT_TOPLEVEL, []Nod{funcDef, other})
+
+	var graph struct {
+		Root  int
+		Nodes []struct {
+			Type  string
+			Data  interface{}
+			Stub  bool
+			Edges []struct {
+				Label string
+				To    int
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(dump.NewGraph(top, "f").JSON()), &graph); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, n := range graph.Nodes {
+		counts[n.Type]++
+		if n.Type == NodeTypeName(NT_FUNCDEF) && n.Data == "g" && !n.Stub {
+			t.Error("g should be a stub")
+		}
+		for _, edge := range n.Edges {
+			if edge.To >= len(graph.Nodes) {
+				t.Error("edge to a missing node", edge)
+			}
+		}
+	}
+	if counts["NT_VARDEF"] != 1 || counts["NT_FUNCDEF"] != 2 || counts["NT_IMPERATIVE"] != 1 {
+		t.Errorf("expected one vardef, f and the stub of g, got %v", counts)
+	}
+	selfLinked := false
+	for _, edge := range graph.Nodes[graph.Root].Edges {
+		selfLinked = selfLinked || (edge.Label == "NTR_FUNCDEF" && edge.To == graph.Root)
+	}
+	if !selfLinked {
+		t.Error("expected f's link to itself", graph.Nodes[graph.Root].Edges)
+	}
+}
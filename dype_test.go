@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math/rand"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 
@@ -215,3 +216,212 @@ func TestDype(t *testing.T) {
 	testUnionAll()
 
 }
+
+func TestDypeString(t *testing.T) {
+	union := NodNewChildList(DYPE_UNION, []Nod{MakeInt(), NodNewData(NT_TYPEBASE, TY_STRING)})
+	if s := DypeString(union); s != "int|string" {
+		t.Error("bad union string", s)
+	}
+	if s := DypeString(NodNew(DYPE_ALL)); s != "duck" {
+		t.Error("bad duck string", s)
+	}
+	if s := DypeString(NodNewData(NT_TYPEBASE, TY_U8)); s != "u8" {
+		t.Error("bad sized int string", s)
+	}
+}
+
+func MakeList(arg Nod) Nod {
+	rv := NodNew(NT_TYPECALL)
+	NodSetChild(rv, NTR_RECEIVERCALL_BASE, NodNewData(NT_TYPEBASE, TY_LIST))
+	NodSetChild(rv, NTR_RECEIVERCALL_ARG, arg)
+	return rv
+}
+
+// a value, for checking dypes against what they're meant to hold: a value of
+// a base type, or a list of values. A base of TY_NUMBER or TY_INTEGER is one
+// that's none of the types under it.
+type dypeValue struct {
+	base  int
+	elems []dypeValue
+}
+
+func dypeHolds(n Nod, v dypeValue) bool {
+	switch n.NodeType {
+	case DYPE_ALL:
+		return true
+	case DYPE_EMPTY:
+		return false
+	case DYPE_UNION:
+		for _, arg := range NodGetChildList(n) {
+			if dypeHolds(arg, v) {
+				return true
+			}
+		}
+		return false
+	case DYPE_XSECT:
+		for _, arg := range NodGetChildList(n) {
+			if !dypeHolds(arg, v) {
+				return false
+			}
+		}
+		return true
+	case DYPE_NOT:
+		return !dypeHolds(NodGetChildList(n)[0], v)
+	case NT_TYPECALL:
+		if v.base != TY_LIST {
+			return false
+		}
+		for _, elem := range v.elems {
+			if !dypeHolds(NodGetChild(n, NTR_RECEIVERCALL_ARG), elem) {
+				return false
+			}
+		}
+		return true
+	}
+	return BaseTypeIsSubtype(v.base, n.Data.(int))
+}
+
+func dypeTestValues() []dypeValue {
+	values := []dypeValue{}
+	for _, ty := range []int{TY_INT, TY_FLOAT, TY_I8, TY_INTEGER, TY_NUMBER, TY_STRING, TY_BOOL, TY_OBJECT} {
+		values = append(values, dypeValue{base: ty})
+	}
+	for _, elems := range [][]int{{}, {TY_INT}, {TY_STRING}, {TY_INT, TY_STRING}, {TY_FLOAT}, {TY_I8, TY_INT}, {TY_BOOL}} {
+		list := dypeValue{base: TY_LIST}
+		for _, ty := range elems {
+			list.elems = append(list.elems, dypeValue{base: ty})
+		}
+		values = append(values, list)
+	}
+	return values
+}
+
+// a random dype of depth levels of operators over a few atoms that overlap
+func randomDype(r *rand.Rand, depth int) Nod {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(12) {
+		case 0:
+			return MakeFull()
+		case 1:
+			return MakeEmpty()
+		case 2, 3:
+			return MakeList(randomDype(r, 1))
+		case 4:
+			return NodNewData(NT_TYPEBASE, TY_LIST)
+		}
+		return NodNewData(NT_TYPEBASE, []int{TY_INT, TY_FLOAT, TY_I8, TY_NUMBER, TY_STRING, TY_BOOL, TY_INT, TY_INTEGER, TY_DUCK}[r.Intn(9)])
+	}
+	switch r.Intn(3) {
+	case 0:
+		return DypeNot(randomDype(r, depth-1))
+	case 1:
+		return MakeUnion(randomDype(r, depth-1), randomDype(r, depth-1), randomDype(r, depth-1))
+	}
+	return MakeXSect(randomDype(r, depth-1), randomDype(r, depth-1))
+}
+
+func TestDypeAlgebra(t *testing.T) {
+	number := NodNewData(NT_TYPEBASE, TY_NUMBER)
+	if !DypeIsSubset(MakeList(number), MakeList(MakeInt())) {
+		t.Error("expected list(int) in list(number)")
+	}
+	if DypeIsSubset(MakeList(MakeInt()), MakeList(number)) {
+		t.Error("expected list(number) not in list(int)")
+	}
+	if !DypeIsSubset(NodNewData(NT_TYPEBASE, TY_LIST), MakeList(MakeUnion(MakeInt(), MakeFloat()))) {
+		t.Error("expected list(int|float) in list")
+	}
+	if s := DypeString(DypeNormalize(MakeUnion(MakeInt(), MakeXSect(number, DypeNot(MakeInt()))))); s != "number" {
+		t.Error("expected int|(number&!int) to be number, got", s)
+	}
+	if s := DypeString(DypeNormalize(MakeXSect(number, DypeNot(MakeUnion(MakeInt(), MakeFloat()))))); s != "number&!int&!float" {
+		t.Error("expected number less float and int, got", s)
+	}
+	if s := DypeString(DypeNormalize(MakeXSect(MakeList(MakeUnion(MakeInt(), MakeBool())), MakeList(number)))); s != "list(int)" {
+		t.Error("expected lists to meet in list(int), got", s)
+	}
+	// nested operators used to panic
+	nested := MakeUnion(MakeInt(), MakeXSect(MakeUnion(MakeFloat(), MakeBool()), MakeFloat()))
+	if !DypeIsSubset(nested, MakeFloat()) || DypeIsSubset(nested, MakeBool()) {
+		t.Error("expected float but not bool in", DypeString(nested))
+	}
+}
+
+// go test -run TestDypeAlgebraLaws -v, with a seed to reproduce a failure
+func TestDypeAlgebraLaws(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	values := dypeTestValues()
+	same := func(what string, a Nod, b Nod) {
+		if !DypeEqual(a, b) {
+			t.Errorf("%s: %s is %s, but %s is %s", what, DypeString(a), DypeString(DypeNormalize(a)),
+				DypeString(b), DypeString(DypeNormalize(b)))
+		}
+	}
+	for i := 0; i < 300 && !t.Failed(); i++ {
+		a, b, c := randomDype(r, 3), randomDype(r, 2), randomDype(r, 2)
+		norm := DypeNormalize(a)
+		for _, v := range values {
+			if dypeHolds(a, v) != dypeHolds(norm, v) {
+				t.Fatalf("%s and its normal form %s differ on %v", DypeString(a), DypeString(norm), v)
+			}
+		}
+		if DypeString(DypeNormalize(norm)) != DypeString(norm) {
+			t.Errorf("normal form of %s isn't normal", DypeString(a))
+		}
+		same("union commutes", MakeUnion(a, b), MakeUnion(b, a))
+		same("intersection commutes", MakeXSect(a, b), MakeXSect(b, a))
+		same("union associates", MakeUnion(MakeUnion(a, b), c), MakeUnion(a, MakeUnion(b, c)))
+		same("intersection distributes", MakeXSect(a, MakeUnion(b, c)), MakeUnion(MakeXSect(a, b), MakeXSect(a, c)))
+		same("de morgan", DypeNot(MakeUnion(a, b)), MakeXSect(DypeNot(a), DypeNot(b)))
+		same("double negation", DypeNot(DypeNot(a)), a)
+		same("excluded middle", MakeUnion(a, DypeNot(a)), MakeFull())
+		same("contradiction", MakeXSect(a, DypeNot(a)), MakeEmpty())
+
+		sub := DypeIsSubset(b, a)
+		if sub != DypeEqual(MakeXSect(a, b), a) {
+			t.Errorf("%s in %s is %v, but not by their intersection", DypeString(a), DypeString(b), sub)
+		}
+		for _, v := range values {
+			if sub && dypeHolds(a, v) && !dypeHolds(b, v) {
+				t.Errorf("%s in %s, but only the first holds %v", DypeString(a), DypeString(b), v)
+			}
+		}
+		if !DypeIsSubset(a, MakeXSect(a, b)) || !DypeIsSubset(MakeUnion(a, c), a) {
+			t.Errorf("%s isn't between its intersection and union with others", DypeString(a))
+		}
+	}
+}
+
+func TestDypeLattice(t *testing.T) {
+	number, object, duck := NodNewData(NT_TYPEBASE, TY_NUMBER), NodNewData(NT_TYPEBASE, TY_OBJECT), NodNewData(NT_TYPEBASE, TY_DUCK)
+	if !DypeIsSubset(number, MakeInt()) || !DypeIsSubset(number, MakeUnion(MakeInt(), MakeFloat())) {
+		t.Error("expected int and float in number")
+	}
+	if DypeIsSubset(MakeInt(), number) || DypeIsSubset(number, MakeBool()) {
+		t.Error("expected number not in int, and bool not in number")
+	}
+	if u := DypeUnion(MakeInt(), number); u != number {
+		t.Error("expected int|number to be number, got", DypeString(u))
+	}
+	if x := DypeXSect(number, MakeFloat()); x.NodeType != NT_TYPEBASE || x.Data != TY_FLOAT {
+		t.Error("expected number&float to be float, got", DypeString(x))
+	}
+	if x := DypeSimplifyDeep(MakeXSect(MakeUnion(MakeInt(), NodNewData(NT_TYPEBASE, TY_STRING)), number)); x.NodeType != NT_TYPEBASE || x.Data != TY_INT {
+		t.Error("expected (int|string)&number to be int, got", DypeString(x))
+	}
+
+	cls := NodNew(NT_CLASSDEF)
+	NodSetChild(cls, NTR_CLASSDEF_NAME, NodNewData(NT_IDENTIFIER, "Point"))
+	if !DypeIsSubset(object, cls) || !DypeIsSubset(duck, cls) || !DypeIsSubset(duck, object) {
+		t.Error("expected Point in object in duck")
+	}
+	if DypeIsSubset(cls, object) || DypeIsSubset(number, cls) {
+		t.Error("expected object not in Point, and Point not in number")
+	}
+	if !DypeIsSubset(MakeList(object), MakeList(cls)) || !DypeEqual(MakeUnion(cls, object), object) {
+		t.Error("expected list(Point) in list(object), and Point|object to be object")
+	}
+	if s := DypeString(DypeNormalize(MakeUnion(duck, MakeInt()))); s != "duck" {
+		t.Error("expected duck|int to be duck, got", s)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"pocket-lang/pktest"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files instead of comparing against them")
+
+// formats src, or returns the error that stopped it, as a golden file records it
+func formatForGolden(path string, src string) (out string, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, ok = fmt.Sprintln("error:", r), false
+		}
+	}()
+	return pktest.FormatCase(src), true
+}
+
+func formatGoldenInputs(t *testing.T) []string {
+	paths, _ := filepath.Glob("testcases/*.pkt")
+	filepath.Walk("srcexample", func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".pk") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if len(paths) == 0 {
+		t.Fatal("no inputs found")
+	}
+	return paths
+}
+
+func TestFormatGolden(t *testing.T) {
+	for _, path := range formatGoldenInputs(t) {
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := formatForGolden(path, string(dat))
+
+		golden := filepath.Join("testdata", "fmt", path)
+		if *update {
+			os.MkdirAll(filepath.Dir(golden), 0755)
+			if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err, "(run with -update to create it)")
+		}
+		if got != string(want) {
+			t.Errorf("%s formats differently from %s:\n%s", path, golden, got)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if again, _ := formatForGolden(golden, got); again != got {
+			t.Errorf("formatting %s again changes it:\n%s", golden, again)
+		}
+		if diff := sameCaseTrees(string(dat), got); diff != "" {
+			t.Errorf("formatting %s changed what it parses to: %s", path, diff)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	src := "# header\n\nmain func # trailing\n# moves into the block\n    x : 1   # after x\n\n\n    print x\n      # still in main\n# before f\nf func(a int) int: a\n"
+	want := "# header\n\nmain func # trailing\n    # moves into the block\n    x : 1 # after x\n\n    print(x)\n    # still in main\n\n# before f\nf func(a int) int: a\n"
+	got, ok := formatForGolden("", src)
+	if !ok || got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+// compares the parse trees of each source section of two case files
+func sameCaseTrees(a string, b string) string {
+	aSections, bSections := strings.Split(a, ">>>"), strings.Split(b, ">>>")
+	if len(aSections) != len(bSections) {
+		return "different number of sections"
+	}
+	for i := 0; i < len(aSections); i += 2 {
+		if aSections[i] == bSections[i] {
+			// left alone, like the ones that expect a syntax error
+			continue
+		}
+		aTree := pocket.Parse(pocket.Tokenize(aSections[i]))
+		bTree := pocket.Parse(pocket.Tokenize(bSections[i]))
+		if diff := sameTree(aTree, bTree); diff != "" {
+			return fmt.Sprint("section ", i/2, ": ", diff)
+		}
+	}
+	return ""
+}
+
+// a molecule in parens, e.g. f(-x), parses to a one element op stream, which
+// only marks where the parens were, so it's the same as its element
+func unwrapParens(n Nod) Nod {
+	if n.NodeType == NT_INLINEOPSTREAM && len(n.Out) == 1 {
+		return unwrapParens(NodGetChildList(n)[0])
+	}
+	return n
+}
+
+func sameTree(a Nod, b Nod) string {
+	a, b = unwrapParens(a), unwrapParens(b)
+	if a.NodeType != b.NodeType || fmt.Sprint(a.Data) != fmt.Sprint(b.Data) || len(a.Out) != len(b.Out) {
+		return PrettyPrint(a) + "\nvs\n" + PrettyPrint(b)
+	}
+	for edgeType, edge := range a.Out {
+		other, ok := b.Out[edgeType]
+		if !ok {
+			return PrettyPrint(a) + "\nvs\n" + PrettyPrint(b)
+		}
+		if diff := sameTree(edge.Out, other.Out); diff != "" {
+			return diff
+		}
+	}
+	return ""
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 	. "pocket-lang/parse"
 	"strconv"
+	"sync"
 )
 
 type Debug struct {
-	initialized    bool
+	initialized    sync.Once      // tests print trees from several goroutines at once
 	nodeTypeLookup map[int]string // NT_IMPERATIVE -> "IMPERATIVE", etc
 	typeLookup     map[int]string // TY_INT -> "int", etc
 }
@@ -22,9 +23,7 @@ type DebugPrinter struct {
 var DEBUG *Debug = &Debug{} // singleton
 
 func (d *Debug) ensureInitialized() {
-	if !d.initialized {
-		d.initialize()
-	}
+	d.initialized.Do(d.initialize)
 }
 
 func (d *Debug) initialize() {
@@ -48,6 +47,7 @@ func (d *Debug) initialize() {
 
 	ntl[NT_LIT_INT] = "INT"
 	ntl[NT_LIT_STRING] = "STRING"
+	ntl[NT_LIT_FSTRING] = "FSTRING"
 	ntl[NT_INLINEOPSTREAM] = "OPSTREAM"
 	ntl[NT_VALUE_MOLECULE] = "OPMOLECULE"
 
@@ -60,6 +60,10 @@ func (d *Debug) initialize() {
 	ntl[NT_MULOP] = "MUL"
 	ntl[NT_DIVOP] = "DIV"
 	ntl[NT_MODOP] = "MOD"
+	ntl[NT_POWOP] = "POW"
+	ntl[NT_XOROP] = "XOR"
+	ntl[NT_SHLOP] = "SHL"
+	ntl[NT_SHROP] = "SHR"
 	ntl[NT_GTOP] = "GT"
 	ntl[NT_LTOP] = "LT"
 	ntl[NT_GTEQOP] = "GTEQ"
@@ -69,6 +73,13 @@ func (d *Debug) initialize() {
 	ntl[NTR_BINOP_RIGHT] = "RIGHT"
 
 	ntl[NT_REFERENCEOP] = "REF"
+	ntl[NT_NEGOP] = "NEG"
+	ntl[NT_POSOP] = "POS"
+	ntl[NT_NOTOP] = "NOT"
+	ntl[NT_RANGEOP] = "RANGE"
+	ntl[NT_RANGEEXCLOP] = "RANGEEXCL"
+	ntl[NT_RANGESTEPOP] = "RANGESTEP"
+	ntl[NTR_RANGE_STEP] = "STEP"
 
 	ntl[NT_INCREMENTOR] = "INCREMENTOR"
 	ntl[NT_INCREMENTOR_OP] = "INCREMENTOROP"
@@ -157,6 +168,10 @@ func (d *Debug) initialize() {
 	ntl[NT_MODF_STATIC] = "MODFSTATIC"
 	ntl[NTR_PRAGMAPAINT] = "PRAGMAPAINT"
 	ntl[NT_PRAGMAPAINT] = "PRAGMAPAINT"
+	ntl[NT_TESTDEF] = "TESTDEF"
+	ntl[NTR_TESTDEF_CODE] = "BODY"
+	ntl[NT_ASSERT] = "ASSERT"
+	ntl[NTR_ASSERT_COND] = "COND"
 
 	ntl[NNT_SYMTABLE] = "SYMTABLE"
 	ntl[NNTR_SYMTABLE] = "SYMTABLE"
@@ -174,6 +189,7 @@ func (d *Debug) initialize() {
 	ntl[DYPE_EMPTY] = "DYPE_EMPTY"
 	ntl[DYPE_UNION] = "UNION"
 	ntl[DYPE_XSECT] = "XSECT"
+	ntl[DYPE_NOT] = "NOT"
 
 	d.typeLookup = map[int]string{}
 	tl := d.typeLookup
@@ -187,8 +203,14 @@ func (d *Debug) initialize() {
 	tl[TY_SET] = "set"
 	tl[TY_VOID] = "void"
 	tl[TY_FUNC] = "func"
-
-	d.initialized = true
+	tl[TY_NUMBER] = "number"
+	tl[TY_INTEGER] = "integer"
+	tl[TY_OBJECT] = "object"
+	for _, ty := range NumericTypes() {
+		if _, ok := tl[ty]; !ok {
+			tl[ty] = NumericTypeName(ty)
+		}
+	}
 }
 
 func PrettyPrintNodes(nodes []Nod) string {
@@ -401,3 +423,59 @@ func PrettyPrintOp(printOp func(*DebugPrinter)) string {
 	printOp(d)
 	return d.String()
 }
+
+// the name of a TY_ constant, e.g. int, or its number if it has none
+func TypeName(ty int) string {
+	DEBUG.ensureInitialized()
+	if name, ok := DEBUG.typeLookup[ty]; ok {
+		return name
+	}
+	return strconv.Itoa(ty)
+}
+
+// one line, source-like rendering of a dype, e.g. int|string
+func DypeString(n Nod) string {
+	DEBUG.ensureInitialized()
+	if n == nil {
+		return "?"
+	}
+	switch n.NodeType {
+	case NT_TYPEBASE:
+		return TypeName(n.Data.(int))
+	case DYPE_ALL:
+		return "duck"
+	case DYPE_EMPTY:
+		return "void"
+	case DYPE_UNION, DYPE_XSECT:
+		sep := "|"
+		if n.NodeType == DYPE_XSECT {
+			sep = "&"
+		}
+		rv := ""
+		for ndx, arg := range NodGetChildList(n) {
+			if ndx > 0 {
+				rv += sep
+			}
+			rv += dypeStringNested(arg)
+		}
+		return rv
+	case DYPE_NOT:
+		return "!" + dypeStringNested(NodGetChildList(n)[0])
+	case NT_CLASSDEF:
+		return NodGetChild(n, NTR_CLASSDEF_NAME).Data.(string)
+	case NT_FUNCDEF:
+		return "func"
+	case NT_TYPECALL:
+		return DypeString(NodGetChild(n, NTR_RECEIVERCALL_BASE)) +
+			"(" + DypeString(NodGetChild(n, NTR_RECEIVERCALL_ARG)) + ")"
+	}
+	return PrettyPrintDepth(n, 0)
+}
+
+// the string of a dype inside an operator, in parens if it's one too
+func dypeStringNested(n Nod) string {
+	if DypeIsOperator(n.NodeType) {
+		return "(" + DypeString(n) + ")"
+	}
+	return DypeString(n)
+}
@@ -1,19 +1,21 @@
 package common
 
 import (
-	"fmt"
 	. "pocket-lang/parse"
 )
 
 // Represents an algebra over the universe of dypes, a minimal extension
 // to "regular nodes" that includes unions and intersections.
 // The concrete representation of a dype is Nod.
+// The functions here are the quick ones the solver uses on flat dypes; see
+// dypenorm.go for the ones that handle any dype.
 
 const (
 	DYPE_ALL   = 382322 + iota // represents the enumeration of all nodes
 	DYPE_EMPTY                 // represents no nod, or void
 	DYPE_UNION                 // union of its children
 	DYPE_XSECT                 // intersection of its children
+	DYPE_NOT                   // everything but its one child
 )
 
 func DypeXSect(a Nod, b Nod) Nod {
@@ -32,6 +34,13 @@ func DypeXSect(a Nod, b Nod) Nod {
 	if DypeDeepForwardsEqual(a, b) {
 		return a
 	}
+	if !DypeIsMeta(a.NodeType) && !DypeIsMeta(b.NodeType) {
+		if dypeIsSubtype(a, b) {
+			return a
+		} else if dypeIsSubtype(b, a) {
+			return b
+		}
+	}
 	rv := NodNew(DYPE_XSECT)
 	NodSetOutList(rv, []Nod{a, b})
 	return rv
@@ -50,92 +59,165 @@ func DypeUnion(a Nod, b Nod) Nod {
 	if DypeDeepForwardsEqual(a, b) {
 		return a
 	}
+	if !DypeIsMeta(a.NodeType) && !DypeIsMeta(b.NodeType) {
+		if dypeIsSubtype(a, b) {
+			return b
+		} else if dypeIsSubtype(b, a) {
+			return a
+		}
+	}
 	rv := NodNew(DYPE_UNION)
 	NodSetOutList(rv, []Nod{a, b})
 	return rv
 }
 
-func DypeIsSubset(a Nod, b Nod) bool {
-	// assumes no nested operators
-
-	if a.NodeType == DYPE_ALL {
+// DypeIsSubset returns whether everything in sub is in container. Unions of
+// atoms are handled here, the rest by the full algebra in dypenorm.go, which
+// both agree with.
+func DypeIsSubset(container Nod, sub Nod) bool {
+	if container.NodeType == DYPE_ALL {
 		return true
 	}
-	if b.NodeType == DYPE_EMPTY {
+	if sub.NodeType == DYPE_EMPTY {
 		return true
 	}
-	if b.NodeType == DYPE_ALL {
-		return a.NodeType == DYPE_ALL
+	// an operator can still mean everything or nothing
+	if sub.NodeType == DYPE_ALL && !DypeIsMeta(container.NodeType) {
+		return dypeIsDuck(container)
 	}
-	if a.NodeType == DYPE_EMPTY {
-		return b.NodeType == DYPE_EMPTY
+	if container.NodeType == DYPE_EMPTY && !DypeIsMeta(sub.NodeType) {
+		return false
 	}
 
-	if !DypeIsMeta(a.NodeType) && !DypeIsMeta(b.NodeType) {
-		return DypeDeepForwardsEqual(a, b)
+	if !DypeIsMeta(sub.NodeType) && !DypeIsMeta(container.NodeType) {
+		return dypeAtomIn(sub, container)
 	}
 
-	if DypeDeepForwardsEqual(a, b) {
+	if DypeDeepForwardsEqual(sub, container) {
 		return true
 	}
 
-	asimp := DypeSimplifyShallow(a)
-	bsimp := DypeSimplifyShallow(b)
+	// unions of atoms, however they're nested, without flattening them into
+	// new nodes. An atom is only covered by a union if it's in one of its
+	// atoms, and everything is only covered by one with duck.
+	if cAtoms, ok := dypeUnionAtoms(container, nil); ok {
+		subAtoms, ok := dypeUnionAtoms(sub, nil)
+		if sub.NodeType == DYPE_ALL {
+			subAtoms, ok = []Nod{NodNewData(NT_TYPEBASE, TY_DUCK)}, true
+		}
+		if ok {
+			for _, atom := range subAtoms {
+				if !dypeListHasSuperset(cAtoms, atom) {
+					return false
+				}
+			}
+			return true
+		}
+	}
 
-	if asimp.NodeType == DYPE_UNION && !DypeIsMeta(bsimp.NodeType) {
-		DypeCheckNoNestedOps(asimp)
-		rv := DypeListContains(NodGetChildList(asimp), bsimp)
-		return rv
+	subsimp := DypeSimplifyShallow(sub)
+	csimp := DypeSimplifyShallow(container)
+
+	if csimp.NodeType == DYPE_UNION && !DypeIsMeta(subsimp.NodeType) && dypeIsFlat(csimp) {
+		return dypeListHasSuperset(NodGetChildList(csimp), subsimp)
 	}
-	if bsimp.NodeType == DYPE_UNION && !DypeIsMeta(asimp.NodeType) {
-		return false
+	if subsimp.NodeType == DYPE_UNION && !DypeIsMeta(csimp.NodeType) && dypeIsFlat(subsimp) {
+		// only a supertype holds a union, e.g. number holds int|float
+		for _, arg := range NodGetChildList(subsimp) {
+			if !dypeAtomIn(arg, csimp) {
+				return false
+			}
+		}
+		return true
 	}
-	if asimp.NodeType == DYPE_UNION && bsimp.NodeType == DYPE_UNION {
-		return DypeIsSubsetUnionUnion(asimp, bsimp)
+	if subsimp.NodeType == DYPE_UNION && csimp.NodeType == DYPE_UNION && dypeIsFlat(subsimp) && dypeIsFlat(csimp) {
+		return dypeIsSubsetUnionUnion(csimp, subsimp)
 	}
 
-	fmt.Println("Couldn't determine subsetness: container (simplified):",
-		PrettyPrint(asimp), "\nsub:", PrettyPrint(bsimp))
-	panic("Couldn't determine subsetness")
+	Tracef(LOGCH_SOLVE, "subsetness by normal forms: sub (simplified): %s\ncontainer: %s",
+		LazyTree(subsimp), LazyTree(csimp))
+	return dypeSubsetOf(subsimp, csimp)
 }
 
-func DypeListContains(nods []Nod, e Nod) bool {
+// whether the atom sub is in the atom super. The lattice orders all but
+// parameterized types, which are ordered by their parameters.
+func dypeAtomIn(sub Nod, super Nod) bool {
+	if sub.NodeType == NT_TYPECALL || super.NodeType == NT_TYPECALL {
+		return dypeSubsetOf(sub, super)
+	}
+	return dypeIsSubtype(sub, super)
+}
+
+// appends the atoms of n, a union of them or of unions of them, to atoms,
+// leaving out void. Not ok if n has anything else under it.
+func dypeUnionAtoms(n Nod, atoms []Nod) ([]Nod, bool) {
+	switch n.NodeType {
+	case DYPE_EMPTY:
+		return atoms, true
+	case DYPE_UNION:
+		for _, arg := range NodGetChildList(n) {
+			var ok bool
+			if atoms, ok = dypeUnionAtoms(arg, atoms); !ok {
+				return nil, false
+			}
+		}
+		return atoms, true
+	}
+	if DypeIsMeta(n.NodeType) {
+		return nil, false
+	}
+	return append(atoms, n), true
+}
+
+// whether e is in any of nods, all non-meta
+func dypeListHasSuperset(nods []Nod, e Nod) bool {
 	for _, cnod := range nods {
-		if DypeDeepForwardsEqual(cnod, e) {
+		if dypeAtomIn(e, cnod) {
 			return true
 		}
 	}
 	return false
 }
 
-func DypeIsSubsetUnionUnion(ua Nod, ub Nod) bool {
-
-	DypeCheckNoNestedOps(ua)
-	DypeCheckNoNestedOps(ub)
+// whether e is a subtype of any of nods by the lattice, all non-meta
+func dypeListHasSupertype(nods []Nod, e Nod) bool {
+	for _, cnod := range nods {
+		if dypeIsSubtype(e, cnod) {
+			return true
+		}
+	}
+	return false
+}
 
-	// iterate through other's elements
-	myNods := NodGetChildList(ua)
-	otherNods := NodGetChildList(ub)
+func DypeListContains(nods []Nod, e Nod) bool {
+	for _, cnod := range nods {
+		if DypeDeepForwardsEqual(cnod, e) {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, otherNod := range otherNods {
-		contained := DypeListContains(myNods, otherNod)
-		if !contained {
+// whether every atom of the union sub is in one of the union container, both
+// flat
+func dypeIsSubsetUnionUnion(container Nod, sub Nod) bool {
+	containerNods := NodGetChildList(container)
+	for _, subNod := range NodGetChildList(sub) {
+		if !dypeListHasSuperset(containerNods, subNod) {
 			return false
 		}
 	}
 	return true
 }
 
-func DypeCheckNoNestedOps(n Nod) {
-	if !DypeIsOperator(n.NodeType) {
-		return
-	}
-	myNods := NodGetChildList(n)
-	for _, nod := range myNods {
-		if DypeIsOperator(nod.NodeType) {
-			panic("no nested ops allowed")
+// whether none of n's args are meta, other than all and empty
+func dypeIsFlat(n Nod) bool {
+	for _, nod := range NodGetChildList(n) {
+		if DypeIsOperator(nod.NodeType) || nod.NodeType == DYPE_NOT {
+			return false
 		}
 	}
+	return true
 }
 
 func DypeWouldChangeUnion(a Nod, b Nod) bool {
@@ -149,9 +231,9 @@ func DypeWouldChangeXSect(a Nod, b Nod) bool {
 func DypeSimplifyShallow(n Nod) Nod {
 	// performs quick simplifications (non-recursive),
 	// returns original Nod if no simplifications made (Nod-Idem)
+	n = DypeDeassociate(n)
 	n = DypeCollapseShallow(n)
 	n = DypeRemoveMonoArgs(n)
-	n = DypeDeassociate(n)
 	n = DypeDeduplicate(n)
 	return n
 }
@@ -314,8 +396,10 @@ func DypeEvaluateXSectBinary(a Nod, b Nod) Nod {
 		return a
 	}
 	if !DypeIsMeta(a.NodeType) && !DypeIsMeta(b.NodeType) {
-		if DypeDeepForwardsEqual(a, b) {
+		if dypeIsSubtype(a, b) {
 			return a
+		} else if dypeIsSubtype(b, a) {
+			return b
 		}
 		return NodNew(DYPE_EMPTY)
 	}
@@ -325,10 +409,11 @@ func DypeEvaluateXSectBinary(a Nod, b Nod) Nod {
 	if b.NodeType == DYPE_UNION && !DypeIsMeta(a.NodeType) {
 		return DypeEvaluateXSectBinaryUnionNonUnion(b, a)
 	}
-	if a.NodeType == DYPE_UNION && b.NodeType == DYPE_UNION {
+	if a.NodeType == DYPE_UNION && b.NodeType == DYPE_UNION && dypeIsFlat(a) && dypeIsFlat(b) {
 		return DypeEvaluateXSectBinaryUnionUnion(a, b)
 	}
-	panic("invalid preconditions")
+	// negations, or operators under the unions
+	return DypeNormalize(NodNewChildList(DYPE_XSECT, []Nod{a, b}))
 }
 
 func DypeEvaluateXSectBinaryUnionUnion(a Nod, b Nod) Nod {
@@ -336,17 +421,29 @@ func DypeEvaluateXSectBinaryUnionUnion(a Nod, b Nod) Nod {
 	aArgs := NodGetChildList(a)
 	bArgs := NodGetChildList(b)
 
+	// or what's under both, e.g. int from number and int|string
 	commonArgs := []Nod{}
+	allContained := true
 	for _, aArg := range aArgs {
-		if DypeListContains(bArgs, aArg) {
+		if dypeListHasSupertype(bArgs, aArg) {
 			commonArgs = append(commonArgs, aArg)
+			continue
+		}
+		allContained = false
+		for _, bArg := range bArgs {
+			if dypeIsSubtype(bArg, aArg) && !DypeListContains(commonArgs, bArg) {
+				commonArgs = append(commonArgs, bArg)
+			}
 		}
 	}
 
-	if len(commonArgs) == len(aArgs) {
+	if allContained {
 		// nothing changed, avoid new object creation
 		return a
 	}
+	if len(commonArgs) == 0 {
+		return NodNew(DYPE_EMPTY)
+	}
 	if len(commonArgs) == 1 {
 		return commonArgs[0]
 	}
@@ -355,10 +452,17 @@ func DypeEvaluateXSectBinaryUnionUnion(a Nod, b Nod) Nod {
 
 func DypeEvaluateXSectBinaryUnionNonUnion(union Nod, nonunion Nod) Nod {
 	unionArgs := NodGetChildList(union)
-	if DypeListContains(unionArgs, nonunion) {
+	if dypeListHasSupertype(unionArgs, nonunion) {
 		return nonunion
 	}
-	return NodNew(DYPE_EMPTY)
+	// the args under it, e.g. int|string in duck
+	under := []Nod{}
+	for _, arg := range unionArgs {
+		if dypeIsSubtype(arg, nonunion) {
+			under = append(under, arg)
+		}
+	}
+	return DypeSimplifyShallow(NodNewChildList(DYPE_UNION, under))
 }
 
 func DypeSimplifyChildren(n Nod) Nod {
@@ -385,7 +489,7 @@ func DypeIsOperator(nt int) bool {
 
 func DypeIsMeta(nt int) bool {
 	return nt == DYPE_ALL || nt == DYPE_EMPTY ||
-		nt == DYPE_UNION || nt == DYPE_XSECT
+		nt == DYPE_UNION || nt == DYPE_XSECT || nt == DYPE_NOT
 }
 
 func DypeDeepForwardsEqual(n0 Nod, n1 Nod) bool {
@@ -0,0 +1,431 @@
+package common
+
+import (
+	. "pocket-lang/parse"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The full algebra over dypes: normal forms, negation and subset checks that
+// work on any nesting of unions, intersections and negations.
+//
+// The dypes that aren't meta are atoms: base types, parameterized types like
+// list(int), classes and funcs. Atoms are ordered by containment. Base types
+// and classes are ordered by the lattice in lattice.go, where duck is
+// everything, a bare base type like list contains it with any parameter, and
+// list(a) contains list(b) if a contains b, so list(int) is in list(number).
+// Atoms that aren't ordered don't overlap, except two parameterizations of a
+// base, which meet in the one by what they have in common. No atom is covered
+// by atoms it strictly contains, e.g. some numbers are neither int nor float,
+// and some lists of int|string are neither lists of int nor lists of string.
+//
+// So a dype's meaning only depends on which cells it covers, where the atoms
+// it mentions, and their meets, cut the universe into a cell for each atom,
+// holding what's in it but in no smaller one, plus one for what's in none.
+// The normal form is worked out from those cells, after merging the ones that
+// don't make a difference, so dypes with the same meaning get the same normal
+// form. Normal forms are hash-consed while working out one of the calls here,
+// so within it they're equal exactly when they're the same node. The table
+// only lives as long as the call, so nothing's kept between compilations, and
+// normal forms are compared with DypeEqual from outside.
+
+func DypeNot(n Nod) Nod {
+	return NodNewChildList(DYPE_NOT, []Nod{n})
+}
+
+// DypeNormalize returns the normal form of n, which is the same as that of
+// every dype that means the same
+func DypeNormalize(n Nod) Nod {
+	return newDypeTable().normalize(n)
+}
+
+func DypeEqual(a Nod, b Nod) bool {
+	t := newDypeTable()
+	return t.normalize(a) == t.normalize(b)
+}
+
+// whether everything in sub is in container, ordering parameterized types by
+// their parameters. DypeIsSubset is the way in, with quicker paths for flat
+// dypes.
+func dypeSubsetOf(sub Nod, container Nod) bool {
+	return newDypeTable().subsetOf(sub, container)
+}
+
+// the hash-consing table, keyed by a string that's the same for the same
+// normal form. Classes and funcs are atoms in their own right, keyed by their
+// name and a number for each one of the same name.
+type dypeTable struct {
+	byKey  map[string]Nod
+	keys   map[Nod]string
+	opaque map[Nod]string
+	named  map[string]int
+}
+
+func newDypeTable() *dypeTable {
+	return &dypeTable{byKey: map[string]Nod{}, keys: map[Nod]string{}, opaque: map[Nod]string{}, named: map[string]int{}}
+}
+
+func (t *dypeTable) intern(key string, build func() Nod) Nod {
+	if n, ok := t.byKey[key]; ok {
+		return n
+	}
+	built := build()
+	t.byKey[key] = built
+	t.keys[built] = key
+	return built
+}
+
+func (t *dypeTable) key(n Nod) string {
+	return t.keys[n]
+}
+
+func (t *dypeTable) opaqueKey(n Nod) string {
+	if key, ok := t.opaque[n]; ok {
+		return key
+	}
+	name := "o" + strconv.Itoa(n.NodeType) + ":" + DypeString(n)
+	key := name + "#" + strconv.Itoa(t.named[name])
+	t.named[name]++
+	t.opaque[n] = key
+	return key
+}
+
+func (t *dypeTable) normalize(n Nod) Nod {
+	if t.key(n) != "" {
+		return n
+	}
+	return t.newCells(n).normalForm(n)
+}
+
+func (t *dypeTable) subsetOf(sub Nod, container Nod) bool {
+	cells := t.newCells(sub, container)
+	for _, cell := range cells.all {
+		if cells.covers(sub, cell) && !cells.covers(container, cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// the atom n in normal form
+func (t *dypeTable) atom(n Nod) Nod {
+	if t.key(n) != "" {
+		return n
+	}
+	switch n.NodeType {
+	case NT_TYPEBASE:
+		ty := n.Data.(int)
+		return t.intern("b"+strconv.Itoa(ty), func() Nod { return NodNewData(NT_TYPEBASE, ty) })
+	case NT_TYPECALL:
+		base := t.atom(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+		arg := t.normalize(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+		return t.parameterized(base, arg)
+	}
+	return t.intern(t.opaqueKey(n), func() Nod { return n })
+}
+
+// base(arg), or just base if arg is everything
+func (t *dypeTable) parameterized(base Nod, arg Nod) Nod {
+	if arg.NodeType == DYPE_ALL {
+		return base
+	}
+	key := "c(" + t.key(base) + "," + t.key(arg) + ")"
+	return t.intern(key, func() Nod {
+		rv := NodNew(NT_TYPECALL)
+		NodSetChild(rv, NTR_RECEIVERCALL_BASE, base)
+		NodSetChild(rv, NTR_RECEIVERCALL_ARG, arg)
+		return rv
+	})
+}
+
+// the base and parameter of an atom that can be parameterized, with a bare
+// base type taking everything
+func dypeAtomParams(a Nod) (Nod, Nod, bool) {
+	if a.NodeType == NT_TYPECALL {
+		return NodGetChild(a, NTR_RECEIVERCALL_BASE), NodGetChild(a, NTR_RECEIVERCALL_ARG), true
+	} else if a.NodeType == NT_TYPEBASE {
+		return a, NodNew(DYPE_ALL), true
+	}
+	return nil, nil, false
+}
+
+// whether atom a is in atom b, both in normal form
+func (t *dypeTable) atomSubset(a Nod, b Nod) bool {
+	if a == b {
+		return true
+	}
+	if a.NodeType != NT_TYPECALL {
+		return dypeIsSubtype(a, b)
+	}
+	aBase, aArg, _ := dypeAtomParams(a)
+	bBase, bArg, bOk := dypeAtomParams(b)
+	if !bOk {
+		return false
+	} else if aBase != bBase {
+		// list(int) is in what list is in
+		return b.NodeType == NT_TYPEBASE && dypeIsSubtype(aBase, b)
+	}
+	return t.subsetOf(aArg, bArg)
+}
+
+// duck is everything, rather than an atom
+func dypeIsDuck(n Nod) bool {
+	return n.NodeType == NT_TYPEBASE && n.Data.(int) == TY_DUCK
+}
+
+// the atom of what's in both a and b, or nil if nothing is
+func (t *dypeTable) atomMeet(a Nod, b Nod) Nod {
+	if t.atomSubset(a, b) {
+		return a
+	} else if t.atomSubset(b, a) {
+		return b
+	}
+	aBase, aArg, aOk := dypeAtomParams(a)
+	bBase, bArg, bOk := dypeAtomParams(b)
+	if !aOk || !bOk || aBase != bBase {
+		return nil
+	}
+	return t.parameterized(aBase, t.normalize(NodNewChildList(DYPE_XSECT, []Nod{aArg, bArg})))
+}
+
+// the atoms of some dypes, closed under meets, largest first. A cell is an
+// atom, standing for what's in it but in no smaller atom, or nil for what's
+// in none of them.
+type dypeCells struct {
+	t       *dypeTable
+	atoms   []Nod
+	all     []Nod // the atoms, then nil
+	atomsOf map[Nod]Nod
+}
+
+func (t *dypeTable) newCells(ns ...Nod) *dypeCells {
+	cells := &dypeCells{t: t, atomsOf: map[Nod]Nod{}}
+	seen := map[Nod]bool{}
+	for _, n := range ns {
+		cells.collect(n, seen)
+	}
+	for i := 0; i < len(cells.atoms); i++ {
+		for j := 0; j < i; j++ {
+			if meet := t.atomMeet(cells.atoms[i], cells.atoms[j]); meet != nil && !seen[meet] {
+				seen[meet] = true
+				cells.atoms = append(cells.atoms, meet)
+			}
+		}
+	}
+	cells.sort()
+	return cells
+}
+
+func (cells *dypeCells) collect(n Nod, seen map[Nod]bool) {
+	if dypeIsDuck(n) {
+		return
+	} else if DypeIsMeta(n.NodeType) {
+		for _, arg := range NodGetChildList(n) {
+			cells.collect(arg, seen)
+		}
+		return
+	}
+	atom := cells.t.atom(n)
+	cells.atomsOf[n] = atom
+	if !seen[atom] {
+		seen[atom] = true
+		cells.atoms = append(cells.atoms, atom)
+	}
+}
+
+func (cells *dypeCells) sort() {
+	below := map[Nod]int{}
+	for _, a := range cells.atoms {
+		below[a] = len(cells.below(a))
+	}
+	sort.Slice(cells.atoms, func(i, j int) bool {
+		a, b := cells.atoms[i], cells.atoms[j]
+		if below[a] != below[b] {
+			return below[a] > below[b]
+		}
+		return cells.t.key(a) < cells.t.key(b)
+	})
+	cells.all = append(append([]Nod{}, cells.atoms...), nil)
+}
+
+// the atoms strictly in cell, all of them for the nil cell
+func (cells *dypeCells) below(cell Nod) []Nod {
+	rv := []Nod{}
+	for _, a := range cells.atoms {
+		if a != cell && (cell == nil || cells.t.atomSubset(a, cell)) {
+			rv = append(rv, a)
+		}
+	}
+	return rv
+}
+
+// the smallest atom strictly containing a, or nil if none does. It's a if a
+// is the meet of the atoms containing it.
+func (cells *dypeCells) above(a Nod) Nod {
+	var meet Nod
+	for _, b := range cells.atoms {
+		if b != a && cells.t.atomSubset(a, b) {
+			if meet == nil {
+				meet = b
+			} else {
+				meet = cells.t.atomMeet(meet, b)
+			}
+		}
+	}
+	return meet
+}
+
+// whether n covers cell
+func (cells *dypeCells) covers(n Nod, cell Nod) bool {
+	switch n.NodeType {
+	case DYPE_ALL:
+		return true
+	case DYPE_EMPTY:
+		return false
+	case DYPE_UNION:
+		for _, arg := range NodGetChildList(n) {
+			if cells.covers(arg, cell) {
+				return true
+			}
+		}
+		return false
+	case DYPE_XSECT:
+		for _, arg := range NodGetChildList(n) {
+			if !cells.covers(arg, cell) {
+				return false
+			}
+		}
+		return true
+	case DYPE_NOT:
+		return !cells.covers(NodGetChildList(n)[0], cell)
+	}
+	if dypeIsDuck(n) {
+		return true
+	}
+	return cell != nil && cells.t.atomSubset(cell, cells.atomsOf[n])
+}
+
+// merges the cells that don't make a difference to what's covered, leaving
+// the ones that do. An atom's cell merges into the one of the smallest atom
+// containing it, unless it's the meet of those, as meets have to stay.
+func (cells *dypeCells) reduce(covered map[Nod]bool) {
+	for merged := true; merged; {
+		merged = false
+		for ndx := len(cells.atoms) - 1; ndx >= 0; ndx-- {
+			a := cells.atoms[ndx]
+			if up := cells.above(a); up != a && covered[up] == covered[a] {
+				cells.atoms = append(cells.atoms[:ndx], cells.atoms[ndx+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+	cells.sort()
+}
+
+func (cells *dypeCells) normalForm(n Nod) Nod {
+	covered := map[Nod]bool{}
+	for _, cell := range cells.all {
+		covered[cell] = cells.covers(n, cell)
+	}
+	cells.reduce(covered)
+
+	// what's under a cell's atom, the cell included
+	under := func(cell Nod) []Nod {
+		return append(cells.below(cell), cell)
+	}
+	allCovered := func(cell Nod) bool {
+		for _, c := range under(cell) {
+			if !covered[c] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// each covered cell gets a clause of its atom less the largest atoms
+	// under it that aren't all covered, unless the clauses before it cover
+	// everything it would
+	clauses := []Nod{}
+	done := map[Nod]bool{}
+	for _, cell := range append([]Nod{nil}, cells.atoms...) {
+		if !covered[cell] {
+			continue
+		}
+		below := cells.below(cell)
+		excluded := []Nod{}
+		for _, a := range below {
+			if allCovered(a) {
+				continue
+			}
+			largest := true
+			for _, b := range below {
+				if b != a && cells.t.atomSubset(a, b) {
+					largest = false
+					break
+				}
+			}
+			if largest {
+				excluded = append(excluded, a)
+			}
+		}
+		newlyDone := false
+		for _, c := range under(cell) {
+			isExcluded := false
+			for _, x := range excluded {
+				if c != nil && cells.t.atomSubset(c, x) {
+					isExcluded = true
+					break
+				}
+			}
+			if !isExcluded && !done[c] {
+				done[c] = true
+				newlyDone = true
+			}
+		}
+		if newlyDone {
+			clauses = append(clauses, cells.t.clause(cell, excluded))
+		}
+	}
+	return cells.t.operator(DYPE_UNION, "|", clauses, NodNew(DYPE_EMPTY))
+}
+
+// atom, or everything if it's nil, less each of excluded
+func (t *dypeTable) clause(atom Nod, excluded []Nod) Nod {
+	args := []Nod{}
+	for _, x := range excluded {
+		args = append(args, t.intern("!"+t.key(x), func() Nod { return DypeNot(x) }))
+	}
+	t.sort(args)
+	if atom != nil {
+		args = append([]Nod{atom}, args...)
+	}
+	return t.operator(DYPE_XSECT, "&", args, NodNew(DYPE_ALL))
+}
+
+// the operator over args in normal form, or just the arg if there's one, or
+// none if there are none
+func (t *dypeTable) operator(nt int, sep string, args []Nod, none Nod) Nod {
+	if nt == DYPE_UNION {
+		t.sort(args)
+	}
+	if len(args) == 1 {
+		return args[0]
+	} else if len(args) == 0 {
+		key := "*"
+		if none.NodeType == DYPE_EMPTY {
+			key = "0"
+		}
+		return t.intern(key, func() Nod { return none })
+	}
+	keys := []string{}
+	for _, arg := range args {
+		keys = append(keys, t.key(arg))
+	}
+	return t.intern(sep+"("+strings.Join(keys, ",")+")", func() Nod { return NodNewChildList(nt, args) })
+}
+
+func (t *dypeTable) sort(ns []Nod) {
+	sort.Slice(ns, func(i, j int) bool { return t.key(ns[i]) < t.key(ns[j]) })
+}
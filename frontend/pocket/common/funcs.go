@@ -0,0 +1,20 @@
+package common
+
+import (
+	. "pocket-lang/parse"
+)
+
+// FuncDefParams returns the params a func def takes, in order. A func of one
+// param has it as its in type, and one that takes nothing has an in type of
+// void.
+func FuncDefParams(fDef Nod) []Nod {
+	inType := NodGetChildOrNil(fDef, NTR_FUNCDEF_INTYPE)
+	if inType == nil {
+		return []Nod{}
+	} else if inType.NodeType == NT_PARAMETER {
+		return []Nod{inType}
+	} else if inType.NodeType == NT_LIT_LIST {
+		return NodGetChildList(inType)
+	}
+	return []Nod{} // func() is in type void
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	. "pocket-lang/parse"
+	"sort"
+)
+
+// The lattice of base types, as which type directly contains which. Every
+// numeric type is a number, and the integer ones are integers on the way;
+// every class is an object; and duck contains everything. number, integer
+// and object are never a value's own type, so they aren't covered by what's
+// under them.
+
+var baseTypeParents = map[int]int{
+	TY_INTEGER: TY_NUMBER,
+	TY_NUMBER:  TY_DUCK,
+	TY_OBJECT:  TY_DUCK,
+	TY_BOOL:    TY_DUCK,
+	TY_STRING:  TY_DUCK,
+	TY_LIST:    TY_DUCK,
+	TY_MAP:     TY_DUCK,
+	TY_SET:     TY_DUCK,
+	TY_FUNC:    TY_DUCK,
+}
+
+func init() {
+	for _, ty := range NumericTypes() {
+		if IsIntegerType(ty) {
+			baseTypeParents[ty] = TY_INTEGER
+		} else {
+			baseTypeParents[ty] = TY_NUMBER
+		}
+	}
+}
+
+// whether every sub is a super
+func BaseTypeIsSubtype(sub int, super int) bool {
+	for ty, ok := sub, true; ok; ty, ok = baseTypeParents[ty] {
+		if ty == super {
+			return true
+		}
+	}
+	return super == TY_DUCK
+}
+
+// the types under ty that nothing is under, or just ty if nothing is, the
+// numeric ones in their canonical order
+func BaseTypeLeaves(ty int) []int {
+	hasSubtypes := map[int]bool{}
+	for _, parent := range baseTypeParents {
+		hasSubtypes[parent] = true
+	}
+	if !hasSubtypes[ty] {
+		return []int{ty}
+	}
+	order := map[int]int{}
+	for ndx, numTy := range NumericTypes() {
+		order[numTy] = ndx
+	}
+	leaves := []int{}
+	for sub := range baseTypeParents {
+		if !hasSubtypes[sub] && BaseTypeIsSubtype(sub, ty) {
+			leaves = append(leaves, sub)
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		oi, iNumeric := order[leaves[i]]
+		oj, jNumeric := order[leaves[j]]
+		if iNumeric != jNumeric {
+			return iNumeric
+		} else if iNumeric {
+			return oi < oj
+		}
+		return leaves[i] < leaves[j]
+	})
+	return leaves
+}
+
+// whether the non-meta dype sub is in super by the lattice, which only
+// orders base types and classes: parameterized types only contain themselves
+// and duck holds them
+func dypeIsSubtype(sub Nod, super Nod) bool {
+	if super.NodeType == NT_TYPEBASE && super.Data.(int) == TY_DUCK {
+		return true
+	}
+	if DypeDeepForwardsEqual(sub, super) {
+		return true
+	}
+	if super.NodeType != NT_TYPEBASE {
+		return false
+	}
+	switch sub.NodeType {
+	case NT_TYPEBASE:
+		return BaseTypeIsSubtype(sub.Data.(int), super.Data.(int))
+	case NT_CLASSDEF:
+		return BaseTypeIsSubtype(TY_OBJECT, super.Data.(int))
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	. "pocket-lang/parse"
+	"strings"
+	"sync"
+)
+
+// The compiler's diagnostics go through channels, one per pass, each with its
+// own level. Everything is off by default, and what's on goes to stderr, so
+// stdout only ever has the program's output. Turn channels on with a spec
+// like solve:debug,parse:trace, or just debug for all of them.
+
+const (
+	LOG_OFF = iota
+	LOG_ERROR
+	LOG_WARN
+	LOG_INFO
+	LOG_DEBUG
+	LOG_TRACE
+)
+
+var logLevelNames = []string{"off", "error", "warn", "info", "debug", "trace"}
+
+const (
+	LOGCH_TOKENIZE = "tokenize"
+	LOGCH_PARSE    = "parse"
+	LOGCH_XFORM    = "xform" // the passes starting and the tree between them
+	LOGCH_PREPARE  = "prepare"
+	LOGCH_DESUGAR  = "desugar"
+	LOGCH_SOLVE    = "solve"
+	LOGCH_CHECK    = "check" // what the checks after solving warn about
+	LOGCH_GEN      = "gen"
+	LOGCH_RUN      = "run" // building and running generated code
+)
+
+var LogChannels = []string{LOGCH_TOKENIZE, LOGCH_PARSE, LOGCH_XFORM, LOGCH_PREPARE,
+	LOGCH_DESUGAR, LOGCH_SOLVE, LOGCH_CHECK, LOGCH_GEN, LOGCH_RUN}
+
+type Logger struct {
+	mu     sync.Mutex // tests compile from several goroutines at once
+	out    io.Writer
+	levels map[string]int
+}
+
+var LOG *Logger = &Logger{out: os.Stderr, levels: map[string]int{}} // singleton
+
+// turns on the channels in spec, e.g. solve:debug,parse:trace, and turns the
+// rest off. A level on its own applies to every channel.
+func SetLogLevels(spec string) error {
+	levels := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		channels, levelName := LogChannels, part
+		if ndx := strings.Index(part, ":"); ndx >= 0 {
+			channels, levelName = []string{part[:ndx]}, part[ndx+1:]
+			if !isLogChannel(channels[0]) {
+				return fmt.Errorf("unknown log channel %s, expected one of %s", channels[0], strings.Join(LogChannels, ", "))
+			}
+		}
+		level := logLevel(levelName)
+		if level < 0 {
+			return fmt.Errorf("unknown log level %s, expected one of %s", levelName, strings.Join(logLevelNames, ", "))
+		}
+		for _, ch := range channels {
+			levels[ch] = level
+		}
+	}
+	LOG.mu.Lock()
+	defer LOG.mu.Unlock()
+	LOG.levels = levels
+	return nil
+}
+
+// where the messages go, nil for stderr
+func SetLogOutput(w io.Writer) {
+	if w == nil {
+		w = os.Stderr
+	}
+	LOG.mu.Lock()
+	defer LOG.mu.Unlock()
+	LOG.out = w
+}
+
+func isLogChannel(name string) bool {
+	for _, ch := range LogChannels {
+		if ch == name {
+			return true
+		}
+	}
+	return false
+}
+
+func logLevel(name string) int {
+	for level, levelName := range logLevelNames {
+		if levelName == name {
+			return level
+		}
+	}
+	return -1
+}
+
+func LogEnabled(ch string, level int) bool {
+	LOG.mu.Lock()
+	defer LOG.mu.Unlock()
+	return LOG.levels[ch] >= level
+}
+
+// writes a message, prefixed with where it's from, if its channel is on at
+// level. The args are only formatted then, so see LazyTree for dumps.
+func Logf(ch string, level int, format string, args ...interface{}) {
+	if !LogEnabled(ch, level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	LOG.mu.Lock()
+	defer LOG.mu.Unlock()
+	fmt.Fprintf(LOG.out, "[%s:%s] %s\n", ch, logLevelNames[level], strings.TrimSuffix(msg, "\n"))
+}
+
+func Warnf(ch string, format string, args ...interface{}) {
+	Logf(ch, LOG_WARN, format, args...)
+}
+
+func Infof(ch string, format string, args ...interface{}) {
+	Logf(ch, LOG_INFO, format, args...)
+}
+
+func Debugf(ch string, format string, args ...interface{}) {
+	Logf(ch, LOG_DEBUG, format, args...)
+}
+
+func Tracef(ch string, format string, args ...interface{}) {
+	Logf(ch, LOG_TRACE, format, args...)
+}
+
+// a log arg that's only rendered if the message is written
+type Lazy func() string
+
+func (l Lazy) String() string {
+	return l()
+}
+
+func LazyTree(n Nod) Lazy {
+	return func() string { return PrettyPrint(n) }
+}
+
+func LazyTrees(nodes []Nod) Lazy {
+	return func() string { return PrettyPrintNodes(nodes) }
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	. "pocket-lang/parse"
+	"strconv"
+)
+
+// NodeTypeNames maps node and edge types to the names of their constants, for
+// tools that show the graph to compiler developers, e.g. pocket dump
+var NodeTypeNames = map[int]string{
+	NT_FLAG:                      "NT_FLAG",
+	NT_IMPERATIVE:                "NT_IMPERATIVE",
+	NT_VARINIT:                   "NT_VARINIT",
+	NT_VARASSIGN:                 "NT_VARASSIGN",
+	NTR_VAR_NAME:                 "NTR_VAR_NAME",
+	NTR_VARASSIGN_VALUE:          "NTR_VARASSIGN_VALUE",
+	NT_VARDEF:                    "NT_VARDEF",
+	NTR_VARDEF_NAME:              "NTR_VARDEF_NAME",
+	NT_VARDEF_SCOPE:              "NT_VARDEF_SCOPE",
+	NTR_VARDEF_SCOPE:             "NTR_VARDEF_SCOPE",
+	NTR_VARDEF:                   "NTR_VARDEF",
+	NT_RECEIVERCALL:              "NT_RECEIVERCALL",
+	NT_RECEIVERCALL_CMD:          "NT_RECEIVERCALL_CMD",
+	NT_RECEIVERCALL_METHOD:       "NT_RECEIVERCALL_METHOD",
+	NTR_RECEIVERCALL_METHOD_NAME: "NTR_RECEIVERCALL_METHOD_NAME",
+	NTR_RECEIVERCALL_BASE:        "NTR_RECEIVERCALL_BASE",
+	NTR_RECEIVERCALL_ARG:         "NTR_RECEIVERCALL_ARG",
+	NTR_RECEIVERCALL_CFG_ARG:     "NTR_RECEIVERCALL_CFG_ARG",
+	NT_IDENTIFIER:                "NT_IDENTIFIER",
+	NT_IDENTIFIER_NOSCOPE:        "NT_IDENTIFIER_NOSCOPE",
+	NT_IDENTIFIER_RVAL:           "NT_IDENTIFIER_RVAL",
+	NT_IDENTIFIER_LVAL:           "NT_IDENTIFIER_LVAL",
+	NT_IDENTIFIER_FUNC_NOSCOPE:   "NT_IDENTIFIER_FUNC_NOSCOPE",
+	NT_IDENTIFIER_TYPE_NOSCOPE:   "NT_IDENTIFIER_TYPE_NOSCOPE",
+	NT_IDENTIFIER_KWARG:          "NT_IDENTIFIER_KWARG",
+	NT_IDENTIFIER_RESOLVED:       "NT_IDENTIFIER_RESOLVED",
+	NT_TYPEBASE:                  "NT_TYPEBASE",
+	NT_TYPE:                      "NT_TYPE",
+	NTR_TYPE:                     "NTR_TYPE",
+	NTR_TYPE_DECL:                "NTR_TYPE_DECL",
+	NT_FUNCDEF_RV_PLACEHOLDER:    "NT_FUNCDEF_RV_PLACEHOLDER",
+	NT_VARTABLE:                  "NT_VARTABLE",
+	NTR_VARTABLE:                 "NTR_VARTABLE",
+	NT_TYPECOND_DEFS:             "NT_TYPECOND_DEFS",
+	NTR_TYPECOND_DEFS:            "NTR_TYPECOND_DEFS",
+	NT_DYPE:                      "NT_DYPE",
+	NTR_MYPE_POS:                 "NTR_MYPE_POS",
+	NTR_MYPE_NEG:                 "NTR_MYPE_NEG",
+	NTR_MYPE_VALID:               "NTR_MYPE_VALID",
+	NT_TOPLEVEL:                  "NT_TOPLEVEL",
+	NTR_TOPLEVEL_IMPERATIVE:      "NTR_TOPLEVEL_IMPERATIVE",
+	NT_FUNCDEF:                   "NT_FUNCDEF",
+	NTR_FUNCDEF:                  "NTR_FUNCDEF",
+	NTR_FUNCTABLE:                "NTR_FUNCTABLE",
+	NT_FUNCTABLE:                 "NT_FUNCTABLE",
+	NT_EMPTYARGLIST:              "NT_EMPTYARGLIST",
+	NT_PARAMETER:                 "NT_PARAMETER",
+	NTR_FUNCDEF_NAME:             "NTR_FUNCDEF_NAME",
+	NTR_FUNCDEF_INTYPE:           "NTR_FUNCDEF_INTYPE",
+	NTR_FUNCDEF_OUTTYPE:          "NTR_FUNCDEF_OUTTYPE",
+	NTR_FUNCDEF_CODE:             "NTR_FUNCDEF_CODE",
+	NT_RETURN:                    "NT_RETURN",
+	NTR_RETURN_VALUE:             "NTR_RETURN_VALUE",
+	NTR_RETURNVAL_PLACEHOLDER:    "NTR_RETURNVAL_PLACEHOLDER",
+	NT_LOOP:                      "NT_LOOP",
+	NTR_LOOP_ARG:                 "NTR_LOOP_ARG",
+	NTR_LOOP_BODY:                "NTR_LOOP_BODY",
+	NT_FOR_IN:                    "NT_FOR_IN",
+	NTR_FOR_IN_ITERVAR:           "NTR_FOR_IN_ITERVAR",
+	NTR_FOR_IN_ITEROVER:          "NTR_FOR_IN_ITEROVER",
+	NTR_FOR_BODY:                 "NTR_FOR_BODY",
+	NT_FOR_CLASSIC:               "NT_FOR_CLASSIC",
+	NTR_FOR_INITIALIZER:          "NTR_FOR_INITIALIZER",
+	NTR_FOR_PROGRESSOR:           "NTR_FOR_PROGRESSOR",
+	NT_BREAK:                     "NT_BREAK",
+	NT_IF:                        "NT_IF",
+	NTR_IF_COND:                  "NTR_IF_COND",
+	NTR_IF_BODY_TRUE:             "NTR_IF_BODY_TRUE",
+	NTR_IF_BODY_FALSE:            "NTR_IF_BODY_FALSE",
+	NT_WHILE:                     "NT_WHILE",
+	NTR_WHILE_COND:               "NTR_WHILE_COND",
+	NTR_WHILE_BODY:               "NTR_WHILE_BODY",
+	NT_PASS:                      "NT_PASS",
+	NT_ADDOP:                     "NT_ADDOP",
+	NT_SUBOP:                     "NT_SUBOP",
+	NT_MULOP:                     "NT_MULOP",
+	NT_DIVOP:                     "NT_DIVOP",
+	NT_POWOP:                     "NT_POWOP",
+	NT_GTOP:                      "NT_GTOP",
+	NT_GTEQOP:                    "NT_GTEQOP",
+	NT_LTOP:                      "NT_LTOP",
+	NT_LTEQOP:                    "NT_LTEQOP",
+	NT_EQOP:                      "NT_EQOP",
+	NT_OROP:                      "NT_OROP",
+	NT_ANDOP:                     "NT_ANDOP",
+	NT_MODOP:                     "NT_MODOP",
+	NT_DOTOP:                     "NT_DOTOP",
+	NT_DOTPIPEOP:                 "NT_DOTPIPEOP",
+	NT_XOROP:                     "NT_XOROP",
+	NT_REFERENCEOP:               "NT_REFERENCEOP",
+	NT_RANGEOP:                   "NT_RANGEOP",
+	NT_RANGEEXCLOP:               "NT_RANGEEXCLOP",
+	NT_RANGESTEPOP:               "NT_RANGESTEPOP",
+	NTR_RANGE_STEP:               "NTR_RANGE_STEP",
+	NTR_BINOP_LEFT:               "NTR_BINOP_LEFT",
+	NTR_BINOP_RIGHT:              "NTR_BINOP_RIGHT",
+	NT_INLINEOPSTREAM:            "NT_INLINEOPSTREAM",
+	NT_VALUE_MOLECULE:            "NT_VALUE_MOLECULE",
+	NT_COLLECTION_INDEXOR:        "NT_COLLECTION_INDEXOR",
+	NT_INCREMENTOR:               "NT_INCREMENTOR",
+	NTR_INCREMENTOR_LVALUE:       "NTR_INCREMENTOR_LVALUE",
+	NTR_INCREMENTOR_OP:           "NTR_INCREMENTOR_OP",
+	NT_INCREMENTOR_OP:            "NT_INCREMENTOR_OP",
+	NT_SHLOP:                     "NT_SHLOP",
+	NT_SHROP:                     "NT_SHROP",
+	NT_NEGOP:                     "NT_NEGOP",
+	NT_POSOP:                     "NT_POSOP",
+	NT_NOTOP:                     "NT_NOTOP",
+	NT_CLASSDEF:                  "NT_CLASSDEF",
+	NTR_CLASSDEF_NAME:            "NTR_CLASSDEF_NAME",
+	NT_CLASSDEFPARTIAL:           "NT_CLASSDEFPARTIAL",
+	NTR_METHOD_SELFDEF:           "NTR_METHOD_SELFDEF",
+	NTR_CLASSDEF:                 "NTR_CLASSDEF",
+	NT_CLASSFIELD:                "NT_CLASSFIELD",
+	NT_OBJFIELD_ACCESSOR:         "NT_OBJFIELD_ACCESSOR",
+	NTR_OBJFIELD_ACCESSOR_NAME:   "NTR_OBJFIELD_ACCESSOR_NAME",
+	NT_CLASSTABLE:                "NT_CLASSTABLE",
+	NTR_CLASSTABLE:               "NTR_CLASSTABLE",
+	NTR_CLASSDEF_STATICZONE:      "NTR_CLASSDEF_STATICZONE",
+	NTR_TABLE_PARENT:             "NTR_TABLE_PARENT",
+	NT_OBJINIT:                   "NT_OBJINIT",
+	NT_REFLECTTYPE:               "NT_REFLECTTYPE",
+	NTR_REFLECTTYPE_CLASSDEF:     "NTR_REFLECTTYPE_CLASSDEF",
+	NTR_LIT_VALUE:                "NTR_LIT_VALUE",
+	NT_LIT_BOOL:                  "NT_LIT_BOOL",
+	NT_LIT_INT:                   "NT_LIT_INT",
+	NT_LIT_FLOAT:                 "NT_LIT_FLOAT",
+	NT_LIT_STRING:                "NT_LIT_STRING",
+	NT_LIT_FSTRING:               "NT_LIT_FSTRING",
+	NT_LIT_LIST:                  "NT_LIT_LIST",
+	NT_LIT_MAP:                   "NT_LIT_MAP",
+	NT_LIT_MAP_KVPAIR:            "NT_LIT_MAP_KVPAIR",
+	NTR_KVPAIR_KEY:               "NTR_KVPAIR_KEY",
+	NTR_KVPAIR_VAL:               "NTR_KVPAIR_VAL",
+	NT_LIT_SET:                   "NT_LIT_SET",
+	NT_LIT_PRIMITIVE:             "NT_LIT_PRIMITIVE",
+	NTR_NAMESPACE:                "NTR_NAMESPACE",
+	NT_NAMESPACE:                 "NT_NAMESPACE",
+	NTR_NAMESPACE_PARENT:         "NTR_NAMESPACE_PARENT",
+	NT_KWARGS:                    "NT_KWARGS",
+	NT_KWARG:                     "NT_KWARG",
+	NT_VAR_GETTER:                "NT_VAR_GETTER",
+	NT_DOTOP_QUALIFIER:           "NT_DOTOP_QUALIFIER",
+	NT_VARASSIGN_ARITH:           "NT_VARASSIGN_ARITH",
+	NTR_VARASSIGN_ARITHOP:        "NTR_VARASSIGN_ARITHOP",
+	NT_TYPECALL:                  "NT_TYPECALL",
+	NT_MODF_STATIC:               "NT_MODF_STATIC",
+	NT_MODF_CONFIG:               "NT_MODF_CONFIG",
+	NT_MODF_PRIVATE:              "NT_MODF_PRIVATE",
+	NT_PRAGMACLAUSE:              "NT_PRAGMACLAUSE",
+	NTR_PRAGMA_BODY:              "NTR_PRAGMA_BODY",
+	NTR_PRAGMAPAINT:              "NTR_PRAGMAPAINT",
+	NT_PRAGMAPAINT:               "NT_PRAGMAPAINT",
+	NT_TESTDEF:                   "NT_TESTDEF",
+	NTR_TESTDEF_CODE:             "NTR_TESTDEF_CODE",
+	NT_ASSERT:                    "NT_ASSERT",
+	NTR_ASSERT_COND:              "NTR_ASSERT_COND",
+	NNT_SYMTABLE:                 "NNT_SYMTABLE",
+	NNTR_SYMTABLE:                "NNTR_SYMTABLE",
+	KNOW_RUNVALUE:                "KNOW_RUNVALUE",
+	KNOW_RUNTYPE:                 "KNOW_RUNTYPE",
+	NNT_RUNVALUE:                 "NNT_RUNVALUE",
+	NNTR_RUNVALUE_TYPE:           "NNTR_RUNVALUE_TYPE",
+	NNTR_KNOWLEDGE:               "NNTR_KNOWLEDGE",
+	NNT_KNOWLEDGE_DISJUNCTION:    "NNT_KNOWLEDGE_DISJUNCTION",
+	DYPE_ALL:                     "DYPE_ALL",
+	DYPE_EMPTY:                   "DYPE_EMPTY",
+	DYPE_UNION:                   "DYPE_UNION",
+	DYPE_XSECT:                   "DYPE_XSECT",
+	DYPE_NOT:                     "DYPE_NOT",
+}
+
+// the name of a node or edge type's constant, [n] for the nth element of a
+// list, or the number if it has no name
+func NodeTypeName(nt int) string {
+	if name, ok := NodeTypeNames[nt]; ok {
+		return name
+	} else if nt >= NTR_LIST_0 && nt < NTR_LIST_MAX {
+		return "[" + strconv.Itoa(nt-NTR_LIST_0) + "]"
+	}
+	return strconv.Itoa(nt)
+}
@@ -78,6 +78,7 @@ const (
 	NT_SUBOP                     = 101
 	NT_MULOP                     = 102
 	NT_DIVOP                     = 103
+	NT_POWOP                     = 104 // a ** b, ints only
 	NT_GTOP                      = 105
 	NT_GTEQOP                    = 106
 	NT_LTOP                      = 107
@@ -88,7 +89,12 @@ const (
 	NT_MODOP                     = 112
 	NT_DOTOP                     = 113
 	NT_DOTPIPEOP                 = 114
+	NT_XOROP                     = 115
 	NT_REFERENCEOP               = 116
+	NT_RANGEOP                   = 117 // a..b, inclusive
+	NT_RANGEEXCLOP               = 118 // a..<b, excludes the upper bound
+	NT_RANGESTEPOP               = 119 // <range> by step, folded into the range during prepare
+	NTR_RANGE_STEP               = 120
 	NTR_BINOP_LEFT               = 122
 	NTR_BINOP_RIGHT              = 123
 	NT_INLINEOPSTREAM            = 125
@@ -98,6 +104,11 @@ const (
 	NTR_INCREMENTOR_LVALUE       = 131
 	NTR_INCREMENTOR_OP           = 132
 	NT_INCREMENTOR_OP            = 134
+	NT_SHLOP                     = 135
+	NT_SHROP                     = 136
+	NT_NEGOP                     = 137 // unary -, like the ref op its arg is NTR_RECEIVERCALL_ARG
+	NT_POSOP                     = 138
+	NT_NOTOP                     = 139
 	NT_CLASSDEF                  = 150
 	NTR_CLASSDEF_NAME            = 151
 	NT_CLASSDEFPARTIAL           = 152
@@ -118,6 +129,7 @@ const (
 	NT_LIT_INT                   = 210
 	NT_LIT_FLOAT                 = 211
 	NT_LIT_STRING                = 215
+	NT_LIT_FSTRING               = 216 // f'..{x}..', children are the string pieces and values in order
 	NT_LIT_LIST                  = 230
 	NT_LIT_MAP                   = 235
 	NT_LIT_MAP_KVPAIR            = 236
@@ -144,6 +156,11 @@ const (
 	NTR_PRAGMAPAINT = 273
 	NT_PRAGMAPAINT  = 274
 
+	NT_TESTDEF       = 280 // test 'name' block, the name is in .Data
+	NTR_TESTDEF_CODE = 281
+	NT_ASSERT        = 282
+	NTR_ASSERT_COND  = 283
+
 	// "NNT": solver type for the nsolver (new solver)
 	// this is just to help keep these types mentally separate
 	// eventually a cleanup needs to be performed and remove unused NTs
@@ -160,18 +177,31 @@ const (
 )
 
 const (
-	TY_VOID   = 1
-	TY_BOOL   = 2
-	TY_INT    = 3
-	TY_FLOAT  = 4
-	TY_STRING = 5
-	TY_SET    = 6
-	TY_MAP    = 7
-	TY_LIST   = 8
-	TY_FUNC   = 15
-	TY_OBJECT = 20
-	TY_NUMBER = 22
-	TY_DUCK   = 30
+	TY_VOID    = 1
+	TY_BOOL    = 2
+	TY_INT     = 3
+	TY_FLOAT   = 4
+	TY_STRING  = 5
+	TY_SET     = 6
+	TY_MAP     = 7
+	TY_LIST    = 8
+	TY_FUNC    = 15
+	TY_OBJECT  = 20
+	TY_NUMBER  = 22
+	TY_INTEGER = 23
+	TY_DUCK    = 30
+
+	// sized numeric types, see numeric.go
+	TY_I8     = 40
+	TY_I16    = 41
+	TY_I32    = 42
+	TY_I64    = 43
+	TY_U8     = 44
+	TY_U16    = 45
+	TY_U32    = 46
+	TY_U64    = 47
+	TY_F32    = 48
+	TY_BIGINT = 49
 )
 
 const (
@@ -0,0 +1,150 @@
+package common
+
+// The numeric tower: which number types exist, what they're called in source,
+// and what type results when two of them meet in an arithmetic op.
+// int is a signed 64 bit integer and float is a 64 bit float (f64).
+
+type numericTypeInfo struct {
+	name     string
+	bits     int // 0 means arbitrary precision
+	isFloat  bool
+	isSigned bool
+}
+
+var numericTypeInfos = map[int]numericTypeInfo{
+	TY_INT:    {"int", 64, false, true},
+	TY_I8:     {"i8", 8, false, true},
+	TY_I16:    {"i16", 16, false, true},
+	TY_I32:    {"i32", 32, false, true},
+	TY_I64:    {"i64", 64, false, true},
+	TY_U8:     {"u8", 8, false, false},
+	TY_U16:    {"u16", 16, false, false},
+	TY_U32:    {"u32", 32, false, false},
+	TY_U64:    {"u64", 64, false, false},
+	TY_BIGINT: {"bigint", 0, false, true},
+	TY_F32:    {"f32", 32, true, true},
+	TY_FLOAT:  {"float", 64, true, true},
+}
+
+// in canonical order, narrowest first within each family
+var numericTypesOrdered = []int{
+	TY_INT, TY_I8, TY_I16, TY_I32, TY_I64,
+	TY_U8, TY_U16, TY_U32, TY_U64, TY_BIGINT,
+	TY_F32, TY_FLOAT,
+}
+
+func NumericTypes() []int {
+	return numericTypesOrdered
+}
+
+func IsNumericType(ty int) bool {
+	_, ok := numericTypeInfos[ty]
+	return ok
+}
+
+func IsIntegerType(ty int) bool {
+	info, ok := numericTypeInfos[ty]
+	return ok && !info.isFloat
+}
+
+func IsFloatType(ty int) bool {
+	info, ok := numericTypeInfos[ty]
+	return ok && info.isFloat
+}
+
+// returns the numeric type written as name in source, e.g. "u8" -> TY_U8
+// int and float are keywords, so they aren't looked up here
+func NumericTypeByName(name string) (int, bool) {
+	if name == "f64" {
+		return TY_FLOAT, true
+	}
+	for _, ty := range numericTypesOrdered {
+		if ty != TY_INT && ty != TY_FLOAT && numericTypeInfos[ty].name == name {
+			return ty, true
+		}
+	}
+	return 0, false
+}
+
+// returns the type a conversion call like u8(x) or int(x) converts to
+func ConversionFuncType(name string) (int, bool) {
+	if name == "int" {
+		return TY_INT, true
+	} else if name == "float" {
+		return TY_FLOAT, true
+	}
+	return NumericTypeByName(name)
+}
+
+func NumericTypeName(ty int) string {
+	return numericTypeInfos[ty].name
+}
+
+// returns the type both operands are converted to before an arithmetic op,
+// and false if they can't be mixed without an explicit conversion
+func NumericPromote(a int, b int) (int, bool) {
+	if a == b {
+		return a, true
+	}
+	ia, ib := numericTypeInfos[a], numericTypeInfos[b]
+	if ia.isFloat || ib.isFloat {
+		if ia.isFloat && ib.isFloat {
+			if ia.bits > ib.bits {
+				return a, true
+			}
+			return b, true
+		}
+		// bigints never silently lose precision to a float
+		if ia.bits == 0 || ib.bits == 0 {
+			return 0, false
+		}
+		if ia.isFloat {
+			return a, true
+		}
+		return b, true
+	}
+	// bigint absorbs any fixed size integer
+	if ia.bits == 0 {
+		return a, true
+	}
+	if ib.bits == 0 {
+		return b, true
+	}
+	if ia.isSigned == ib.isSigned {
+		if ia.bits > ib.bits {
+			return a, true
+		} else if ib.bits > ia.bits {
+			return b, true
+		}
+		// same width, i.e. int and i64: the explicitly sized type wins
+		if a == TY_INT {
+			return b, true
+		}
+		return a, true
+	}
+	// mixed signedness only works if the signed type holds every unsigned value
+	signed, unsigned := a, b
+	if !ia.isSigned {
+		signed, unsigned = b, a
+	}
+	if numericTypeInfos[signed].bits > numericTypeInfos[unsigned].bits {
+		return signed, true
+	}
+	return 0, false
+}
+
+// whether the integer literal v can be stored in a ty without overflowing
+func NumericLiteralFits(ty int, v int64) bool {
+	info := numericTypeInfos[ty]
+	if info.isFloat || info.bits == 0 {
+		return true
+	}
+	if !info.isSigned {
+		return v >= 0 && (info.bits == 64 || v < int64(1)<<uint(info.bits))
+	}
+	if info.bits == 64 {
+		return true
+	}
+	limit := int64(1) << uint(info.bits-1)
+	return v >= -limit && v < limit
+}
@@ -0,0 +1,37 @@
+package common
+
+// A class can say what the operators do with its objects by defining methods
+// with these names, e.g. v + w calls v.add(w) when v is a Vector with an add
+// method that takes w. Only the left operand's class is looked at.
+var OperatorMethodNames = map[int]string{
+	NT_ADDOP:  "add",
+	NT_SUBOP:  "sub",
+	NT_MULOP:  "mul",
+	NT_DIVOP:  "div",
+	NT_MODOP:  "mod",
+	NT_LTOP:   "lt",
+	NT_GTOP:   "gt",
+	NT_LTEQOP: "lteq",
+	NT_GTEQOP: "gteq",
+	NT_EQOP:   "eq",
+}
+
+// the methods that stand in for the rest of the syntax objects can share with
+// the built in types: obj[i] calls obj.index(i), obj.len calls the len method
+// when there's no len field, and print shows what obj.str() returns
+const (
+	INDEX_METHOD_NAME = "index"
+	LEN_METHOD_NAME   = "len"
+	STR_METHOD_NAME   = "str"
+)
+
+// a method named new or init is a constructor: Point(1, 2) makes a Point,
+// then calls its constructor with 1 and 2, see xform/constructors.go
+const (
+	NEW_METHOD_NAME  = "new"
+	INIT_METHOD_NAME = "init"
+)
+
+func IsConstructorName(name string) bool {
+	return name == NEW_METHOD_NAME || name == INIT_METHOD_NAME
+}
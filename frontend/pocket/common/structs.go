@@ -1 +1,7 @@
 package common
+
+// a test block, compiled into a func that takes and returns nothing
+type TestDef struct {
+	Name     string // as written in test 'name'
+	FuncName string
+}
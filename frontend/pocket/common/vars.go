@@ -0,0 +1,18 @@
+package common
+
+import (
+	. "pocket-lang/parse"
+)
+
+// VarDefsRead returns the var defs that some of getters read. A local that
+// isn't among them is never used, which the flow check warns about, and which
+// the go backend has to make up a use of, as go rejects it.
+func VarDefsRead(getters []Nod) map[Nod]bool {
+	read := map[Nod]bool{}
+	for _, getter := range getters {
+		if varDef := NodGetChildOrNil(getter, NTR_VARDEF); varDef != nil {
+			read[varDef] = true
+		}
+	}
+	return read
+}
@@ -0,0 +1,509 @@
+package pocket
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"pocket-lang/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The formatter prints the parse tree back out in one canonical layout:
+// calls always use parens, assignments are spaced as x : 1, func headers
+// are written func(a int) int, and blocks are indented by 4 spaces.
+// Comments aren't in the tree, so they're threaded back in by source line,
+// each one going before the first thing that follows it.
+
+type formatter struct {
+	lines        []string
+	indent       int
+	atBlockStart bool // nothing printed in the current block yet
+	lastItem     int  // index in lines of the last header or statement, where trailing comments go
+	lastLine     int  // source line of the last header or statement
+	src          []string
+	comments     []Comment
+	codeLines    []int // sorted source lines with tokens on them
+	spellings    map[*types.SourceLocation]string
+}
+
+// Format returns src in canonical layout, panicking like Parse if it
+// doesn't parse. Formatting formatted source gives the same source.
+func Format(src string) string {
+	tokens, extras := TokenizeForFormat(src)
+	top := Parse(tokens)
+	f := &formatter{
+		src:       strings.Split(src, "\n"),
+		comments:  extras.Comments,
+		spellings: extras.Spellings,
+	}
+	seen := map[int]bool{}
+	for _, tok := range tokens {
+		if tok.Type != TK_EOL && tok.Type != TK_INCINDENT && tok.Type != TK_DECINDENT && !seen[tok.Line] {
+			seen[tok.Line] = true
+			f.codeLines = append(f.codeLines, tok.Line)
+		}
+	}
+	sort.Ints(f.codeLines)
+
+	for _, unit := range NodGetChildList(top) {
+		if len(f.lines) > 0 && f.lines[len(f.lines)-1] != "" {
+			f.lines = append(f.lines, "")
+		}
+		f.unit(unit)
+	}
+	f.commentsBefore(len(f.src), 0)
+	if len(f.lines) == 0 {
+		return ""
+	}
+	return strings.Join(f.lines, "\n") + "\n"
+}
+
+func (f *formatter) unit(n Nod) {
+	switch n.NodeType {
+	case NT_FUNCDEF:
+		header := NodGetChild(n, NTR_FUNCDEF_NAME).Data.(string) + " " + f.funcHeader(n)
+		code := NodGetChild(n, NTR_FUNCDEF_CODE)
+		if code.NodeType != NT_IMPERATIVE {
+			f.item(n, header+": "+f.value(code))
+			return
+		}
+		f.item(n, header)
+		f.block(code)
+	case NT_CLASSDEF:
+		f.item(n, NodGetChild(n, NTR_CLASSDEF_NAME).Data.(string)+" class")
+		f.block(n)
+	case NT_TESTDEF:
+		f.item(n, "test "+quoteString(n.Data.(string)))
+		f.block(NodGetChild(n, NTR_TESTDEF_CODE))
+	case NT_CLASSFIELD:
+		text := NodGetChild(n, NTR_VARDEF_NAME).Data.(string)
+		if NodHasChild(n, NTR_TYPE_DECL) {
+			text += " " + f.typ(NodGetChild(n, NTR_TYPE_DECL))
+		}
+		if NodHasChild(n, NTR_VARASSIGN_VALUE) {
+			text += " : " + f.value(NodGetChild(n, NTR_VARASSIGN_VALUE))
+		}
+		f.item(n, text)
+	case NT_PRAGMACLAUSE:
+		text := "pragma"
+		for _, modifier := range NodGetChildList(n) {
+			text += " " + modifierNames[modifier.NodeType]
+		}
+		f.item(n, text)
+		f.block(NodGetChild(n, NTR_PRAGMA_BODY))
+	case NT_IMPERATIVE:
+		// a block of its own, without a header
+		f.block(n)
+	case NT_IF:
+		f.item(n, "if "+f.value(NodGetChild(n, NTR_IF_COND)))
+		f.block(NodGetChild(n, NTR_IF_BODY_TRUE))
+		if NodHasChild(n, NTR_IF_BODY_FALSE) {
+			elseBody := NodGetChild(n, NTR_IF_BODY_FALSE)
+			f.item(elseBody, "else")
+			f.block(elseBody)
+		}
+	case NT_WHILE:
+		f.item(n, "while "+f.value(NodGetChild(n, NTR_WHILE_COND)))
+		f.block(NodGetChild(n, NTR_WHILE_BODY))
+	case NT_FOR_IN:
+		f.item(n, "for "+NodGetChild(n, NTR_FOR_IN_ITERVAR).Data.(string)+" in "+
+			f.value(NodGetChild(n, NTR_FOR_IN_ITEROVER)))
+		f.block(NodGetChild(n, NTR_FOR_BODY))
+	case NT_FOR_CLASSIC:
+		f.item(n, "for "+f.statement(NodGetChild(n, NTR_FOR_INITIALIZER))+", "+
+			f.value(NodGetChild(n, NTR_WHILE_COND))+", "+
+			f.statement(NodGetChild(n, NTR_FOR_PROGRESSOR)))
+		f.block(NodGetChild(n, NTR_FOR_BODY))
+	case NT_LOOP:
+		text := "loop"
+		if NodHasChild(n, NTR_LOOP_ARG) {
+			text += " " + f.value(NodGetChild(n, NTR_LOOP_ARG))
+		}
+		f.item(n, text)
+		f.block(NodGetChild(n, NTR_LOOP_BODY))
+	case NT_BREAK:
+		f.item(n, "break")
+	default:
+		f.item(n, f.statement(n))
+	}
+}
+
+var modifierNames = map[int]string{
+	NT_MODF_STATIC:  "static",
+	NT_MODF_CONFIG:  "config",
+	NT_MODF_PRIVATE: "private",
+}
+
+// prints the children of n one level deeper
+func (f *formatter) block(n Nod) {
+	f.indent++
+	f.atBlockStart = true
+	for _, child := range NodGetChildList(n) {
+		f.unit(child)
+	}
+	// comments after the last statement stay in the block if they're indented like it
+	f.commentsBefore(f.nextCodeLine(f.lastLine), 4*f.indent)
+	f.indent--
+	f.atBlockStart = false
+}
+
+// prints one line for n, after the comments that come before it
+func (f *formatter) item(n Nod, text string) {
+	line := f.lastLine
+	if n.Loc != nil {
+		line = n.Loc.Line
+	}
+	f.commentsBefore(line, 0)
+	f.blankFromSource(line)
+	f.lastItem = len(f.lines)
+	f.lastLine = line
+	f.emit(text)
+}
+
+func (f *formatter) emit(text string) {
+	f.lines = append(f.lines, strings.Repeat("    ", f.indent)+text)
+	f.atBlockStart = false
+}
+
+// prints the comments above line, stopping at the first one indented less
+// than minColumn, which belongs to an outer block
+func (f *formatter) commentsBefore(line int, minColumn int) {
+	for len(f.comments) > 0 && f.comments[0].Line < line {
+		comment := f.comments[0]
+		if comment.Trailing {
+			f.lines[f.lastItem] += " " + comment.Text
+		} else if comment.Column < minColumn {
+			return
+		} else {
+			f.blankFromSource(comment.Line)
+			f.emit(comment.Text)
+		}
+		f.comments = f.comments[1:]
+	}
+}
+
+// keeps one blank line where the source had any
+func (f *formatter) blankFromSource(line int) {
+	if line == 0 || line > len(f.src) || strings.TrimSpace(f.src[line-1]) != "" {
+		return
+	}
+	if f.atBlockStart || len(f.lines) == 0 || f.lines[len(f.lines)-1] == "" {
+		return
+	}
+	f.lines = append(f.lines, "")
+}
+
+func (f *formatter) nextCodeLine(line int) int {
+	ndx := sort.SearchInts(f.codeLines, line+1)
+	if ndx == len(f.codeLines) {
+		return len(f.src)
+	}
+	return f.codeLines[ndx]
+}
+
+// statements that fit on one line, also used in for loop headers
+func (f *formatter) statement(n Nod) string {
+	switch n.NodeType {
+	case NT_PASS:
+		return "pass"
+	case NT_ASSERT:
+		return "assert " + f.value(NodGetChild(n, NTR_ASSERT_COND))
+	case NT_RETURN:
+		if NodHasChild(n, NTR_RETURN_VALUE) {
+			return "return " + f.value(NodGetChild(n, NTR_RETURN_VALUE))
+		}
+		return "return"
+	case NT_VARASSIGN:
+		text := f.lvalue(NodGetChild(n, NTR_VAR_NAME))
+		if NodHasChild(n, NTR_TYPE_DECL) {
+			text += " " + f.typ(NodGetChild(n, NTR_TYPE_DECL))
+		}
+		return text + " : " + f.value(NodGetChild(n, NTR_VARASSIGN_VALUE))
+	case NT_VARASSIGN_ARITH:
+		op := NodGetChild(n, NTR_VARASSIGN_ARITHOP).NodeType
+		return f.lvalue(NodGetChild(n, NTR_VAR_NAME)) + " " + opSpellings[op] + ": " +
+			f.value(NodGetChild(n, NTR_VARASSIGN_VALUE))
+	case NT_INCREMENTOR:
+		if NodGetChild(n, NTR_INCREMENTOR_OP).Data.(bool) {
+			return f.lvalue(NodGetChild(n, NTR_INCREMENTOR_LVALUE)) + "++"
+		}
+		return f.lvalue(NodGetChild(n, NTR_INCREMENTOR_LVALUE)) + "--"
+	case NT_RECEIVERCALL_CMD:
+		// print x is written print(x)
+		return f.lvalue(NodGetChild(n, NTR_RECEIVERCALL_BASE)) + f.callArg(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	}
+	panic("can't format statement " + PrettyPrint(n))
+}
+
+var opSpellings = map[int]string{
+	NT_ADDOP:       "+",
+	NT_SUBOP:       "-",
+	NT_MULOP:       "*",
+	NT_DIVOP:       "/",
+	NT_MODOP:       "%",
+	NT_POWOP:       "**",
+	NT_LTOP:        "<",
+	NT_GTOP:        ">",
+	NT_LTEQOP:      "<=",
+	NT_GTEQOP:      ">=",
+	NT_EQOP:        "=",
+	NT_OROP:        "|",
+	NT_ANDOP:       "&",
+	NT_XOROP:       "^",
+	NT_SHLOP:       "<<",
+	NT_SHROP:       ">>",
+	NT_DOTOP:       ".",
+	NT_DOTPIPEOP:   ".>",
+	NT_RANGEOP:     "..",
+	NT_RANGEEXCLOP: "..<",
+	NT_RANGESTEPOP: "by",
+	NT_REFERENCEOP: "@",
+	NT_NEGOP:       "-",
+	NT_POSOP:       "+",
+	NT_NOTOP:       "not",
+}
+
+// ops written without spaces around them, e.g. a.b and 0..9
+func isTightOp(nt int) bool {
+	return nt == NT_DOTOP || nt == NT_DOTPIPEOP || nt == NT_RANGEOP || nt == NT_RANGEEXCLOP
+}
+
+// the target of an assignment or command, where only a dot stream goes bare
+func (f *formatter) lvalue(n Nod) string {
+	if n.NodeType == NT_INLINEOPSTREAM {
+		elems := NodGetChildList(n)
+		for ndx := 1; ndx < len(elems); ndx += 2 {
+			if elems[ndx].NodeType != NT_DOTOP {
+				return f.operand(n)
+			}
+		}
+	}
+	return f.value(n)
+}
+
+// the argument list of a call, including its brackets
+func (f *formatter) callArg(arg Nod) string {
+	switch arg.NodeType {
+	case NT_EMPTYARGLIST:
+		return "()"
+	case NT_LIT_LIST:
+		// a list of two or more is how f(a, b) parses, and one is an index, l[i]
+		elems := NodGetChildList(arg)
+		if len(elems) >= 2 {
+			return "(" + f.values(elems) + ")"
+		} else if len(elems) == 1 {
+			return f.value(arg)
+		}
+	case NT_LIT_MAP, NT_LIT_SET:
+		// keyword args, e.g. Point{x: 1}
+		if len(NodGetChildList(arg)) > 0 {
+			return f.value(arg)
+		}
+	case NT_INLINEOPSTREAM:
+		// a parenthesized molecule, e.g. f(-x), needs no second pair of parens
+		if elems := NodGetChildList(arg); len(elems) == 1 {
+			return "(" + f.value(elems[0]) + ")"
+		}
+	}
+	return "(" + f.value(arg) + ")"
+}
+
+func (f *formatter) values(nodes []Nod) string {
+	texts := []string{}
+	for _, n := range nodes {
+		texts = append(texts, f.value(n))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// a value as it stands on its own, e.g. to the right of a :
+func (f *formatter) value(n Nod) string {
+	switch n.NodeType {
+	case NT_INLINEOPSTREAM:
+		elems := NodGetChildList(n)
+		if len(elems) == 1 {
+			return "(" + f.value(elems[0]) + ")"
+		}
+		text := f.operand(elems[0])
+		for ndx := 1; ndx+1 < len(elems); ndx += 2 {
+			op := elems[ndx].NodeType
+			if isTightOp(op) {
+				text += opSpellings[op]
+			} else {
+				text += " " + opSpellings[op] + " "
+			}
+			text += f.operand(elems[ndx+1])
+		}
+		return text
+	case NT_VALUE_MOLECULE:
+		text := ""
+		for _, elem := range NodGetChildList(n) {
+			spelling, isOp := opSpellings[elem.NodeType]
+			if !isOp {
+				next := f.operand(elem)
+				if strings.HasSuffix(text, "-") && strings.HasPrefix(next, "-") ||
+					strings.HasSuffix(text, "+") && strings.HasPrefix(next, "+") {
+					text += " "
+				}
+				text += next
+			} else if elem.NodeType == NT_NOTOP {
+				text += "not "
+			} else {
+				// - -x, not --x, which is a decrement
+				if strings.HasSuffix(text, spelling) && (spelling == "-" || spelling == "+") {
+					text += " "
+				}
+				text += spelling
+			}
+		}
+		return text
+	case NT_IDENTIFIER, NT_IDENTIFIER_RVAL, NT_IDENTIFIER_RESOLVED:
+		return n.Data.(string)
+	case NT_LIT_BOOL:
+		return strconv.FormatBool(n.Data.(bool))
+	case NT_LIT_INT, NT_LIT_FLOAT, NT_LIT_STRING, NT_LIT_FSTRING:
+		return f.literal(n)
+	case NT_LIT_LIST:
+		return "[" + f.values(NodGetChildList(n)) + "]"
+	case NT_LIT_SET:
+		return "{" + f.values(NodGetChildList(n)) + "}"
+	case NT_LIT_MAP:
+		pairs := []string{}
+		for _, pair := range NodGetChildList(n) {
+			pairs = append(pairs, f.value(NodGetChild(pair, NTR_KVPAIR_KEY))+": "+
+				f.value(NodGetChild(pair, NTR_KVPAIR_VAL)))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case NT_TYPEBASE, NT_TYPECALL:
+		return f.typ(n)
+	case NT_FUNCDEF:
+		code := NodGetChild(n, NTR_FUNCDEF_CODE)
+		if code.NodeType == NT_IMPERATIVE {
+			panic("can't format a func literal with a block body")
+		}
+		return f.funcHeader(n) + ": " + f.value(code)
+	case NT_RECEIVERCALL:
+		text := f.operand(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+		if NodHasChild(n, NTR_RECEIVERCALL_CFG_ARG) {
+			text += "~" + f.value(NodGetChild(n, NTR_RECEIVERCALL_CFG_ARG)) + "~"
+		}
+		if NodHasChild(n, NTR_RECEIVERCALL_ARG) {
+			return text + f.callArg(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+		}
+		return text + "()"
+	}
+	panic("can't format value " + PrettyPrint(n))
+}
+
+// a value inside an op stream or molecule, where an op stream needs parens
+func (f *formatter) operand(n Nod) string {
+	if n.NodeType == NT_INLINEOPSTREAM && len(NodGetChildList(n)) > 1 {
+		return "(" + f.value(n) + ")"
+	}
+	return f.value(n)
+}
+
+// literals are printed as they were written, so 0x1f stays 0x1f
+func (f *formatter) literal(n Nod) string {
+	if spelling, ok := f.spellings[n.Loc]; ok && n.Loc != nil {
+		return spelling
+	}
+	switch n.NodeType {
+	case NT_LIT_INT:
+		return strconv.Itoa(n.Data.(int))
+	case NT_LIT_FLOAT:
+		text := strconv.FormatFloat(n.Data.(float64), 'g', -1, 64)
+		if !strings.ContainsAny(text, ".e") {
+			text += ".0"
+		}
+		return text
+	case NT_LIT_STRING:
+		return quoteString(n.Data.(string))
+	}
+	panic("can't format literal " + PrettyPrint(n))
+}
+
+func quoteString(s string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "'", "\\'", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+	return "'" + replacer.Replace(s) + "'"
+}
+
+// func, with its parameters and return type if it has them
+func (f *formatter) funcHeader(fDef Nod) string {
+	text := "func"
+	if !NodHasChild(fDef, NTR_FUNCDEF_INTYPE) {
+		return text
+	}
+	in := NodGetChild(fDef, NTR_FUNCDEF_INTYPE)
+	switch {
+	case in.NodeType == NT_TYPEBASE && in.Data == TY_VOID:
+		text += "()"
+	case in.NodeType == NT_PARAMETER:
+		text += "(" + f.parameter(in) + ")"
+	case in.NodeType == NT_LIT_LIST:
+		params := []string{}
+		for _, param := range NodGetChildList(in) {
+			params = append(params, f.parameter(param))
+		}
+		if len(params) < 2 {
+			// a list of one parameter is only written with brackets
+			text += "[" + strings.Join(params, ", ") + "]"
+		} else {
+			text += "(" + strings.Join(params, ", ") + ")"
+		}
+	default:
+		text += " " + f.typ(in)
+	}
+	if NodHasChild(fDef, NTR_FUNCDEF_OUTTYPE) {
+		out := NodGetChild(fDef, NTR_FUNCDEF_OUTTYPE)
+		if out.NodeType == NT_PARAMETER {
+			text += " (" + f.parameter(out) + ")"
+		} else {
+			text += " " + f.typ(out)
+		}
+	}
+	return text
+}
+
+func (f *formatter) parameter(n Nod) string {
+	text := NodGetChild(n, NTR_VARDEF_NAME).Data.(string)
+	if NodHasChild(n, NTR_TYPE_DECL) {
+		text += " " + f.typ(NodGetChild(n, NTR_TYPE_DECL))
+	}
+	return text
+}
+
+var keywordTypeNames = map[int]string{
+	TY_VOID:   "void",
+	TY_BOOL:   "bool",
+	TY_INT:    "int",
+	TY_FLOAT:  "float",
+	TY_STRING: "string",
+	TY_LIST:   "list",
+	TY_SET:    "set",
+	TY_MAP:    "map",
+}
+
+func (f *formatter) typ(n Nod) string {
+	switch n.NodeType {
+	case NT_TYPEBASE:
+		if name, ok := keywordTypeNames[n.Data.(int)]; ok {
+			return name
+		}
+		return NumericTypeName(n.Data.(int))
+	case NT_IDENTIFIER:
+		return n.Data.(string)
+	case NT_LIT_BOOL:
+		return f.value(n)
+	case NT_TYPECALL:
+		base := f.typ(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+		arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+		if arg.NodeType == NT_TYPEBASE || arg.NodeType == NT_IDENTIFIER {
+			return base + " " + f.typ(arg)
+		}
+		// a config arg, e.g. list~3~
+		return base + "~" + f.value(arg) + "~"
+	}
+	panic("can't format type " + PrettyPrint(n))
+}
@@ -1,52 +1,189 @@
 package pocket
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 	"pocket-lang/types"
 	"strconv"
+	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 )
 
 type ParserPocket struct {
 	*Parser
+
+	// whether an op stream being parsed has a range op without its step yet,
+	// so a command's arg can't start with by, e.g. 0..n by 2
+	rangeOpen bool
+}
+
+// how tokens read in parse errors
+var tokenNames = map[int]string{
+	TK_EOL:           "end of line",
+	TK_INCINDENT:     "an indented block",
+	TK_DECINDENT:     "end of block",
+	TK_LITERALINT:    "an int",
+	TK_LITERALFLOAT:  "a float",
+	TK_LITERALSTRING: "a string",
+	TK_LITERALFSTR:   "an f-string",
+	TK_ALPHANUM:      "a name",
+	TK_COLON:         "':'",
+	TK_DOT:           "'.'",
+	TK_COMMA:         "','",
+	TK_TILDE:         "'~'",
+	TK_PARENL:        "'('",
+	TK_PARENR:        "')'",
+	TK_BRACKL:        "'['",
+	TK_BRACKR:        "']'",
+	TK_CURLYL:        "'{'",
+	TK_CURLYR:        "'}'",
+	TK_IF:            "'if'",
+	TK_ELSE:          "'else'",
+	TK_LOOP:          "'loop'",
+	TK_FOR:           "'for'",
+	TK_IN:            "'in'",
+	TK_WHILE:         "'while'",
+	TK_BREAK:         "'break'",
+	TK_PASS:          "'pass'",
+	TK_RETURN:        "'return'",
+	TK_CLASS:         "'class'",
+	TK_PRAGMA:        "'pragma'",
+	TK_TRUE:          "'true'",
+	TK_FALSE:         "'false'",
+	TK_VOID:          "'void'",
+	TK_BOOL:          "'bool'",
+	TK_INT:           "'int'",
+	TK_FLOAT:         "'float'",
+	TK_STRING:        "'string'",
+	TK_LIST:          "'list'",
+	TK_SET:           "'set'",
+	TK_MAP:           "'map'",
+}
+
+func newParser(tokens []types.Token) *ParserPocket {
+	return &ParserPocket{
+		Parser: &Parser{
+			Input:      tokens,
+			Pos:        0,
+			TokenNames: tokenNames,
+		},
+	}
 }
 
+// panics with ParseErrors if anything in the source doesn't parse
 func Parse(tokens []types.Token) Nod {
-
-	parser := &ParserPocket{
-		&Parser{
-			Input: tokens,
-			Pos:   0,
-		},
+	rv, errs := ParseWithErrors(tokens)
+	if len(errs) > 0 {
+		panic(errs)
 	}
+	return rv
+}
 
-	return parser.parseTopLevel()
+// ParseWithErrors parses as much as it can, skipping lines and units that
+// don't parse, and returns what it got along with everything it skipped
+func ParseWithErrors(tokens []types.Token) (Nod, ParseErrors) {
+	parser := newParser(tokens)
+	rv := parser.parseTopLevel()
+	return rv, ParseErrors(parser.Errors)
 }
 
 func (p *ParserPocket) parseTopLevel() Nod {
-	units := p.ParseManyGreedy(func() Nod {
-		return p.parseTopLevelUnit()
-	})
+	units := []Nod{}
+	for !p.IsEOF() {
+		units = append(units, p.parseUnitsRecovering(func() Nod {
+			return p.parseTopLevelUnit()
+		})...)
+		if !p.IsEOF() {
+			// a dedent with no block to close
+			p.RecordError(&ParseError{Msg: "unexpected end of block", Location: p.CurrToken().SourceLocation})
+			p.Pos++
+		}
+	}
 
-	fmt.Println("top level units:", PrettyPrintNodes(units))
+	Debugf(LOGCH_PARSE, "top level units: %s", LazyTrees(units))
 
-	if !p.IsEOF() {
-		p.RaiseParseError("failed to consume all input")
+	return NodNewChildList(NT_TOPLEVEL, units)
+}
+
+// parses units up to the end of the block. A unit that doesn't parse is
+// recorded as an error and skipped up to the next line at the same depth,
+// so one syntax error doesn't hide the rest of the source.
+func (p *ParserPocket) parseUnitsRecovering(parseUnit ParseFunc) []Nod {
+	units := []Nod{}
+	for !p.IsEOF() && p.CurrToken().Type != TK_DECINDENT {
+		start := p.Pos
+		p.ResetFurthest()
+		unit, err := p.Tryparse(parseUnit)
+		if err == nil {
+			units = append(units, unit)
+			continue
+		}
+		p.RecordError(p.FurthestError())
+		p.Pos = start
+		p.skipToNextLine()
+		if p.Pos == start {
+			p.Pos++
+		}
 	}
+	return units
+}
 
-	return NodNewChildList(NT_TOPLEVEL, units)
+// skips the rest of the line along with any block it opens
+func (p *ParserPocket) skipToNextLine() {
+	depth := 0
+	for !p.IsEOF() {
+		ty := p.CurrToken().Type
+		if ty == TK_DECINDENT {
+			if depth == 0 {
+				return
+			}
+			depth--
+			p.Pos++
+			if depth == 0 {
+				return
+			}
+			continue
+		}
+		if ty == TK_INCINDENT {
+			depth++
+		}
+		p.Pos++
+		if ty == TK_EOL && depth == 0 && (p.IsEOF() || p.CurrToken().Type != TK_INCINDENT) {
+			return
+		}
+	}
 }
 
 func (p *ParserPocket) parseTopLevelUnit() Nod {
-	return p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseFuncDefTL() },
-		func() Nod { return p.parseClassDef() },
+	return p.Expecting("a func, class or test", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseFuncDefTL() },
+			func() Nod { return p.parseClassDef() },
+			func() Nod { return p.parseTestDef() },
+		})
 	})
 }
 
+func (p *ParserPocket) parseTestDef() Nod {
+	// test 'name', with its statements in a block
+	p.parseKeywordName("test")
+	name := p.ParseToken(TK_LITERALSTRING).Data
+	p.parseEOL()
+	body := p.parseImperativeBlock()
+	rv := NodNewData(NT_TESTDEF, name)
+	NodSetChild(rv, NTR_TESTDEF_CODE, body)
+	return rv
+}
+
+// func, test, assert and by aren't reserved, they're only keywords where the grammar expects them
+func (p *ParserPocket) parseKeywordName(name string) {
+	if p.IsEOF() || p.CurrToken().Type != TK_ALPHANUM || p.CurrToken().Data != name {
+		p.RaiseExpected("'" + name + "'")
+	}
+	p.Pos++
+}
+
 func (p *ParserPocket) parseFuncDefTL() Nod {
 	funcName := p.ParseToken(TK_ALPHANUM).Data
 	// TODO: modifiers parsed here
@@ -85,10 +222,7 @@ func (p *ParserPocket) parseFuncDefOneline() Nod {
 }
 
 func (p *ParserPocket) parseFuncHeaderInto(fDef Nod) {
-	funcWord := p.ParseToken(TK_ALPHANUM).Data
-	if funcWord != "func" {
-		p.RaiseParseError("missing func keyword")
-	}
+	p.parseKeywordName("func")
 	// parse function type declarations if extant
 	// for now, if they are extant, require an explicit in type and explicit out type
 	funcInputType := p.ParseAtMostOne(func() Nod { return p.parseFuncDefTypeValue() })
@@ -118,7 +252,7 @@ func (p *ParserPocket) parseFuncDefClassic() Nod {
 }
 
 func (p *ParserPocket) parseImperative() Nod {
-	units := p.ParseAtLeastOneGreedy(func() Nod {
+	units := p.parseUnitsRecovering(func() Nod {
 		return p.parseImperativeUnit()
 	})
 	rv := NodNewChildList(NT_IMPERATIVE, units)
@@ -126,14 +260,17 @@ func (p *ParserPocket) parseImperative() Nod {
 }
 
 func (p *ParserPocket) parseImperativeUnit() Nod {
-	return p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseIf() },
-		func() Nod { return p.parseWhile() },
-		func() Nod { return p.parseFor() },
-		func() Nod { return p.parseLoop() },
-		func() Nod { return p.parseBreak() },
-		func() Nod { return p.parseImperativeBlock() },
-		func() Nod { return p.parseStatement() },
+	return p.Expecting("a statement", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseIf() },
+			func() Nod { return p.parseWhile() },
+			func() Nod { return p.parseFor() },
+			func() Nod { return p.parseLoop() },
+			func() Nod { return p.parseBreak() },
+			func() Nod { return p.parseImperativeBlock() },
+			func() Nod { return p.parseAssert() },
+			func() Nod { return p.parseStatement() },
+		})
 	})
 }
 
@@ -174,6 +311,13 @@ func (p *ParserPocket) parseForIn() Nod {
 	return rv
 }
 
+func (p *ParserPocket) parseAssert() Nod {
+	p.parseKeywordName("assert")
+	cond := p.parseValue()
+	p.parseEOL()
+	return NodNewChild(NT_ASSERT, NTR_ASSERT_COND, cond)
+}
+
 func (p *ParserPocket) parseBreak() Nod {
 	p.ParseToken(TK_BREAK)
 	p.parseEOL()
@@ -246,19 +390,20 @@ func (p *ParserPocket) parseClassDefBlock() Nod {
 }
 
 func (p *ParserPocket) parseClassDefBlockInternals() Nod {
-	units := p.ParseAtLeastOneGreedy(func() Nod {
+	units := p.parseUnitsRecovering(func() Nod {
 		return p.parseClassDefUnit()
 	})
 	return NodNewChildList(NT_CLASSDEFPARTIAL, units)
 }
 
 func (p *ParserPocket) parseClassDefUnit() Nod {
-	rv := p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseFuncDefTL() },
-		func() Nod { return p.parseClassDefField() },
-		func() Nod { return p.parsePragma(func() Nod { return p.parseClassDefBlockInternals() }) },
+	return p.Expecting("a field or method", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseFuncDefTL() },
+			func() Nod { return p.parseClassDefField() },
+			func() Nod { return p.parsePragma(func() Nod { return p.parseClassDefBlockInternals() }) },
+		})
 	})
-	return rv
 }
 
 func (p *ParserPocket) parsePragma(innerUnitParser func() Nod) Nod {
@@ -287,7 +432,8 @@ func (p *ParserPocket) parseModifier() Nod {
 	} else if adata == "private" {
 		return NodNew(NT_MODF_PRIVATE)
 	} else {
-		p.RaiseParseError("invalid modifier")
+		p.Pos--
+		p.RaiseParseError("invalid modifier " + adata)
 		return nil
 	}
 }
@@ -440,10 +586,12 @@ func (p *ParserPocket) parseVarName() Nod {
 }
 
 func (p *ParserPocket) parseValue() Nod {
-	return p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseValueInlineOpStream() },
-		func() Nod { return p.parseValueParenthetical() },
-		func() Nod { return p.parseValueMolecular() },
+	return p.Expecting("a value", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseValueInlineOpStream() },
+			func() Nod { return p.parseValueParenthetical() },
+			func() Nod { return p.parseValueMolecular() },
+		})
 	})
 }
 
@@ -451,20 +599,37 @@ func (p *ParserPocket) parseValueParenthetical() Nod {
 	p.ParseToken(TK_PARENL)
 	innerVal := p.parseValue()
 	p.ParseToken(TK_PARENR)
+	if innerVal.NodeType == NT_VALUE_MOLECULE {
+		// keeps e.g. (@a).b from becoming @(a.b) once it's in an op stream
+		return NodNewChildList(NT_INLINEOPSTREAM, []Nod{innerVal})
+	}
 	return innerVal
 }
 
 func (p *ParserPocket) parseValueInlineOpStream() Nod {
+	defer func(outerOpen bool) { p.rangeOpen = outerOpen }(p.rangeOpen)
+	outerOpen, open := p.rangeOpen, false
 	elements := p.ParseUnrolledSequenceGreedy([]ParseFunc{
 		func() Nod { return p.parseValueMolecular() },
-		func() Nod { return p.parseInlineOp() },
+		func() Nod {
+			op := p.parseInlineOpInRange(open)
+			switch op.NodeType {
+			case NT_RANGEOP, NT_RANGEEXCLOP:
+				open = true
+			case NT_RANGESTEPOP:
+				open = false
+			}
+			p.rangeOpen = outerOpen || open
+			return op
+		},
 	})
 
+	// the element that failed to parse already said why
 	if len(elements) < 3 {
-		p.RaiseParseError("invalid op stream")
+		p.Reject("invalid op stream")
 	}
 	if len(elements)%2 != 1 {
-		p.RaiseParseError("invalid op stream")
+		p.Reject("invalid op stream")
 	}
 
 	return NodNewChildList(NT_INLINEOPSTREAM, elements)
@@ -488,19 +653,20 @@ func (p *ParserPocket) parseValueMolecular() Nod {
 	elems = append(elems, atom)
 	elems = append(elems, suffixOps...)
 	rv := NodNewChildList(NT_VALUE_MOLECULE, elems)
-	fmt.Println("returning molecule:", PrettyPrint(rv))
+	Tracef(LOGCH_PARSE, "returning molecule: %s", LazyTree(rv))
 	return rv
 }
 
 func (p *ParserPocket) parsePrefixOp() Nod {
 	optok := p.ParseTokenOnCondition(func(t *types.Token) bool {
-		return t.Type == TK_REF
+		return t.Type == TK_REF || t.Type == TK_SUBOP || t.Type == TK_ADDOP ||
+			t.Type == TK_NOT
 	})
 	return NodNew(p.prefixOpTokenToNT(optok.Type))
 }
 
 func (p *ParserPocket) parseSuffixOp() Nod {
-	p.RaiseParseError("no suffix ops")
+	p.Reject("no suffix ops")
 	return nil
 }
 
@@ -522,7 +688,7 @@ func (p *ParserPocket) parseLValueDotStream() Nod {
 	}
 	dotSeq := p.ParseUnrolledSequenceGreedy(dotPattern)
 	if len(dotSeq) < 2 {
-		p.RaiseParseError("not a dot stream")
+		p.Reject("not a dot stream")
 	}
 	streamNods := []Nod{baseVal}
 	streamNods = append(streamNods, dotSeq...)
@@ -532,11 +698,14 @@ func (p *ParserPocket) parseLValueDotStream() Nod {
 }
 
 func (p *ParserPocket) parseValueAtomic() Nod {
-	return p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseValueParenthetical() },
-		func() Nod { return p.parseLiteral() },
-		func() Nod { return p.parseReceiverCall() },
-		func() Nod { return p.parseValueIdentifier() },
+	return p.Expecting("a value", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseValueParenthetical() },
+			func() Nod { return p.parseReceiverCallConversion() },
+			func() Nod { return p.parseLiteral() },
+			func() Nod { return p.parseReceiverCall() },
+			func() Nod { return p.parseValueIdentifier() },
+		})
 	})
 }
 
@@ -544,6 +713,7 @@ func (p *ParserPocket) parseLiteral() Nod {
 	return p.ParseDisjunction([]ParseFunc{
 		func() Nod { return p.parseLiteralKeyword() },
 		func() Nod { return p.parseLiteralString() },
+		func() Nod { return p.parseLiteralFString() },
 		func() Nod { return p.parseLiteralList() },
 		func() Nod { return p.parseLiteralSet() },
 		func() Nod { return p.parseLiteralMap() },
@@ -555,18 +725,24 @@ func (p *ParserPocket) parseLiteral() Nod {
 
 func (p *ParserPocket) parseLiteralInt() Nod {
 	tok := p.ParseToken(TK_LITERALINT)
-	ival, err := strconv.Atoi(tok.Data)
+	base := 10
+	if len(tok.Data) > 1 && tok.Data[0] == '0' && !isDigit(rune(tok.Data[1])) {
+		base = 0 // let the 0x/0o/0b prefix decide
+	}
+	ival, err := strconv.ParseInt(tok.Data, base, 64)
 	if err != nil {
-		p.RaiseParseError("in int literal")
+		p.Pos--
+		p.RaiseParseError("int literal " + tok.Data + " out of range, use bigint('" + tok.Data + "')")
 	}
-	return NodNewData(NT_LIT_INT, ival)
+	return NodNewData(NT_LIT_INT, int(ival))
 }
 
 func (p *ParserPocket) parseLiteralFloat() Nod {
 	tok := p.ParseToken(TK_LITERALFLOAT)
 	ival, err := strconv.ParseFloat(tok.Data, 64)
 	if err != nil {
-		p.RaiseParseError("in float literal")
+		p.Pos--
+		p.RaiseParseError("invalid float literal " + tok.Data)
 	}
 	return NodNewData(NT_LIT_FLOAT, ival)
 
@@ -577,6 +753,68 @@ func (p *ParserPocket) parseLiteralString() Nod {
 	return NodNewData(NT_LIT_STRING, tkn.Data)
 }
 
+func (p *ParserPocket) parseLiteralFString() Nod {
+	// splits f'a {x} b' into the pieces 'a ', x, ' b'
+	// {{ and }} stand for literal braces
+	tkn := p.ParseToken(TK_LITERALFSTR)
+	pieces := []Nod{}
+	var lit []rune
+	flushLit := func() {
+		if len(lit) > 0 {
+			pieces = append(pieces, NodNewData(NT_LIT_STRING, string(lit)))
+			lit = nil
+		}
+	}
+	runes := []rune(tkn.Data)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '{' && i+1 < len(runes) && runes[i+1] == '{' {
+			lit = append(lit, '{')
+			i++
+		} else if r == '}' && i+1 < len(runes) && runes[i+1] == '}' {
+			lit = append(lit, '}')
+			i++
+		} else if r == '{' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				p.RaiseParseError("unclosed { in interpolated string")
+			}
+			flushLit()
+			pieces = append(pieces, p.parseInterpolatedValue(string(runes[i+1:end])))
+			i = end
+		} else if r == '}' {
+			p.RaiseParseError("unmatched } in interpolated string")
+		} else {
+			lit = append(lit, r)
+		}
+	}
+	flushLit()
+	return NodNewChildList(NT_LIT_FSTRING, pieces)
+}
+
+func (p *ParserPocket) parseInterpolatedValue(src string) Nod {
+	// the placeholder is parsed as a standalone value with its own tokenizer
+	if strings.TrimSpace(src) == "" {
+		p.RaiseParseError("empty {} in interpolated string")
+	}
+	sub := newParser(Tokenize(strings.TrimSpace(src)))
+	val, err := sub.Tryparse(func() Nod {
+		rv := sub.parseValue()
+		sub.parseEOL()
+		if !sub.IsEOF() {
+			sub.RaiseParseError("trailing input")
+		}
+		return rv
+	})
+	if err != nil {
+		p.RaiseParseError("invalid value '" + src + "' in interpolated string")
+	}
+	return val
+}
+
 func (p *ParserPocket) parseLiteralSet() Nod {
 	p.ParseToken(TK_CURLYL)
 	elements := p.parseManyOptDelimited(func() Nod { return p.parseValue() },
@@ -646,6 +884,16 @@ func (p *ParserPocket) parseFalse() Nod {
 	return NodNewData(NT_LIT_BOOL, false)
 }
 
+func (p *ParserPocket) parseNumericTypeName() Nod {
+	// sized numeric types like i8 and u32 aren't keywords, just reserved type names
+	tok := p.ParseTokenOnCondition(func(t *types.Token) bool {
+		_, ok := NumericTypeByName(t.Data)
+		return t.Type == TK_ALPHANUM && ok
+	})
+	ty, _ := NumericTypeByName(tok.Data)
+	return NodNewData(NT_TYPEBASE, ty)
+}
+
 func (p *ParserPocket) parseKeywordPrimitive(tokenType int, nodeType int, data interface{}) Nod {
 	p.ParseToken(tokenType)
 	return NodNewData(nodeType, data)
@@ -676,15 +924,18 @@ func (p *ParserPocket) parseFDTEmptyParen(tok0 int, tok1 int) Nod {
 }
 
 func (p *ParserPocket) parseType() Nod {
-	return p.ParseDisjunction([]ParseFunc{
-		func() Nod { return p.parseTypeArged() },
-		func() Nod { return p.parseTypeBase() },
+	return p.Expecting("a type", func() Nod {
+		return p.ParseDisjunction([]ParseFunc{
+			func() Nod { return p.parseTypeArged() },
+			func() Nod { return p.parseTypeBase() },
+		})
 	})
 }
 
 func (p *ParserPocket) parseTypeBase() Nod {
 	return p.ParseDisjunction([]ParseFunc{
 		func() Nod { return p.parseLiteralKeyword() },
+		func() Nod { return p.parseNumericTypeName() },
 		// TODO: add support for scoped type identifiers (e.g Geometry.Point)
 		func() Nod { return p.parseIdentifier() },
 	})
@@ -768,15 +1019,31 @@ func (p *ParserPocket) parseParameterParenthetical() Nod {
 	return rv
 }
 
+// by is the step op only in a stream with a range op that has no step yet
+func (p *ParserPocket) parseInlineOpInRange(open bool) Nod {
+	if open && p.atBy() {
+		p.parseKeywordName("by")
+		return NodNew(NT_RANGESTEPOP)
+	}
+	return p.parseInlineOp()
+}
+
+func (p *ParserPocket) atBy() bool {
+	return !p.IsEOF() && p.CurrToken().Type == TK_ALPHANUM && p.CurrToken().Data == "by"
+}
+
 func (p *ParserPocket) parseInlineOp() Nod {
-	ctok := p.CurrToken().Type
+	ctok := -1
+	if !p.IsEOF() {
+		ctok = p.CurrToken().Type
+	}
 	nt := p.inlineOpTokenToNT(ctok)
 	if nt != -1 {
 		p.ParseToken(ctok)
 		rv := NodNew(nt)
 		return rv
 	}
-	p.RaiseParseError("invalid inline op")
+	p.RaiseExpected("an operator")
 	return nil
 
 }
@@ -784,6 +1051,12 @@ func (p *ParserPocket) parseInlineOp() Nod {
 func (p *ParserPocket) prefixOpTokenToNT(ty int) int {
 	if ty == TK_REF {
 		return NT_REFERENCEOP
+	} else if ty == TK_SUBOP {
+		return NT_NEGOP
+	} else if ty == TK_ADDOP {
+		return NT_POSOP
+	} else if ty == TK_NOT {
+		return NT_NOTOP
 	}
 	panic("unknown prefix op type")
 }
@@ -813,10 +1086,22 @@ func (p *ParserPocket) inlineOpTokenToNT(tokenType int) int {
 		return NT_ANDOP
 	} else if tokenType == TK_MOD {
 		return NT_MODOP
+	} else if tokenType == TK_XOR {
+		return NT_XOROP
+	} else if tokenType == TK_SHL {
+		return NT_SHLOP
+	} else if tokenType == TK_SHR {
+		return NT_SHROP
+	} else if tokenType == TK_POW {
+		return NT_POWOP
 	} else if tokenType == TK_DOT {
 		return NT_DOTOP
 	} else if tokenType == TK_DOTPIPE {
 		return NT_DOTPIPEOP
+	} else if tokenType == TK_RANGE {
+		return NT_RANGEOP
+	} else if tokenType == TK_RANGEEXCL {
+		return NT_RANGEEXCLOP
 	} else {
 		return -1
 	}
@@ -835,9 +1120,9 @@ func (p *ParserPocket) parseIdentifier() Nod {
 }
 
 func (p *ParserPocket) parseCommand() Nod {
-	fmt.Println("tryina parse command", spew.Sdump(p.CurrToken()))
+	Tracef(LOGCH_PARSE, "trying to parse a command at %s", Lazy(func() string { return spew.Sdump(p.CurrToken()) }))
 	target := p.parseReceiverBase()
-	fmt.Println("receiver base", PrettyPrint(target))
+	Tracef(LOGCH_PARSE, "receiver base %s", LazyTree(target))
 	rv := NodNew(NT_RECEIVERCALL_CMD)
 	NodSetChild(rv, NTR_RECEIVERCALL_BASE, target)
 	parenArg := p.ParseAtMostOne(func() Nod { return p.parseCommandParentheticalArg() })
@@ -846,7 +1131,7 @@ func (p *ParserPocket) parseCommand() Nod {
 	} else {
 		args := p.ParseManyGreedy(func() Nod { return p.parseValue() })
 		if len(args) > 1 {
-			p.RaiseParseError("only zro and one arg cmds supported for now")
+			p.RaiseParseError("only zero and one arg cmds supported for now, use parens for more")
 		}
 		if len(args) > 0 {
 			NodSetChild(rv, NTR_RECEIVERCALL_ARG, args[0])
@@ -883,6 +1168,14 @@ func (p *ParserPocket) parseReceiverCall() Nod {
 
 func (p *ParserPocket) parseReceiverCallCommandStyle() Nod {
 	name := p.parseReceiverName()
+	// f -x is the binary op f - x, a signed arg needs parens: f(-x)
+	if p.IsEOF() {
+		p.RaiseExpected("a value")
+	} else if ty := p.CurrToken().Type; ty == TK_SUBOP || ty == TK_ADDOP {
+		p.RaiseParseError("ambiguous signed arg")
+	} else if p.rangeOpen && p.atBy() {
+		p.Reject("by steps the range rather than being an arg")
+	}
 	val := p.parseValue()
 	rv := NodNew(NT_RECEIVERCALL)
 	NodSetChild(rv, NTR_RECEIVERCALL_BASE, name)
@@ -890,6 +1183,20 @@ func (p *ParserPocket) parseReceiverCallCommandStyle() Nod {
 	return rv
 }
 
+func (p *ParserPocket) parseReceiverCallConversion() Nod {
+	// int(x) and float(x), the keyword types called as conversion functions
+	tok := p.ParseTokenOnCondition(func(t *types.Token) bool {
+		return t.Type == TK_INT || t.Type == TK_FLOAT
+	})
+	p.ParseToken(TK_PARENL)
+	p.Pos--
+	arg := p.parseReceiverCallParentheticalArg()
+	rv := NodNew(NT_RECEIVERCALL)
+	NodSetChild(rv, NTR_RECEIVERCALL_BASE, NodNewData(NT_IDENTIFIER, tok.Data))
+	NodSetChild(rv, NTR_RECEIVERCALL_ARG, arg)
+	return rv
+}
+
 func (p *ParserPocket) parseReceiverCallParentheticalStyle() Nod {
 	name := p.parseReceiverName()
 
@@ -930,7 +1237,7 @@ func (p *ParserPocket) parseRCPAParenList() Nod {
 	elements := p.parseManyOptDelimited(func() Nod { return p.parseValue() },
 		func() Nod { return p.parseComma() })
 	if len(elements) < 2 {
-		p.RaiseParseError("needed 2 more elements to be considered a list")
+		p.Reject("needed 2 more elements to be considered a list")
 	}
 	p.ParseToken(TK_PARENR)
 	return NodNewChildList(NT_LIT_LIST, elements)
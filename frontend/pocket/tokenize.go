@@ -2,17 +2,37 @@ package pocket
 
 import (
 	"bytes"
-	"fmt"
+	. "pocket-lang/frontend/pocket/common"
 	"pocket-lang/tokenize"
 	"pocket-lang/types"
+	"strconv"
+	"strings"
 )
 
 type TokenizerPocket struct {
 	isPreline   bool // whether we are in the "first whitespace" on a line
 	indentLevel int
+	extras      *SourceExtras // only kept when tokenizing for the formatter
+	comment     Comment       // the comment being read, while in TK_COMMENT
+	commentPos  int
 	*tokenize.Tokenizer
 }
 
+// a comment, which the parser never sees but the formatter keeps
+type Comment struct {
+	Text     string // from the # to the end of the line
+	Line     int
+	Column   int
+	Trailing bool // follows code on the same line
+}
+
+// what the formatter needs from the source besides the tokens
+type SourceExtras struct {
+	Comments []Comment
+	// literals as written, e.g. 0x1f or r'\d', by their token's location
+	Spellings map[*types.SourceLocation]string
+}
+
 const (
 	TKS_INIT         = 0
 	TK_EOL           = 1
@@ -21,12 +41,15 @@ const (
 	TK_LITERALINT    = 10
 	TK_LITERALFLOAT  = 11
 	TK_LITERALSTRING = 15
+	TK_LITERALFSTR   = 16 // f'..{x}..', still containing its {} placeholders
 	TK_ALPHANUM      = 20
 	TK_REF           = 25
 	TK_COLON         = 30
 	TK_DOT           = 32
 	TK_DOTPIPE       = 33
 	TK_EQOP          = 35
+	TK_RANGE         = 36
+	TK_RANGEEXCL     = 37
 	TK_ADDOP         = 40
 	TK_SUBOP         = 41
 	TK_MULTOP        = 42
@@ -39,8 +62,12 @@ const (
 	TK_EQ            = 49
 	TK_OR            = 50
 	TK_AND           = 51
+	TK_XOR           = 52
 	TK_PLUSPLUS      = 53
 	TK_MINUSMINUS    = 54
+	TK_SHL           = 55
+	TK_SHR           = 56
+	TK_POW           = 57
 
 	TK_PARENL = 60
 	TK_PARENR = 61
@@ -52,6 +79,7 @@ const (
 	TK_TILDE  = 67
 	TK_IF     = 75
 	TK_ELSE   = 76
+	TK_NOT    = 77
 	TK_LOOP   = 80
 	TK_FOR    = 81
 	TK_IN     = 82
@@ -84,8 +112,18 @@ const (
 )
 
 func Tokenize(input string) []types.Token {
+	return newTokenizer(input).run()
+}
+
+// tokenizes like Tokenize, also keeping the comments and literal spellings
+func TokenizeForFormat(input string) ([]types.Token, *SourceExtras) {
+	tkzr := newTokenizer(input)
+	tkzr.extras = &SourceExtras{Spellings: map[*types.SourceLocation]string{}}
+	return tkzr.run(), tkzr.extras
+}
 
-	tkzr := &TokenizerPocket{
+func newTokenizer(input string) *TokenizerPocket {
+	return &TokenizerPocket{
 		Tokenizer: &tokenize.Tokenizer{
 			Input: input,
 			Pos:   0,
@@ -100,10 +138,15 @@ func Tokenize(input string) []types.Token {
 		isPreline:   true,
 		indentLevel: 0,
 	}
+}
 
+func (tkzr *TokenizerPocket) run() []types.Token {
 	for !tkzr.IsEOF() {
 		tkzr.process()
 	}
+	if tkzr.State == TK_COMMENT {
+		tkzr.endComment()
+	}
 
 	tkzr.addFinalEOLIfMissing()
 	tkzr.cleanUpDanglingIndents()
@@ -119,7 +162,9 @@ func (tkzr *TokenizerPocket) process() {
 		return
 	}
 	if tkzr.State == TKS_INIT {
+		start, ntoks := tkzr.Pos, len(tkzr.Outtoks)
 		tkzr.processInit()
+		tkzr.noteSpelling(start, ntoks)
 	} else if tkzr.State == TK_COMMENT {
 		tkzr.processComment()
 	} else {
@@ -154,7 +199,7 @@ func (tkzr *TokenizerPocket) processPreline() {
 		}
 	}
 	if lineComment {
-		tkzr.State = TK_COMMENT
+		tkzr.startComment(false)
 		return
 	}
 	if lineEmpty {
@@ -168,7 +213,7 @@ func (tkzr *TokenizerPocket) processPreline() {
 		panic("Invalid indent")
 	}
 	if nspaces-expectedSpaces == 4 {
-		fmt.Println("Indented block")
+		Tracef(LOGCH_TOKENIZE, "indented block")
 		tkzr.indentLevel++
 		tkzr.EmitTokenNoData(TK_INCINDENT)
 	} else {
@@ -182,7 +227,7 @@ func (tkzr *TokenizerPocket) deindent(nDecIndents int) {
 		tkzr.EmitTokenNoData(TK_DECINDENT)
 	}
 	tkzr.indentLevel -= nDecIndents
-	fmt.Println("Deindented (", nDecIndents, ") block(s)")
+	Tracef(LOGCH_TOKENIZE, "deindented %d block(s)", nDecIndents)
 }
 
 func (tkzr *TokenizerPocket) cleanUpDanglingIndents() {
@@ -191,10 +236,11 @@ func (tkzr *TokenizerPocket) cleanUpDanglingIndents() {
 
 func (tkzr *TokenizerPocket) processComment() {
 	if isEOL(tkzr.CurrRune()) {
+		tkzr.endComment()
 		tkzr.emitEOLIfNotRedundant()
 		tkzr.State = TKS_INIT
 		tkzr.isPreline = true
-		tkzr.Incr()
+		tkzr.IncrLine()
 		return
 	}
 	tkzr.Incr()
@@ -254,6 +300,8 @@ func (tkzr *TokenizerPocket) processInit() {
 		tkzr.processMod()
 	} else if input == '@' {
 		tkzr.EmitTokenRuneAndIncr(TK_REF)
+	} else if input == '^' {
+		tkzr.EmitTokenRuneAndIncr(TK_XOR)
 	} else if input == '.' {
 		tkzr.processDot()
 	} else if input == '#' {
@@ -276,8 +324,8 @@ func (tkzr *TokenizerPocket) processMinus() {
 }
 
 func (tkzr *TokenizerPocket) processMult() {
-	tkzr.process1Or2CharOpNChoices('*', []rune{':'}, TK_MULTOP, []int{
-		TK_MULTASSIGN,
+	tkzr.process1Or2CharOpNChoices('*', []rune{':', '*'}, TK_MULTOP, []int{
+		TK_MULTASSIGN, TK_POW,
 	})
 }
 
@@ -325,9 +373,25 @@ func (tkzr *TokenizerPocket) process1Or2CharOpNChoices(firstRune rune,
 }
 
 func (tkzr *TokenizerPocket) processDot() {
+	if tkzr.PeekRune(1) == '.' {
+		tkzr.processRange()
+		return
+	}
 	tkzr.process1Or2CharOp('.', '>', TK_DOT, TK_DOTPIPE)
 }
 
+func (tkzr *TokenizerPocket) processRange() {
+	// '..' is an inclusive range, '..<' excludes the upper bound
+	tkzr.Incr()
+	tkzr.Incr()
+	if !tkzr.IsEOF() && tkzr.CurrRune() == '<' {
+		tkzr.EmitToken(TK_RANGEEXCL, "..<")
+		tkzr.Incr()
+	} else {
+		tkzr.EmitToken(TK_RANGE, "..")
+	}
+}
+
 func (tkzr *TokenizerPocket) process1Or2CharOp(firstRune rune, secondRune rune, tok1 int, tok2 int) {
 	// skip first rune
 	tkzr.Incr()
@@ -344,47 +408,102 @@ func (tkzr *TokenizerPocket) process1Or2CharOp(firstRune rune, secondRune rune,
 }
 
 func (tkzr *TokenizerPocket) processLT() {
-	tkzr.processGTOrLT('<', TK_LT, TK_LTEQ)
+	tkzr.processGTOrLT('<', TK_LT, TK_LTEQ, TK_SHL)
 }
 
 func (tkzr *TokenizerPocket) processGT() {
-	tkzr.processGTOrLT('>', TK_GT, TK_GTEQ)
+	tkzr.processGTOrLT('>', TK_GT, TK_GTEQ, TK_SHR)
 }
 
-func (tkzr *TokenizerPocket) processGTOrLT(firstRune rune, tokNoEq int, tokEq int) {
-	tkzr.process1Or2CharOp(firstRune, '=', tokNoEq, tokEq)
+func (tkzr *TokenizerPocket) processGTOrLT(firstRune rune, tokNoEq int, tokEq int, tokShift int) {
+	// a doubled rune is a shift, e.g. << or >>
+	tkzr.process1Or2CharOpNChoices(firstRune, []rune{'=', firstRune}, tokNoEq, []int{
+		tokEq, tokShift,
+	})
 }
 
 func (tkzr *TokenizerPocket) processPound() {
-	tkzr.State = TK_COMMENT
+	tkzr.startComment(true)
 	tkzr.Incr()
 }
 
+func (tkzr *TokenizerPocket) startComment(trailing bool) {
+	tkzr.State = TK_COMMENT
+	tkzr.comment = Comment{
+		Line:     tkzr.SrcLoc.Line,
+		Column:   tkzr.SrcLoc.Column,
+		Trailing: trailing,
+	}
+	tkzr.commentPos = tkzr.Pos
+}
+
+func (tkzr *TokenizerPocket) endComment() {
+	if tkzr.extras == nil {
+		return
+	}
+	tkzr.comment.Text = strings.TrimRight(tkzr.Input[tkzr.commentPos:tkzr.Pos], " \r")
+	tkzr.extras.Comments = append(tkzr.extras.Comments, tkzr.comment)
+}
+
+// remembers how a literal token just emitted was written in the source
+func (tkzr *TokenizerPocket) noteSpelling(start int, ntoks int) {
+	if tkzr.extras == nil || len(tkzr.Outtoks) != ntoks+1 {
+		return
+	}
+	tok := tkzr.Outtoks[ntoks]
+	if tok.Type == TK_LITERALINT || tok.Type == TK_LITERALFLOAT ||
+		tok.Type == TK_LITERALSTRING || tok.Type == TK_LITERALFSTR {
+		tkzr.extras.Spellings[tok.SourceLocation] = tkzr.Input[start:tkzr.Pos]
+	}
+}
+
 func isCommentStart(chr rune) bool {
 	return chr == '#'
 }
 
 func (tkzr *TokenizerPocket) processLiteralNumeric() {
 	tkzr.State = TK_LITERALINT
+	if tkzr.CurrRune() == '0' && isRadixPrefix(tkzr.PeekRune(1)) {
+		tkzr.processLiteralNumericRadix()
+		return
+	}
 	decPointFound := false
+	expFound := false
 	for !tkzr.IsEOF() {
 		chr := tkzr.CurrRune()
 		if isDigit(chr) {
 			tkzr.Tokbuf.WriteRune(chr)
 			tkzr.Incr()
+		} else if chr == '_' && isDigit(tkzr.PeekRune(1)) {
+			// digit separator, e.g. 1_000_000
+			tkzr.Incr()
 		} else if isDecimalPoint(chr) {
-			if decPointFound {
+			if tkzr.PeekRune(1) == '.' {
+				// start of a range, e.g. 0..9
+				break
+			}
+			if decPointFound || expFound {
 				panic("too many decimal points")
 			}
 			decPointFound = true
 			tkzr.Tokbuf.WriteRune(chr)
 			tkzr.Incr()
+		} else if (chr == 'e' || chr == 'E') && !expFound && (isDigit(tkzr.PeekRune(1)) ||
+			((tkzr.PeekRune(1) == '-' || tkzr.PeekRune(1) == '+') && isDigit(tkzr.PeekRune(2)))) {
+			// exponent, e.g. 1e9 or 2.5E-3
+			expFound = true
+			tkzr.Tokbuf.WriteRune('e')
+			tkzr.Incr()
+			if sign := tkzr.CurrRune(); sign == '-' || sign == '+' {
+				tkzr.Tokbuf.WriteRune(sign)
+				tkzr.Incr()
+			}
 		} else {
 			break
 		}
 	}
 	var tokType int
-	if decPointFound {
+	if decPointFound || expFound {
 		tokType = TK_LITERALFLOAT
 	} else {
 		tokType = TK_LITERALINT
@@ -393,6 +512,27 @@ func (tkzr *TokenizerPocket) processLiteralNumeric() {
 	tkzr.State = TKS_INIT
 }
 
+func (tkzr *TokenizerPocket) processLiteralNumericRadix() {
+	// 0x1f, 0o17 and 0b1010 style integers, kept with their prefix for the parser
+	tkzr.Tokbuf.WriteRune('0')
+	tkzr.Incr()
+	tkzr.Tokbuf.WriteRune(tkzr.CurrRune() | 0x20) // lowercase the prefix
+	tkzr.Incr()
+	for !tkzr.IsEOF() {
+		chr := tkzr.CurrRune()
+		if isHexDigit(chr) {
+			tkzr.Tokbuf.WriteRune(chr)
+			tkzr.Incr()
+		} else if chr == '_' && isHexDigit(tkzr.PeekRune(1)) {
+			tkzr.Incr()
+		} else {
+			break
+		}
+	}
+	tkzr.EndBufedToken(TK_LITERALINT)
+	tkzr.State = TKS_INIT
+}
+
 func (tkzr *TokenizerPocket) processSpace() {
 	// skip for now
 	tkzr.Incr()
@@ -421,7 +561,8 @@ func (tkzr *TokenizerPocket) processAlphanum() {
 	tkzr.State = TK_ALPHANUM
 	for !tkzr.IsEOF() {
 		chr := tkzr.CurrRune()
-		if isAlphic(chr) {
+		// digits are allowed after the first char, e.g. i8 or x2
+		if isAlphic(chr) || isDigit(chr) {
 			tkzr.Tokbuf.WriteRune(chr)
 			tkzr.Incr()
 		} else {
@@ -429,6 +570,18 @@ func (tkzr *TokenizerPocket) processAlphanum() {
 		}
 	}
 
+	// string prefixes: r'...' is raw, f'...' is interpolated
+	if word := tkzr.Tokbuf.String(); (word == "r" || word == "f") &&
+		!tkzr.IsEOF() && isStringDelim(tkzr.CurrRune()) {
+		tkzr.Tokbuf.Reset()
+		if word == "r" {
+			tkzr.processLiteralStringOfKind(TK_LITERALSTRING, true)
+		} else {
+			tkzr.processLiteralStringOfKind(TK_LITERALFSTR, false)
+		}
+		return
+	}
+
 	keywordType := tkzr.checkKeyword(tkzr.Tokbuf.String())
 
 	if keywordType == -1 {
@@ -441,28 +594,103 @@ func (tkzr *TokenizerPocket) processAlphanum() {
 }
 
 func (tkzr *TokenizerPocket) processLiteralString() {
-	// skip initial quote
-	tkzr.Incr()
+	tkzr.processLiteralStringOfKind(TK_LITERALSTRING, false)
+}
+
+func (tkzr *TokenizerPocket) processLiteralStringOfKind(tokType int, isRaw bool) {
+	// ''' opens a multi-line string, which only ends at the next '''
+	isTriple := tkzr.PeekRune(1) == '\'' && tkzr.PeekRune(2) == '\''
+	if isTriple {
+		tkzr.Incr()
+		tkzr.Incr()
+		tkzr.Incr()
+		// a newline right after the opening quotes isn't part of the string
+		if !tkzr.IsEOF() && isEOL(tkzr.CurrRune()) {
+			tkzr.IncrLine()
+		}
+	} else {
+		// skip initial quote
+		tkzr.Incr()
+	}
 	terminated := false
 	for !tkzr.IsEOF() {
 		chr := tkzr.CurrRune()
-		if isStringDelim(chr) {
+		if isStringDelim(chr) && (!isTriple ||
+			(tkzr.PeekRune(1) == '\'' && tkzr.PeekRune(2) == '\'')) {
 			terminated = true
+			if isTriple {
+				tkzr.Incr()
+				tkzr.Incr()
+			}
 			tkzr.Incr()
 			break
+		} else if isEOL(chr) {
+			if !isTriple {
+				break
+			}
+			tkzr.Tokbuf.WriteByte('\n')
+			tkzr.IncrLine()
+		} else if chr == '\\' && !isRaw {
+			tkzr.processEscapeSequence()
 		} else {
-			tkzr.Tokbuf.WriteRune(chr)
+			// copy bytes rather than runes so multi-byte characters survive
+			tkzr.Tokbuf.WriteByte(tkzr.Input[tkzr.Pos])
 			tkzr.Incr()
 		}
 	}
 	if !terminated {
-		panic("unterminated string literal")
+		panic("unterminated string literal at " + tkzr.CreateCurrSourceLocation().StringDebug())
 	}
-	tkzr.EmitToken(TK_LITERALSTRING, tkzr.Tokbuf.String())
+	tkzr.EmitToken(tokType, tkzr.Tokbuf.String())
 	tkzr.Tokbuf.Reset()
 	tkzr.State = TKS_INIT
 }
 
+func (tkzr *TokenizerPocket) processEscapeSequence() {
+	// skip the backslash
+	tkzr.Incr()
+	if tkzr.IsEOF() {
+		panic("unterminated escape sequence")
+	}
+	simpleEscapes := map[rune]rune{
+		'n':  '\n',
+		't':  '\t',
+		'r':  '\r',
+		'0':  0,
+		'\\': '\\',
+		'\'': '\'',
+		'"':  '"',
+	}
+	chr := tkzr.CurrRune()
+	if replacement, ok := simpleEscapes[chr]; ok {
+		tkzr.Tokbuf.WriteRune(replacement)
+		tkzr.Incr()
+		return
+	}
+	// \uXXXX and \UXXXXXXXX give a unicode code point in hex
+	var nDigits int
+	if chr == 'u' {
+		nDigits = 4
+	} else if chr == 'U' {
+		nDigits = 8
+	} else {
+		panic("unknown escape sequence '\\" + string(chr) + "' at " +
+			tkzr.CreateCurrSourceLocation().StringDebug())
+	}
+	tkzr.Incr()
+	if tkzr.Pos+nDigits > len(tkzr.Input) {
+		panic("unterminated unicode escape")
+	}
+	codePoint, err := strconv.ParseUint(tkzr.Input[tkzr.Pos:tkzr.Pos+nDigits], 16, 32)
+	if err != nil {
+		panic("invalid unicode escape at " + tkzr.CreateCurrSourceLocation().StringDebug())
+	}
+	tkzr.Tokbuf.WriteRune(rune(codePoint))
+	for i := 0; i < nDigits; i++ {
+		tkzr.Incr()
+	}
+}
+
 func (tkzr *TokenizerPocket) checkKeyword(word string) int {
 	// returns TK_TYPE if keyword, -1 otherwise
 	if word == "return" {
@@ -489,6 +717,8 @@ func (tkzr *TokenizerPocket) checkKeyword(word string) int {
 		return TK_FOR
 	} else if word == "while" {
 		return TK_WHILE
+	} else if word == "not" {
+		return TK_NOT
 	} else if word == "if" {
 		return TK_IF
 	} else if word == "else" {
@@ -520,6 +750,15 @@ func isDigit(input rune) bool {
 	return (input >= '0' && input <= '9')
 }
 
+func isHexDigit(input rune) bool {
+	return isDigit(input) || (input >= 'a' && input <= 'f') || (input >= 'A' && input <= 'F')
+}
+
+func isRadixPrefix(input rune) bool {
+	return input == 'x' || input == 'X' || input == 'o' || input == 'O' ||
+		input == 'b' || input == 'B'
+}
+
 func isEOL(input rune) bool {
 	return input == '\n'
 }
@@ -0,0 +1,281 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"strings"
+)
+
+// A function's control flow graph has a node per statement after desugaring,
+// where for loops are already whiles, and one for the condition of each if
+// and loop. The checks follow it to find statements that can't be reached,
+// variables that might be read before they're assigned, functions that can
+// finish without returning a value, and locals that are never read.
+
+type cfgNode struct {
+	stmt  Nod   // the statement, or the if or loop it's the condition of; nil at the end
+	reads []Nod // the var getters it evaluates
+	write Nod   // the vardef it assigns, if any
+	succs []*cfgNode
+	preds []*cfgNode
+}
+
+type cfg struct {
+	entry  *cfgNode
+	end    *cfgNode // falling off the end of the function
+	nodes  []*cfgNode
+	starts map[Nod]*cfgNode // where each statement starts
+}
+
+func buildCFG(fDef Nod) *cfg {
+	g := &cfg{end: &cfgNode{}, starts: map[Nod]*cfgNode{}}
+	g.entry = g.build(NodGetChild(fDef, NTR_FUNCDEF_CODE), g.end, nil)
+	for _, n := range append(g.nodes, g.end) {
+		for _, succ := range n.succs {
+			succ.preds = append(succ.preds, n)
+		}
+	}
+	return g
+}
+
+func (g *cfg) newNode(stmt Nod, reads []Nod) *cfgNode {
+	n := &cfgNode{stmt: stmt, reads: reads}
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+// adds stmt, which goes on to next or, on a break, to breakTo, and returns
+// where it starts. A block is built from its last statement back, so the
+// ones after a return are there, but unreachable.
+func (g *cfg) build(stmt Nod, next *cfgNode, breakTo *cfgNode) *cfgNode {
+	var start *cfgNode
+	switch stmt.NodeType {
+	case NT_IMPERATIVE:
+		start = next
+		units := NodGetChildList(stmt)
+		for ndx := len(units) - 1; ndx >= 0; ndx-- {
+			start = g.build(units[ndx], start, breakTo)
+		}
+		return start
+	case NT_IF:
+		start = g.newNode(stmt, varGettersIn(NodGetChild(stmt, NTR_IF_COND)))
+		onFalse := next
+		if body := NodGetChildOrNil(stmt, NTR_IF_BODY_FALSE); body != nil {
+			onFalse = g.build(body, next, breakTo)
+		}
+		start.succs = []*cfgNode{g.build(NodGetChild(stmt, NTR_IF_BODY_TRUE), next, breakTo), onFalse}
+	case NT_WHILE:
+		start = g.newNode(stmt, varGettersIn(NodGetChild(stmt, NTR_WHILE_COND)))
+		start.succs = []*cfgNode{g.build(NodGetChild(stmt, NTR_WHILE_BODY), start, next), next}
+	case NT_LOOP:
+		// without a count, only a break gets out
+		arg := NodGetChildOrNil(stmt, NTR_LOOP_ARG)
+		start = g.newNode(stmt, varGettersIn(arg))
+		start.succs = []*cfgNode{g.build(NodGetChild(stmt, NTR_LOOP_BODY), start, next)}
+		if arg != nil {
+			start.succs = append(start.succs, next)
+		}
+	case NT_RETURN:
+		start = g.newNode(stmt, varGettersIn(stmt))
+	case NT_BREAK:
+		start = g.newNode(stmt, nil)
+		if breakTo != nil {
+			start.succs = []*cfgNode{breakTo}
+		}
+	default:
+		start = g.newNode(stmt, varGettersIn(stmt))
+		if stmt.NodeType == NT_VARASSIGN {
+			start.write = NodGetChildOrNil(stmt, NTR_VARDEF)
+		}
+		start.succs = []*cfgNode{next}
+	}
+	g.starts[stmt] = start
+	return start
+}
+
+// the var getters evaluated in n, leaving out the funcs and classes defined
+// in it, whose code runs some other time
+func varGettersIn(n Nod) []Nod {
+	rv := []Nod{}
+	if n != nil {
+		collectVarGetters(n, map[Nod]bool{}, &rv)
+	}
+	return rv
+}
+
+func collectVarGetters(n Nod, seen map[Nod]bool, rv *[]Nod) {
+	if seen[n] {
+		return
+	}
+	seen[n] = true
+	switch n.NodeType {
+	case NT_FUNCDEF, NT_CLASSDEF, NT_VARDEF, NT_FUNCDEF_RV_PLACEHOLDER, NT_NAMESPACE, NT_VARTABLE:
+		return
+	case NT_VAR_GETTER:
+		*rv = append(*rv, n)
+	}
+	for _, et := range sortedEdgeTypes(n) {
+		collectVarGetters(n.Out[et].Out, seen, rv)
+	}
+}
+
+func (g *cfg) reachable() map[*cfgNode]bool {
+	rv := map[*cfgNode]bool{g.entry: true}
+	todo := []*cfgNode{g.entry}
+	for len(todo) > 0 {
+		n := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, succ := range n.succs {
+			if !rv[succ] {
+				rv[succ] = true
+				todo = append(todo, succ)
+			}
+		}
+	}
+	return rv
+}
+
+// the locals assigned on every path from the entry to each reachable node,
+// before it runs
+func (g *cfg) definitelyAssigned(reachable map[*cfgNode]bool, atEntry map[Nod]bool) map[*cfgNode]map[Nod]bool {
+	// a node no path has been followed to yet is nil, which is every local
+	rv := map[*cfgNode]map[Nod]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, n := range append(g.nodes, g.end) {
+			if !reachable[n] {
+				continue
+			}
+			var in map[Nod]bool
+			if n == g.entry {
+				in = atEntry
+			}
+			for _, pred := range n.preds {
+				if !reachable[pred] || rv[pred] == nil {
+					continue
+				}
+				out := map[Nod]bool{}
+				for v := range rv[pred] {
+					out[v] = true
+				}
+				if pred.write != nil {
+					out[pred.write] = true
+				}
+				in = intersectVarSets(in, out)
+			}
+			if in != nil && (rv[n] == nil || len(in) != len(rv[n])) {
+				rv[n] = in
+				changed = true
+			}
+		}
+	}
+	return rv
+}
+
+func intersectVarSets(a map[Nod]bool, b map[Nod]bool) map[Nod]bool {
+	if a == nil {
+		return b
+	}
+	rv := map[Nod]bool{}
+	for v := range a {
+		if b[v] {
+			rv[v] = true
+		}
+	}
+	return rv
+}
+
+func (x *XformerPocket) checkFlow() {
+	read := VarDefsRead(x.SearchForNodeType(NT_VAR_GETTER))
+	for _, fDef := range x.solvedFuncDefs() {
+		if code := NodGetChildOrNil(fDef, NTR_FUNCDEF_CODE); code == nil || code.NodeType != NT_IMPERATIVE {
+			continue
+		}
+		x.checkFuncFlow(fDef, buildCFG(fDef), read)
+	}
+}
+
+func (x *XformerPocket) checkFuncFlow(fDef Nod, g *cfg, read map[Nod]bool) {
+	reachable := g.reachable()
+
+	if reachable[g.end] && needsReturnValue(fDef) {
+		x.errorAt(fDef, funcDefName(fDef)+" doesn't return a value on every path")
+	}
+
+	// only the first of a run of unreachable statements is reported
+	for _, n := range g.nodes {
+		stmt := n.stmt
+		if reachable[n] || g.starts[stmt] != n {
+			continue
+		}
+		block := NodGetParentByOrNil(stmt, func(n Nod) bool { return n.NodeType == NT_IMPERATIVE })
+		prev := previousStatement(block, stmt)
+		if prev != nil && reachable[g.starts[prev]] {
+			x.warnAt(stmt, "unreachable code")
+		}
+	}
+
+	// locals are the func's own variables, parameters are assigned on entry
+	locals := map[Nod]bool{}
+	atEntry := map[Nod]bool{}
+	for _, varDef := range NodGetChildList(NodGetChild(fDef, NTR_VARTABLE)) {
+		switch NodGetChild(varDef, NTR_VARDEF_SCOPE).Data.(int) {
+		case VSCOPE_FUNCLOCAL:
+			locals[varDef] = true
+		case VSCOPE_FUNCPARAM:
+			atEntry[varDef] = true
+		}
+	}
+	if selfDef := NodGetChildOrNil(fDef, NTR_METHOD_SELFDEF); selfDef != nil {
+		delete(locals, selfDef)
+		atEntry[selfDef] = true
+	}
+
+	assigned := g.definitelyAssigned(reachable, atEntry)
+	for _, n := range g.nodes {
+		if !reachable[n] {
+			continue
+		}
+		for _, getter := range n.reads {
+			varDef := NodGetChildOrNil(getter, NTR_VARDEF)
+			if locals[varDef] && !assigned[n][varDef] {
+				x.errorAt(getter, "'"+varDefName(varDef)+"' might be used before it's assigned")
+			}
+		}
+	}
+
+	for _, param := range FuncDefParams(fDef) {
+		if varDef := NodGetChildOrNil(param, NTR_VARDEF); varDef != nil && !read[varDef] {
+			x.warnAt(param, "parameter '"+varDefName(varDef)+"' is never used")
+		}
+	}
+	for _, n := range g.nodes {
+		if n.write != nil && locals[n.write] && !read[n.write] && !isTempVarName(varDefName(n.write)) {
+			x.warnAt(n.stmt, "'"+varDefName(n.write)+"' is assigned but never used")
+			read[n.write] = true // once is enough
+		}
+	}
+}
+
+// the statement before stmt in block, if any
+func previousStatement(block Nod, stmt Nod) Nod {
+	if block == nil {
+		return nil
+	}
+	units := NodGetChildList(block)
+	for ndx := 1; ndx < len(units); ndx++ {
+		if units[ndx] == stmt {
+			return units[ndx-1]
+		}
+	}
+	return nil
+}
+
+func varDefName(varDef Nod) string {
+	return NodGetChild(varDef, NTR_VARDEF_NAME).Data.(string)
+}
+
+// the variables desugaring makes up, like a for loop's index
+func isTempVarName(name string) bool {
+	return strings.HasPrefix(name, "__pk")
+}
@@ -0,0 +1,286 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"pocket-lang/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the checks that run once every node has its type: that calls to user
+// functions give each parameter one argument it can hold, that functions
+// return what they're declared to, and what follows from each function's
+// control flow graph (see cfg.go). The solver doesn't look at any of it,
+// since a call only links to the callee's return value. Every error is
+// reported, one per line, in a single panic; warnings are logged on the check
+// channel and kept for tools like the language server.
+
+type CheckDiagnostic struct {
+	Msg     string
+	Loc     *types.SourceLocation
+	Warning bool
+}
+
+func (d *CheckDiagnostic) String() string {
+	return d.Msg + ", at " + d.Loc.StringDebug()
+}
+
+func (x *XformerPocket) errorAt(n Nod, msg string) {
+	x.diagnostics = append(x.diagnostics, &CheckDiagnostic{Msg: msg, Loc: nearestLoc(n, map[Nod]bool{})})
+}
+
+func (x *XformerPocket) warnAt(n Nod, msg string) {
+	x.diagnostics = append(x.diagnostics,
+		&CheckDiagnostic{Msg: msg, Loc: nearestLoc(n, map[Nod]bool{}), Warning: true})
+}
+
+func (x *XformerPocket) check() {
+	x.diagnostics = nil
+	x.checkCallArgs()
+	x.checkReturns()
+	x.checkFlow()
+	x.checkOverloads()
+	x.checkConstructors()
+
+	// in source order, with those from nowhere in particular first
+	sort.SliceStable(x.diagnostics, func(i, j int) bool {
+		return locBefore(x.diagnostics[i].Loc, x.diagnostics[j].Loc)
+	})
+	errs := []string{}
+	for _, d := range x.diagnostics {
+		if d.Warning {
+			Warnf(LOGCH_CHECK, "%s", d)
+		} else {
+			errs = append(errs, d.String())
+		}
+	}
+	if len(errs) > 0 {
+		panic(strings.Join(errs, "\n"))
+	}
+}
+
+func (x *XformerPocket) checkCallArgs() {
+	// ops, indexors and .len linked to operator methods are calls too, and
+	// object initializers linked to constructors
+	calls := x.SearchRoot(func(n Nod) bool {
+		return (isReceiverCallType(n.NodeType) || n.NodeType == NT_RECEIVERCALL_METHOD ||
+			isBinaryOpType(n.NodeType) || n.NodeType == NT_OBJFIELD_ACCESSOR ||
+			n.NodeType == NT_OBJINIT) && NodHasChild(n, NTR_FUNCDEF)
+	})
+	for _, call := range calls {
+		x.checkCallArg(call)
+	}
+}
+
+func (x *XformerPocket) checkCallArg(call Nod) {
+	fDef := NodGetChild(call, NTR_FUNCDEF)
+	name := funcDefName(fDef)
+	params := FuncDefParams(fDef)
+	arg := callArgOf(call)
+
+	if arg != nil && arg.NodeType == NT_KWARGS {
+		x.checkKeywordArgs(call, name, params, arg)
+		return
+	}
+
+	args := positionalArgs(arg, len(params))
+	if len(args) != len(params) {
+		x.errorAt(call, name+" takes "+countArgs(len(params))+" but is given "+countArgs(len(args)))
+		return
+	}
+	for ndx, param := range params {
+		x.checkArgType(name, param, args[ndx])
+	}
+}
+
+func (x *XformerPocket) checkKeywordArgs(call Nod, name string, params []Nod, kwargs Nod) {
+	given := map[Nod]bool{}
+	for _, kwarg := range NodGetChildList(kwargs) {
+		kwName := NodGetChild(kwarg, NTR_VAR_NAME).Data.(string)
+		param := paramForVarDef(params, NodGetChildOrNil(kwarg, NTR_VARDEF))
+		if param == nil {
+			x.errorAt(kwarg, name+" has no parameter '"+kwName+"'")
+		} else if given[param] {
+			x.errorAt(kwarg, "'"+kwName+"' is given twice to "+name)
+		} else {
+			given[param] = true
+			x.checkArgType(name, param, NodGetChild(kwarg, NTR_VARASSIGN_VALUE))
+		}
+	}
+	for _, param := range params {
+		if !given[param] {
+			x.errorAt(call, name+" is missing '"+NodGetChild(param, NTR_VARDEF_NAME).Data.(string)+"'")
+		}
+	}
+}
+
+func (x *XformerPocket) checkArgType(name string, param Nod, arg Nod) {
+	paramType := NodGetChildOrNil(NodGetChild(param, NTR_VARDEF), NTR_TYPE)
+	argType := NodGetChildOrNil(arg, NTR_TYPE)
+	if paramType == nil || argType == nil || typeAccepts(paramType, arg) {
+		return
+	}
+	x.errorAt(arg, name+" takes "+DypeString(paramType)+" for '"+
+		NodGetChild(param, NTR_VARDEF_NAME).Data.(string)+"' but is given "+DypeString(argType))
+}
+
+func paramForVarDef(params []Nod, varDef Nod) Nod {
+	for _, param := range params {
+		if varDef != nil && NodGetChild(param, NTR_VARDEF) == varDef {
+			return param
+		}
+	}
+	return nil
+}
+
+// the funcdefs the solver got to, which have a return value placeholder
+func (x *XformerPocket) solvedFuncDefs() []Nod {
+	return x.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_FUNCDEF && NodHasChild(n, NTR_RETURNVAL_PLACEHOLDER)
+	})
+}
+
+// the return statements of a funcdef, which link to its placeholder, in
+// source order
+func funcDefReturns(fDef Nod) []Nod {
+	rv := []Nod{}
+	for _, edge := range NodGetChild(fDef, NTR_RETURNVAL_PLACEHOLDER).In {
+		if edge.In.NodeType == NT_RETURN {
+			rv = append(rv, edge.In)
+		}
+	}
+	sort.SliceStable(rv, func(i, j int) bool {
+		return locBefore(nearestLoc(rv[i], map[Nod]bool{}), nearestLoc(rv[j], map[Nod]bool{}))
+	})
+	return rv
+}
+
+func (x *XformerPocket) checkReturns() {
+	for _, fDef := range x.solvedFuncDefs() {
+		x.checkFuncReturns(fDef, funcDefReturns(fDef))
+	}
+}
+
+func (x *XformerPocket) checkFuncReturns(fDef Nod, returns []Nod) {
+	name := funcDefName(fDef)
+	outType := NodGetChildOrNil(fDef, NTR_FUNCDEF_OUTTYPE)
+
+	if outType != nil && outType.NodeType == NT_TYPEBASE && outType.Data.(int) == TY_VOID {
+		for _, ret := range returns {
+			if NodHasChild(ret, NTR_RETURN_VALUE) {
+				x.errorAt(ret, name+" is declared void but returns a value")
+			}
+		}
+		return
+	}
+
+	// without a declaration, the first value returned sets what the others
+	// have to agree with
+	var first Nod
+	for _, ret := range returns {
+		val := NodGetChildOrNil(ret, NTR_RETURN_VALUE)
+		if val == nil {
+			if outType != nil || first != nil {
+				x.errorAt(ret, name+" returns without a value")
+			}
+			continue
+		}
+		valType := NodGetChildOrNil(val, NTR_TYPE)
+		if valType == nil {
+			continue
+		}
+		if outType != nil {
+			if isResolvedTypeNod(outType) && !typeAccepts(outType, val) {
+				x.errorAt(ret, name+" is declared "+DypeString(outType)+" but returns "+DypeString(valType))
+			}
+		} else if first == nil {
+			first = val
+		} else if firstType := NodGetChild(first, NTR_TYPE); !typeAccepts(firstType, val) &&
+			!typeAccepts(valType, first) {
+			x.errorAt(ret, name+" returns "+DypeString(valType)+" here but "+DypeString(firstType)+
+				" at "+nearestLoc(first, map[Nod]bool{}).StringDebug())
+		}
+	}
+}
+
+// whether fDef has to return a value on every path: it's declared to, or it
+// returns one somewhere
+func needsReturnValue(fDef Nod) bool {
+	if outType := NodGetChildOrNil(fDef, NTR_FUNCDEF_OUTTYPE); outType != nil {
+		return outType.NodeType != NT_TYPEBASE || outType.Data.(int) != TY_VOID
+	}
+	for _, ret := range funcDefReturns(fDef) {
+		if NodHasChild(ret, NTR_RETURN_VALUE) {
+			return true
+		}
+	}
+	return false
+}
+
+// whether a value of type container can hold val. The solver can leave a
+// value as a union that only later narrows to one of its types, so it's
+// enough for them to share one. A number literal can be any numeric type it
+// fits, the same as when it's assigned to one.
+func typeAccepts(container Nod, val Nod) bool {
+	if DypeSimplifyDeep(DypeXSect(container, NodGetChild(val, NTR_TYPE))).NodeType != DYPE_EMPTY {
+		return true
+	}
+	return literalFitsType(container, val)
+}
+
+func literalFitsType(container Nod, val Nod) bool {
+	if container.NodeType != NT_TYPEBASE || !IsNumericType(container.Data.(int)) {
+		return false
+	}
+	ty := container.Data.(int)
+	if val.NodeType == NT_LIT_FLOAT {
+		return IsFloatType(ty)
+	} else if val.NodeType == NT_LIT_INT {
+		return NumericLiteralFits(ty, int64(val.Data.(int)))
+	}
+	return false
+}
+
+// declared types naming a class are left as identifiers
+func isResolvedTypeNod(n Nod) bool {
+	switch n.NodeType {
+	case NT_TYPEBASE, NT_CLASSDEF:
+		return true
+	case NT_TYPECALL:
+		return isResolvedTypeNod(NodGetChild(n, NTR_RECEIVERCALL_BASE)) &&
+			isResolvedTypeNod(NodGetChild(n, NTR_RECEIVERCALL_ARG))
+	}
+	return false
+}
+
+func funcDefName(fDef Nod) string {
+	if name := NodGetChildOrNil(fDef, NTR_FUNCDEF_NAME); name != nil {
+		return name.Data.(string)
+	}
+	return "function"
+}
+
+// whether a comes before b in the source, where nowhere in particular comes
+// before anywhere
+func locBefore(a *types.SourceLocation, b *types.SourceLocation) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+func checkLocString(n Nod) string {
+	if loc := nearestLoc(n, map[Nod]bool{}); loc != nil {
+		return loc.StringDebug()
+	}
+	return "?"
+}
+
+func countArgs(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return strconv.Itoa(n) + " arguments"
+}
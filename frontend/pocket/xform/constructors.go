@@ -0,0 +1,83 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+)
+
+// A class's constructor is its method named new or init. Making an object
+// sets the fields to their default values, then to any ~config~ arguments,
+// then calls the constructor with the rest of the arguments, which go to it
+// rather than to the fields. Constructors can be overloaded like any other
+// method. A static zone's constructor runs when the zone is set up, before
+// main, so it can't take arguments.
+
+// the constructors of a class or static zone
+func (x *XformerPocket) classConstructors(cls Nod) []Nod {
+	rv := []Nod{}
+	for _, fDef := range NodGetChildList(NodGetChild(cls, NTR_FUNCTABLE)) {
+		if IsConstructorName(funcDefName(fDef)) {
+			rv = append(rv, fDef)
+		}
+	}
+	return rv
+}
+
+func (x *XformerPocket) IRRObjInitConstructor() *RewriteRule {
+	// Point(1, 2) -> a new Point, then .new(1, 2) on it
+	return &RewriteRule{
+		nodeTypes: []int{NT_OBJINIT},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_OBJINIT || NodHasChild(n, NTR_FUNCDEF) {
+				return false
+			}
+			cls := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+			if cls.NodeType != NT_CLASSDEF {
+				return false
+			}
+			ctors := x.classConstructors(cls)
+			if len(ctors) == 0 {
+				return false
+			}
+			ctor := x.resolveOverload(n, funcDefName(ctors[0]), ctors)
+			if ctor == nil {
+				return false
+			}
+			Tracef(LOGCH_SOLVE, "linked object initializer to constructor %s", funcDefName(ctor))
+			NodSetChild(n, NTR_FUNCDEF, ctor)
+			return true
+		},
+	}
+}
+
+// a class has one kind of constructor, which doesn't return anything, and a
+// static zone's doesn't take anything
+func (x *XformerPocket) checkConstructors() {
+	classes := x.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_CLASSDEF || n.NodeType == NT_CLASSDEFPARTIAL
+	})
+	for _, cls := range classes {
+		ctors := x.classConstructors(cls)
+		for _, ctor := range ctors {
+			name := funcDefName(ctor)
+			if name != funcDefName(ctors[0]) {
+				x.errorAt(ctor, "a class can't have both new and init constructors, the other is at "+
+					checkLocString(ctors[0]))
+			}
+			if outType := NodGetChildOrNil(ctor, NTR_FUNCDEF_OUTTYPE); outType != nil &&
+				(outType.NodeType != NT_TYPEBASE || outType.Data.(int) != TY_VOID) {
+				x.errorAt(ctor, "constructor "+name+" can't be declared to return anything")
+			}
+			if NodHasChild(ctor, NTR_RETURNVAL_PLACEHOLDER) {
+				for _, ret := range funcDefReturns(ctor) {
+					if NodHasChild(ret, NTR_RETURN_VALUE) {
+						x.errorAt(ret, "constructor "+name+" can't return a value")
+					}
+				}
+			}
+			if cls.NodeType == NT_CLASSDEFPARTIAL && len(FuncDefParams(ctor)) > 0 {
+				x.errorAt(ctor, "the static zone's "+name+" can't take arguments, since it runs before main")
+			}
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 	"strconv"
@@ -42,9 +41,9 @@ func (x *XformerPocket) createStaticClassZones() {
 			}
 		}
 
-		fmt.Println("static members:", PrettyPrintNodes(staticMembers))
+		Tracef(LOGCH_DESUGAR, "static members: %s", LazyTrees(staticMembers))
 
-		fmt.Println("non-static members:", PrettyPrintNodes(nonstaticMembers))
+		Tracef(LOGCH_DESUGAR, "non-static members: %s", LazyTrees(nonstaticMembers))
 
 		staticDef := NodNew(NT_CLASSDEFPARTIAL)
 		NodSetChild(staticDef, NTR_PRAGMAPAINT,
@@ -170,29 +169,16 @@ func (x *XformerPocket) mergePragmaMods(pragma Nod, addlMods []Nod) {
 	NodSetOutList(pragma, newlist)
 }
 
+const arithAssignRules = `
+# x +: 2 -> x : x + 2
+rule arithAssign
+    VARASSIGN_ARITH(VAR_NAME: lv, VARASSIGN_VALUE: rv, VARASSIGN_ARITHOP: op)
+    > VARASSIGN(VAR_NAME: lv, VARASSIGN_VALUE: op(BINOP_LEFT: VAR_GETTER(VAR_NAME: copy lv), BINOP_RIGHT: rv))
+`
+
 func (x *XformerPocket) rewriteArithAssigns() {
 	// rewrites all NT_VARASSIGN_ARITH into regular var assigns
-	// e.g., statements of the form x +: 2 -> x : x + 2
-	arithAssigns := x.SearchRoot(func(n Nod) bool {
-		if n.NodeType == NT_VARASSIGN_ARITH {
-			return true
-		}
-		return false
-	})
-
-	for _, arithAssign := range arithAssigns {
-		lValue := NodGetChild(arithAssign, NTR_VAR_NAME)
-		rValue := NodGetChild(arithAssign, NTR_VARASSIGN_VALUE)
-		varGetter := NodNew(NT_VAR_GETTER)
-		NodSetChild(varGetter, NTR_VAR_NAME, NodDeepCopyDownwards(lValue))
-		op := NodGetChild(arithAssign, NTR_VARASSIGN_ARITHOP)
-		NodSetChild(op, NTR_BINOP_LEFT, varGetter)
-		NodSetChild(op, NTR_BINOP_RIGHT, rValue)
-		regAssign := NodNew(NT_VARASSIGN)
-		NodSetChild(regAssign, NTR_VAR_NAME, lValue)
-		NodSetChild(regAssign, NTR_VARASSIGN_VALUE, op)
-		x.Replace(arithAssign, regAssign)
-	}
+	x.applyRewriteRulesOnce(x.mustCompileRewriteRules(arithAssignRules, RewriteFuncs{}))
 }
 
 func (x *XformerPocket) rewriteImplicitReturns() {
@@ -286,6 +272,9 @@ func (x *XformerPocket) rewriteForInLoop(forLoop Nod) Nod {
 	// to a lower level form involving a while loop and an index variable
 	rvSeq := []Nod{}
 	loopOver := NodGetChild(forLoop, NTR_FOR_IN_ITEROVER)
+	if isRangeOpType(loopOver.NodeType) && !NodHasChild(loopOver, NTR_RANGE_STEP) {
+		return x.rewriteForInRangeLoop(forLoop)
+	}
 	declaredElementVarName := NodGetChild(forLoop, NTR_FOR_IN_ITERVAR).Data.(string)
 	ndxVarName := x.getTempVarName()
 	iterOverVarName := x.getTempVarName()
@@ -308,11 +297,12 @@ func (x *XformerPocket) rewriteForInLoop(forLoop Nod) Nod {
 	termCond := NodNew(NT_LTOP)
 	termCondNdxVarGetter := NodNewChild(NT_VAR_GETTER, NTR_VAR_NAME,
 		NodNewData(NT_IDENTIFIER, ndxVarName))
-	termCondLenGetter := NodNew(NT_DOTOP)
-	NodSetChild(termCondLenGetter, NTR_BINOP_LEFT,
+	// dot ops have already been prepared by now, so build the field accessor directly
+	termCondLenGetter := NodNew(NT_OBJFIELD_ACCESSOR)
+	NodSetChild(termCondLenGetter, NTR_RECEIVERCALL_BASE,
 		NodNewChild(NT_VAR_GETTER, NTR_VAR_NAME,
 			NodNewData(NT_IDENTIFIER, iterOverVarName)))
-	NodSetChild(termCondLenGetter, NTR_BINOP_RIGHT, NodNewData(NT_IDENTIFIER, "len"))
+	NodSetChild(termCondLenGetter, NTR_OBJFIELD_ACCESSOR_NAME, NodNewData(NT_IDENTIFIER, "len"))
 	NodSetChild(termCond, NTR_BINOP_LEFT, termCondNdxVarGetter)
 	NodSetChild(termCond, NTR_BINOP_RIGHT, termCondLenGetter)
 
@@ -355,6 +345,59 @@ func (x *XformerPocket) rewriteForInLoop(forLoop Nod) Nod {
 
 }
 
+func (x *XformerPocket) rewriteForInRangeLoop(forLoop Nod) Nod {
+	// for <var> in a..b doesn't need the range materialized, so count instead:
+	// __ndx__ : a
+	// __end__ : b
+	// while __ndx__ <= __end__ (< for a..<b)
+	//     <var> : __ndx__
+	//     body
+	//     __ndx__ : __ndx__ + 1
+	rng := NodGetChild(forLoop, NTR_FOR_IN_ITEROVER)
+	declaredElementVarName := NodGetChild(forLoop, NTR_FOR_IN_ITERVAR).Data.(string)
+	ndxVarName := x.getTempVarName()
+	endVarName := x.getTempVarName()
+	newNdxGetter := func() Nod {
+		return NodNewChild(NT_VAR_GETTER, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, ndxVarName))
+	}
+
+	ndxVarInitializer := NodNew(NT_VARASSIGN)
+	NodSetChild(ndxVarInitializer, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, ndxVarName))
+	NodSetChild(ndxVarInitializer, NTR_VARASSIGN_VALUE, NodGetChild(rng, NTR_BINOP_LEFT))
+
+	endVarInitializer := NodNew(NT_VARASSIGN)
+	NodSetChild(endVarInitializer, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, endVarName))
+	NodSetChild(endVarInitializer, NTR_VARASSIGN_VALUE, NodGetChild(rng, NTR_BINOP_RIGHT))
+
+	var termCond Nod
+	if rng.NodeType == NT_RANGEEXCLOP {
+		termCond = NodNew(NT_LTOP)
+	} else {
+		termCond = NodNew(NT_LTEQOP)
+	}
+	NodSetChild(termCond, NTR_BINOP_LEFT, newNdxGetter())
+	NodSetChild(termCond, NTR_BINOP_RIGHT,
+		NodNewChild(NT_VAR_GETTER, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, endVarName)))
+
+	iterVarAssigner := NodNew(NT_VARASSIGN)
+	NodSetChild(iterVarAssigner, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, declaredElementVarName))
+	NodSetChild(iterVarAssigner, NTR_VARASSIGN_VALUE, newNdxGetter())
+
+	ndxVarIncrementor := NodNew(NT_VARASSIGN)
+	NodSetChild(ndxVarIncrementor, NTR_VAR_NAME, NodNewData(NT_IDENTIFIER, ndxVarName))
+	ndxVarIncrementorValue := NodNew(NT_ADDOP)
+	NodSetChild(ndxVarIncrementorValue, NTR_BINOP_LEFT, newNdxGetter())
+	NodSetChild(ndxVarIncrementorValue, NTR_BINOP_RIGHT, NodNewData(NT_LIT_INT, 1))
+	NodSetChild(ndxVarIncrementor, NTR_VARASSIGN_VALUE, ndxVarIncrementorValue)
+
+	loopBodySeq := []Nod{iterVarAssigner, NodGetChild(forLoop, NTR_FOR_BODY), ndxVarIncrementor}
+	whileLoop := NodNew(NT_WHILE)
+	NodSetChild(whileLoop, NTR_WHILE_COND, termCond)
+	NodSetChild(whileLoop, NTR_WHILE_BODY, NodNewChildList(NT_IMPERATIVE, loopBodySeq))
+
+	return NodNewChildList(NT_IMPERATIVE, []Nod{ndxVarInitializer, endVarInitializer, whileLoop})
+}
+
 func (x *XformerPocket) rewriteForClassicLoops() {
 	classicForLoops := x.SearchRoot(func(n Nod) bool {
 		return n.NodeType == NT_FOR_CLASSIC
@@ -1,7 +1,6 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 )
@@ -30,17 +29,21 @@ func (x *XformerPocket) getIdentifierRewriteRules() []*RewriteRule {
 		x.IRRKeywordArgsObjInit(),
 		// TODO: somehow re-use the var lookup framework to resolve certain Noscope Funcs
 		x.IRRSimpleVarWrites(),
-		x.IRRPlainObjInit(),
+	}
+	rv = append(rv, x.IRRPlainObjInit()...)
+	rv = append(rv,
 		x.IRRArgedObjInit(),
+		x.IRRObjInitConstructor(),
 		x.IRRReturnToPlaceholder(),
 		x.IRRResolveMethodCalls(),
-	}
+	)
 	return rv
 }
 
 func (x *XformerPocket) IRRKeywordArgsObjInit() *RewriteRule {
 	// make progress on keyword arguments that refer to object initializer fields
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_KWARG},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_KWARG {
 				varName := n.Data.(string)
@@ -48,7 +51,9 @@ func (x *XformerPocket) IRRKeywordArgsObjInit() *RewriteRule {
 				parentKwargs := NodGetParentByOrNil(parentKwArg, func(n Nod) bool { return n.NodeType == NT_KWARGS })
 				parentCall := NodGetParent(parentKwargs, NTR_RECEIVERCALL_ARG)
 				base := NodGetChild(parentCall, NTR_RECEIVERCALL_BASE)
-				if parentCall.NodeType == NT_OBJINIT && base.NodeType == NT_CLASSDEF {
+				// a constructor's parameters take them instead of the fields
+				if parentCall.NodeType == NT_OBJINIT && base.NodeType == NT_CLASSDEF &&
+					len(x.classConstructors(base)) == 0 {
 					cDef := base
 					cvTable := NodGetChild(cDef, NTR_VARTABLE)
 					vDef := x.varTableLookup(cvTable, varName)
@@ -67,6 +72,7 @@ func (x *XformerPocket) IRRKeywordArgsObjInit() *RewriteRule {
 func (x *XformerPocket) IRRKeywordArgsFuncDef() *RewriteRule {
 	// make progress on keyword arguments that refer to function parameters
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_KWARG},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_KWARG {
 				varName := n.Data.(string)
@@ -102,8 +108,9 @@ func (x *XformerPocket) IRRKeywordArgsFuncDef() *RewriteRule {
 func (x *XformerPocket) IRRTypeDeclIdentifiers() *RewriteRule {
 	// make progress on identifiers directly within type declarations
 	return &RewriteRule{
+		nodeTypes: []int{NT_VARASSIGN, NT_CLASSFIELD, NT_PARAMETER},
 		condition: func(n Nod) bool {
-			if n.NodeType == NT_VARASSIGN || n.NodeType == NT_CLASSFIELD {
+			if n.NodeType == NT_VARASSIGN || n.NodeType == NT_CLASSFIELD || n.NodeType == NT_PARAMETER {
 				if typeDecl := NodGetChildOrNil(n, NTR_TYPE_DECL); typeDecl != nil {
 					if typeDecl.NodeType == NT_IDENTIFIER {
 						return true
@@ -122,6 +129,7 @@ func (x *XformerPocket) IRRTypeDeclIdentifiers() *RewriteRule {
 func (x *XformerPocket) IRRReturnToPlaceholder() *RewriteRule {
 	// link return statements directly to the placeholder for the return value
 	return &RewriteRule{
+		nodeTypes: []int{NT_RETURN},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_RETURN {
 				if !NodHasChild(n, NTR_RETURNVAL_PLACEHOLDER) {
@@ -141,6 +149,7 @@ func (x *XformerPocket) IRRReturnToPlaceholder() *RewriteRule {
 func (x *XformerPocket) IRRParametersToLocals() *RewriteRule {
 	// all parameters link to variables in the local var table
 	return &RewriteRule{
+		nodeTypes: []int{NT_PARAMETER},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_PARAMETER {
 				if !NodHasChild(n, NTR_VARDEF) {
@@ -168,6 +177,7 @@ func (x *XformerPocket) IRRParametersToLocals() *RewriteRule {
 func (x *XformerPocket) IRRNoscopesType() *RewriteRule {
 	// make progress on single-word references to known types
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_TYPE_NOSCOPE},
 		condition: func(n Nod) bool {
 
 			if n.NodeType == NT_IDENTIFIER_TYPE_NOSCOPE {
@@ -189,36 +199,42 @@ func (x *XformerPocket) IRRNoscopesType() *RewriteRule {
 	}
 }
 
-func (x *XformerPocket) IRRPlainObjInit() *RewriteRule {
+const plainObjInitRules = `
+# a class named on its own makes an object of it, e.g. p : Point
+rule plainObjInit
+    id@IDENTIFIER_NOSCOPE when isClassName(id), !inRefOp(id)
+    > id as OBJINIT(RECEIVERCALL_BASE: classDef(id), RECEIVERCALL_ARG: EMPTYARGLIST)
+
+# but a ref to it, @Point, is the class itself
+rule plainClassRef
+    REFERENCEOP(RECEIVERCALL_ARG: id@IDENTIFIER_NOSCOPE) when isClassName(id) > classDef(id)
+
+# which it may already have been resolved to
+rule resolvedClassRef
+    REFERENCEOP(RECEIVERCALL_ARG: cls@CLASSDEF) > cls
+`
+
+func (x *XformerPocket) IRRPlainObjInit() []*RewriteRule {
 	// look up single-word references to classes, treat as either object initializers
 	// or direct class references, depending on the context
-	return &RewriteRule{
-		condaction: func(n Nod) bool {
-			if n.NodeType == NT_IDENTIFIER_NOSCOPE {
-				idtext := n.Data.(string)
-				cDef := x.globalClassDefLookup(idtext)
-				if cDef != nil {
-					// if in ref op, treat as that
-					if prnt := NodGetParentByOrNil(n,
-						func(n0 Nod) bool { return n0.NodeType == NT_REFERENCEOP }); prnt != nil {
-						x.Replace(prnt, cDef) // replace the whole ref op with the classdef
-						n.NodeType = NT_IDENTIFIER_RESOLVED
-					} else { // otherwise treat as object initializer
-						n.NodeType = NT_OBJINIT
-						NodSetChild(n, NTR_RECEIVERCALL_BASE, cDef)
-						NodSetChild(n, NTR_RECEIVERCALL_ARG, NodNew(NT_EMPTYARGLIST))
-					}
-					return true
-				}
-			}
-			return false
+	return x.mustCompileRewriteRules(plainObjInitRules, RewriteFuncs{
+		Preds: map[string]func(Nod) bool{
+			"isClassName": func(n Nod) bool { return x.globalClassDefLookup(n.Data.(string)) != nil },
+			"inRefOp": func(n Nod) bool {
+				return NodGetParentByOrNil(n, func(n0 Nod) bool { return n0.NodeType == NT_REFERENCEOP }) != nil
+			},
 		},
-	}
+		Builders: map[string]func(Nod) Nod{
+			"classDef": func(n Nod) Nod { return x.globalClassDefLookup(n.Data.(string)) },
+		},
+	})
 }
+
 func (x *XformerPocket) IRRArgedObjInit() *RewriteRule {
 	// look up single-word class references in call bases, treat as object initializers
 	// e.g. Point()
 	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_RECEIVERCALL {
 				if callBase := NodGetChildOrNil(n, NTR_RECEIVERCALL_BASE); callBase != nil {
@@ -245,6 +261,7 @@ func (x *XformerPocket) IRRArgedObjInit() *RewriteRule {
 func (x *XformerPocket) IRRBaseIdentifiers() *RewriteRule {
 	// simple rvalue identifiers are either dotop qualifiers or noscope
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_RVAL},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_RVAL {
 				return true
@@ -266,35 +283,59 @@ func (x *XformerPocket) IRRBaseIdentifiers() *RewriteRule {
 func (x *XformerPocket) IRRResolveMethodCalls() *RewriteRule {
 	// try linking known method calls to their static definition
 	return &RewriteRule{
-		condition: func(n Nod) bool {
-			if n.NodeType == NT_RECEIVERCALL_METHOD {
-				if !NodHasChild(n, NTR_TYPECOND_DEFS) {
-					return true
-				}
+		nodeTypes: []int{NT_RECEIVERCALL_METHOD},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_RECEIVERCALL_METHOD || NodHasChild(n, NTR_FUNCDEF) {
+				return false
 			}
-			return false
-		},
-		action: func(n Nod) {
 			methName := NodGetChild(n, NTR_RECEIVERCALL_METHOD_NAME).Data.(string)
-			// look up method in func table of all known classes
-			clss := x.SearchRoot(func(n Nod) bool { return n.NodeType == NT_CLASSDEF })
-			possMeths := []Nod{}
-			for _, cls := range clss {
-				methTable := NodGetChild(cls, NTR_FUNCTABLE)
-				methDef := x.funcTableLookup(methTable, methName)
-				if methDef != nil {
-					possMeths = append(possMeths, methDef)
+			if !NodHasChild(n, NTR_TYPECOND_DEFS) {
+				// look up method in func table of all known classes
+				clss := x.SearchRoot(func(n Nod) bool { return n.NodeType == NT_CLASSDEF })
+				possMeths := []Nod{}
+				for _, cls := range clss {
+					methTable := NodGetChild(cls, NTR_FUNCTABLE)
+					possMeths = append(possMeths, x.funcTableLookupAll(methTable, methName)...)
 				}
+				condDef := NodNewChildList(NT_TYPECOND_DEFS, possMeths)
+				NodSetChild(n, NTR_TYPECOND_DEFS, condDef)
+				return true
+			}
+
+			// once the receiver is known to be of one class, pick among its overloads
+			cls := x.receiverClassDefOrNil(NodGetChild(n, NTR_RECEIVERCALL_BASE))
+			if cls == nil {
+				return false
 			}
-			condDef := NodNewChildList(NT_TYPECOND_DEFS, possMeths)
-			NodSetChild(n, NTR_TYPECOND_DEFS, condDef)
+			overloads := x.funcTableLookupAll(NodGetChild(cls, NTR_FUNCTABLE), methName)
+			if len(overloads) < 2 {
+				return false
+			}
+			if mDef := x.resolveOverload(n, methName, overloads); mDef != nil {
+				NodSetChild(n, NTR_FUNCDEF, mDef)
+				return true
+			}
+			return false
 		},
 	}
 }
 
+// the class a receiver is an object of, if that's all it can be
+func (x *XformerPocket) receiverClassDefOrNil(base Nod) Nod {
+	mype := NodGetChildOrNil(base, NTR_MYPE_POS)
+	if mype == nil {
+		return nil
+	}
+	if dype := mype.Data.(Nod); dype.NodeType == NT_CLASSDEF {
+		return dype
+	}
+	return nil
+}
+
 func (x *XformerPocket) IRRBaseCallIdentifiers() *RewriteRule {
 	// simple words on the base of calls can be specified as such
 	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL, NT_RECEIVERCALL_CMD},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_RECEIVERCALL || n.NodeType == NT_RECEIVERCALL_CMD {
 				baseNod := NodGetChild(n, NTR_RECEIVERCALL_BASE)
@@ -315,6 +356,7 @@ func (x *XformerPocket) IRRBaseCallIdentifiers() *RewriteRule {
 func (x *XformerPocket) IRRBaseIdentifiersVarGet() *RewriteRule {
 	// simple identifiers inside a var get can be rewritten as IDENTIFIER_RVAL_NOSCOPE
 	return &RewriteRule{
+		nodeTypes: []int{NT_VAR_GETTER},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_VAR_GETTER {
 				lvalue := NodGetChild(n, NTR_VAR_NAME)
@@ -351,10 +393,11 @@ func (x *XformerPocket) resolveIdentifierRValNoscopeAsVar(ident Nod, varDef Nod)
 func (x *XformerPocket) IRRNoscopesGeneric() *RewriteRule {
 	// make progress on generic rvalue references: look up as either class, func, or var
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_NOSCOPE},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_NOSCOPE {
 				idtext := n.Data.(string)
-				fmt.Println("looking up unresolved generic identifier:", idtext)
+				Tracef(LOGCH_SOLVE, "looking up unresolved generic identifier: %s", idtext)
 				iDef := x.containingNamespaceLookup(n, idtext)
 				if iDef != nil {
 					if iDef.NodeType == NT_FUNCDEF {
@@ -381,29 +424,24 @@ func (x *XformerPocket) IRRNoscopesGeneric() *RewriteRule {
 func (x *XformerPocket) IRRNoscopesFuncOwnClass() *RewriteRule {
 	// make progress towards resolving NT_IDENTIFIER_FUNC_NOSCOPE: lookup in own class func table
 	return &RewriteRule{
-		condition: func(n Nod) bool {
-			if n.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
-				idtext := n.Data.(string)
-				cCls := x.getContainingClassDef(n)
-				if cCls != nil {
-					fTable := NodGetChild(cCls, NTR_FUNCTABLE)
-					fDef := x.funcTableLookup(fTable, idtext)
-					if fDef != nil {
-						return true
-					}
-				}
+		nodeTypes: []int{NT_IDENTIFIER_FUNC_NOSCOPE},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_IDENTIFIER_FUNC_NOSCOPE {
+				return false
 			}
-			return false
-		},
-		action: func(n Nod) {
-			// area() -> self.area()
-
 			idtext := n.Data.(string)
 			cCls := x.getContainingClassDef(n)
+			if cCls == nil {
+				return false
+			}
 			fTable := NodGetChild(cCls, NTR_FUNCTABLE)
-			fDef := x.funcTableLookup(fTable, idtext)
-
 			parentCall := NodGetParent(n, NTR_RECEIVERCALL_BASE)
+			fDef := x.resolveOverload(parentCall, idtext, x.funcTableLookupAll(fTable, idtext))
+			if fDef == nil {
+				return false
+			}
+
+			// area() -> self.area()
 			NodSetChild(parentCall, NTR_FUNCDEF, fDef)
 			parentCall.NodeType = NT_RECEIVERCALL_METHOD
 
@@ -414,10 +452,11 @@ func (x *XformerPocket) IRRNoscopesFuncOwnClass() *RewriteRule {
 			NodSetChild(parentCall, NTR_RECEIVERCALL_BASE, newBase)
 
 			x.initializeSolvableNode(newBase)
-
+			return true
 		},
 	}
 }
+
 func (x *XformerPocket) containingNamespaceLookup(start Nod, idtext string) Nod {
 	contns := x.getContainingNodOrNil(start, func(n Nod) bool { return NodHasChild(n, NTR_NAMESPACE) })
 	if contns == nil {
@@ -440,7 +479,7 @@ func (x *XformerPocket) namespaceLookupRecursive(ns Nod, idtext string) Nod {
 
 func (x *XformerPocket) namespaceLookupImmediate(ns Nod, idtext string) Nod {
 	// todo: support class/var defs
-	fmt.Println("ns lookup immediate: '", idtext, "' in", PrettyPrint(ns))
+	Tracef(LOGCH_SOLVE, "ns lookup immediate: '%s' in %s", idtext, LazyTree(ns))
 	if fTable := NodGetChildOrNil(ns, NTR_FUNCTABLE); fTable != nil {
 		fResult := x.funcTableLookup(fTable, idtext)
 		if fResult != nil {
@@ -465,24 +504,21 @@ func (x *XformerPocket) namespaceLookupImmediate(ns Nod, idtext string) Nod {
 func (x *XformerPocket) IRRNoscopesFuncGlobal() *RewriteRule {
 	// make progress towards resolving NT_IDENTIFIER_FUNC_NOSCOPE: lookup in global func table
 	return &RewriteRule{
-		condition: func(n Nod) bool {
-			if n.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
-				idtext := n.Data.(string)
-				fTable := NodGetChild(x.Root, NTR_FUNCTABLE)
-				fDef := x.funcTableLookup(fTable, idtext)
-				if fDef != nil {
-					return true
-				}
+		nodeTypes: []int{NT_IDENTIFIER_FUNC_NOSCOPE},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_IDENTIFIER_FUNC_NOSCOPE {
+				return false
 			}
-			return false
-		},
-		action: func(n Nod) {
 			idtext := n.Data.(string)
 			fTable := NodGetChild(x.Root, NTR_FUNCTABLE)
-			fDef := x.funcTableLookup(fTable, idtext)
-			n.NodeType = NT_IDENTIFIER_RESOLVED
 			parentCall := NodGetParent(n, NTR_RECEIVERCALL_BASE)
+			fDef := x.resolveOverload(parentCall, idtext, x.funcTableLookupAll(fTable, idtext))
+			if fDef == nil {
+				return false
+			}
+			n.NodeType = NT_IDENTIFIER_RESOLVED
 			NodSetChild(parentCall, NTR_FUNCDEF, fDef)
+			return true
 		},
 	}
 }
@@ -490,6 +526,7 @@ func (x *XformerPocket) IRRNoscopesFuncGlobal() *RewriteRule {
 func (x *XformerPocket) IRRNoscopesFuncObjInit() *RewriteRule {
 	// make progress towards resolving NT_IDENTIFIER_FUNC_NOSCOPE: lookup object initializer in class table
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_FUNC_NOSCOPE},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
 				idtext := n.Data.(string)
@@ -517,6 +554,7 @@ func (x *XformerPocket) IRRNoscopesFuncObjInit() *RewriteRule {
 func (x *XformerPocket) IRRNoscopesFuncLocalVar() *RewriteRule {
 	// make progress towards resolving NT_IDENTIFIER_FUNC_NOSCOPE: lookup object initializer in class table
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_FUNC_NOSCOPE},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
 				idtext := n.Data.(string)
@@ -550,6 +588,7 @@ func (x *XformerPocket) IRRNoscopesFuncLocalVar() *RewriteRule {
 func (x *XformerPocket) IRRNoscopesLocals() *RewriteRule {
 	// make progress towards resolving NT_IDENTIFIER_RVAL_NOSCOPEs: check for local variable
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_NOSCOPE},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_NOSCOPE {
 				idtext := n.Data.(string)
@@ -577,6 +616,7 @@ func (x *XformerPocket) IRRNoscopesLocals() *RewriteRule {
 func (x *XformerPocket) IRRSimpleVarWrites() *RewriteRule {
 	// resolve simple variable writes: either existing local, new local, or class var
 	return &RewriteRule{
+		nodeTypes: []int{NT_VARASSIGN},
 		condition: func(n Nod) bool {
 			if n.NodeType == NT_VARASSIGN {
 				varName := NodGetChild(n, NTR_VAR_NAME)
@@ -589,7 +629,7 @@ func (x *XformerPocket) IRRSimpleVarWrites() *RewriteRule {
 		action: func(n Nod) {
 			varName := NodGetChild(n, NTR_VAR_NAME)
 			idtext := varName.Data.(string)
-			cCls := x.getContainingClassDef(n)
+			cCls := x.getContainingClassOrStaticZone(n)
 			if cCls != nil {
 				cTable := NodGetChild(cCls, NTR_VARTABLE)
 				clsVarDef := x.varTableLookup(cTable, idtext)
@@ -626,6 +666,13 @@ func (x *XformerPocket) getContainingClassDef(n Nod) Nod {
 	return x.getContainingNodOrNil(n, func(n Nod) bool { return n.NodeType == NT_CLASSDEF })
 }
 
+// like getContainingClassDef, but the static zone for what's in one
+func (x *XformerPocket) getContainingClassOrStaticZone(n Nod) Nod {
+	return x.getContainingNodOrNil(n, func(n Nod) bool {
+		return n.NodeType == NT_CLASSDEF || n.NodeType == NT_CLASSDEFPARTIAL
+	})
+}
+
 func (x *XformerPocket) varTableLookup(vt Nod, varName string) Nod {
 	vDefs := NodGetChildList(vt)
 	for _, vDef := range vDefs {
@@ -638,12 +685,13 @@ func (x *XformerPocket) varTableLookup(vt Nod, varName string) Nod {
 }
 
 func (x *XformerPocket) funcTableLookup(ft Nod, funcName string) Nod {
-	fDefs := NodGetChildList(ft)
-	for _, fDef := range fDefs {
-		fName := NodGetChild(fDef, NTR_FUNCDEF_NAME).Data.(string)
-		if fName == funcName {
-			return fDef
-		}
+	// without a call's arguments there's no telling overloads apart
+	fDefs := x.funcTableLookupAll(ft, funcName)
+	if len(fDefs) > 1 {
+		panic(funcName + " is overloaded, so it can only be called, at " + checkLocString(fDefs[1]))
+	}
+	if len(fDefs) == 1 {
+		return fDefs[0]
 	}
 	return nil
 }
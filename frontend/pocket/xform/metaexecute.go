@@ -1,7 +1,6 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 )
@@ -12,7 +11,7 @@ type MetaExecutor struct {
 
 func (e *MetaExecutor) executeFunction(function Nod) {
 	imperativeBody := NodGetChild(function, NTR_FUNCDEF_CODE)
-	fmt.Println("meta-executing", PrettyPrint(imperativeBody))
+	Tracef(LOGCH_SOLVE, "meta-executing %s", LazyTree(imperativeBody))
 
 	executableNodes := e.solver.xformer.SearchRoot(func(n Nod) bool {
 		return e.isSolvable(n.NodeType)
@@ -22,7 +21,7 @@ func (e *MetaExecutor) executeFunction(function Nod) {
 		e.executeNode(execNode)
 	}
 
-	fmt.Println("after executing function", PrettyPrint(function))
+	Tracef(LOGCH_SOLVE, "after executing function %s", LazyTree(function))
 
 	panic("done executing function")
 }
@@ -32,7 +31,7 @@ func (e *MetaExecutor) isSolvable(nt int) bool {
 }
 
 func (e *MetaExecutor) executeNode(n Nod) bool { // returns whether change made
-	fmt.Println("Executing node", PrettyPrint(n))
+	Tracef(LOGCH_SOLVE, "executing node %s", LazyTree(n))
 
 	nt := n.NodeType
 
@@ -48,7 +47,7 @@ func (e *MetaExecutor) executeNode(n Nod) bool { // returns whether change made
 
 	if !NodHasChild(n, NNTR_KNOWLEDGE) {
 		knowledgeNod := NodNewChildList(NNT_KNOWLEDGE_DISJUNCTION, outKnow)
-		fmt.Println("output knowledge:", PrettyPrint(knowledgeNod))
+		Tracef(LOGCH_SOLVE, "output knowledge: %s", LazyTree(knowledgeNod))
 
 		NodSetChild(n, NNTR_KNOWLEDGE, knowledgeNod)
 		return true
@@ -1,7 +1,6 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 )
@@ -35,7 +34,7 @@ func (s *NSolver) initializeSymbolTables() {
 		s.initializeSymbolTable(host)
 	}
 
-	fmt.Println("after initializing symbol tables:", PrettyPrint(s.xformer.Root))
+	Debugf(LOGCH_SOLVE, "after initializing symbol tables: %s", LazyTree(s.xformer.Root))
 }
 
 func (s *NSolver) initializeSymbolTable(host Nod) {
@@ -71,7 +70,7 @@ func (s *NSolver) initializeSymbolTable(host Nod) {
 		NodSetChild(table, NTR_CLASSTABLE, NodNewData(NT_CLASSTABLE, map[string]Nod{}))
 	}
 
-	fmt.Println("inserting symtable", PrettyPrint(table))
+	Tracef(LOGCH_SOLVE, "inserting symtable %s", LazyTree(table))
 
 	NodSetChild(host, NNTR_SYMTABLE, table)
 }
@@ -0,0 +1,119 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"sort"
+)
+
+// Operators on objects are calls to their class's operator methods (see
+// OperatorMethodNames). Once the left operand is known to be an object of one
+// class, the op, indexor or .len is linked to the method through NTR_FUNCDEF
+// and typed by what it returns, like any other call. The go backend's
+// preparer turns them into method calls.
+
+func (x *XformerPocket) marPosOperatorMethods() []*RewriteRule {
+	ops := []int{}
+	for op := range OperatorMethodNames {
+		ops = append(ops, op)
+	}
+	sort.Ints(ops)
+	rv := []*RewriteRule{}
+	for _, op := range ops {
+		rv = append(rv, x.marPosOperatorMethod(op, OperatorMethodNames[op]))
+	}
+	return append(rv, x.marPosIndexMethod(), x.marPosLenMethod())
+}
+
+func (x *XformerPocket) marPosOperatorMethod(op int, name string) *RewriteRule {
+	// v + w -> v.add(w), when v is an object of a class with an add method
+	return &RewriteRule{
+		nodeTypes: []int{op},
+		condaction: func(n Nod) bool {
+			if n.NodeType == op && !NodHasChild(n, NTR_FUNCDEF) {
+				return x.linkOperatorMethod(n, NodGetChild(n, NTR_BINOP_LEFT), name, 1)
+			}
+			return false
+		},
+	}
+}
+
+func (x *XformerPocket) marPosIndexMethod() *RewriteRule {
+	// obj[i] -> obj.index(i)
+	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_RECEIVERCALL || NodHasChild(n, NTR_FUNCDEF) ||
+				!NodHasChild(n, NTR_RECEIVERCALL_ARG) {
+				return false
+			}
+			base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+			cls := x.receiverClassDefOrNil(base)
+			if cls == nil || len(x.funcTableLookupAll(NodGetChild(cls, NTR_FUNCTABLE), INDEX_METHOD_NAME)) == 0 {
+				return false
+			}
+			// the method takes the index the [i] syntax wraps in a list
+			arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
+			if idx := getIndexArg(arg); idx != arg {
+				NodSetChild(n, NTR_RECEIVERCALL_ARG, idx)
+			}
+			return x.linkOperatorMethod(n, base, INDEX_METHOD_NAME, 1)
+		},
+	}
+}
+
+func (x *XformerPocket) marPosLenMethod() *RewriteRule {
+	// obj.len -> obj.len(), unless len is a field
+	return &RewriteRule{
+		nodeTypes: []int{NT_OBJFIELD_ACCESSOR},
+		condaction: func(n Nod) bool {
+			if n.NodeType != NT_OBJFIELD_ACCESSOR || NodHasChild(n, NTR_FUNCDEF) {
+				return false
+			}
+			if name, ok := NodGetChild(n, NTR_OBJFIELD_ACCESSOR_NAME).Data.(string); !ok || name != LEN_METHOD_NAME {
+				return false
+			}
+			base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+			cls := x.receiverClassDefOrNil(base)
+			if cls == nil || x.varTableLookup(NodGetChild(cls, NTR_VARTABLE), LEN_METHOD_NAME) != nil {
+				return false
+			}
+			return x.linkOperatorMethod(n, base, LEN_METHOD_NAME, 0)
+		},
+	}
+}
+
+// links n to the method of object's class named name that stands in for it,
+// once it's known which one that is, and gives n the type it returns. Methods
+// with that name that take some other number of arguments aren't operators.
+func (x *XformerPocket) linkOperatorMethod(n Nod, object Nod, name string, nParams int) bool {
+	cls := x.receiverClassDefOrNil(object)
+	if cls == nil {
+		return false
+	}
+	candidates := []Nod{}
+	for _, mDef := range x.funcTableLookupAll(NodGetChild(cls, NTR_FUNCTABLE), name) {
+		if len(FuncDefParams(mDef)) == nParams {
+			candidates = append(candidates, mDef)
+		}
+	}
+	mDef := x.resolveOverload(n, name, candidates)
+	if mDef == nil {
+		return false
+	}
+	Tracef(LOGCH_SOLVE, "linked %s to operator method %s", NodeTypeName(n.NodeType), name)
+	NodSetChild(n, NTR_FUNCDEF, mDef)
+	rvPlaceholder := NodGetChild(mDef, NTR_RETURNVAL_PLACEHOLDER)
+	NodSetChild(n, NTR_MYPE_POS, NodGetChild(rvPlaceholder, NTR_MYPE_POS))
+	NodSetChild(n, NTR_MYPE_NEG, NodGetChild(rvPlaceholder, NTR_MYPE_NEG))
+	return true
+}
+
+// what a call gives the func it's linked to: an operator's method gets the
+// right operand, and a len method nothing
+func callArgOf(call Nod) Nod {
+	if isBinaryOpType(call.NodeType) {
+		return NodGetChild(call, NTR_BINOP_RIGHT)
+	}
+	return NodGetChildOrNil(call, NTR_RECEIVERCALL_ARG)
+}
@@ -0,0 +1,227 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"strings"
+)
+
+// Funcs and methods can share a name as long as their parameters differ. The
+// func tables keep every one, and a call is linked to the overload its
+// arguments fit once their types are known: the one that needs the fewest
+// number literals taken as another numeric type, then the one whose
+// parameters are the most specific.
+
+// the funcs named funcName in a func table, its overload set
+func (x *XformerPocket) funcTableLookupAll(ft Nod, funcName string) []Nod {
+	rv := []Nod{}
+	for _, fDef := range NodGetChildList(ft) {
+		if NodGetChild(fDef, NTR_FUNCDEF_NAME).Data.(string) == funcName {
+			rv = append(rv, fDef)
+		}
+	}
+	return rv
+}
+
+// picks the overload of name that call is to, or nil until its arguments'
+// types are known well enough to tell
+func (x *XformerPocket) resolveOverload(call Nod, name string, candidates []Nod) Nod {
+	if len(candidates) < 2 {
+		if len(candidates) == 0 {
+			return nil
+		}
+		return candidates[0]
+	}
+	arg := callArgOf(call)
+	best := []Nod{}
+	bestConversions := -1
+	for _, cand := range candidates {
+		conversions, fits, known := x.overloadFits(cand, arg)
+		if !known {
+			return nil
+		}
+		if !fits {
+			continue
+		}
+		if bestConversions < 0 || conversions < bestConversions {
+			best, bestConversions = []Nod{}, conversions
+		}
+		if conversions == bestConversions {
+			best = append(best, cand)
+		}
+	}
+	if len(best) == 0 {
+		panic("no " + name + " takes " + x.describeCallArgs(arg) + ", at " + checkLocString(call))
+	}
+	for _, cand := range best {
+		mostSpecific := true
+		for _, other := range best {
+			if other != cand && !x.overloadAtLeastAsSpecific(cand, other) {
+				mostSpecific = false
+			}
+		}
+		if mostSpecific {
+			return cand
+		}
+	}
+	locs := []string{}
+	for _, cand := range best {
+		locs = append(locs, checkLocString(cand))
+	}
+	panic("ambiguous call to " + name + ", which could be the one at " + strings.Join(locs, " or the one at ") +
+		", at " + checkLocString(call))
+}
+
+// whether a call's argument fits cand, and with how many number literals
+// taken as another numeric type. It isn't known while an argument's type
+// is still empty.
+func (x *XformerPocket) overloadFits(cand Nod, arg Nod) (conversions int, fits bool, known bool) {
+	params := FuncDefParams(cand)
+	args, ok := callArgsFor(arg, params)
+	if !ok {
+		return 0, false, true
+	}
+	for ndx, param := range params {
+		decl := x.paramDeclType(param)
+		argMype := NodGetChildOrNil(args[ndx], NTR_MYPE_POS)
+		if decl == nil || argMype == nil {
+			continue
+		}
+		argDype := argMype.Data.(Nod)
+		if argDype.NodeType == DYPE_EMPTY {
+			return 0, false, false
+		}
+		if DypeIsSubset(decl, argDype) {
+			continue
+		} else if literalFitsType(decl, args[ndx]) {
+			conversions++
+		} else {
+			return conversions, false, true
+		}
+	}
+	return conversions, true, true
+}
+
+// whether every argument list a fits, b fits too
+func (x *XformerPocket) overloadAtLeastAsSpecific(a Nod, b Nod) bool {
+	aParams, bParams := FuncDefParams(a), FuncDefParams(b)
+	if len(aParams) != len(bParams) {
+		return false
+	}
+	for ndx := range aParams {
+		aDecl, bDecl := x.paramDeclType(aParams[ndx]), x.paramDeclType(bParams[ndx])
+		if bDecl != nil && (aDecl == nil || !DypeIsSubset(bDecl, aDecl)) {
+			return false
+		}
+	}
+	return true
+}
+
+// whether two overloads take the same types, so no call could tell them apart
+func (x *XformerPocket) overloadsClash(a Nod, b Nod) bool {
+	return x.overloadAtLeastAsSpecific(a, b) && x.overloadAtLeastAsSpecific(b, a)
+}
+
+// the type a parameter is declared as, with class names looked up, or nil
+// if it takes anything
+func (x *XformerPocket) paramDeclType(param Nod) Nod {
+	decl := NodGetChildOrNil(param, NTR_TYPE_DECL)
+	if decl == nil {
+		return nil
+	}
+	if name, ok := decl.Data.(string); ok && decl.NodeType != NT_TYPEBASE {
+		if cDef := x.classTableLookup(NodGetChild(x.Root, NTR_CLASSTABLE), name); cDef != nil {
+			return cDef
+		}
+	}
+	return decl
+}
+
+// the arguments a call gives each of params, in order, or false if they don't
+// line up. Keyword arguments go by name.
+func callArgsFor(arg Nod, params []Nod) ([]Nod, bool) {
+	if arg == nil || arg.NodeType != NT_KWARGS {
+		args := positionalArgs(arg, len(params))
+		return args, len(args) == len(params)
+	}
+	kwargs := NodGetChildList(arg)
+	if len(kwargs) != len(params) {
+		return nil, false
+	}
+	byName := map[string]Nod{}
+	for _, kwarg := range kwargs {
+		byName[NodGetChild(kwarg, NTR_VAR_NAME).Data.(string)] = NodGetChild(kwarg, NTR_VARASSIGN_VALUE)
+	}
+	rv := []Nod{}
+	for _, param := range params {
+		val, ok := byName[NodGetChild(param, NTR_VARDEF_NAME).Data.(string)]
+		if !ok {
+			return nil, false
+		}
+		rv = append(rv, val)
+	}
+	return rv, true
+}
+
+// the positional arguments of a call to a func with nParams parameters: a
+// single parameter takes the argument whole, even a list
+func positionalArgs(arg Nod, nParams int) []Nod {
+	if arg == nil || arg.NodeType == NT_EMPTYARGLIST {
+		return []Nod{}
+	} else if arg.NodeType == NT_LIT_LIST && nParams != 1 {
+		return NodGetChildList(arg)
+	}
+	return []Nod{arg}
+}
+
+// e.g. (int, string), by what's known of the argument types so far
+func (x *XformerPocket) describeCallArgs(arg Nod) string {
+	args := positionalArgs(arg, -1)
+	if arg != nil && arg.NodeType == NT_KWARGS {
+		args = []Nod{}
+		for _, kwarg := range NodGetChildList(arg) {
+			args = append(args, NodGetChild(kwarg, NTR_VARASSIGN_VALUE))
+		}
+	}
+	names := []string{}
+	for _, a := range args {
+		if mype := NodGetChildOrNil(a, NTR_MYPE_POS); mype != nil {
+			names = append(names, DypeString(mype.Data.(Nod)))
+		} else {
+			names = append(names, "?")
+		}
+	}
+	return "(" + strings.Join(names, ", ") + ")"
+}
+
+// overloads have to differ in what they take, and calls to an overloaded
+// method have to be linked to one of them
+func (x *XformerPocket) checkOverloads() {
+	tables := x.SearchRoot(func(n Nod) bool { return n.NodeType == NT_FUNCTABLE })
+	for _, table := range tables {
+		fDefs := NodGetChildList(table)
+		for i, a := range fDefs {
+			for _, b := range fDefs[i+1:] {
+				if funcDefName(a) == funcDefName(b) && x.overloadsClash(a, b) {
+					x.errorAt(b, funcDefName(b)+" takes the same types as the one at "+checkLocString(a))
+				}
+			}
+		}
+	}
+
+	calls := x.SearchRoot(func(n Nod) bool {
+		return n.NodeType == NT_RECEIVERCALL_METHOD && !NodHasChild(n, NTR_FUNCDEF) &&
+			NodHasChild(n, NTR_TYPECOND_DEFS)
+	})
+	for _, call := range calls {
+		perClass := map[Nod]int{}
+		for _, mDef := range NodGetChildList(NodGetChild(call, NTR_TYPECOND_DEFS)) {
+			if cls := x.getContainingClassDef(mDef); cls != nil {
+				perClass[cls]++
+				if perClass[cls] == 2 {
+					x.errorAt(call, "can't tell which "+funcDefName(mDef)+" is called")
+				}
+			}
+		}
+	}
+}
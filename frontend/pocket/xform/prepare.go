@@ -1,14 +1,15 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 )
 
 func (x *XformerPocket) prepare() {
-	x.parseMolecules()
+	// op streams go first, since they unpack the molecules among their operands
 	x.parseInlineOpStreams()
+	x.parseMolecules()
+	x.prepareRangeSteps()
 	x.prepareDotOps()
 	x.addImplicitSelvesToMethods()
 	x.annotateKeywordArgs()
@@ -34,6 +35,24 @@ func (x *XformerPocket) parseInlineOpStreams() {
 	})
 }
 
+func (x *XformerPocket) prepareRangeSteps() {
+	// folds <range> by <step> into the range itself, so that a stepped range
+	// is just a range with an extra child
+	x.SearchReplaceAll(func(n Nod) bool {
+		return n.NodeType == NT_RANGESTEPOP
+	}, func(n Nod) Nod {
+		rng := NodGetChild(n, NTR_BINOP_LEFT)
+		if !isRangeOpType(rng.NodeType) || NodHasChild(rng, NTR_RANGE_STEP) {
+			panic("'by' must follow a range, at " + n.Loc.StringDebug())
+		}
+		step := NodGetChild(n, NTR_BINOP_RIGHT)
+		NodRemoveChild(n, NTR_BINOP_LEFT)
+		NodRemoveChild(n, NTR_BINOP_RIGHT)
+		NodSetChild(rng, NTR_RANGE_STEP, step)
+		return rng
+	})
+}
+
 func (x *XformerPocket) annotateKeywordArgs() {
 	candCalls := x.SearchRoot(func(n Nod) bool {
 		if n.NodeType == NT_RECEIVERCALL || n.NodeType == NT_RECEIVERCALL_CMD {
@@ -166,71 +185,135 @@ func (x *XformerPocket) prepareDotOps() {
 func (x *XformerPocket) parseMolecule(molecule Nod) Nod {
 	// converts an instance of NT_VALUE_MOLECULE
 	// to a proper tree representation of the constituent ops
+	prefixOps, atom := x.splitMolecule(molecule)
+	return x.applyPrefixOps(prefixOps, atom, len(prefixOps))
+}
 
-	// for now assume priority is determined by left-right ordering
+func (x *XformerPocket) splitMolecule(molecule Nod) ([]Nod, Nod) {
+	// returns the prefix ops (outermost first) and the atom of a molecule
 	streamNods := NodGetChildList(molecule)
-	// our strategy: reduce streamNods until it no longer directly contains any stub operators
-	atomNdx := -1 // contains the known index of the atom within streamNods
-	// first find the atom
 	for i, nod := range streamNods {
 		if !isPrefixOpType(nod.NodeType) {
-			atomNdx = i
-			break
+			if i != len(streamNods)-1 {
+				panic("invalid molecule stream")
+			}
+			return streamNods[:i], nod
 		}
 	}
-	if atomNdx == -1 {
-		panic("state error")
-	}
-	for len(streamNods) > 1 {
-		if firstNod := streamNods[0]; isPrefixOpType(firstNod.NodeType) {
-			pop := streamNods[atomNdx-1]
-			atom := streamNods[atomNdx]
-			NodSetChild(pop, NTR_RECEIVERCALL_ARG, atom)
-			streamNods = x.removeNodListAt(streamNods, atomNdx)
-			atomNdx--
-		} else if lastNod := streamNods[len(streamNods)-1]; isSuffixOpType(lastNod.NodeType) {
-			panic("ah suffix")
+	panic("state error")
+}
+
+func (x *XformerPocket) applyPrefixOps(prefixOps []Nod, atom Nod, count int) Nod {
+	// applies the innermost count prefix ops to atom
+	for i := len(prefixOps) - 1; i >= len(prefixOps)-count; i-- {
+		pop := prefixOps[i]
+		if pop.NodeType == NT_NEGOP && atom.NodeType == NT_LIT_INT {
+			// fold negative literals so that e.g. i8 : -128 is in range
+			atom.Data = -atom.Data.(int)
+		} else if pop.NodeType == NT_NEGOP && atom.NodeType == NT_LIT_FLOAT {
+			atom.Data = -atom.Data.(float64)
+		} else if pop.NodeType == NT_POSOP && isNumericLiteralNodeType(atom.NodeType) {
+			// no-op
 		} else {
-			panic("invalid molecule stream")
+			NodSetChild(pop, NTR_RECEIVERCALL_ARG, atom)
+			atom = pop
 		}
 	}
-	rv := streamNods[0]
-	return rv
+	return atom
+}
+
+type opPrecedenceLevel struct {
+	ops        []int
+	isPrefix   bool // the ops are prefix ops of the operands, not binary ops
+	rightAssoc bool
+}
+
+func getOpPrecedenceLevels() []opPrecedenceLevel {
+	// highest priority first.  & and | are low since they're mostly used as
+	// logical ops, so bitwise uses next to comparisons need parens. As in
+	// python, -2 ** 2 is -(2 ** 2), and 2 ** -1 takes the sign with it.
+	return []opPrecedenceLevel{
+		{ops: []int{NT_DOTOP, NT_DOTPIPEOP}},
+		{ops: []int{NT_REFERENCEOP}, isPrefix: true},
+		{ops: []int{NT_POWOP}, rightAssoc: true},
+		{ops: []int{NT_NEGOP, NT_POSOP, NT_REFERENCEOP}, isPrefix: true},
+		{ops: []int{NT_MULOP, NT_DIVOP, NT_MODOP}},
+		{ops: []int{NT_ADDOP, NT_SUBOP}},
+		{ops: []int{NT_SHLOP, NT_SHROP}},
+		{ops: []int{NT_XOROP}},
+		{ops: []int{NT_RANGEOP, NT_RANGEEXCLOP}},
+		{ops: []int{NT_RANGESTEPOP}},
+		{ops: []int{NT_LTOP, NT_LTEQOP, NT_GTOP, NT_GTEQOP, NT_EQOP}},
+		// not a = b is not (a = b)
+		{ops: []int{NT_NOTOP, NT_NEGOP, NT_POSOP, NT_REFERENCEOP}, isPrefix: true},
+		{ops: []int{NT_OROP, NT_ANDOP}},
+	}
 }
 
 func (x *XformerPocket) parseInlineOpStream(opStream Nod) Nod {
 	// converts an inline op stream to a proper prioritized tree representation
-	priGroups := [][]int{
-		[]int{NT_DOTOP, NT_DOTPIPEOP},
-		[]int{NT_MULOP, NT_DIVOP, NT_MODOP},
-		[]int{NT_ADDOP, NT_SUBOP},
-		[]int{NT_LTOP, NT_LTEQOP, NT_GTOP, NT_GTEQOP, NT_EQOP},
-		[]int{NT_OROP, NT_ANDOP},
-	}
 	opStreamNods := NodGetChildList(opStream)
 	operands := []Nod{}
+	prefixes := [][]Nod{} // pending prefix ops of each operand, outermost first
 	operators := []Nod{}
 	for i := 0; i < len(opStreamNods); i += 2 {
-		operands = append(operands, opStreamNods[i])
+		operand := opStreamNods[i]
+		var prefixOps []Nod
+		if operand.NodeType == NT_VALUE_MOLECULE {
+			prefixOps, operand = x.splitMolecule(operand)
+		}
+		operands = append(operands, operand)
+		prefixes = append(prefixes, prefixOps)
 	}
 	for i := 1; i < len(opStreamNods); i += 2 {
 		operators = append(operators, opStreamNods[i])
 	}
-	fmt.Println("operands", PrettyPrintNodes(operands))
-	fmt.Println("operators", PrettyPrintNodes(operators))
-	for _, priGroup := range priGroups {
-		for _, currOp := range priGroup {
+	Tracef(LOGCH_PREPARE, "operands %s", LazyTrees(operands))
+	Tracef(LOGCH_PREPARE, "operators %s", LazyTrees(operators))
+	for _, level := range getOpPrecedenceLevels() {
+		if level.isPrefix {
+			for i, prefixOps := range prefixes {
+				count := 0
+				for count < len(prefixOps) &&
+					isIntInList(prefixOps[len(prefixOps)-count-1].NodeType, level.ops) {
+					count++
+				}
+				operands[i] = x.applyPrefixOps(prefixOps, operands[i], count)
+				prefixes[i] = prefixOps[:len(prefixOps)-count]
+			}
+			continue
+		}
+		groupAt := func(i int) {
+			op := operators[i].NodeType
+			if op == NT_POWOP {
+				// the exponent's sign is its own
+				operands[i+1] = x.applyPrefixOps(prefixes[i+1], operands[i+1], len(prefixes[i+1]))
+				prefixes[i+1] = nil
+			}
+			if len(prefixes[i+1]) > 0 {
+				panic("prefix op needs parentheses here, at " + operators[i].Loc.StringDebug())
+			}
+			groupedOp := NodNew(op)
+			groupedOp.Loc = operators[i].Loc
+			NodSetChild(groupedOp, NTR_BINOP_LEFT, operands[i])
+			NodSetChild(groupedOp, NTR_BINOP_RIGHT, operands[i+1])
+			// replace 2 operands with single group
+			operands = x.removeNodListAt(operands, i)
+			operands[i] = groupedOp
+			prefixes = append(prefixes[:i+1], prefixes[i+2:]...)
+			// remove operator
+			operators = x.removeNodListAt(operators, i)
+		}
+		if level.rightAssoc {
+			for i := len(operators) - 1; i >= 0; i-- {
+				if isIntInList(operators[i].NodeType, level.ops) {
+					groupAt(i)
+				}
+			}
+		} else {
 			for i := 0; i < len(operators); i++ {
-				op := operators[i].NodeType
-				if currOp == op {
-					groupedOp := NodNew(op)
-					NodSetChild(groupedOp, NTR_BINOP_LEFT, operands[i])
-					NodSetChild(groupedOp, NTR_BINOP_RIGHT, operands[i+1])
-					// replace 2 operands with single group
-					operands = x.removeNodListAt(operands, i)
-					operands[i] = groupedOp
-					// remove operator
-					operators = x.removeNodListAt(operators, i)
+				if isIntInList(operators[i].NodeType, level.ops) {
+					groupAt(i)
 					i--
 				}
 			}
@@ -0,0 +1,296 @@
+package xform
+
+import (
+	"fmt"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"pocket-lang/types"
+	. "pocket-lang/xform"
+	"sort"
+	"strings"
+)
+
+// Provenance records how the solver got to each node's type: every time a
+// rule widens a positive dype or narrows a negative one, which rule it was,
+// the node it was applied to, the round, and the dype before and after.
+// Dypes are held in containers shared between a var's references, so changes
+// are kept by container, and each node's containers are noted when it's
+// colored. Normal builds only keep what a type error's summary needs.
+type Provenance struct {
+	changes     map[Nod][]*TypeChange // by container
+	origins     map[Nod]*TypeChange   // by the dype a change produced
+	mypes       map[Nod][2]Nod        // the pos and neg containers of each node
+	nChanges    int
+	summaryOnly bool // only the last change to each container, and the changes it came from
+}
+
+type TypeChange struct {
+	seq    int
+	Rule   string
+	Round  int
+	Node   Nod  // the node the rule was applied to
+	Pos    bool // whether it widened a positive dype, rather than narrowed a negative one
+	Before Nod
+	After  Nod
+	Cause  *TypeChange // the change that made the dype it was widened or narrowed by, if any
+	decl   bool        // narrowed by a type declaration
+}
+
+func newProvenance() *Provenance {
+	return &Provenance{changes: map[Nod][]*TypeChange{}, origins: map[Nod]*TypeChange{}, mypes: map[Nod][2]Nod{}}
+}
+
+// a record that can only give a Summary, for saying where a type error came
+// from
+func newSummaryProvenance() *Provenance {
+	p := newProvenance()
+	p.summaryOnly = true
+	return p
+}
+
+func (x *XformerPocket) recordTypeChange(container Nod, pos bool, before Nod, by Nod) {
+	p := x.prov
+	if p == nil {
+		return
+	}
+	p.nChanges++
+	// a declaration narrows by its own type node, which an earlier narrowing
+	// by it could have left as some dype's too
+	decl := x.rewriteNode != nil && NodGetChildOrNil(x.rewriteNode, NTR_TYPE_DECL) == by
+	change := &TypeChange{
+		seq:    p.nChanges,
+		Rule:   GetRewriteRuleName(x.rewriteRule),
+		Round:  x.rewriteRound,
+		Node:   x.rewriteNode,
+		Pos:    pos,
+		Before: before,
+		After:  container.Data.(Nod),
+		decl:   decl,
+	}
+	if !decl {
+		change.Cause = p.origins[by]
+	}
+	if p.summaryOnly {
+		p.changes[container] = []*TypeChange{change}
+	} else {
+		p.changes[container] = append(p.changes[container], change)
+	}
+	p.origins[change.After] = change
+}
+
+// notes the containers of nodes before they're replaced by their final type
+func (x *XformerPocket) recordMypes(nodes []Nod) {
+	if x.prov == nil {
+		return
+	}
+	for _, n := range nodes {
+		x.prov.mypes[n] = [2]Nod{NodGetChildOrNil(n, NTR_MYPE_POS), NodGetChildOrNil(n, NTR_MYPE_NEG)}
+	}
+}
+
+// XformWithProvenance runs Xform on the rule-based solver, keeping a record of
+// how each node got its type. A type error doesn't stop it, so the record can
+// explain it; it's returned as err.
+func XformWithProvenance(root Nod) (prov *Provenance, err error) {
+	prov = newProvenance()
+	xformer := &XformerPocket{Xformer: &Xformer{}, prov: prov}
+	xformer.Root = root
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	xformer.removeTestDefs()
+	xformer.xformUntil("desugar")
+	xformer.oldSolve()
+	xformer.check()
+	return prov, nil
+}
+
+// NodesAt returns the nodes that were given a type and were parsed nearest to
+// line and column, outermost first. See locString for what a node's location
+// is; the nearest is the first one at or after column.
+func (p *Provenance) NodesAt(line int, column int) []Nod {
+	best := -1
+	for n := range p.mypes {
+		if n.Loc != nil && n.Loc.Line+1 == line && locDistance(n.Loc.Column, column) < locDistance(best, column) {
+			best = n.Loc.Column
+		}
+	}
+	rv := []Nod{}
+	for n := range p.mypes {
+		if n.Loc != nil && n.Loc.Line+1 == line && n.Loc.Column == best {
+			rv = append(rv, n)
+		}
+	}
+	// a parent's type is usually what's being asked about
+	sort.Slice(rv, func(i, j int) bool {
+		if a, b := countNodesUnder(rv[i], map[Nod]bool{}), countNodesUnder(rv[j], map[Nod]bool{}); a != b {
+			return a > b
+		}
+		return rv[i].NodeType < rv[j].NodeType
+	})
+	return rv
+}
+
+// how far a location's column is from column, counting the ones before it as
+// further than any after
+func locDistance(locColumn int, column int) int {
+	if locColumn < 0 {
+		return 1 << 30
+	} else if locColumn < column {
+		return 1<<20 + column - locColumn
+	}
+	return locColumn - column
+}
+
+func countNodesUnder(n Nod, seen map[Nod]bool) int {
+	seen[n] = true
+	count := 1
+	for _, edge := range n.Out {
+		if !seen[edge.Out] && edge.EdgeType != NTR_MYPE_POS && edge.EdgeType != NTR_MYPE_NEG &&
+			edge.EdgeType != NTR_TYPE && edge.EdgeType != NTR_VARDEF && edge.EdgeType != NTR_FUNCDEF {
+			count += countNodesUnder(edge.Out, seen)
+		}
+	}
+	return count
+}
+
+// the changes to a container, with the changes that caused them, in the order
+// they were made
+func (p *Provenance) derivation(container Nod) []*TypeChange {
+	seen := map[*TypeChange]bool{}
+	rv := []*TypeChange{}
+	for _, change := range p.changes[container] {
+		for c := change; c != nil && !seen[c]; c = c.Cause {
+			seen[c] = true
+			rv = append(rv, c)
+		}
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].seq < rv[j].seq })
+	return rv
+}
+
+// the change a container's current dype started from
+func (p *Provenance) rootCause(container Nod) *TypeChange {
+	changes := p.changes[container]
+	if len(changes) == 0 {
+		return nil
+	}
+	c := changes[len(changes)-1]
+	for c.Cause != nil {
+		c = c.Cause
+	}
+	return c
+}
+
+// Summary says where n's positive and negative dypes came from, e.g.
+// "inferred int from literal at 3:5, but required string by declaration at 1:9"
+func (p *Provenance) Summary(n Nod) string {
+	mypes := p.mypes[n]
+	parts := []string{}
+	if c := p.rootCause(mypes[0]); c != nil {
+		parts = append(parts, fmt.Sprintf("inferred %s from %s", DypeString(mypes[0].Data.(Nod)), c.describeNode()))
+	}
+	if c := p.rootCause(mypes[1]); c != nil {
+		parts = append(parts, fmt.Sprintf("required %s by %s", DypeString(mypes[1].Data.(Nod)), c.describeNode()))
+	}
+	return strings.Join(parts, ", but ")
+}
+
+// Explain lists the changes that led to n's positive and negative dypes
+func (p *Provenance) Explain(n Nod) string {
+	mypes, ok := p.mypes[n]
+	if !ok {
+		return describeNodeAt(n) + " wasn't given a type\n"
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s: %s\n", describeNodeAt(n), p.Summary(n))
+	for ndx, heading := range []string{"positive", "negative"} {
+		if mypes[ndx] == nil {
+			continue
+		}
+		fmt.Fprintf(b, "%s, %s:\n", heading, DypeString(mypes[ndx].Data.(Nod)))
+		changes := p.derivation(mypes[ndx])
+		if len(changes) == 0 {
+			b.WriteString("    never changed\n")
+		}
+		for _, c := range changes {
+			fmt.Fprintf(b, "    round %d, %s: %s -> %s, %s\n",
+				c.Round, c.Rule, DypeString(c.Before), DypeString(c.After), c.describeNode())
+		}
+	}
+	return b.String()
+}
+
+func (c *TypeChange) describeNode() string {
+	if c.decl {
+		return "declaration at " + locString(c.Node)
+	}
+	return describeNodeAt(c.Node)
+}
+
+func describeNodeAt(n Nod) string {
+	if n == nil {
+		return "unknown"
+	}
+	return describeNodeType(n.NodeType) + " at " + locString(n)
+}
+
+func describeNodeType(nt int) string {
+	switch {
+	case isLiteralNodeType(nt):
+		return "literal"
+	case isBinaryOpType(nt) || isUnaryOpType(nt):
+		return "operator"
+	}
+	switch nt {
+	case NT_VARASSIGN:
+		return "assignment"
+	case NT_VARDEF, NT_VAR_GETTER, NT_IDENTIFIER:
+		return "variable"
+	case NT_PARAMETER:
+		return "parameter"
+	case NT_RECEIVERCALL, NT_RECEIVERCALL_CMD, NT_RECEIVERCALL_METHOD, NT_OBJINIT:
+		return "call"
+	case NT_RETURN:
+		return "return"
+	case NT_FUNCDEF, NT_FUNCDEF_RV_PLACEHOLDER:
+		return "function"
+	case NT_CLASSDEF:
+		return "class"
+	}
+	return strings.ToLower(strings.TrimPrefix(NodeTypeName(nt), "NT_"))
+}
+
+// where n or, failing that, the nearest node under it was parsed, counting
+// from 1. Nodes are located just past their first token, which makes the
+// column the token's last character, e.g. the 1 or the x of x : 1.
+func locString(n Nod) string {
+	if loc := nearestLoc(n, map[Nod]bool{}); loc != nil {
+		return fmt.Sprintf("%d:%d", loc.Line+1, loc.Column)
+	}
+	return "?"
+}
+
+func nearestLoc(n Nod, seen map[Nod]bool) *types.SourceLocation {
+	if n.Loc != nil || seen[n] {
+		return n.Loc
+	}
+	seen[n] = true
+	for _, et := range sortedEdgeTypes(n) {
+		if loc := nearestLoc(n.Out[et].Out, seen); loc != nil {
+			return loc
+		}
+	}
+	return nil
+}
+
+func sortedEdgeTypes(n Nod) []int {
+	rv := []int{}
+	for et := range n.Out {
+		rv = append(rv, et)
+	}
+	sort.Ints(rv)
+	return rv
+}
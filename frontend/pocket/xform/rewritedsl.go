@@ -0,0 +1,532 @@
+package xform
+
+import (
+	"fmt"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	. "pocket-lang/xform"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A small language for rewrite rules, so that a rule reads as the shape it
+// matches and the shape it leaves, e.g.
+//
+//	# x +: 2 -> x : x + 2
+//	rule arithAssign
+//	    VARASSIGN_ARITH(VAR_NAME: lv, VARASSIGN_VALUE: rv, VARASSIGN_ARITHOP: op)
+//	    > VARASSIGN(VAR_NAME: lv, VARASSIGN_VALUE: op(BINOP_LEFT: VAR_GETTER(VAR_NAME: copy lv), BINOP_RIGHT: rv))
+//
+// A rule is a pattern, optional guards after when, then > and a template.
+// Capitalized words are node types in patterns and templates (NT_ is
+// implied), and edge labels before a colon (NTR_ is implied), or [n] for the
+// nth element of a list.
+//
+// Patterns:
+//
+//	TYPE | TYPE       a node of either type, * for any type
+//	TYPE = 'len'      ...whose data is 'len', or an int, or true/false
+//	TYPE(L: p, !M)    ...with an L child matching p and no M child
+//	v@TYPE(...)       ...bound to v
+//	v                 any node, bound to v, the same node if v is repeated
+//
+// Guards are predicates from Go on one bound node, e.g. when isClassName(id),
+// or negated with !.
+//
+// Templates:
+//
+//	v                 the node bound to v
+//	copy v            a deep copy of it
+//	v(L: t)           it, with its L child set to what t builds
+//	v as TYPE(L: t)   it, retyped in place, keeping its other edges and data
+//	TYPE(L: t)        a new node, with = 'lit' or = v for its data
+//	f(v)              what the Go builder f makes from v
+//
+// What the template builds replaces the matched node, unless it's the matched
+// node itself, changed in place.
+
+// the Go side of a set of rewrite rules, each func taking one bound node
+type RewriteFuncs struct {
+	Preds    map[string]func(Nod) bool // for guards
+	Builders map[string]func(Nod) Nod  // for templates
+}
+
+type rwPattern struct {
+	bind    string
+	types   []int // nil for any type
+	data    interface{}
+	hasData bool
+	edges   []*rwEdgePattern
+}
+
+type rwEdgePattern struct {
+	label  int
+	absent bool
+	pat    *rwPattern
+}
+
+type rwGuard struct {
+	pred   func(Nod) bool
+	name   string
+	arg    string
+	negate bool
+}
+
+const (
+	tplVar    = iota // a bound node, maybe with edges set
+	tplCopy          // a deep copy of a bound node
+	tplRetype        // a bound node retyped in place
+	tplNew           // a new node
+	tplCall          // a node from a builder
+)
+
+type rwTemplate struct {
+	kind     int
+	name     string // the bound var it's built from
+	builder  func(Nod) Nod
+	nodeType int
+	data     interface{}
+	dataFrom string // the bound var whose data a new node takes
+	edges    []*rwEdgeTemplate
+}
+
+type rwEdgeTemplate struct {
+	label int
+	tpl   *rwTemplate
+}
+
+type rwRule struct {
+	name   string
+	pat    *rwPattern
+	guards []*rwGuard
+	tpl    *rwTemplate
+}
+
+// compiles the rules in src, in order
+func (x *XformerPocket) compileRewriteRules(src string, funcs RewriteFuncs) (rules []*RewriteRule, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if rerr, ok := r.(*rwSyntaxError); ok {
+				err = rerr
+				return
+			}
+			panic(r)
+		}
+	}()
+	p := &rwParser{toks: rwTokenize(src), funcs: funcs}
+	for p.peek().text != "" {
+		rule := p.parseRule()
+		rules = append(rules, x.newDSLRewriteRule(rule))
+	}
+	return rules, nil
+}
+
+func (x *XformerPocket) mustCompileRewriteRules(src string, funcs RewriteFuncs) []*RewriteRule {
+	rules, err := x.compileRewriteRules(src, funcs)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func (x *XformerPocket) newDSLRewriteRule(rule *rwRule) *RewriteRule {
+	return &RewriteRule{
+		name:      rule.name,
+		nodeTypes: rule.pat.types,
+		condaction: func(n Nod) bool {
+			binds := map[string]Nod{}
+			if !rule.pat.match(n, binds) {
+				return false
+			}
+			for _, guard := range rule.guards {
+				if guard.pred(binds[guard.arg]) == guard.negate {
+					return false
+				}
+			}
+			Tracef(LOGCH_XFORM, "applying rule %s", rule.name)
+			if result := rule.tpl.build(binds); result != n {
+				x.Replace(n, result)
+			}
+			return true
+		},
+	}
+}
+
+// ApplyRewriteRules compiles the rules in src and tries each once on every node
+// under root, e.g. to check what a rule does on its own. Returns how many
+// rewrites were made.
+func ApplyRewriteRules(root Nod, src string, funcs RewriteFuncs) (int, error) {
+	xformer := &XformerPocket{Xformer: &Xformer{}}
+	xformer.Root = root
+	rules, err := xformer.compileRewriteRules(src, funcs)
+	if err != nil {
+		return 0, err
+	}
+	return xformer.applyRewriteRulesOnce(rules), nil
+}
+
+func (p *rwPattern) match(n Nod, binds map[string]Nod) bool {
+	if p.types != nil && !isIntInList(n.NodeType, p.types) {
+		return false
+	}
+	if p.hasData && n.Data != p.data {
+		return false
+	}
+	for _, edge := range p.edges {
+		child := NodGetChildOrNil(n, edge.label)
+		if edge.absent || child == nil {
+			if edge.absent == (child == nil) {
+				continue
+			}
+			return false
+		}
+		if !edge.pat.match(child, binds) {
+			return false
+		}
+	}
+	if p.bind != "" {
+		if bound, ok := binds[p.bind]; ok && bound != n {
+			return false
+		}
+		binds[p.bind] = n
+	}
+	return true
+}
+
+func (t *rwTemplate) build(binds map[string]Nod) Nod {
+	var n Nod
+	switch t.kind {
+	case tplVar:
+		n = binds[t.name]
+	case tplCopy:
+		return NodDeepCopyDownwards(binds[t.name])
+	case tplRetype:
+		n = binds[t.name]
+		n.NodeType = t.nodeType
+	case tplNew:
+		n = NodNew(t.nodeType)
+		n.Data = t.data
+		if t.dataFrom != "" {
+			n.Data = binds[t.dataFrom].Data
+		}
+	case tplCall:
+		return t.builder(binds[t.name])
+	}
+	// build all the children before attaching any, so a child template can
+	// still see the graph as it was matched
+	children := make([]Nod, len(t.edges))
+	for ndx, edge := range t.edges {
+		children[ndx] = edge.tpl.build(binds)
+	}
+	for ndx, edge := range t.edges {
+		if old := NodGetChildOrNil(n, edge.label); old == children[ndx] {
+			continue
+		} else if old != nil {
+			NodRemoveChild(n, edge.label)
+		}
+		NodSetChild(n, edge.label, children[ndx])
+	}
+	return n
+}
+
+type rwSyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *rwSyntaxError) Error() string {
+	return "rewrite rules, line " + strconv.Itoa(e.Line) + ": " + e.Msg
+}
+
+type rwToken struct {
+	text string // "" at the end
+	line int
+	str  bool // a quoted string, with the quotes taken off
+}
+
+func rwTokenize(src string) []rwToken {
+	toks := []rwToken{}
+	line := 1
+	for pos := 0; pos < len(src); {
+		ch := rune(src[pos])
+		switch {
+		case ch == '\n':
+			line++
+			pos++
+		case ch == '#':
+			for pos < len(src) && src[pos] != '\n' {
+				pos++
+			}
+		case unicode.IsSpace(ch):
+			pos++
+		case ch == '\'':
+			end := strings.IndexByte(src[pos+1:], '\'')
+			if end < 0 {
+				panic(&rwSyntaxError{line, "unterminated string"})
+			}
+			toks = append(toks, rwToken{src[pos+1 : pos+1+end], line, true})
+			pos += end + 2
+		case ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch):
+			start := pos
+			for pos < len(src) && (src[pos] == '_' || unicode.IsLetter(rune(src[pos])) || unicode.IsDigit(rune(src[pos]))) {
+				pos++
+			}
+			toks = append(toks, rwToken{src[start:pos], line, false})
+		default:
+			toks = append(toks, rwToken{string(ch), line, false})
+			pos++
+		}
+	}
+	return append(toks, rwToken{"", line, false})
+}
+
+type rwParser struct {
+	toks  []rwToken
+	pos   int
+	funcs RewriteFuncs
+	vars  map[string]bool // bound by the pattern of the rule being parsed
+}
+
+func (p *rwParser) peek() rwToken {
+	return p.toks[p.pos]
+}
+
+func (p *rwParser) next() rwToken {
+	tok := p.toks[p.pos]
+	if tok.text != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *rwParser) fail(format string, args ...interface{}) {
+	panic(&rwSyntaxError{p.peek().line, fmt.Sprintf(format, args...)})
+}
+
+func (p *rwParser) found() string {
+	if tok := p.peek(); tok.str {
+		return "'" + tok.text + "'"
+	} else if tok.text == "" {
+		return "the end"
+	}
+	return "'" + p.peek().text + "'"
+}
+
+func (p *rwParser) expect(text string) {
+	if tok := p.peek(); tok.str || tok.text != text {
+		p.fail("expected '%s', found %s", text, p.found())
+	}
+	p.next()
+}
+
+// whether the next token is the punctuation or keyword text, taking it if so
+func (p *rwParser) accept(text string) bool {
+	if tok := p.peek(); !tok.str && tok.text == text {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func isRwName(tok rwToken) bool {
+	return !tok.str && tok.text != "" && (tok.text[0] == '_' || unicode.IsLetter(rune(tok.text[0])))
+}
+
+func isRwUpper(tok rwToken) bool {
+	return isRwName(tok) && unicode.IsUpper(rune(tok.text[0]))
+}
+
+func (p *rwParser) parseRule() *rwRule {
+	p.expect("rule")
+	if !isRwName(p.peek()) {
+		p.fail("expected the rule's name, found %s", p.found())
+	}
+	rule := &rwRule{name: p.next().text}
+	p.vars = map[string]bool{}
+	rule.pat = p.parsePattern()
+	if rule.pat.types == nil {
+		p.fail("rule %s has to match a node type at the top", rule.name)
+	}
+	if p.accept("when") {
+		rule.guards = append(rule.guards, p.parseGuard())
+		for p.accept(",") {
+			rule.guards = append(rule.guards, p.parseGuard())
+		}
+	}
+	p.expect(">")
+	rule.tpl = p.parseTemplate()
+	return rule
+}
+
+func (p *rwParser) parsePattern() *rwPattern {
+	pat := &rwPattern{}
+	if isRwName(p.peek()) && !isRwUpper(p.peek()) {
+		pat.bind = p.next().text
+		p.vars[pat.bind] = true
+		if !p.accept("@") {
+			return pat // a bare var matches anything
+		}
+	}
+	if !p.accept("*") {
+		pat.types = []int{p.parseNodeType()}
+		for p.accept("|") {
+			pat.types = append(pat.types, p.parseNodeType())
+		}
+	}
+	if p.accept("=") {
+		pat.data, pat.hasData = p.parseLiteral(), true
+	}
+	if p.accept("(") {
+		for {
+			edge := &rwEdgePattern{}
+			if p.accept("!") {
+				edge.absent = true
+				edge.label = p.parseLabel()
+			} else {
+				edge.label = p.parseLabel()
+				p.expect(":")
+				edge.pat = p.parsePattern()
+			}
+			pat.edges = append(pat.edges, edge)
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect(")")
+	}
+	return pat
+}
+
+func (p *rwParser) parseGuard() *rwGuard {
+	guard := &rwGuard{negate: p.accept("!")}
+	guard.name = p.next().text
+	pred, ok := p.funcs.Preds[guard.name]
+	if !ok {
+		p.fail("unknown predicate %s", guard.name)
+	}
+	guard.pred = pred
+	p.expect("(")
+	guard.arg = p.parseBoundVar()
+	p.expect(")")
+	return guard
+}
+
+func (p *rwParser) parseTemplate() *rwTemplate {
+	tpl := &rwTemplate{}
+	if p.accept("copy") {
+		tpl.kind = tplCopy
+		tpl.name = p.parseBoundVar()
+		return tpl
+	}
+	if isRwUpper(p.peek()) {
+		tpl.kind = tplNew
+		tpl.nodeType = p.parseNodeType()
+		if p.accept("=") {
+			if tok := p.peek(); isRwName(tok) && !isRwUpper(tok) && tok.text != "true" && tok.text != "false" {
+				tpl.dataFrom = p.parseBoundVar()
+			} else {
+				tpl.data = p.parseLiteral()
+			}
+		}
+	} else if !isRwName(p.peek()) {
+		p.fail("expected a template, found %s", p.found())
+	} else if builder, ok := p.funcs.Builders[p.peek().text]; ok && !p.vars[p.peek().text] {
+		tpl.kind = tplCall
+		tpl.builder = builder
+		p.next()
+		p.expect("(")
+		tpl.name = p.parseBoundVar()
+		p.expect(")")
+		return tpl
+	} else {
+		tpl.kind = tplVar
+		tpl.name = p.parseBoundVar()
+		if p.accept("as") {
+			tpl.kind = tplRetype
+			tpl.nodeType = p.parseNodeType()
+		}
+	}
+	if p.accept("(") {
+		for {
+			edge := &rwEdgeTemplate{label: p.parseLabel()}
+			p.expect(":")
+			edge.tpl = p.parseTemplate()
+			tpl.edges = append(tpl.edges, edge)
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect(")")
+	}
+	return tpl
+}
+
+func (p *rwParser) parseBoundVar() string {
+	tok := p.peek()
+	if !isRwName(tok) || isRwUpper(tok) {
+		p.fail("expected a variable, found %s", p.found())
+	}
+	if !p.vars[tok.text] {
+		p.fail("%s isn't bound by the pattern", tok.text)
+	}
+	return p.next().text
+}
+
+func (p *rwParser) parseLiteral() interface{} {
+	tok := p.peek()
+	if tok.str {
+		p.next()
+		return tok.text
+	} else if tok.text == "true" || tok.text == "false" {
+		p.next()
+		return tok.text == "true"
+	} else if n, err := strconv.Atoi(tok.text); err == nil {
+		p.next()
+		return n
+	}
+	p.fail("expected a string, int or bool, found %s", p.found())
+	return nil
+}
+
+func (p *rwParser) parseNodeType() int {
+	return p.parseConstName("NT_", "node type")
+}
+
+func (p *rwParser) parseLabel() int {
+	if p.accept("[") {
+		ndx, err := strconv.Atoi(p.peek().text)
+		if err != nil || ndx < 0 || NTR_LIST_0+ndx >= NTR_LIST_MAX {
+			p.fail("expected a list index, found %s", p.found())
+		}
+		p.next()
+		p.expect("]")
+		return NTR_LIST_0 + ndx
+	}
+	return p.parseConstName("NTR_", "edge label")
+}
+
+func (p *rwParser) parseConstName(prefix string, what string) int {
+	tok := p.peek()
+	if !isRwUpper(tok) {
+		p.fail("expected a %s, found %s", what, p.found())
+	}
+	name := tok.text
+	if !strings.HasPrefix(name, prefix) {
+		name = prefix + name
+	}
+	nt, ok := rwNodeTypes[name]
+	if !ok {
+		p.fail("unknown %s %s", what, tok.text)
+	}
+	p.next()
+	return nt
+}
+
+var rwNodeTypes = func() map[string]int {
+	byName := map[string]int{}
+	for nt, name := range NodeTypeNames {
+		byName[name] = nt
+	}
+	return byName
+}()
@@ -0,0 +1,64 @@
+package xform
+
+import (
+	"fmt"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	. "pocket-lang/xform"
+)
+
+// XformTests transforms root for running its tests rather than its main:
+// each test block becomes a func, named by the returned TestDefs, and main
+// is left out so that modules without one can be tested too.
+func XformTests(root Nod) (Nod, []TestDef) {
+	Infof(LOGCH_XFORM, "starting XformTests()")
+
+	xformer := &XformerPocket{Xformer: &Xformer{}}
+	xformer.Root = root
+	tests := xformer.rewriteTestDefsAsFuncs()
+	xformer.Xform()
+	return root, tests
+}
+
+// normal builds leave the test blocks out
+func (x *XformerPocket) removeTestDefs() {
+	x.rewriteUnits(func(unit Nod) Nod {
+		if unit.NodeType == NT_TESTDEF {
+			return nil
+		}
+		return unit
+	})
+}
+
+func (x *XformerPocket) rewriteTestDefsAsFuncs() []TestDef {
+	tests := []TestDef{}
+	x.rewriteUnits(func(unit Nod) Nod {
+		if unit.NodeType == NT_FUNCDEF && NodGetChild(unit, NTR_FUNCDEF_NAME).Data == "main" {
+			return nil
+		} else if unit.NodeType != NT_TESTDEF {
+			return unit
+		}
+		test := TestDef{Name: unit.Data.(string), FuncName: fmt.Sprint("_pk_test_", len(tests))}
+		tests = append(tests, test)
+
+		code := NodGetChild(unit, NTR_TESTDEF_CODE)
+		NodRemoveChild(unit, NTR_TESTDEF_CODE)
+		fDef := NodNew(NT_FUNCDEF)
+		fDef.Loc = unit.Loc
+		NodSetChild(fDef, NTR_FUNCDEF_NAME, NodNewData(NT_IDENTIFIER_RESOLVED, test.FuncName))
+		NodSetChild(fDef, NTR_FUNCDEF_CODE, code)
+		return fDef
+	})
+	return tests
+}
+
+// replaces each top level unit with what f returns for it, dropping it for nil
+func (x *XformerPocket) rewriteUnits(f func(unit Nod) Nod) {
+	units := []Nod{}
+	for _, unit := range NodGetChildList(x.Root) {
+		if kept := f(unit); kept != nil {
+			units = append(units, kept)
+		}
+	}
+	NodReplaceOutList(x.Root, units)
+}
@@ -1,9 +1,9 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
+	"strconv"
 )
 
 func (x *XformerPocket) getAllSolveTypeRules() []*RewriteRule {
@@ -42,6 +42,7 @@ func (x *XformerPocket) getAllPositiveMARRules() []*RewriteRule {
 		x.marPosFunctionDefs(),
 		x.marPosClassDefs(),
 		x.marPosRefOp(),
+		x.marPosUnaryOps(),
 		x.marPosVarAssign(),
 		x.marPosPublicParameter(),
 		x.marPosPublicClassField(),
@@ -52,8 +53,12 @@ func (x *XformerPocket) getAllPositiveMARRules() []*RewriteRule {
 		x.marPosObjInitUser(),
 		x.marPosFuncCallUser(),
 		x.marPosReturnValue(),
+		x.marPosRangeOp(),
+		x.marPosInterpolatedStrings(),
+		x.marPosConversionCall(),
 	}
 	rv = append(rv, x.marPosOpEvaluateRules()...)
+	rv = append(rv, x.marPosOperatorMethods()...)
 	return rv
 }
 
@@ -92,6 +97,7 @@ func (x *XformerPocket) marRemoveMypesFromDotopQualifiers() *RewriteRule {
 	// there is no inherent type of the right side of a dot expression
 	// e.g.  in obj.x, the ".x" should be typeless
 	return &RewriteRule{
+		nodeTypes: []int{NT_DOTOP_QUALIFIER},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_DOTOP_QUALIFIER {
 				if NodHasChild(n, NTR_MYPE_POS) {
@@ -108,6 +114,7 @@ func (x *XformerPocket) marRemoveMypesFromDotopQualifiers() *RewriteRule {
 func (x *XformerPocket) marPosRefOp() *RewriteRule {
 	// ref ops inherit the type of their arg
 	return &RewriteRule{
+		nodeTypes: []int{NT_REFERENCEOP},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_REFERENCEOP {
 				arg := NodGetChild(n, NTR_RECEIVERCALL_ARG)
@@ -120,9 +127,34 @@ func (x *XformerPocket) marPosRefOp() *RewriteRule {
 	}
 }
 
+func (x *XformerPocket) marPosUnaryOps() *RewriteRule {
+	// -x and +x keep the numeric type of x, not x is a bool
+	return &RewriteRule{
+		nodeTypes: []int{NT_NEGOP, NT_POSOP, NT_NOTOP},
+		condaction: func(n Nod) bool {
+			if n.NodeType == NT_NEGOP || n.NodeType == NT_POSOP || n.NodeType == NT_NOTOP {
+				argMype := NodGetChild(NodGetChild(n, NTR_RECEIVERCALL_ARG), NTR_MYPE_POS).Data.(Nod)
+				candTypes := NumericTypes()
+				if n.NodeType == NT_NOTOP {
+					candTypes = []int{TY_BOOL}
+				}
+				changed := false
+				for _, ty := range candTypes {
+					if DypeIsSubset(argMype, NodNewData(NT_TYPEBASE, ty)) {
+						changed = x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), NodNewData(NT_TYPEBASE, ty)) || changed
+					}
+				}
+				return changed
+			}
+			return false
+		},
+	}
+}
+
 func (x *XformerPocket) marPosFunctionRefs() *RewriteRule {
 	// type of a function ref is a func
 	return &RewriteRule{
+		nodeTypes: []int{NT_IDENTIFIER_RESOLVED},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_IDENTIFIER_RESOLVED {
 				if NodHasChild(n, NTR_MYPE_POS) {
@@ -142,6 +174,7 @@ func (x *XformerPocket) marPosFunctionRefs() *RewriteRule {
 func (x *XformerPocket) marPosFunctionDefs() *RewriteRule {
 	// type of a function def can be itself
 	return &RewriteRule{
+		nodeTypes: []int{NT_FUNCDEF},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_FUNCDEF {
 				extMype := NodGetChild(n, NTR_MYPE_POS)
@@ -156,6 +189,7 @@ func (x *XformerPocket) marPosFunctionDefs() *RewriteRule {
 func (x *XformerPocket) marPosClassDefs() *RewriteRule {
 	// type of a classdef is a reflection to itself
 	return &RewriteRule{
+		nodeTypes: []int{NT_CLASSDEF},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_CLASSDEF {
 				extMype := NodGetChild(n, NTR_MYPE_POS)
@@ -185,6 +219,7 @@ func (x *XformerPocket) marGenLinkVarRefsToVarDef() *RewriteRule {
 	// link up mypes of variable references to point to the same
 	// mypes as their definitions
 	return &RewriteRule{
+		nodeTypes: []int{NT_VAR_GETTER, NT_VARASSIGN, NT_PARAMETER},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_VAR_GETTER || n.NodeType == NT_VARASSIGN ||
 				n.NodeType == NT_PARAMETER {
@@ -224,7 +259,7 @@ func (x *XformerPocket) NodComputeParentChildIntegrityOn(n Nod) bool {
 		child := edge.Out
 		cInIndex := NodGetInEdgeNdx(child, edge)
 		if cInIndex == -1 {
-			fmt.Println("missing", PrettyPrint(n), "for child", PrettyPrint(child))
+			Debugf(LOGCH_SOLVE, "missing %s for child %s", LazyTree(n), LazyTree(child))
 			return false
 		}
 	}
@@ -241,6 +276,7 @@ func (x *XformerPocket) NodCheckParentChildIntegrity() {
 func (x *XformerPocket) generateValidMypes(nodes []Nod) {
 
 	x.NodCheckParentChildIntegrity()
+	x.recordMypes(nodes)
 
 	for _, node := range nodes {
 		posMype := NodGetChild(node, NTR_MYPE_POS).Data.(Nod)
@@ -253,7 +289,7 @@ func (x *XformerPocket) generateValidMypes(nodes []Nod) {
 				node.NodeType == NT_RECEIVERCALL_METHOD {
 				// this is acceptable for these node types (can safely ignore)
 			} else {
-				panic("couldn't find a valid type for node: " + PrettyPrint(node))
+				x.panicNoValidType(node)
 			}
 		}
 		NodSetChild(node, NTR_TYPE, validMype)
@@ -262,6 +298,14 @@ func (x *XformerPocket) generateValidMypes(nodes []Nod) {
 	}
 }
 
+func (x *XformerPocket) panicNoValidType(node Nod) {
+	msg := "couldn't find a valid type for " + describeNodeAt(node)
+	if summary := x.prov.Summary(node); summary != "" {
+		msg += ": " + summary
+	}
+	panic(msg)
+}
+
 func (x *XformerPocket) postProcessDype(dype Nod) Nod {
 	// returns a postprocessed dype for the final type coloring
 	// allows for a layer of postprocessing after the solver has concluded
@@ -298,6 +342,7 @@ func (x *XformerPocket) marPosFuncCallUser() *RewriteRule {
 	// TODO: this logic isn't the best; we don't want the use of a function to affect
 	// it's potential return type
 	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL, NT_RECEIVERCALL_CMD, NT_RECEIVERCALL_METHOD},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_RECEIVERCALL || n.NodeType == NT_RECEIVERCALL_CMD ||
 				n.NodeType == NT_RECEIVERCALL_METHOD {
@@ -321,6 +366,7 @@ func (x *XformerPocket) marPosFuncCallUser() *RewriteRule {
 func (x *XformerPocket) marPosObjInitUser() *RewriteRule {
 	// Type.new(x) or Type(x) returns type Type for user-defined classes
 	return &RewriteRule{
+		nodeTypes: []int{NT_OBJINIT},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_OBJINIT {
 				base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
@@ -364,10 +410,10 @@ func (x *XformerPocket) RICUnionOld(old Nod, new Nod) bool {
 		unioned := DypeSimplifyShallowComplex(DypeUnion(old, new))
 		// fmt.Println("ricunion 1, before replace: old:", PrettyPrint(old),
 		// 	"\nnew:", PrettyPrint(unioned))
-		fmt.Println("ric, unioned:", PrettyPrint(unioned))
+		Tracef(LOGCH_SOLVE, "ric, unioned: %s", LazyTree(unioned))
 		x.Replace(old, unioned)
-		fmt.Println("ricunion 2, after replace: old:", PrettyPrint(old),
-			"\nnew:", PrettyPrint(unioned))
+		Tracef(LOGCH_SOLVE, "ricunion 2, after replace: old: %s\nnew: %s",
+			LazyTree(old), LazyTree(unioned))
 		return true
 	}
 	return false
@@ -379,6 +425,7 @@ func (x *XformerPocket) RICUnion2(oldContainer Nod, newDype Nod) bool {
 	if DypeWouldChangeUnion(oldDype, newDype) {
 		unioned := DypeSimplifyShallowComplex(DypeUnion(oldDype, newDype))
 		oldContainer.Data = unioned
+		x.recordTypeChange(oldContainer, true, oldDype, newDype)
 		return true
 	}
 	return false
@@ -388,6 +435,7 @@ func (x *XformerPocket) RICXSect2(oldContainer Nod, newDype Nod) bool {
 	oldDype := oldContainer.Data.(Nod)
 	if DypeWouldChangeXSect(oldDype, newDype) {
 		oldContainer.Data = DypeSimplifyShallowComplex(DypeXSect(oldDype, newDype))
+		x.recordTypeChange(oldContainer, false, oldDype, newDype)
 		return true
 	}
 	return false
@@ -404,6 +452,7 @@ func (x *XformerPocket) RICXSectOld(old Nod, new Nod) bool {
 func (x *XformerPocket) marPosCollectionLiterals() *RewriteRule {
 	// [3, 4, 5] -+> {list, list<int>}
 	return &RewriteRule{
+		nodeTypes: []int{NT_LIT_LIST},
 		condaction: func(n Nod) bool {
 			// TODO: support maps and sets
 			if n.NodeType == NT_LIT_LIST {
@@ -419,12 +468,13 @@ func (x *XformerPocket) marPosCollectionLiterals() *RewriteRule {
 func (x *XformerPocket) marPosSysFunc() *RewriteRule {
 	// all sys funcs can return anything
 	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL, NT_RECEIVERCALL_CMD},
 		condaction: func(n Nod) bool {
 			if isReceiverCallType(n.NodeType) {
 				baseNod := NodGetChild(n, NTR_RECEIVERCALL_BASE)
 				if baseNod.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
 					rcName := baseNod.Data.(string)
-					if isSystemFuncName(rcName) {
+					if isSystemFuncName(rcName) && !isConversionFuncName(rcName) {
 						return x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), NodNew(DYPE_ALL))
 					}
 				}
@@ -434,10 +484,44 @@ func (x *XformerPocket) marPosSysFunc() *RewriteRule {
 	}
 }
 
+func getNumericDype() Nod {
+	ncs := []Nod{}
+	for _, ty := range NumericTypes() {
+		ncs = append(ncs, NodNewData(NT_TYPEBASE, ty))
+	}
+	return NodNewChildList(DYPE_UNION, ncs)
+}
+
+func (x *XformerPocket) marPosConversionCall() *RewriteRule {
+	// u8(<number>) -> u8, and bigint(<string>) -> bigint for literals too big for int
+	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL, NT_RECEIVERCALL_CMD},
+		condaction: func(n Nod) bool {
+			if isReceiverCallType(n.NodeType) {
+				baseNod := NodGetChild(n, NTR_RECEIVERCALL_BASE)
+				if baseNod.NodeType == NT_IDENTIFIER_FUNC_NOSCOPE {
+					if ty, ok := ConversionFuncType(baseNod.Data.(string)); ok {
+						argMype := NodGetChild(NodGetChild(n, NTR_RECEIVERCALL_ARG), NTR_MYPE_POS).Data.(Nod)
+						convertible := getNumericDype()
+						if ty == TY_BIGINT {
+							convertible = DypeUnion(convertible, NodNewData(NT_TYPEBASE, TY_STRING))
+						}
+						if argMype.NodeType != DYPE_EMPTY && DypeIsSubset(convertible, argMype) {
+							return x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), NodNewData(NT_TYPEBASE, ty))
+						}
+					}
+				}
+			}
+			return false
+		},
+	}
+}
+
 func (x *XformerPocket) marPosPublicParameter() *RewriteRule {
 	// assume that assignments to this parameter are in fact called
 	// with every allowable type
 	return &RewriteRule{
+		nodeTypes: []int{NT_PARAMETER},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_PARAMETER {
 				// return x.RICUnion(NodGetChild(n, NTR_MYPE_POS), NodNew(DYPE_ALL))
@@ -445,6 +529,8 @@ func (x *XformerPocket) marPosPublicParameter() *RewriteRule {
 				var allowedMype Nod
 				if typeDecl == nil {
 					allowedMype = NodNew(DYPE_ALL)
+				} else if isUnresolvedTypeDecl(typeDecl) {
+					return false
 				} else {
 					allowedMype = typeDecl
 				}
@@ -457,6 +543,11 @@ func (x *XformerPocket) marPosPublicParameter() *RewriteRule {
 	}
 }
 
+// a class named in a type declaration until it's looked up
+func isUnresolvedTypeDecl(n Nod) bool {
+	return n.NodeType == NT_IDENTIFIER || n.NodeType == NT_IDENTIFIER_TYPE_NOSCOPE
+}
+
 func marPosPublicClassFieldGetCandMype(classField Nod) Nod {
 	// get the assumed maximal type from a class field using its type decl
 	typeDecl := NodGetChildOrNil(classField, NTR_TYPE_DECL)
@@ -472,6 +563,7 @@ func marPosPublicClassFieldGetCandMype(classField Nod) Nod {
 func (x *XformerPocket) marPosPublicClassField() *RewriteRule {
 	// assume that assignments to this field are maximal
 	return &RewriteRule{
+		nodeTypes: []int{NT_CLASSFIELD},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_CLASSFIELD {
 				varDef := NodGetChild(n, NTR_VARDEF)
@@ -491,6 +583,7 @@ func (x *XformerPocket) marPosObjFieldAccessor() *RewriteRule {
 
 	// TODO: somehow merge with the above marPosPublicClassField(), they seem to be doing similar things
 	return &RewriteRule{
+		nodeTypes: []int{NT_OBJFIELD_ACCESSOR},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_OBJFIELD_ACCESSOR {
 				posDype := NodGetChild(n, NTR_MYPE_POS).Data.(Nod)
@@ -533,6 +626,7 @@ func (x *XformerPocket) marPosObjFieldAccessorDuckProp() *RewriteRule {
 	// if the type of base is ALL, destructively union upwards
 
 	return &RewriteRule{
+		nodeTypes: []int{NT_OBJFIELD_ACCESSOR},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_OBJFIELD_ACCESSOR {
 				dype := NodGetChild(n, NTR_MYPE_POS)
@@ -550,6 +644,7 @@ func (x *XformerPocket) marPosObjFieldAccessorDuckProp() *RewriteRule {
 func (x *XformerPocket) marPosSelf() *RewriteRule {
 	// type of 'self' is the current class
 	return &RewriteRule{
+		nodeTypes: []int{NT_VARDEF},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_VARDEF {
 				varName := NodGetChild(n, NTR_VARDEF_NAME).Data.(string)
@@ -568,6 +663,7 @@ func (x *XformerPocket) marPosSelf() *RewriteRule {
 func (x *XformerPocket) marPosVarAssign() *RewriteRule {
 	// propagate var assign values from rhs -> lhs
 	return &RewriteRule{
+		nodeTypes: []int{NT_VARASSIGN},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_VARASSIGN {
 				dypeLHS := NodGetChild(n, NTR_MYPE_POS)
@@ -582,6 +678,7 @@ func (x *XformerPocket) marPosVarAssign() *RewriteRule {
 func (x *XformerPocket) marPosReturnValue() *RewriteRule {
 	// propagate return values into the placeholder
 	return &RewriteRule{
+		nodeTypes: []int{NT_RETURN},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_RETURN {
 				if lhs := NodGetChildOrNil(n, NTR_RETURNVAL_PLACEHOLDER); lhs != nil {
@@ -598,6 +695,7 @@ func (x *XformerPocket) marPosReturnValue() *RewriteRule {
 func (x *XformerPocket) marNegVarAssign() *RewriteRule {
 	// propagate var type restrictions from lhs -> rhs
 	return &RewriteRule{
+		nodeTypes: []int{NT_VARASSIGN},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_VARASSIGN {
 				mypeLHSData := NodGetChild(n, NTR_MYPE_NEG).Data.(Nod)
@@ -612,10 +710,12 @@ func (x *XformerPocket) marNegVarAssign() *RewriteRule {
 func (x *XformerPocket) marNegDeclaredType() *RewriteRule {
 	// propagate type declarations to the base dypes of parameters, var assignment, and var defs
 	return &RewriteRule{
+		nodeTypes: []int{NT_PARAMETER, NT_VARASSIGN, NT_VARDEF},
 		condaction: func(n Nod) bool {
 			if n.NodeType == NT_PARAMETER || n.NodeType == NT_VARASSIGN ||
 				n.NodeType == NT_VARDEF {
-				if typeDeclNod := NodGetChildOrNil(n, NTR_TYPE_DECL); typeDeclNod != nil {
+				if typeDeclNod := NodGetChildOrNil(n, NTR_TYPE_DECL); typeDeclNod != nil &&
+					!isUnresolvedTypeDecl(typeDeclNod) {
 					negMype := NodGetChild(n, NTR_MYPE_NEG)
 					declMype := typeDeclNod
 					return x.RICXSect2(negMype, declMype)
@@ -636,44 +736,10 @@ type MypeOpEvaluateRule struct {
 
 func marGetCompactOpEvaluateRules() []*MypeOpEvaluateRule {
 	// define type propagation rules of the form (int + int) -> int
-	return []*MypeOpEvaluateRule{
-		// TODO: potentially compress this table
-		&MypeOpEvaluateRule{NT_ADDOP, TY_INT, TY_INT, TY_INT},
-		&MypeOpEvaluateRule{NT_ADDOP, TY_INT, TY_FLOAT, TY_FLOAT},
-		&MypeOpEvaluateRule{NT_ADDOP, TY_FLOAT, TY_FLOAT, TY_FLOAT},
+	rv := []*MypeOpEvaluateRule{
 		&MypeOpEvaluateRule{NT_ADDOP, TY_STRING, TY_STRING, TY_STRING},
 		&MypeOpEvaluateRule{NT_ADDOP, TY_LIST, TY_LIST, TY_LIST},
 
-		&MypeOpEvaluateRule{NT_SUBOP, TY_INT, TY_INT, TY_INT},
-		&MypeOpEvaluateRule{NT_SUBOP, TY_FLOAT, TY_FLOAT, TY_FLOAT},
-		&MypeOpEvaluateRule{NT_SUBOP, TY_INT, TY_FLOAT, TY_FLOAT},
-
-		&MypeOpEvaluateRule{NT_MULOP, TY_INT, TY_INT, TY_INT},
-		&MypeOpEvaluateRule{NT_MULOP, TY_FLOAT, TY_FLOAT, TY_FLOAT},
-		&MypeOpEvaluateRule{NT_MULOP, TY_INT, TY_FLOAT, TY_FLOAT},
-
-		&MypeOpEvaluateRule{NT_DIVOP, TY_INT, TY_INT, TY_INT},
-		&MypeOpEvaluateRule{NT_DIVOP, TY_FLOAT, TY_FLOAT, TY_FLOAT},
-		&MypeOpEvaluateRule{NT_DIVOP, TY_INT, TY_FLOAT, TY_FLOAT},
-
-		&MypeOpEvaluateRule{NT_MODOP, TY_INT, TY_INT, TY_INT},
-		&MypeOpEvaluateRule{NT_MODOP, TY_FLOAT, TY_FLOAT, TY_FLOAT},
-		&MypeOpEvaluateRule{NT_MODOP, TY_INT, TY_FLOAT, TY_FLOAT},
-
-		&MypeOpEvaluateRule{NT_GTOP, TY_INT, TY_INT, TY_BOOL},
-		&MypeOpEvaluateRule{NT_GTOP, TY_FLOAT, TY_FLOAT, TY_BOOL},
-
-		&MypeOpEvaluateRule{NT_LTOP, TY_INT, TY_INT, TY_BOOL},
-		&MypeOpEvaluateRule{NT_LTOP, TY_FLOAT, TY_FLOAT, TY_BOOL},
-
-		&MypeOpEvaluateRule{NT_GTEQOP, TY_INT, TY_INT, TY_BOOL},
-		&MypeOpEvaluateRule{NT_GTEQOP, TY_FLOAT, TY_FLOAT, TY_BOOL},
-
-		&MypeOpEvaluateRule{NT_LTEQOP, TY_INT, TY_INT, TY_BOOL},
-		&MypeOpEvaluateRule{NT_LTEQOP, TY_FLOAT, TY_FLOAT, TY_BOOL},
-
-		&MypeOpEvaluateRule{NT_EQOP, TY_INT, TY_INT, TY_BOOL},
-		&MypeOpEvaluateRule{NT_EQOP, TY_FLOAT, TY_FLOAT, TY_BOOL},
 		&MypeOpEvaluateRule{NT_EQOP, TY_STRING, TY_STRING, TY_BOOL},
 		&MypeOpEvaluateRule{NT_EQOP, TY_BOOL, TY_BOOL, TY_BOOL},
 
@@ -681,6 +747,51 @@ func marGetCompactOpEvaluateRules() []*MypeOpEvaluateRule {
 
 		&MypeOpEvaluateRule{NT_ANDOP, TY_BOOL, TY_BOOL, TY_BOOL},
 	}
+	return append(rv, marGetNumericOpEvaluateRules()...)
+}
+
+func marGetNumericOpEvaluateRules() []*MypeOpEvaluateRule {
+	// stated once for a supertype of the operands, which stands for each pair
+	// of numeric types under it that can be mixed, e.g. (i8 + i32) -> i32. A
+	// supertype result is the type the pair is promoted to.
+	supertypeRules := []*MypeOpEvaluateRule{}
+	for _, op := range []int{NT_ADDOP, NT_SUBOP, NT_MULOP, NT_DIVOP, NT_MODOP} {
+		supertypeRules = append(supertypeRules, &MypeOpEvaluateRule{op, TY_NUMBER, TY_NUMBER, TY_NUMBER})
+	}
+	for _, op := range []int{NT_GTOP, NT_LTOP, NT_GTEQOP, NT_LTEQOP, NT_EQOP} {
+		supertypeRules = append(supertypeRules, &MypeOpEvaluateRule{op, TY_NUMBER, TY_NUMBER, TY_BOOL})
+	}
+	// & and | are bitwise for ints, logical for bools
+	for _, op := range []int{NT_POWOP, NT_XOROP, NT_ANDOP, NT_OROP} {
+		supertypeRules = append(supertypeRules, &MypeOpEvaluateRule{op, TY_INTEGER, TY_INTEGER, TY_INTEGER})
+	}
+	return expandOpEvaluateRules(supertypeRules)
+}
+
+func expandOpEvaluateRules(supertypeRules []*MypeOpEvaluateRule) []*MypeOpEvaluateRule {
+	rv := []*MypeOpEvaluateRule{}
+	ntys := BaseTypeLeaves(TY_NUMBER)
+	for i, low := range ntys {
+		for _, high := range ntys[i:] {
+			promoted, ok := NumericPromote(low, high)
+			if !ok {
+				continue
+			}
+			for _, oer := range supertypeRules {
+				if !BaseTypeIsSubtype(low, oer.operandLow) || !BaseTypeIsSubtype(high, oer.operandHigh) {
+					continue
+				}
+				result := oer.result
+				if BaseTypeIsSubtype(promoted, result) {
+					result = promoted
+				} else if len(BaseTypeLeaves(result)) > 1 {
+					continue
+				}
+				rv = append(rv, &MypeOpEvaluateRule{oer.operator, low, high, result})
+			}
+		}
+	}
+	return rv
 }
 
 func (x *XformerPocket) marPosOpEvaluateRules() []*RewriteRule {
@@ -691,12 +802,76 @@ func (x *XformerPocket) marPosOpEvaluateRules() []*RewriteRule {
 	}
 
 	rv = append(rv, x.marPosOpCollectionLenRule())
+	rv = append(rv, x.marPosShiftOps())
 
 	return rv
 }
 
+func (x *XformerPocket) marPosShiftOps() *RewriteRule {
+	// shifts aren't commutative, so they don't fit the op evaluate rules.  the
+	// result has the type of the left operand, the count can be any sized int
+	return &RewriteRule{
+		nodeTypes: []int{NT_SHLOP, NT_SHROP},
+		condaction: func(n Nod) bool {
+			if n.NodeType == NT_SHLOP || n.NodeType == NT_SHROP {
+				leftMype := NodGetChild(NodGetChild(n, NTR_BINOP_LEFT), NTR_MYPE_POS).Data.(Nod)
+				rightMype := NodGetChild(NodGetChild(n, NTR_BINOP_RIGHT), NTR_MYPE_POS).Data.(Nod)
+				rightIsInt := false
+				for _, ty := range NumericTypes() {
+					if IsIntegerType(ty) && ty != TY_BIGINT &&
+						DypeIsSubset(rightMype, NodNewData(NT_TYPEBASE, ty)) {
+						rightIsInt = true
+					}
+				}
+				if !rightIsInt {
+					return false
+				}
+				changed := false
+				for _, ty := range NumericTypes() {
+					if IsIntegerType(ty) && ty != TY_BIGINT &&
+						DypeIsSubset(leftMype, NodNewData(NT_TYPEBASE, ty)) {
+						changed = x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), NodNewData(NT_TYPEBASE, ty)) || changed
+					}
+				}
+				return changed
+			}
+			return false
+		},
+	}
+}
+
 func getLengthableTypes() []int {
-	return []int{TY_LIST, TY_MAP, TY_SET}
+	return []int{TY_LIST, TY_MAP, TY_SET, TY_STRING}
+}
+
+func getIntListDype() Nod {
+	rv := NodNew(NT_TYPECALL)
+	NodSetChild(rv, NTR_RECEIVERCALL_BASE, NodNewData(NT_TYPEBASE, TY_LIST))
+	NodSetChild(rv, NTR_RECEIVERCALL_ARG, NodNewData(NT_TYPEBASE, TY_INT))
+	return rv
+}
+
+func (x *XformerPocket) marPosRangeOp() *RewriteRule {
+	// int..int -> list~int~, same for a..<b and stepped ranges
+	return &RewriteRule{
+		nodeTypes: []int{NT_RANGEOP, NT_RANGEEXCLOP},
+		condaction: func(n Nod) bool {
+			if isRangeOpType(n.NodeType) {
+				intDype := NodNewData(NT_TYPEBASE, TY_INT)
+				bounds := []Nod{NodGetChild(n, NTR_BINOP_LEFT), NodGetChild(n, NTR_BINOP_RIGHT)}
+				if step := NodGetChildOrNil(n, NTR_RANGE_STEP); step != nil {
+					bounds = append(bounds, step)
+				}
+				for _, bound := range bounds {
+					if !DypeIsSubset(NodGetChild(bound, NTR_MYPE_POS).Data.(Nod), intDype) {
+						return false
+					}
+				}
+				return x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), getIntListDype())
+			}
+			return false
+		},
+	}
 }
 
 func getLengthableDype() Nod {
@@ -712,8 +887,9 @@ func (x *XformerPocket) marPosOpCollectionLenRule() *RewriteRule {
 	// <collection>.len -> int
 
 	return &RewriteRule{
+		nodeTypes: []int{NT_OBJFIELD_ACCESSOR},
 		condaction: func(n Nod) bool {
-			if n.NodeType == NT_OBJFIELD_ACCESSOR {
+			if n.NodeType == NT_OBJFIELD_ACCESSOR && !NodHasChild(n, NTR_FUNCDEF) {
 				if qualName, ok := NodGetChild(n, NTR_OBJFIELD_ACCESSOR_NAME).Data.(string); ok {
 					if qualName == "len" {
 						resulPosMype := NodGetChild(n, NTR_MYPE_POS)
@@ -721,7 +897,7 @@ func (x *XformerPocket) marPosOpCollectionLenRule() *RewriteRule {
 						isLengthable := DypeSimplifyShallow(DypeUnion(
 							leftArgPosMype, getLengthableDype())).NodeType != DYPE_EMPTY
 						if isLengthable {
-							fmt.Println("applied collection len rule")
+							Tracef(LOGCH_SOLVE, "applied collection len rule")
 							intMype := NodNewData(NT_TYPEBASE, TY_INT)
 							return x.RICUnion2(resulPosMype, intMype)
 						}
@@ -757,8 +933,10 @@ func (x *XformerPocket) marNegOpRestrictRules() []*RewriteRule {
 
 func (x *XformerPocket) createNegOpResultRestrictRule(operatorType int, allowableResult Nod) *RewriteRule {
 	return &RewriteRule{
+		nodeTypes: []int{operatorType},
 		condaction: func(n Nod) bool {
-			if n.NodeType == operatorType {
+			// an op linked to an operator method returns whatever the method does
+			if n.NodeType == operatorType && !NodHasChild(n, NTR_FUNCDEF) {
 				resultMype := NodGetChild(n, NTR_MYPE_NEG)
 				return x.RICXSect2(resultMype, allowableResult)
 			}
@@ -769,6 +947,7 @@ func (x *XformerPocket) createNegOpResultRestrictRule(operatorType int, allowabl
 
 func (x *XformerPocket) createPosRewriteRuleFromOpEvaluateRule(oer *MypeOpEvaluateRule) *RewriteRule {
 	return &RewriteRule{
+		nodeTypes: []int{oer.operator},
 		condaction: func(n Nod) bool {
 			if n.NodeType == oer.operator {
 				resultMype := NodGetChild(n, NTR_MYPE_POS)
@@ -806,11 +985,15 @@ func writeTypeAndData(dst Nod, src Nod) {
 
 func (x *XformerPocket) marPosPrimitiveLiterals() *RewriteRule {
 	return &RewriteRule{
+		nodeTypes: []int{NT_LIT_INT, NT_LIT_STRING, NT_LIT_BOOL, NT_LIT_FLOAT},
 		condaction: func(n Nod) bool {
 			if isPrimitiveLiteralNodeType(n.NodeType) {
 				extDype := NodGetChild(n, NTR_MYPE_POS)
 				if extDype.Data.(Nod).NodeType == DYPE_EMPTY {
 					ty := getLiteralTypeAnnDataFromNT(n.NodeType)
+					if declTy, ok := getLiteralDeclaredNumericType(n); ok {
+						ty = declTy
+					}
 					return x.RICUnion2(extDype, NodNewData(NT_TYPEBASE, ty))
 				}
 			}
@@ -819,6 +1002,45 @@ func (x *XformerPocket) marPosPrimitiveLiterals() *RewriteRule {
 	}
 }
 
+func (x *XformerPocket) marPosInterpolatedStrings() *RewriteRule {
+	// f'..{x}..' -> string, whatever the types of the interpolated values
+	return &RewriteRule{
+		nodeTypes: []int{NT_LIT_FSTRING},
+		condaction: func(n Nod) bool {
+			if n.NodeType == NT_LIT_FSTRING {
+				return x.RICUnion2(NodGetChild(n, NTR_MYPE_POS), NodNewData(NT_TYPEBASE, TY_STRING))
+			}
+			return false
+		},
+	}
+}
+
+func getLiteralDeclaredNumericType(lit Nod) (int, bool) {
+	// a number literal assigned straight to a declared numeric variable takes on
+	// that type, so x u8 : 200 doesn't need a conversion
+	if lit.NodeType != NT_LIT_INT && lit.NodeType != NT_LIT_FLOAT {
+		return 0, false
+	}
+	assign := NodGetParentOrNil(lit, NTR_VARASSIGN_VALUE)
+	if assign == nil || assign.NodeType != NT_VARASSIGN {
+		return 0, false
+	}
+	decl := NodGetChildOrNil(assign, NTR_TYPE_DECL)
+	if decl == nil || decl.NodeType != NT_TYPEBASE || !IsNumericType(decl.Data.(int)) {
+		return 0, false
+	}
+	declTy := decl.Data.(int)
+	if lit.NodeType == NT_LIT_FLOAT {
+		if !IsFloatType(declTy) {
+			return 0, false
+		}
+	} else if !NumericLiteralFits(declTy, int64(lit.Data.(int))) {
+		panic("literal " + strconv.Itoa(lit.Data.(int)) + " overflows " +
+			NumericTypeName(declTy) + ", at " + lit.Loc.StringDebug())
+	}
+	return declTy, true
+}
+
 // specifies type evaluation rules for expressions such as
 // <list>(<index>) -> <list>.eletype
 
@@ -827,16 +1049,24 @@ func (x *XformerPocket) marGenPCCollectionIndex() *RewriteRule {
 	// syntax of <list>(<index>) at this stage is a receivercall with an
 	// indexable base
 	return &RewriteRule{
+		nodeTypes: []int{NT_RECEIVERCALL},
 		condaction: func(n Nod) bool {
-			if n.NodeType == NT_RECEIVERCALL {
+			if n.NodeType == NT_RECEIVERCALL && !NodHasChild(n, NTR_FUNCDEF) {
 				base := NodGetChild(n, NTR_RECEIVERCALL_BASE)
 				if NodHasChild(base, NTR_MYPE_POS) {
 					basePosDype := NodGetChild(base, NTR_MYPE_POS)
 					baseNegDype := NodGetChild(base, NTR_MYPE_NEG)
 					// heuristic: only apply the indexing rule if something was explicitly
 					// mentioned as indexable
-					posEleDype := x.getIndexableElementDype(basePosDype.Data.(Nod))
-					negEleDype := x.getIndexableElementDype(baseNegDype.Data.(Nod))
+					var posEleDype, negEleDype Nod
+					if isRangeOpType(getIndexArg(NodGetChild(n, NTR_RECEIVERCALL_ARG)).NodeType) {
+						// slicing yields the same kind of collection
+						posEleDype = x.getSliceableDype(basePosDype.Data.(Nod))
+						negEleDype = x.getSliceableDype(baseNegDype.Data.(Nod))
+					} else {
+						posEleDype = x.getIndexableElementDype(basePosDype.Data.(Nod))
+						negEleDype = x.getIndexableElementDype(baseNegDype.Data.(Nod))
+					}
 
 					// fmt.Println("basePosDype", PrettyPrint(basePosDype))
 					// fmt.Println("baseNegDype", PrettyPrint(baseNegDype))
@@ -871,6 +1101,9 @@ func (x *XformerPocket) getIndexableElementDype(dype Nod) Nod {
 		whichType := dype.Data.(int)
 		if whichType == TY_LIST {
 			return NodNew(DYPE_EMPTY)
+		} else if whichType == TY_STRING {
+			// indexing a string gives a one character string
+			return dype
 		}
 	} else if dype.NodeType == NT_TYPECALL {
 		baseType := NodGetChild(dype, NTR_RECEIVERCALL_BASE)
@@ -893,3 +1126,40 @@ func (x *XformerPocket) getIndexableElementDype(dype Nod) Nod {
 
 	return NodNew(DYPE_EMPTY)
 }
+
+func getIndexArg(arg Nod) Nod {
+	// the [i] syntax parses as a single element list, unwrap it
+	if arg.NodeType == NT_LIT_LIST {
+		if eles := NodGetChildList(arg); len(eles) == 1 {
+			return eles[0]
+		}
+	}
+	return arg
+}
+
+func (x *XformerPocket) getSliceableDype(dype Nod) Nod {
+	// returns the part of the input dype that can be sliced by a range
+	// given e.g. Union(list~int~, bool) returns list~int~
+	if dype.NodeType == DYPE_ALL {
+		return dype
+	} else if dype.NodeType == NT_TYPEBASE {
+		whichType := dype.Data.(int)
+		if whichType == TY_LIST || whichType == TY_STRING {
+			return dype
+		}
+	} else if dype.NodeType == NT_TYPECALL {
+		baseType := NodGetChild(dype, NTR_RECEIVERCALL_BASE)
+		if baseType.NodeType == NT_TYPEBASE && baseType.Data.(int) == TY_LIST {
+			return dype
+		}
+	} else if dype.NodeType == DYPE_UNION {
+		subNodes := NodGetChildList(dype)
+		subSliceTypes := []Nod{}
+		for _, subNode := range subNodes {
+			subSliceTypes = append(subSliceTypes, x.getSliceableDype(subNode))
+		}
+		return DypeSimplifyShallow(NodNewChildList(DYPE_UNION, subSliceTypes))
+	}
+
+	return NodNew(DYPE_EMPTY)
+}
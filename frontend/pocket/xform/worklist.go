@@ -0,0 +1,219 @@
+package xform
+
+import (
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"time"
+)
+
+// rewrites are applied in rounds over a worklist rather than in passes over
+// the whole graph. The nodes queued for a round are bucketed by type, so each
+// rule only looks at the types it names, and a node a rule changes queues up
+// just the nodes around it for the next round.
+
+// how many edges out from a changed node to queue up. Rules read their node's
+// children and the dypes hanging off those, and dypes are shared between a
+// var's references, so two edges covers them. What's further away, like a
+// call reading its funcdef's return placeholder, gets picked up by a sweep
+// once the worklist runs dry.
+const rewriteNeighbourhood = 2
+
+// the nodes reachable from the root, as of the last sweep, plus the ones rules
+// have added since. Desugaring leaves replaced nodes pointing at children that
+// are still in use, so following an in edge can lead out of the tree.
+type liveNods map[Nod]bool
+
+func newLiveNods(nods []Nod) liveNods {
+	live := liveNods{}
+	for _, n := range nods {
+		live[n] = true
+	}
+	return live
+}
+
+// whether a rule hasn't replaced n since it was queued, in which case the
+// edges into it point at what replaced it. Replaced nodes are still tried for
+// the rest of the round they're replaced in, as some rules pick up after
+// others through them, e.g. a class name inside a ref op.
+func (x *XformerPocket) isStillLinked(n Nod) bool {
+	if n == x.Root {
+		return true
+	}
+	for _, edge := range n.In {
+		if edge.Out == n {
+			return true
+		}
+	}
+	return false
+}
+
+type rewriteWorklist struct {
+	queued map[Nod]bool
+	byType map[int][]Nod
+	types  []int // in the order they were first queued, for rules on any type
+}
+
+func newRewriteWorklist(nods []Nod) *rewriteWorklist {
+	w := &rewriteWorklist{queued: map[Nod]bool{}, byType: map[int][]Nod{}}
+	for _, n := range nods {
+		w.add(n)
+	}
+	return w
+}
+
+func (w *rewriteWorklist) add(n Nod) {
+	if w.queued[n] {
+		return
+	}
+	w.queued[n] = true
+	w.file(n)
+}
+
+// puts n in the bucket for its current type, e.g. after a rule retyped it
+func (w *rewriteWorklist) file(n Nod) {
+	if _, ok := w.byType[n.NodeType]; !ok {
+		w.types = append(w.types, n.NodeType)
+	}
+	w.byType[n.NodeType] = append(w.byType[n.NodeType], n)
+}
+
+// drops the nodes that don't pass keep
+func (w *rewriteWorklist) prune(keep func(Nod) bool) {
+	for nt, nods := range w.byType {
+		kept := []Nod{}
+		for _, n := range nods {
+			if keep(n) {
+				kept = append(kept, n)
+			} else {
+				delete(w.queued, n)
+			}
+		}
+		w.byType[nt] = kept
+	}
+}
+
+func (w *rewriteWorklist) empty() bool {
+	return len(w.queued) == 0
+}
+
+func (w *rewriteWorklist) triggerTypes(rule *RewriteRule) []int {
+	if len(rule.nodeTypes) == 0 {
+		return w.types
+	}
+	return rule.nodeTypes
+}
+
+// queues n and the live nodes within rewriteNeighbourhood edges of it, either
+// way. What n points to is live since n is, new nodes included.
+func (x *XformerPocket) queueNeighbourhood(w *rewriteWorklist, n Nod, live liveNods) {
+	frontier := []Nod{n}
+	seen := map[Nod]bool{n: true}
+	w.add(n)
+	for dist := 0; dist < rewriteNeighbourhood; dist++ {
+		next := []Nod{}
+		for _, cur := range frontier {
+			for _, near := range x.AllOutNodes(cur) {
+				live[near] = true
+			}
+			for _, near := range append(x.AllOutNodes(cur), x.AllInNodes(cur)...) {
+				if !seen[near] && live[near] {
+					seen[near] = true
+					w.add(near)
+					next = append(next, near)
+				}
+			}
+		}
+		frontier = next
+	}
+}
+
+func (x *XformerPocket) applyRewritesUntilStable(rules []*RewriteRule) {
+	// repeatedly apply rewrite rules, allowing for new nodes to pop in or out of existence.
+	// a set of rules that keeps undoing each other never runs dry, so give up
+	// after more rounds than it'd take a change to spread over the whole graph
+	start := time.Now()
+	// checking the whole graph after every rewrite is slow, so it's only done
+	// when tracing the solver
+	checkIntegrity := LogEnabled(LOGCH_SOLVE, LOG_TRACE)
+	allNods := x.SearchRoot(func(n Nod) bool { return true })
+	maxRounds := 20 + len(allNods)
+	live := newLiveNods(allNods)
+	work := newRewriteWorklist(allNods)
+	sweeping := true
+	nRounds, nApplied := 0, 0
+	for {
+		nRounds++
+		x.rewriteRound = nRounds
+		next := newRewriteWorklist(nil)
+		applied, lastRule := x.applyRewriteRound(rules, work, next, live, checkIntegrity)
+		nApplied += applied
+		if nRounds > maxRounds && applied > 0 {
+			Warnf(LOGCH_SOLVE, "%d rounds exceeded, likely cycle detected, last rewrite by rule %s",
+				maxRounds, Lazy(func() string { return GetRewriteRuleDebugInfo(lastRule) }))
+			panic("too many passes, could not solve")
+		}
+		Tracef(LOGCH_SOLVE, "round %d: %d of %d queued nodes rewritten", nRounds, applied, len(work.queued))
+		if next.empty() {
+			if sweeping {
+				break
+			}
+			allNods = x.SearchRoot(func(n Nod) bool { return true })
+			live = newLiveNods(allNods)
+			next = newRewriteWorklist(allNods)
+			sweeping = true
+		} else {
+			sweeping = false
+		}
+		work = next
+	}
+	Infof(LOGCH_SOLVE, "rewrites stable after %d rounds, %d rewrites on %d nodes in %v",
+		nRounds, nApplied, len(allNods), time.Since(start))
+}
+
+// tries each rule on the queued nodes of its types, in order, queueing the
+// neighbourhoods of the nodes it changes onto next. Returns how many rewrites
+// were made and the last rule to make one.
+func (x *XformerPocket) applyRewriteRound(rules []*RewriteRule, work *rewriteWorklist, next *rewriteWorklist,
+	live liveNods, checkIntegrity bool) (int, *RewriteRule) {
+	work.prune(x.isStillLinked)
+	nApplied := 0
+	var lastRule *RewriteRule
+	for _, rule := range rules {
+		for _, nt := range work.triggerTypes(rule) {
+			// the bucket can grow as earlier nodes in it are retyped into it
+			for ndx := 0; ndx < len(work.byType[nt]); ndx++ {
+				n := work.byType[nt][ndx]
+				if n.NodeType != nt {
+					continue // retyped since it was filed here
+				}
+				if x.applyRewriteRuleOn(rule, n, checkIntegrity) {
+					nApplied++
+					lastRule = rule
+					if n.NodeType != nt {
+						work.file(n) // so the rules after this one see it as what it is now
+					}
+					x.queueNeighbourhood(next, n, live)
+				}
+			}
+		}
+	}
+	return nApplied, lastRule
+}
+
+func (x *XformerPocket) applyRewriteRuleOn(rule *RewriteRule, n Nod, checkIntegrity bool) bool {
+	x.rewriteRule, x.rewriteNode = rule, n
+	if rule.condaction == nil {
+		// TODO: remove this old-school condition-action approach
+		if !rule.condition(n) {
+			return false
+		}
+		rule.action(n)
+	} else if !rule.condaction(n) {
+		return false
+	}
+	if checkIntegrity && !x.NodComputeParentChildIntegrity() {
+		Logf(LOGCH_SOLVE, LOG_ERROR, "integrity check failed after rule %s", Lazy(func() string { return GetRewriteRuleDebugInfo(rule) }))
+		panic("integrity check failed")
+	}
+	return true
+}
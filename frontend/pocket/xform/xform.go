@@ -1,50 +1,119 @@
 package xform
 
 import (
-	"fmt"
 	. "pocket-lang/frontend/pocket/common"
 	. "pocket-lang/parse"
 	. "pocket-lang/xform"
 	"reflect"
 	"runtime"
 	"strconv"
-
-	"github.com/davecgh/go-spew/spew"
+	"strings"
 )
 
 type XformerPocket struct {
 	*Xformer
 	tempVarCounter int
+
+	// what the solver is doing, for the provenance log if it's kept
+	prov         *Provenance
+	rewriteRule  *RewriteRule
+	rewriteNode  Nod
+	rewriteRound int
+
+	// what the checks after solving found
+	diagnostics []*CheckDiagnostic
 }
 
+// the passes of Xform, in order
+var Stages = []string{"prepare", "desugar", "solve"}
+
 func Xform(root Nod) Nod {
-	fmt.Println("starting Xform()")
+	return XformUntil(root, "solve")
+}
+
+// runs the passes of Xform up to and including the last one, for tools that
+// show the tree in between
+func XformUntil(root Nod, last string) Nod {
+	Infof(LOGCH_XFORM, "starting Xform()")
+
+	xformer := &XformerPocket{Xformer: &Xformer{}}
+
+	xformer.Root = root
+	xformer.removeTestDefs()
+	xformer.xformUntil(last)
+	return root
+}
+
+// runs Xform, also returning what the checks after solving warned about.
+// Errors still panic.
+func XformWithWarnings(root Nod) (Nod, []*CheckDiagnostic) {
+	xformer := &XformerPocket{Xformer: &Xformer{}}
+
+	xformer.Root = root
+	xformer.removeTestDefs()
+	xformer.xformUntil("solve")
+	return root, xformer.diagnostics
+}
 
-	xformer := &XformerPocket{&Xformer{}, 0}
+// runs Xform with the rule-based solver that newSolve is replacing, e.g. to
+// benchmark it
+func XformOldSolve(root Nod) Nod {
+	xformer := &XformerPocket{Xformer: &Xformer{}}
 
 	xformer.Root = root
-	xformer.Xform()
+	xformer.removeTestDefs()
+	xformer.xformUntil("desugar")
+	xformer.oldSolve()
+	xformer.check()
 	return root
 }
 
 func (x *XformerPocket) Xform() {
+	x.xformUntil("solve")
+}
+
+func (x *XformerPocket) xformUntil(last string) {
+	if !isStage(last) {
+		panic("unknown stage " + last)
+	}
+
+	if x.prov == nil {
+		// so a type error can say where both sides came from
+		x.prov = newSummaryProvenance()
+	}
 
 	x.NodCheckParentChildIntegrity()
 
 	x.prepare()
+	if last == "prepare" {
+		return
+	}
 
 	x.NodCheckParentChildIntegrity()
 	x.desugar()
 
 	x.NodCheckParentChildIntegrity()
 
-	fmt.Println("after desugaring:", PrettyPrint(x.Root))
+	Debugf(LOGCH_XFORM, "after desugaring: %s", LazyTree(x.Root))
+	if last == "desugar" {
+		return
+	}
 
 	x.newSolve()
-	fmt.Println("after solving", PrettyPrint(x.Root))
+	Debugf(LOGCH_XFORM, "after solving: %s", LazyTree(x.Root))
+	x.check()
 
 }
 
+func isStage(name string) bool {
+	for _, stage := range Stages {
+		if stage == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (x *XformerPocket) newSolve() {
 	nsolver := &NSolver{x}
 	nsolver.solve()
@@ -55,9 +124,9 @@ func (x *XformerPocket) oldSolve() {
 	rules := x.getAllSolveRules()
 	nodes := x.getSolvableNodes()
 	x.initializeSolvableNodes(nodes)
-	fmt.Println("after initializing solveable nodes", PrettyPrint(x.Root))
+	Debugf(LOGCH_SOLVE, "after initializing solvable nodes: %s", LazyTree(x.Root))
 
-	x.applyGraphRewritesUntilStable(rules)
+	x.applyRewritesUntilStable(rules)
 	x.colorTypes()
 
 	x.checkAllVarsResolved()
@@ -259,10 +328,22 @@ func (x *XformerPocket) checkAllCallsResolved() {
 }
 
 func isSystemFuncName(name string) bool {
-	return name == "print" || name == "$li"
+	return name == "print" || name == "$li" || isConversionFuncName(name)
+}
+
+func isConversionFuncName(name string) bool {
+	// int(x), u8(x), bigint(x), etc convert between the numeric types
+	_, ok := ConversionFuncType(name)
+	return ok
 }
 
 type RewriteRule struct {
+	// for messages; rules written in go are named after the func that makes them
+	name string
+
+	// the node types the rule can apply to, so it's only tried on those
+	nodeTypes []int
+
 	// returns whether progress made
 	condaction func(n Nod) bool
 
@@ -280,6 +361,27 @@ func GetRewriteRuleDebugInfo(rule *RewriteRule) string {
 	return GetFPointerDebugInfo(rule.condition)
 }
 
+func GetRewriteRuleName(rule *RewriteRule) string {
+	if rule == nil {
+		return "?"
+	} else if rule.name != "" {
+		return rule.name
+	}
+	f := reflect.ValueOf(rule.condaction)
+	if rule.condaction == nil {
+		f = reflect.ValueOf(rule.condition)
+	}
+	// e.g. pocket-lang/frontend/pocket/xform.(*XformerPocket).marNegVarAssign.func1
+	name := runtime.FuncForPC(f.Pointer()).Name()
+	parts := strings.Split(name[strings.LastIndex(name, "/")+1:], ".")
+	for ndx := len(parts) - 1; ndx > 0; ndx-- {
+		if !strings.HasPrefix(parts[ndx], "func") {
+			return parts[ndx]
+		}
+	}
+	return name
+}
+
 func GetFPointerDebugInfo(f interface{}) string {
 	fPointer := reflect.ValueOf(f).Pointer()
 	funcObject := runtime.FuncForPC(fPointer)
@@ -289,7 +391,7 @@ func GetFPointerDebugInfo(f interface{}) string {
 
 func isLiteralNodeType(nt int) bool {
 	return isPrimitiveLiteralNodeType(nt) ||
-		isCollectionLiteralNodeType(nt)
+		isCollectionLiteralNodeType(nt) || nt == NT_LIT_FSTRING
 
 }
 
@@ -325,7 +427,12 @@ func isBinaryOpType(nt int) bool {
 		nt == NT_GTEQOP || nt == NT_LTEQOP || nt == NT_EQOP ||
 		nt == NT_SUBOP || nt == NT_DIVOP || nt == NT_MULOP ||
 		nt == NT_OROP || nt == NT_ANDOP || nt == NT_MODOP ||
-		nt == NT_DOTOP || nt == NT_DOTPIPEOP
+		nt == NT_POWOP || nt == NT_XOROP || nt == NT_SHLOP || nt == NT_SHROP ||
+		nt == NT_DOTOP || nt == NT_DOTPIPEOP || isRangeOpType(nt)
+}
+
+func isRangeOpType(nt int) bool {
+	return nt == NT_RANGEOP || nt == NT_RANGEEXCLOP
 }
 
 func isUnaryOpType(nt int) bool {
@@ -333,7 +440,20 @@ func isUnaryOpType(nt int) bool {
 }
 
 func isPrefixOpType(nt int) bool {
-	return nt == NT_REFERENCEOP
+	return nt == NT_REFERENCEOP || nt == NT_NEGOP || nt == NT_POSOP || nt == NT_NOTOP
+}
+
+func isNumericLiteralNodeType(nt int) bool {
+	return nt == NT_LIT_INT || nt == NT_LIT_FLOAT
+}
+
+func isIntInList(v int, list []int) bool {
+	for _, ele := range list {
+		if ele == v {
+			return true
+		}
+	}
+	return false
 }
 
 func isSuffixOpType(nt int) bool {
@@ -350,34 +470,6 @@ func isCallType(nt int) bool {
 		nt == NT_RECEIVERCALL_METHOD || nt == NT_OBJINIT
 }
 
-func (x *XformerPocket) applyGraphRewritesUntilStable(rules []*RewriteRule) {
-	// repeatedly apply rewrite rules, allowing for new nodes to pop in or out of existence
-	// by re-generating the node list after each pass
-	nPasses := 0
-	for {
-		maxApplied := 0
-		allNods := x.SearchRoot(func(n Nod) bool { return true })
-		for _, rule := range rules {
-			// fmt.Println("applying rule", GetRewriteRuleDebugInfo(rule))
-			nApplied := x.applyRewriteRuleOnJust(allNods, rule)
-			if nPasses > 20 && nApplied > 0 { // we should never need more than 20 passes
-				fmt.Println("Warning: 20 passes exceed, likely cycle detected")
-				rule.action(nil) // try to break it to get a good debug trace
-				panic("too many passes, could not solve")
-			}
-			if nApplied > maxApplied {
-				maxApplied = nApplied
-			}
-		}
-		if maxApplied == 0 {
-			break
-		}
-		nPasses++
-		fmt.Println("nPasses", nPasses)
-	}
-	fmt.Println("exiting applyrwus")
-}
-
 func (x *XformerPocket) applyRewriteOnGraph(rule *RewriteRule) int {
 	nApplied := 0
 	nods := x.SearchRoot(rule.condition)
@@ -388,30 +480,19 @@ func (x *XformerPocket) applyRewriteOnGraph(rule *RewriteRule) int {
 	return nApplied
 }
 
-func (x *XformerPocket) applyRewriteRuleOnJust(nods []Nod, rule *RewriteRule) int {
+// tries each rule once on the nodes of its types, in order, for passes that
+// only need one go over the graph
+func (x *XformerPocket) applyRewriteRulesOnce(rules []*RewriteRule) int {
 	nApplied := 0
-	// fmt.Println("START RULE APPLY to nods", rule, GetRewriteRuleDebugInfo(rule))
-	for _, ele := range nods {
-		if rule.condaction != nil {
-			applied := rule.condaction(ele)
-			if applied {
-				nApplied++
-				integrity := x.NodComputeParentChildIntegrity()
-				if !integrity {
-					fmt.Println("integrity check after rule", spew.Sdump(rule.condaction))
-					// for finding a stack trace of the rule, try to break it
-					rule.condaction(nil)
-					panic("integrity check failed")
-				}
-			}
-		} else {
-			// TODO: remove this old-school condition-action approach
-			if rule.condition(ele) {
-				rule.action(ele)
+	for _, rule := range rules {
+		nods := x.SearchRoot(func(n Nod) bool {
+			return len(rule.nodeTypes) == 0 || isIntInList(n.NodeType, rule.nodeTypes)
+		})
+		for _, n := range nods {
+			if x.applyRewriteRuleOn(rule, n, false) {
 				nApplied++
 			}
 		}
-
 	}
 	return nApplied
 }
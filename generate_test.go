@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"pocket-lang/backend/goback"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	"pocket-lang/types"
+	"strings"
+	"testing"
+)
+
+func generateValuePanic(n Nod) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	goback.GenerateValue(n)
+	return ""
+}
+
+func TestGenerateInternalErrors(t *testing.T) {
+	// a statement where a value goes, located by the node under it
+	brk := NodNew(NT_BREAK)
+	ret := NodNewChild(NT_RETURN, NTR_RETURN_VALUE, brk)
+	brk.Loc = &types.SourceLocation{Line: 2, Column: 4}
+	want := "internal compiler error: the go backend can't generate NT_RETURN as a value, at line 3 col 5"
+	if got := generateValuePanic(ret); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// every one is reported, not just the first
+	sum := NodNewChild(NT_ADDOP, NTR_BINOP_LEFT, NodNew(NT_BREAK))
+	NodSetChild(sum, NTR_BINOP_RIGHT, NodNew(NT_PASS))
+	sum.Loc = &types.SourceLocation{Line: 0, Column: 0}
+	want = "internal compiler error: the go backend can't generate NT_BREAK as a value, at line 1 col 1\n" +
+		"internal compiler error: the go backend can't generate NT_PASS as a value, at line 1 col 1"
+	if got := generateValuePanic(sum); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestGenerateIsFormatted(t *testing.T) {
+	src := "main func\n    x : 1\n    if x > 0\n        print x + 1\n"
+	genned := goback.Generate(xform.XformOldSolve(pocket.Parse(pocket.Tokenize(src))))
+	formatted, err := format.Source([]byte(genned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != genned {
+		t.Errorf("expected generated code to be formatted, got\n%s", genned)
+	}
+	if !strings.Contains(genned, "\n\tif ") {
+		t.Errorf("expected an indented if, got\n%s", genned)
+	}
+}
+
+func TestGenerateImportsBigOnlyWhenUsed(t *testing.T) {
+	cases := map[string]bool{
+		"main func\n    print 1 + 2\n":                    false,
+		"main func\n    x : bigint(2)\n    print x * x\n": true,
+	}
+	for src, want := range cases {
+		genned := goback.Generate(xform.XformOldSolve(pocket.Parse(pocket.Tokenize(src))))
+		if got := strings.Contains(genned, "\"math/big\""); got != want {
+			t.Errorf("expected importing math/big to be %v, got\n%s", want, genned)
+		}
+		if strings.Contains(genned, "var _ =") {
+			t.Errorf("expected no placeholder vars, got\n%s", genned)
+		}
+	}
+}
+
+func TestGenerateMarksOnlyUnusedVarsUsed(t *testing.T) {
+	src := "main func\n    x : 1\n    y : 2\n    print x\n"
+	genned := goback.Generate(xform.XformOldSolve(pocket.Parse(pocket.Tokenize(src))))
+	if strings.Contains(genned, "_ = x\n") || !strings.Contains(genned, "_ = y\n") {
+		t.Errorf("expected a made up use of y but not of x, got\n%s", genned)
+	}
+}
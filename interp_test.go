@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"pocket-lang/backend/interp"
+	. "pocket-lang/frontend/pocket/common"
+	"testing"
+)
+
+// the interpreter has to give the same output as the go backend, so every
+// case is run through it too, against the same expected output
+func TestRunAllInterp(t *testing.T) {
+	if *interpret {
+		t.Skip("TestRunAll is already running the cases through the interpreter")
+	}
+	paths, _ := filepath.Glob("testcases/*.pkt")
+	if len(paths) == 0 {
+		t.Fatal("no cases found")
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) { runCaseFile(t, path, true) })
+	}
+}
+
+func TestInterpConvert(t *testing.T) {
+	if v := interp.Convert(300, TY_U8); v != uint8(44) {
+		t.Error("u8 conversion should wrap like go", v)
+	}
+	if v := interp.Convert(2.7, TY_INT); v != 2 {
+		t.Error("float to int conversion should truncate", v)
+	}
+	if v := interp.Convert(int8(-3), TY_FLOAT); v != -3.0 {
+		t.Error("bad i8 to float conversion", v)
+	}
+	b := interp.Convert(uint64(1)<<63, TY_BIGINT).(*big.Int)
+	if b.String() != "9223372036854775808" {
+		t.Error("bad u64 to bigint conversion", b)
+	}
+	if v := interp.Convert(big.NewInt(-5), TY_I16); v != int16(-5) {
+		t.Error("bad bigint to i16 conversion", v)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"pocket-lang/dump"
+	. "pocket-lang/frontend/pocket/common"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogOutput(buf)
+	defer SetLogOutput(nil)
+	defer SetLogLevels("")
+
+	if err := SetLogLevels("solve:debug,parse:trace"); err != nil {
+		t.Fatal(err)
+	}
+	Debugf(LOGCH_SOLVE, "solving %d", 1)
+	Tracef(LOGCH_SOLVE, "too detailed")
+	Tracef(LOGCH_PARSE, "parsing")
+	Warnf(LOGCH_GEN, "not on")
+	if want := "[solve:debug] solving 1\n[parse:trace] parsing\n"; buf.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, buf.String())
+	}
+
+	// a lazy arg isn't rendered for a message that isn't written
+	rendered := false
+	Debugf(LOGCH_GEN, "%s", Lazy(func() string { rendered = true; return "" }))
+	if rendered {
+		t.Error("rendered a lazy arg for a channel that's off")
+	}
+
+	for spec, msg := range map[string]string{"bogus:debug": "unknown log channel bogus", "solve:loud": "unknown log level loud"} {
+		if err := SetLogLevels(spec); err == nil || !strings.HasPrefix(err.Error(), msg) {
+			t.Errorf("%s: expected an error starting %q, got %v", spec, msg, err)
+		}
+	}
+}
+
+func TestLogSilentByDefault(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetLogOutput(buf)
+	defer SetLogOutput(nil)
+
+	dump.Compile("main func\n    if 1 > 0\n        print(1)\n", "desugar")
+	if buf.Len() > 0 {
+		t.Errorf("expected no diagnostics, got\n%s", buf.String())
+	}
+}
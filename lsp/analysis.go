@@ -0,0 +1,567 @@
+package lsp
+
+import (
+	"fmt"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	"pocket-lang/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Analysis is what's known about one version of a document. Broken source
+// is analyzed as far as it goes: every top level unit is tokenized on its
+// own, so a bad string literal doesn't hide the other units, the parser
+// skips lines it can't parse, and units the solver chokes on are left out
+// until the rest solves.
+type Analysis struct {
+	lines       []string
+	Diagnostics []Diagnostic
+	Symbols     []DocumentSymbol
+	occurrences []*occurrence
+}
+
+// one mention of a name that resolves to a definition
+type occurrence struct {
+	rng     Range
+	name    string
+	def     Nod // VARDEF, FUNCDEF or CLASSDEF
+	typ     Nod // the solved type at this mention
+	isDef   bool
+	assigns bool // locals are defined by their first assignment
+}
+
+// lines [start, end) of the source, a top level unit and its body
+type chunk struct {
+	start, end int
+}
+
+// a unit starts at any line that isn't indented, blank or a comment
+func splitChunks(lines []string) []chunk {
+	rv := []chunk{}
+	for ndx, line := range lines {
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		if len(rv) > 0 {
+			rv[len(rv)-1].end = ndx
+		}
+		rv = append(rv, chunk{ndx, len(lines)})
+	}
+	return rv
+}
+
+func Analyze(src string) *Analysis {
+	a := &Analysis{
+		lines:       strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n"),
+		Diagnostics: []Diagnostic{},
+		Symbols:     []DocumentSymbol{},
+	}
+	units := []Nod{}
+	unitChunks := map[Nod]chunk{}
+	for _, c := range splitChunks(a.lines) {
+		for _, unit := range a.parseChunk(c) {
+			units = append(units, unit)
+			unitChunks[unit] = c
+			a.Symbols = append(a.Symbols, a.unitSymbol(unit, c))
+		}
+	}
+	if top := a.solve(units, unitChunks); top != nil {
+		a.index(top)
+	}
+	return a
+}
+
+// parses just the chunk's lines, others are blanked to keep line numbers.
+// the parser recovers from syntax errors by itself, failure is set when
+// tokenizing fails and nothing could be parsed.
+func (a *Analysis) tryParse(c chunk) (units []Nod, errs ParseErrors, failure interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = r
+		}
+	}()
+	src := make([]string, len(a.lines))
+	copy(src[c.start:c.end], a.lines[c.start:c.end])
+	var top Nod
+	top, errs = pocket.ParseWithErrors(pocket.Tokenize(strings.Join(src, "\n") + "\n"))
+	return NodGetChildList(top), errs, nil
+}
+
+func (a *Analysis) parseChunk(c chunk) []Nod {
+	units, errs, failure := a.tryParse(c)
+	if failure != nil {
+		a.Diagnostics = append(a.Diagnostics, a.diagnosticFor(failure, c.start))
+		return nil
+	}
+	for _, pe := range errs {
+		a.Diagnostics = append(a.Diagnostics, a.diagnosticFor(pe, c.start))
+	}
+	return units
+}
+
+// solves a copy of the units, returning the solved top level and what the
+// checks after solving warned about
+func trySolve(units []Nod) (top Nod, warnings []*xform.CheckDiagnostic, failure interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure = r
+		}
+	}()
+	copies := []Nod{}
+	for _, unit := range units {
+		copies = append(copies, NodDeepCopyDownwards(unit))
+	}
+	top, warnings = xform.XformWithWarnings(NodNewChildList(NT_TOPLEVEL, copies))
+	return top, warnings, nil
+}
+
+func (a *Analysis) solve(units []Nod, unitChunks map[Nod]chunk) Nod {
+	if len(units) == 0 {
+		return nil
+	}
+	top, warnings, failure := trySolve(units)
+	if failure == nil {
+		a.addWarnings(warnings)
+		return top
+	}
+	// the solver works on whole programs, so find the unit to leave out
+	for skip, culprit := range units {
+		rest := append(append([]Nod{}, units[:skip]...), units[skip+1:]...)
+		if top, warnings, _ := trySolve(rest); top != nil {
+			a.Diagnostics = append(a.Diagnostics, a.diagnosticFor(failure, unitChunks[culprit].start))
+			a.addWarnings(warnings)
+			return top
+		}
+	}
+	a.Diagnostics = append(a.Diagnostics, a.diagnosticFor(failure, 0))
+	return nil
+}
+
+var locationPattern = regexp.MustCompile(`line (\d+) col (\d+)`)
+
+// turns a parser, tokenizer or solver panic into a diagnostic, placed at
+// fallbackLine when the message doesn't say where
+func (a *Analysis) diagnosticFor(failure interface{}, fallbackLine int) Diagnostic {
+	msg := fmt.Sprint(failure)
+	rng := a.lineRange(fallbackLine)
+	if pe, ok := failure.(*ParseError); ok && pe.Location != nil {
+		msg = pe.Msg
+		rng = a.rangeFrom(Position{pe.Location.Line, pe.Location.Column})
+	} else if m := locationPattern.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		rng = a.rangeFrom(Position{line - 1, col - 1})
+	}
+	return Diagnostic{
+		Range:    rng,
+		Severity: severityError,
+		Source:   "pocket",
+		Message:  msg,
+	}
+}
+
+func (a *Analysis) addWarnings(warnings []*xform.CheckDiagnostic) {
+	for _, w := range warnings {
+		if !w.Warning || w.Loc == nil {
+			continue
+		}
+		a.Diagnostics = append(a.Diagnostics, Diagnostic{
+			Range:    a.rangeFrom(Position{w.Loc.Line, w.Loc.Column}),
+			Severity: severityWarning,
+			Source:   "pocket",
+			Message:  w.Msg,
+		})
+	}
+}
+
+func (a *Analysis) lineRange(line int) Range {
+	if line < 0 || line >= len(a.lines) {
+		return Range{}
+	}
+	indent := len(a.lines[line]) - len(strings.TrimLeft(a.lines[line], " \t"))
+	return Range{Position{line, indent}, Position{line, len(a.lines[line])}}
+}
+
+// from pos to the end of its line
+func (a *Analysis) rangeFrom(pos Position) Range {
+	end := pos
+	if pos.Line >= 0 && pos.Line < len(a.lines) && len(a.lines[pos.Line]) > pos.Character {
+		end.Character = len(a.lines[pos.Line])
+	}
+	return Range{pos, end}
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// node locations point just past a token that's near the name, so this
+// finds the mention of name on that line closest to the location
+func (a *Analysis) nameRange(loc *types.SourceLocation, name string) (Range, bool) {
+	if loc == nil || name == "" || loc.Line >= len(a.lines) {
+		return Range{}, false
+	}
+	line := a.lines[loc.Line]
+	best, bestDist := -1, 0
+	for from := 0; from < len(line); {
+		ndx := strings.Index(line[from:], name)
+		if ndx < 0 {
+			break
+		}
+		ndx += from
+		end := ndx + len(name)
+		if (ndx == 0 || !isWordChar(line[ndx-1])) && (end == len(line) || !isWordChar(line[end])) {
+			dist := abs(ndx - loc.Column)
+			if d := abs(end - loc.Column); d < dist {
+				dist = d
+			}
+			if best < 0 || dist < bestDist {
+				best, bestDist = ndx, dist
+			}
+		}
+		from = ndx + 1
+	}
+	if best < 0 {
+		return Range{}, false
+	}
+	return Range{Position{loc.Line, best}, Position{loc.Line, best + len(name)}}, true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func unitName(unit Nod) string {
+	if unit.NodeType == NT_FUNCDEF {
+		return nameOf(NodGetChildOrNil(unit, NTR_FUNCDEF_NAME))
+	} else if unit.NodeType == NT_CLASSDEF {
+		return nameOf(NodGetChildOrNil(unit, NTR_CLASSDEF_NAME))
+	}
+	return ""
+}
+
+func nameOf(n Nod) string {
+	if n == nil {
+		return ""
+	}
+	name, _ := n.Data.(string)
+	return name
+}
+
+func (a *Analysis) unitSymbol(unit Nod, c chunk) DocumentSymbol {
+	last := c.end - 1
+	for last > c.start && strings.TrimSpace(a.lines[last]) == "" {
+		last--
+	}
+	sym := DocumentSymbol{
+		Name:  unitName(unit),
+		Kind:  symbolKindFunction,
+		Range: Range{Position{c.start, 0}, Position{last, len(a.lines[last])}},
+	}
+	sym.SelectionRange, _ = a.nameRange(unit.Loc, sym.Name)
+	if unit.NodeType != NT_CLASSDEF {
+		return sym
+	}
+	sym.Kind = symbolKindClass
+	for _, member := range NodGetChildList(unit) {
+		child := DocumentSymbol{}
+		if member.NodeType == NT_CLASSFIELD {
+			child.Name = nameOf(NodGetChildOrNil(member, NTR_VARDEF_NAME))
+			child.Kind = symbolKindField
+		} else if member.NodeType == NT_FUNCDEF {
+			child.Name = unitName(member)
+			child.Kind = symbolKindMethod
+		} else {
+			continue
+		}
+		child.SelectionRange, _ = a.nameRange(member.Loc, child.Name)
+		child.Range = a.lineRange(child.SelectionRange.Start.Line)
+		sym.Children = append(sym.Children, child)
+	}
+	return sym
+}
+
+func (a *Analysis) index(top Nod) {
+	seen := map[Nod]bool{}
+	var walk func(n Nod)
+	walk = func(n Nod) {
+		if n == nil || seen[n] {
+			return
+		}
+		seen[n] = true
+		a.indexNode(n)
+		for edgeType, edge := range n.Out {
+			// types and namespaces point back up the tree
+			if edgeType != NTR_TYPE && edgeType != NTR_TYPE_DECL && edgeType != NTR_NAMESPACE {
+				walk(edge.Out)
+			}
+		}
+	}
+	walk(top)
+
+	sort.Slice(a.occurrences, func(i, j int) bool {
+		return a.occurrences[i].rng.Start.before(a.occurrences[j].rng.Start)
+	})
+	defined := map[Nod]bool{}
+	for _, occ := range a.occurrences {
+		if occ.isDef {
+			defined[occ.def] = true
+		}
+	}
+	for _, occ := range a.occurrences {
+		if occ.assigns && !defined[occ.def] {
+			occ.isDef = true
+			defined[occ.def] = true
+		}
+	}
+}
+
+func (a *Analysis) addOccurrence(loc *types.SourceLocation, name string, def Nod, typ Nod, isDef bool) *occurrence {
+	if def == nil {
+		return nil
+	}
+	rng, ok := a.nameRange(loc, name)
+	if !ok {
+		return nil
+	}
+	occ := &occurrence{rng: rng, name: name, def: def, typ: typ, isDef: isDef}
+	a.occurrences = append(a.occurrences, occ)
+	return occ
+}
+
+func isIdentifierType(nt int) bool {
+	return nt == NT_IDENTIFIER || nt == NT_IDENTIFIER_RESOLVED || nt == NT_IDENTIFIER_FUNC_NOSCOPE
+}
+
+// the class a value of the node's type is an instance of, if any
+func classOf(n Nod) Nod {
+	if n == nil {
+		return nil
+	}
+	if typ := NodGetChildOrNil(n, NTR_TYPE); typ != nil && typ.NodeType == NT_CLASSDEF {
+		return typ
+	}
+	return nil
+}
+
+func memberOf(cls Nod, table int, name string) Nod {
+	if cls == nil || !NodHasChild(cls, table) {
+		return nil
+	}
+	for _, member := range NodGetChildList(NodGetChild(cls, table)) {
+		if member.NodeType == NT_VARDEF && nameOf(NodGetChildOrNil(member, NTR_VARDEF_NAME)) == name {
+			return member
+		} else if member.NodeType == NT_FUNCDEF && unitName(member) == name {
+			return member
+		}
+	}
+	return nil
+}
+
+func (a *Analysis) indexNode(n Nod) {
+	switch n.NodeType {
+	case NT_VAR_GETTER:
+		a.addOccurrence(n.Loc, nameOf(NodGetChildOrNil(n, NTR_VAR_NAME)), NodGetChildOrNil(n, NTR_VARDEF), NodGetChildOrNil(n, NTR_TYPE), false)
+	case NT_VARASSIGN:
+		lvalue := NodGetChild(n, NTR_VAR_NAME)
+		varDef := NodGetChildOrNil(n, NTR_VARDEF)
+		if isIdentifierType(lvalue.NodeType) && varDef != nil {
+			if occ := a.addOccurrence(n.Loc, nameOf(lvalue), varDef, NodGetChildOrNil(varDef, NTR_TYPE), false); occ != nil {
+				occ.assigns = true
+			}
+		}
+	case NT_PARAMETER, NT_CLASSFIELD:
+		varDef := NodGetChildOrNil(n, NTR_VARDEF)
+		if varDef != nil {
+			a.addOccurrence(n.Loc, nameOf(NodGetChildOrNil(n, NTR_VARDEF_NAME)), varDef, NodGetChildOrNil(varDef, NTR_TYPE), true)
+		}
+	case NT_FUNCDEF, NT_CLASSDEF:
+		a.addOccurrence(n.Loc, unitName(n), n, n, true)
+	case NT_RECEIVERCALL, NT_RECEIVERCALL_CMD:
+		if def := NodGetChildOrNil(n, NTR_FUNCDEF); def != nil {
+			a.addOccurrence(n.Loc, nameOf(NodGetChildOrNil(n, NTR_RECEIVERCALL_BASE)), def, def, false)
+		}
+	case NT_RECEIVERCALL_METHOD:
+		name := nameOf(NodGetChildOrNil(n, NTR_RECEIVERCALL_METHOD_NAME))
+		method := memberOf(classOf(NodGetChildOrNil(n, NTR_RECEIVERCALL_BASE)), NTR_FUNCTABLE, name)
+		a.addOccurrence(n.Loc, name, method, method, false)
+	case NT_OBJFIELD_ACCESSOR:
+		name := nameOf(NodGetChildOrNil(n, NTR_OBJFIELD_ACCESSOR_NAME))
+		field := memberOf(classOf(NodGetChildOrNil(n, NTR_RECEIVERCALL_BASE)), NTR_VARTABLE, name)
+		a.addOccurrence(n.Loc, name, field, NodGetChildOrNil(n, NTR_TYPE), false)
+	case NT_OBJINIT:
+		if cls := NodGetChildOrNil(n, NTR_RECEIVERCALL_BASE); cls != nil && cls.NodeType == NT_CLASSDEF {
+			a.addOccurrence(n.Loc, unitName(cls), cls, cls, false)
+		}
+	}
+}
+
+func (a *Analysis) occurrenceAt(pos Position) *occurrence {
+	for _, occ := range a.occurrences {
+		if occ.rng.contains(pos) {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (a *Analysis) definitionOf(def Nod) *occurrence {
+	for _, occ := range a.occurrences {
+		if occ.def == def && occ.isDef {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (a *Analysis) referencesTo(def Nod) []*occurrence {
+	rv := []*occurrence{}
+	for _, occ := range a.occurrences {
+		if occ.def == def {
+			rv = append(rv, occ)
+		}
+	}
+	return rv
+}
+
+// a short description of what a mention refers to, like `add func(a int) int`
+func (occ *occurrence) describe() string {
+	switch occ.def.NodeType {
+	case NT_CLASSDEF:
+		return occ.name + " class"
+	case NT_FUNCDEF:
+		return occ.name + " " + funcSignature(occ.def)
+	}
+	return occ.name + " " + DypeString(occ.typ)
+}
+
+func funcSignature(def Nod) string {
+	params := []string{}
+	if inType := NodGetChildOrNil(def, NTR_FUNCDEF_INTYPE); inType != nil {
+		paramNods := []Nod{inType}
+		if inType.NodeType == NT_LIT_LIST {
+			paramNods = NodGetChildList(inType)
+		}
+		for _, param := range paramNods {
+			if param.NodeType == NT_PARAMETER {
+				params = append(params, nameOf(NodGetChildOrNil(param, NTR_VARDEF_NAME))+" "+DypeString(NodGetChildOrNil(param, NTR_TYPE)))
+			}
+		}
+	}
+	rv := "func(" + strings.Join(params, ", ") + ")"
+	if placeholder := NodGetChildOrNil(def, NTR_RETURNVAL_PLACEHOLDER); placeholder != nil {
+		if out := DypeString(NodGetChildOrNil(placeholder, NTR_TYPE)); out != "void" {
+			rv += " " + out
+		}
+	}
+	return rv
+}
+
+var keywords = []string{
+	"return", "void", "bool", "int", "float", "string", "list", "set", "map",
+	"loop", "for", "while", "not", "if", "else", "break", "true", "false",
+	"in", "by", "pass", "class", "func", "pragma",
+}
+
+var receiverPattern = regexp.MustCompile(`([A-Za-z_$][A-Za-z0-9_$]*)\.[A-Za-z0-9_$]*$`)
+
+func (a *Analysis) Complete(pos Position) []CompletionItem {
+	prefix := ""
+	if pos.Line < len(a.lines) {
+		line := a.lines[pos.Line]
+		if pos.Character < len(line) {
+			line = line[:pos.Character]
+		}
+		prefix = line
+	}
+	if m := receiverPattern.FindStringSubmatch(prefix); m != nil {
+		return a.completeMembers(a.classNamed(m[1], pos))
+	}
+
+	rv := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			rv = append(rv, item)
+		}
+	}
+	for _, occ := range a.occurrences {
+		// variables in scope are the ones defined in the same unit
+		if occ.isDef && occ.def.NodeType == NT_VARDEF && a.sameUnit(occ.rng.Start, pos) {
+			add(CompletionItem{Label: occ.name, Kind: completionKindVariable, Detail: occ.describe()})
+		}
+	}
+	for _, sym := range a.Symbols {
+		if sym.Kind == symbolKindClass {
+			add(CompletionItem{Label: sym.Name, Kind: completionKindClass})
+		} else {
+			add(CompletionItem{Label: sym.Name, Kind: completionKindFunction})
+		}
+	}
+	for _, keyword := range keywords {
+		add(CompletionItem{Label: keyword, Kind: completionKindKeyword})
+	}
+	return rv
+}
+
+func (a *Analysis) sameUnit(p1, p2 Position) bool {
+	for _, sym := range a.Symbols {
+		if sym.Range.contains(p1) {
+			return sym.Range.contains(p2)
+		}
+	}
+	return false
+}
+
+// the class of the closest variable named name at or before pos
+func (a *Analysis) classNamed(name string, pos Position) Nod {
+	var rv Nod
+	for _, occ := range a.occurrences {
+		if occ.name != name || pos.before(occ.rng.Start) {
+			continue
+		}
+		if occ.typ != nil && occ.typ.NodeType == NT_CLASSDEF {
+			rv = occ.typ
+		} else if cls := classOf(occ.def); occ.def.NodeType == NT_VARDEF && cls != nil {
+			rv = cls
+		}
+	}
+	return rv
+}
+
+func (a *Analysis) completeMembers(cls Nod) []CompletionItem {
+	rv := []CompletionItem{}
+	if cls == nil {
+		return rv
+	}
+	if varTable := NodGetChildOrNil(cls, NTR_VARTABLE); varTable != nil {
+		for _, varDef := range NodGetChildList(varTable) {
+			name := nameOf(NodGetChildOrNil(varDef, NTR_VARDEF_NAME))
+			rv = append(rv, CompletionItem{
+				Label:  name,
+				Kind:   completionKindField,
+				Detail: name + " " + DypeString(NodGetChildOrNil(varDef, NTR_TYPE)),
+			})
+		}
+	}
+	if funcTable := NodGetChildOrNil(cls, NTR_FUNCTABLE); funcTable != nil {
+		for _, method := range NodGetChildList(funcTable) {
+			name := unitName(method)
+			rv = append(rv, CompletionItem{
+				Label:  name,
+				Kind:   completionKindMethod,
+				Detail: name + " " + funcSignature(method),
+			})
+		}
+	}
+	return rv
+}
@@ -0,0 +1,184 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Server answers requests one at a time, re-analyzing a document whenever
+// it changes. Replies go to the writer the server was started with; the
+// frontend only logs when asked to with --log, and then to stderr.
+type Server struct {
+	out      io.Writer
+	docs     map[string]*Analysis // by uri
+	shutdown bool
+}
+
+// Serve runs a server until the client sends exit. It errors if the
+// connection breaks or the client exits without shutting down first.
+func Serve(in io.Reader, out io.Writer) error {
+	s := &Server{
+		out:  out,
+		docs: map[string]*Analysis{},
+	}
+	reader := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (err error) {
+	var result interface{}
+	defer func() {
+		// a bug in one request shouldn't take down the editor session
+		if r := recover(); r != nil {
+			err = s.replyError(msg, errInternal, fmt.Sprint(r))
+		}
+	}()
+	switch msg.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // full text on every change
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+			},
+			"serverInfo": map[string]string{"name": "pocket"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		params := didOpenParams{}
+		s.decode(msg, &params)
+		return s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		params := didChangeParams{}
+		s.decode(msg, &params)
+		if len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		params := didCloseParams{}
+		s.decode(msg, &params)
+		delete(s.docs, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []Diagnostic{}})
+	case "textDocument/hover":
+		result = s.hover(s.positionParams(msg))
+	case "textDocument/definition":
+		result = s.definition(s.positionParams(msg))
+	case "textDocument/references":
+		result = s.references(s.positionParams(msg))
+	case "textDocument/completion":
+		params := s.positionParams(msg)
+		if doc := s.docs[params.TextDocument.URI]; doc != nil {
+			result = doc.Complete(params.Position)
+		}
+	case "textDocument/documentSymbol":
+		params := didCloseParams{}
+		s.decode(msg, &params)
+		if doc := s.docs[params.TextDocument.URI]; doc != nil {
+			result = doc.Symbols
+		}
+	default:
+		if msg.ID == nil {
+			// unknown notifications, like initialized, are fine to ignore
+			return nil
+		}
+		return s.replyError(msg, errMethodNotFound, "unsupported method "+msg.Method)
+	}
+	if msg.ID == nil {
+		return nil
+	}
+	return writeMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+func (s *Server) decode(msg *message, params interface{}) {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		panic("bad params for " + msg.Method + ": " + err.Error())
+	}
+}
+
+func (s *Server) positionParams(msg *message) textDocumentPositionParams {
+	params := textDocumentPositionParams{}
+	s.decode(msg, &params)
+	return params
+}
+
+func (s *Server) replyError(msg *message, code int, text string) error {
+	if msg.ID == nil {
+		return nil
+	}
+	return writeMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: responseError{code, text}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) update(uri string, text string) error {
+	doc := Analyze(text)
+	s.docs[uri] = doc
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, doc.Diagnostics})
+}
+
+func (s *Server) occurrenceAt(params textDocumentPositionParams) (*Analysis, *occurrence) {
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return nil, nil
+	}
+	return doc, doc.occurrenceAt(params.Position)
+}
+
+func (s *Server) hover(params textDocumentPositionParams) interface{} {
+	_, occ := s.occurrenceAt(params)
+	if occ == nil {
+		return nil
+	}
+	rv := &hoverResult{Range: occ.rng}
+	rv.Contents.Kind = "plaintext"
+	rv.Contents.Value = occ.describe()
+	return rv
+}
+
+func (s *Server) definition(params textDocumentPositionParams) interface{} {
+	doc, occ := s.occurrenceAt(params)
+	if occ == nil {
+		return nil
+	}
+	if def := doc.definitionOf(occ.def); def != nil {
+		return Location{params.TextDocument.URI, def.rng}
+	}
+	return nil
+}
+
+func (s *Server) references(params textDocumentPositionParams) interface{} {
+	rv := []Location{}
+	doc, occ := s.occurrenceAt(params)
+	if occ == nil {
+		return rv
+	}
+	for _, ref := range doc.referencesTo(occ.def) {
+		rv = append(rv, Location{params.TextDocument.URI, ref.rng})
+	}
+	return rv
+}
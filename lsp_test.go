@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"pocket-lang/lsp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func lspFrame(msg string) string {
+	return "Content-Length: " + strconv.Itoa(len(msg)) + "\r\n\r\n" + msg
+}
+
+// runs a whole session and returns the server's messages in order
+func lspSession(t *testing.T, src string, requests ...string) []map[string]interface{} {
+	text, _ := json.Marshal(src)
+	in := lspFrame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+		lspFrame(`{"jsonrpc":"2.0","method":"initialized","params":{}}`) +
+		lspFrame(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///t.pkt","text":`+string(text)+`}}}`)
+	for ndx, req := range requests {
+		in += lspFrame(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,%s}`, ndx+2, req))
+	}
+	in += lspFrame(`{"jsonrpc":"2.0","id":100,"method":"shutdown"}`) +
+		lspFrame(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	out := &bytes.Buffer{}
+	if err := lsp.Serve(strings.NewReader(in), out); err != nil {
+		t.Fatal("server failed:", err)
+	}
+
+	rv := []map[string]interface{}{}
+	reader := bufio.NewReader(out)
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return rv
+		}
+		length, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		reader.ReadString('\n')
+		body := make([]byte, length)
+		io.ReadFull(reader, body)
+		msg := map[string]interface{}{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal("bad message from server:", string(body))
+		}
+		rv = append(rv, msg)
+	}
+}
+
+func TestLspSymbolsAndDiagnostics(t *testing.T) {
+	src := "Point class\n    x int\n    norm func\n        return x\n\n" +
+		"broken func\n    y : (1 +\n\n" +
+		"main func\n    print 1\n"
+	msgs := lspSession(t, src,
+		`"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":"file:///t.pkt"}}`)
+	if len(msgs) != 4 {
+		t.Fatal("expected initialize, diagnostics, symbols and shutdown, got", msgs)
+	}
+
+	// the broken unit is reported on its bad line, the others still parse
+	diags := msgs[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	foundBadLine := false
+	for _, diag := range diags {
+		start := diag.(map[string]interface{})["range"].(map[string]interface{})["start"].(map[string]interface{})
+		if start["line"].(float64) == 6 {
+			foundBadLine = true
+		}
+	}
+	if !foundBadLine {
+		t.Error("no diagnostic on the broken line", diags)
+	}
+
+	names := []string{}
+	for _, sym := range msgs[2]["result"].([]interface{}) {
+		sym := sym.(map[string]interface{})
+		names = append(names, sym["name"].(string))
+		if children, ok := sym["children"].([]interface{}); ok {
+			for _, child := range children {
+				names = append(names, sym["name"].(string)+"."+child.(map[string]interface{})["name"].(string))
+			}
+		}
+	}
+	if strings.Join(names, " ") != "Point Point.x Point.norm broken main" {
+		t.Error("unexpected symbols", names)
+	}
+}
+
+func TestLspUnknownMethod(t *testing.T) {
+	msgs := lspSession(t, "main func\n    pass\n", `"method":"workspace/symbol","params":{}`)
+	if msgs[2]["error"] == nil {
+		t.Error("unsupported requests should get an error reply", msgs[2])
+	}
+}
+
+// e.g. 9:4-5 for a range on line 9 from character 4 to 5
+func lspRangeString(rng interface{}) string {
+	r := rng.(map[string]interface{})
+	start := r["start"].(map[string]interface{})
+	end := r["end"].(map[string]interface{})
+	return fmt.Sprintf("%v:%v-%v", start["line"], start["character"], end["character"])
+}
+
+func TestLspNavigation(t *testing.T) {
+	src := "Point class\n    x int\n    y int\n\n" +
+		"double func(a int) int\n    return a * 2\n\n" +
+		"main func\n    p : Point{x: 3, y: 4}\n    n : double(p.x)\n    print(n + p.y)\n"
+	at := func(method string, line, char int) string {
+		return fmt.Sprintf(`"method":"textDocument/%s","params":{"textDocument":{"uri":"file:///t.pkt"},`+
+			`"position":{"line":%d,"character":%d},"context":{"includeDeclaration":true}}`, method, line, char)
+	}
+	msgs := lspSession(t, src,
+		at("hover", 10, 10), at("hover", 9, 9),
+		at("definition", 10, 10), at("definition", 9, 9),
+		at("references", 1, 4), at("references", 9, 16),
+		at("completion", 10, 16), at("completion", 9, 8))
+	if len(msgs) != 11 {
+		t.Fatal("expected initialize, diagnostics, 8 replies and shutdown, got", msgs)
+	}
+	if diags := msgs[1]["params"].(map[string]interface{})["diagnostics"].([]interface{}); len(diags) > 0 {
+		t.Fatal("expected no diagnostics, got", diags)
+	}
+	result := func(ndx int) interface{} { return msgs[ndx+2]["result"] }
+
+	hovers := map[int]string{0: "n int", 1: "double func(a int) int"}
+	for ndx, want := range hovers {
+		contents := result(ndx).(map[string]interface{})["contents"].(map[string]interface{})
+		if contents["value"] != want {
+			t.Errorf("expected hover %q, got %q", want, contents["value"])
+		}
+	}
+
+	definitions := map[int]string{2: "9:4-5", 3: "4:0-6"}
+	for ndx, want := range definitions {
+		if got := lspRangeString(result(ndx).(map[string]interface{})["range"]); got != want {
+			t.Errorf("expected definition at %s, got %s", want, got)
+		}
+	}
+
+	references := map[int]string{4: "1:4-5 9:17-18", 5: "8:4-5 9:15-16 10:14-15"}
+	for ndx, want := range references {
+		got := []string{}
+		for _, loc := range result(ndx).([]interface{}) {
+			got = append(got, lspRangeString(loc.(map[string]interface{})["range"]))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("expected references at %s, got %s", want, got)
+		}
+	}
+
+	// after p. the fields, otherwise what's in scope
+	completions := map[int]string{6: "x y", 7: "p n Point double main return"}
+	for ndx, want := range completions {
+		labels := []string{}
+		for _, item := range result(ndx).([]interface{}) {
+			labels = append(labels, item.(map[string]interface{})["label"].(string))
+		}
+		if got := strings.Join(labels, " "); !strings.HasPrefix(got, want) {
+			t.Errorf("expected completions starting with %s, got %s", want, got)
+		}
+	}
+}
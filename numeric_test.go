@@ -0,0 +1,50 @@
+package main
+
+import (
+	. "pocket-lang/backend/goback"
+	. "pocket-lang/frontend/pocket/common"
+	"testing"
+)
+
+func TestNumericPromote(t *testing.T) {
+	cases := []struct {
+		a, b, want int
+		ok         bool
+	}{
+		{TY_I8, TY_I32, TY_I32, true},
+		{TY_U8, TY_I16, TY_I16, true},
+		{TY_U32, TY_I32, 0, false},
+		{TY_I64, TY_FLOAT, TY_FLOAT, true},
+		{TY_U64, TY_BIGINT, TY_BIGINT, true},
+		{TY_BIGINT, TY_F32, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := NumericPromote(c.a, c.b)
+		if ok != c.ok || (ok && got != c.want) {
+			t.Error("bad promotion of", NumericTypeName(c.a), NumericTypeName(c.b), got, ok)
+		}
+	}
+	if NumericLiteralFits(TY_U8, 256) || !NumericLiteralFits(TY_I8, -128) {
+		t.Error("bad literal range check")
+	}
+}
+
+func TestBigint(t *testing.T) {
+	a := P__to_bigint("123456789012345678901234567890")
+	b := P__bigint_arith(a, P__to_bigint(uint8(2)), "*")
+	if b.String() != "246913578024691357802469135780" {
+		t.Error("bad bigint product", b)
+	}
+	if P__bigint_cmp(a, b) >= 0 {
+		t.Error("bad bigint comparison")
+	}
+}
+
+func TestIntegerPow(t *testing.T) {
+	if r := P__ipow(3, 4); r != 81 {
+		t.Error("bad power", r)
+	}
+	if r := P__ipow(uint8(2), uint8(9)); r != 0 {
+		t.Error("power should wrap like other u8 ops", r)
+	}
+}
@@ -1,5 +1,9 @@
 package parse
 
+import (
+	"pocket-lang/types"
+)
+
 const (
 	NTR_LIST_0   = 100000 // 100000<->0th element, 100001<->1st element, etc
 	NTR_LIST_MAX = 200000
@@ -21,6 +25,7 @@ type Node struct {
 	In       []*Edge
 	Out      map[int]*Edge
 	Data     interface{}
+	Loc      *types.SourceLocation // where in the source this node was parsed, if known
 }
 
 type Nod *Node
@@ -173,6 +178,7 @@ func NodGetInEdgeNdx(n Nod, inEdge *Edge) int {
 func NodDeepCopyDownwards(n Nod) Nod {
 	rv := NodNew(n.NodeType)
 	rv.Data = n.Data
+	rv.Loc = n.Loc
 	for edgeType, edge := range n.Out {
 		NodSetChild(rv, edgeType, NodDeepCopyDownwards(edge.Out))
 	}
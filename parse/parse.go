@@ -2,12 +2,28 @@ package parse
 
 import (
 	"pocket-lang/types"
+	"strconv"
+	"strings"
 )
 
 type Parser struct {
 	Input  []types.Token
 	Pos    int
 	Output *Node
+
+	TokenNames map[int]string // how token types read in errors, e.g. "end of line"
+	Errors     []*ParseError  // errors recovered from so far, see RecordError
+
+	// error messages come from the failure that got furthest into the input,
+	// that's the alternative that came closest to making sense of it
+	furthest furthestFailure
+}
+
+type furthestFailure struct {
+	set      bool
+	pos      int
+	expected []string // what would have been accepted at pos
+	message  string   // an explicit error raised at pos, wins over expected
 }
 
 type ParseFunc func() Nod
@@ -23,6 +39,19 @@ func (p ParseError) Error() string {
 	return "At " + p.Location.StringDebug() + ": " + p.Msg
 }
 
+// every error of a parse that recovered from them, in source order
+type ParseErrors []*ParseError
+
+var _ error = ParseErrors{}
+
+func (pes ParseErrors) Error() string {
+	msgs := []string{}
+	for _, pe := range pes {
+		msgs = append(msgs, pe.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
 // returns nil if zero parsed, node if one parsed
 func (p *Parser) ParseAtMostOne(f ParseFunc) Nod {
 	opos := p.Pos
@@ -53,7 +82,7 @@ func (p *Parser) ParseManyGreedyEnsureCount(f ParseFunc,
 	countCheck func(int) bool) []Nod {
 	rv := p.ParseManyGreedy(f)
 	if !countCheck(len(rv)) {
-		p.RaiseParseError("incorrect number of subelements")
+		p.Reject("incorrect number of subelements")
 	}
 	return rv
 }
@@ -82,13 +111,28 @@ func (p *Parser) GetCurrToken() *types.Token {
 }
 
 func (p *Parser) Tryparse(parseFunc ParseFunc) (obj Nod, e error) {
+	nerrors := len(p.Errors)
 	defer func() {
 		if r := recover(); r != nil {
-			e = r.(*ParseError)
+			pe, ok := r.(*ParseError)
+			if !ok {
+				panic(r)
+			}
+			e = pe
+			// errors recovered from inside a failed parse don't count
+			p.Errors = p.Errors[:nerrors]
 		}
 	}()
 	e = nil
+	var startLoc *types.SourceLocation
+	if !p.IsEOF() {
+		startLoc = p.CurrToken().SourceLocation
+	}
 	obj = parseFunc()
+	// tag the node with where it started, unless a more specific parse already did
+	if obj != nil && obj.Loc == nil {
+		obj.Loc = startLoc
+	}
 	return
 }
 
@@ -102,39 +146,145 @@ func (p *Parser) ParseDisjunction(funcs []ParseFunc) Nod {
 		}
 		p.Pos = oldpos // backtrack
 	}
-	p.RaiseParseError("parse error")
-	return nil
+	panic(p.FurthestError())
 }
 
+// Expecting parses f, and if f fails without getting past its first token,
+// reports that what (e.g. "a value") was expected there, instead of every
+// token f could have started with
+func (p *Parser) Expecting(what string, f ParseFunc) Nod {
+	start := p.Pos
+	saved := p.furthest
+	val, err := p.Tryparse(f)
+	if err == nil {
+		return val
+	}
+	if p.furthest.pos == start && p.furthest.message == "" {
+		p.furthest = saved
+		p.Pos = start
+		p.RaiseExpected(what)
+	}
+	panic(err)
+}
+
+// RaiseParseError fails with a specific message, which is what gets reported
+// if no other alternative gets further
 func (p *Parser) RaiseParseError(msg string) {
-	tok := p.CurrToken()
-	pe := &ParseError{
-		Msg:      msg,
-		Location: tok.SourceLocation,
+	p.noteFailure("", msg)
+	panic(p.errorAt(p.Pos, msg))
+}
+
+// RaiseExpected fails because what (e.g. "':'") isn't next
+func (p *Parser) RaiseExpected(what string) {
+	p.noteFailure(what, "")
+	panic(p.errorAt(p.Pos, "expected "+what+", found "+p.describeToken(p.Pos)))
+}
+
+// Reject fails without reporting anything, for when a parse that already
+// failed and was recorded is the real reason
+func (p *Parser) Reject(msg string) {
+	panic(p.errorAt(p.Pos, msg))
+}
+
+func (p *Parser) noteFailure(expected string, msg string) {
+	f := &p.furthest
+	if !f.set || p.Pos > f.pos {
+		*f = furthestFailure{set: true, pos: p.Pos}
+	}
+	if p.Pos != f.pos {
+		return
+	}
+	if msg != "" && f.message == "" {
+		f.message = msg
+	}
+	if expected != "" {
+		for _, e := range f.expected {
+			if e == expected {
+				return
+			}
+		}
+		f.expected = append(f.expected, expected)
 	}
-	panic(pe)
+}
+
+// ResetFurthest forgets earlier failures, e.g. once a broken unit was skipped
+func (p *Parser) ResetFurthest() {
+	p.furthest = furthestFailure{}
+}
+
+// FurthestError sums up the furthest failure, like
+// "expected ':' or '(', found end of line"
+func (p *Parser) FurthestError() *ParseError {
+	f := p.furthest
+	if !f.set {
+		return p.errorAt(p.Pos, "unexpected "+p.describeToken(p.Pos))
+	}
+	msg := f.message
+	if msg == "" && len(f.expected) > 0 {
+		msg = "expected " + joinAlternatives(f.expected) + ", found " + p.describeToken(f.pos)
+	} else if msg == "" {
+		msg = "unexpected " + p.describeToken(f.pos)
+	}
+	return p.errorAt(f.pos, msg)
+}
+
+// RecordError keeps an error that parsing recovered from
+func (p *Parser) RecordError(pe *ParseError) {
+	p.Errors = append(p.Errors, pe)
+}
+
+func joinAlternatives(alts []string) string {
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	return strings.Join(alts[:len(alts)-1], ", ") + " or " + alts[len(alts)-1]
+}
+
+func (p *Parser) errorAt(pos int, msg string) *ParseError {
+	pe := &ParseError{Msg: msg}
+	if pos < len(p.Input) {
+		pe.Location = p.Input[pos].SourceLocation
+	} else if len(p.Input) > 0 {
+		pe.Location = p.Input[len(p.Input)-1].SourceLocation
+	}
+	return pe
+}
+
+func (p *Parser) tokenName(tokenType int) string {
+	if name, ok := p.TokenNames[tokenType]; ok {
+		return name
+	}
+	return "token " + strconv.Itoa(tokenType)
+}
+
+// how the token at pos reads in an error, its text or the name of its type
+func (p *Parser) describeToken(pos int) string {
+	if pos >= len(p.Input) {
+		return "end of file"
+	}
+	tok := p.Input[pos]
+	if strings.TrimSpace(tok.Data) == "" {
+		return p.tokenName(tok.Type)
+	}
+	return "'" + tok.Data + "'"
 }
 
 func (p *Parser) ParseToken(tokenType int) *types.Token {
-	cval := p.CurrToken()
-	if cval.Type == tokenType {
+	if !p.IsEOF() && p.CurrToken().Type == tokenType {
 		p.Pos++
-		return cval
-	} else {
-		p.RaiseParseError("unexpected token")
-		return nil
+		return &p.Input[p.Pos-1]
 	}
+	p.RaiseExpected(p.tokenName(tokenType))
+	return nil
 }
 
 func (p *Parser) ParseTokenOnCondition(cond func(t *types.Token) bool) *types.Token {
-	cval := p.CurrToken()
-	if cond(cval) {
+	if !p.IsEOF() && cond(p.CurrToken()) {
 		p.Pos++
-		return cval
-	} else {
-		p.RaiseParseError("unexpected token")
-		return nil
+		return &p.Input[p.Pos-1]
 	}
+	p.noteFailure("", "")
+	panic(p.errorAt(p.Pos, "unexpected "+p.describeToken(p.Pos)))
 }
 
 func (p *Parser) CurrToken() *types.Token {
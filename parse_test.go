@@ -0,0 +1,83 @@
+package main
+
+import (
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	. "pocket-lang/parse"
+	"testing"
+)
+
+func parseWithErrors(src string) (Nod, ParseErrors) {
+	return pocket.ParseWithErrors(pocket.Tokenize(src))
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	src := "Point clas\n    x int\n\n" +
+		"main func\n" +
+		"    y : (1 +\n" +
+		"    if y <\n" +
+		"        print 'skipped'\n" +
+		"    z : 5\n" +
+		"    )\n" +
+		"    print z\n"
+	top, errs := parseWithErrors(src)
+
+	expected := []string{
+		"At line 1 col 11: expected 'func' or 'class', found 'clas'",
+		"At line 5 col 13: expected a value, found end of line",
+		"At line 6 col 11: expected a value, found end of line",
+		"At line 9 col 5: expected a statement, found ')'",
+	}
+	if len(errs) != len(expected) {
+		t.Fatal("expected", len(expected), "errors, got", errs)
+	}
+	for ndx, err := range errs {
+		if err.Error() != expected[ndx] {
+			t.Error("expected", expected[ndx], "got", err.Error())
+		}
+	}
+
+	// main survives with the statements around the broken ones
+	units := NodGetChildList(top)
+	if len(units) != 1 {
+		t.Fatal("expected just main to parse, got", len(units), "units")
+	}
+	if stmts := NodGetChildList(NodGetChild(units[0], NTR_FUNCDEF_CODE)); len(stmts) != 2 {
+		t.Error("expected the two good statements of main, got", len(stmts))
+	}
+}
+
+func TestParseErrorMessages(t *testing.T) {
+	cases := map[string]string{
+		"main func\n    print 1 2\n":                "only zero and one arg cmds supported for now, use parens for more",
+		"main func\n    x : 99999999999999999999\n": "int literal 99999999999999999999 out of range, use bigint('99999999999999999999')",
+		"main fun\n    pass\n":                      "expected 'func' or 'class', found 'fun'",
+		"main func\n    f(1\n":                      "expected an operator, ',', a value or ')', found end of line",
+		"A class\n    pragma loud\n        x int\n": "invalid modifier loud",
+	}
+	for src, msg := range cases {
+		_, errs := parseWithErrors(src)
+		if len(errs) != 1 || errs[0].Msg != msg {
+			t.Errorf("parsing %q: expected %q, got %v", src, msg, errs)
+		}
+	}
+}
+
+func TestParseTestBlocks(t *testing.T) {
+	src := "test 'adds up'\n    assert 1 + 1 = 2\n\nmain func\n    assert : 1\n    print(assert)\n"
+	top, errs := parseWithErrors(src)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	units := NodGetChildList(top)
+	if len(units) != 2 || units[0].NodeType != NT_TESTDEF || units[0].Data != "adds up" {
+		t.Fatal("expected a test block and main, got", PrettyPrintNodes(units))
+	}
+	if stmt := NodGetChildList(NodGetChild(units[0], NTR_TESTDEF_CODE))[0]; stmt.NodeType != NT_ASSERT {
+		t.Error("expected an assert, got", PrettyPrint(stmt))
+	}
+	// assert is only a keyword where it starts a statement
+	if stmt := NodGetChildList(NodGetChild(units[1], NTR_FUNCDEF_CODE))[0]; stmt.NodeType != NT_VARASSIGN {
+		t.Error("expected assert to be assigned to, got", PrettyPrint(stmt))
+	}
+}
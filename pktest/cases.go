@@ -0,0 +1,349 @@
+package pktest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"pocket-lang/backend/goback"
+	"pocket-lang/backend/interp"
+	"pocket-lang/frontend/pocket"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	"strings"
+)
+
+// A case file holds programs and what they print, each followed by its
+// expected output between >>> markers:
+//
+//	# adds two numbers
+//	main func
+//	    print(1 + 1)
+//	>>>2>>>
+//
+// A program's leading comment names its case. The expected output can start
+// with directives, one per line:
+//
+//	error: unknown variable 'x'  compiling fails, with an error containing this
+//	exit: 2                      the program exits with this code
+//	stdin: some input            a line the program gets on stdin
+
+type Case struct {
+	Name     string
+	Src      string
+	Stdin    string
+	Want     string // the expected output, sanitized
+	WantErr  string
+	WantExit int
+
+	section int // index of the expected section in the file, split on >>>
+}
+
+// what came of compiling and running a case
+type Result struct {
+	Output   string
+	Stderr   string
+	ExitCode int
+	Err      string // why compiling failed, if it did
+}
+
+func ReadCases(path string) []*Case {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return ParseCases(string(dat))
+}
+
+func ParseCases(src string) []*Case {
+	sections := strings.Split(src, ">>>")
+	rv := []*Case{}
+	for i := 0; i+1 < len(sections); i += 2 {
+		c := &Case{
+			Name:    caseName(sections[i], len(rv)),
+			Src:     sections[i],
+			section: i + 1,
+		}
+		directives, output := splitDirectives(sections[i+1])
+		for _, directive := range directives {
+			key, value := splitDirective(directive)
+			if key == "error" {
+				c.WantErr = strings.TrimSpace(value)
+			} else if key == "exit" {
+				fmt.Sscan(value, &c.WantExit)
+			} else {
+				c.Stdin += strings.TrimPrefix(value, " ") + "\n"
+			}
+		}
+		c.Want = SanitizeOutput(output)
+		rv = append(rv, c)
+	}
+	return rv
+}
+
+func caseName(src string, ndx int) string {
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		}
+		break
+	}
+	return fmt.Sprint("case ", ndx+1)
+}
+
+var directiveKeys = []string{"error:", "exit:", "stdin:"}
+
+// splits the directive lines off the start of an expected section
+func splitDirectives(section string) ([]string, string) {
+	directives := []string{}
+	rest := strings.TrimLeft(section, " \r\n")
+	for {
+		line := rest
+		if ndx := strings.Index(rest, "\n"); ndx >= 0 {
+			line = rest[:ndx]
+		}
+		if !isDirective(line) {
+			return directives, rest
+		}
+		directives = append(directives, strings.TrimRight(line, "\r"))
+		rest = rest[len(line):]
+		if rest != "" {
+			rest = rest[1:]
+		}
+	}
+}
+
+func expectsCompileError(section string) bool {
+	directives, _ := splitDirectives(section)
+	for _, directive := range directives {
+		if key, _ := splitDirective(directive); key == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+func isDirective(line string) bool {
+	for _, key := range directiveKeys {
+		if strings.HasPrefix(line, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitDirective(line string) (string, string) {
+	ndx := strings.Index(line, ":")
+	return line[:ndx], line[ndx+1:]
+}
+
+// compiles and runs a case, with the interpreter or by building it with the
+// go backend
+func (c *Case) Run(interpret bool) (rv Result) {
+	var code Nod
+	var genned string
+	compiled := func() bool {
+		defer func() {
+			if r := recover(); r != nil {
+				rv.Err = fmt.Sprint(r)
+			}
+		}()
+		code = xform.Xform(pocket.Parse(pocket.Tokenize(c.Src)))
+		if !interpret {
+			genned = goback.Generate(code)
+		}
+		return true
+	}()
+	if !compiled {
+		return rv
+	}
+
+	if interpret {
+		// a runtime error ends the program like a panic in generated code would
+		out := &bytes.Buffer{}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					rv.Stderr = fmt.Sprint("panic: ", r)
+					rv.ExitCode = 2
+				}
+			}()
+			interp.Run(code, out)
+		}()
+		rv.Output = out.String()
+		return rv
+	}
+
+	dir, err := ioutil.TempDir("", "pktest")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				rv.Err = fmt.Sprint(r)
+			}
+		}()
+		exec := goback.BuildAndRun(genned, dir, c.Stdin)
+		rv.Output, rv.Stderr, rv.ExitCode = exec.Stdout, exec.Stderr, exec.ExitCode
+	}()
+	return rv
+}
+
+// says how the result differs from what the case expects, or "" if it doesn't
+func (c *Case) Check(r Result) string {
+	if c.WantErr != "" {
+		if r.Err == "" {
+			return fmt.Sprintf("expected a compile error containing %q, but it compiled", c.WantErr)
+		} else if !strings.Contains(r.Err, c.WantErr) {
+			return fmt.Sprintf("expected a compile error containing %q, got:\n%s", c.WantErr, r.Err)
+		}
+		return ""
+	}
+	if r.Err != "" {
+		return "compile error:\n" + r.Err
+	}
+
+	problems := []string{}
+	if r.ExitCode != c.WantExit {
+		problems = append(problems, fmt.Sprintf("exit code %d, expected %d", r.ExitCode, c.WantExit))
+	}
+	if got := SanitizeOutput(r.Output); got != c.Want {
+		problems = append(problems, "output differs:\n"+UnifiedDiff(c.Want, got))
+	}
+	if len(problems) > 0 && r.Stderr != "" {
+		problems = append(problems, "stderr:\n"+r.Stderr)
+	}
+	return strings.Join(problems, "\n")
+}
+
+// rewrites the expected sections of the cases that didn't get what they
+// expected, so they expect what they got instead
+func UpdateCases(src string, cases []*Case, results []Result) string {
+	sections := strings.Split(src, ">>>")
+	for ndx, c := range cases {
+		if c.Check(results[ndx]) != "" {
+			sections[c.section] = c.updatedSection(sections[c.section], results[ndx])
+		}
+	}
+	return strings.Join(sections, ">>>")
+}
+
+func (c *Case) updatedSection(old string, r Result) string {
+	lines := []string{}
+	if r.Err != "" {
+		// errors can run over several lines, the first is enough to match on
+		lines = append(lines, "error: "+strings.Split(r.Err, "\n")[0])
+	} else if r.ExitCode != 0 {
+		lines = append(lines, fmt.Sprint("exit: ", r.ExitCode))
+	}
+	if c.Stdin != "" {
+		for _, line := range strings.Split(strings.TrimSuffix(c.Stdin, "\n"), "\n") {
+			lines = append(lines, "stdin: "+line)
+		}
+	}
+	if output := SanitizeOutput(r.Output); r.Err == "" && output != "" {
+		lines = append(lines, output)
+	}
+
+	// keep the whitespace around the section the way it was
+	lead := old[:len(old)-len(strings.TrimLeft(old, " \r\n"))]
+	trail := old[len(strings.TrimRight(old, " \r\n")):]
+	return lead + strings.Join(lines, "\n") + trail
+}
+
+// a unified diff of want and got, line by line
+func UnifiedDiff(want string, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' ' keeps a line, '-' drops one of want's, '+' adds one of got's
+		line string
+		ai   int // lines of want and got before this one
+		bi   int
+	}
+	edits := []edit{}
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			edits = append(edits, edit{' ', a[i], i, j})
+			i++
+			j++
+		} else if i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]) {
+			edits = append(edits, edit{'-', a[i], i, j})
+			i++
+		} else {
+			edits = append(edits, edit{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	// hunks are the changes with up to 3 unchanged lines around them
+	const context = 3
+	rv := &bytes.Buffer{}
+	rv.WriteString("--- want\n+++ got\n")
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for next := start; next < len(edits) && next-end <= 2*context; next++ {
+			if edits[next].op != ' ' {
+				end = next
+			}
+		}
+		from, to := start-context, end+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		aLen, bLen := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				aLen++
+			}
+			if e.op != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(rv, "@@ -%s +%s @@\n", hunkRange(edits[from].ai, aLen), hunkRange(edits[from].bi, bLen))
+		for _, e := range edits[from:to] {
+			rv.WriteByte(e.op)
+			rv.WriteString(e.line)
+			rv.WriteByte('\n')
+		}
+		start = to
+	}
+	return rv.String()
+}
+
+func hunkRange(before int, length int) string {
+	if length == 0 {
+		return fmt.Sprint(before, ",0")
+	}
+	return fmt.Sprint(before+1, ",", length)
+}
@@ -1,10 +1,11 @@
 package pktest
 
 import (
-	"fmt"
+	"bytes"
 	"io/ioutil"
 	"path/filepath"
 	"pocket-lang/backend/goback"
+	"pocket-lang/backend/interp"
 	"pocket-lang/frontend/pocket"
 	"pocket-lang/frontend/pocket/common"
 	"pocket-lang/frontend/pocket/xform"
@@ -13,26 +14,24 @@ import (
 	"github.com/davecgh/go-spew/spew"
 )
 
-func RunCase(inFile string) {
-	dat, err := ioutil.ReadFile(inFile)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("input file:")
-	fmt.Println(string(dat))
-	sdat := string(dat)
-	sections := strings.Split(sdat, ">>>")
-	for i := 0; i < len(sections)-1; i += 2 {
-		src := sections[i]
-		desOutput := SanitizeOutput(sections[i+1])
-		actOutput := SanitizeOutput(CompileAndRunSrc(src))
-		fmt.Println("wanted", desOutput)
-		fmt.Println("got", actOutput)
-		if desOutput != actOutput {
-			panic("failed")
+// formats the sources of a case file, leaving the expected outputs alone
+func FormatCase(src string) string {
+	sections := strings.Split(src, ">>>")
+	for i := 0; i < len(sections); i += 2 {
+		if i == len(sections)-1 && strings.TrimSpace(sections[i]) == "" {
+			break
+		}
+		if i+1 < len(sections) && expectsCompileError(sections[i+1]) {
+			// these aren't meant to parse
+			continue
+		}
+		sections[i] = pocket.Format(sections[i])
+		if i > 0 {
+			// the source starts on the line after the >>> that ends the last output
+			sections[i] = "\n" + sections[i]
 		}
 	}
-
+	return strings.Join(sections, ">>>")
 }
 
 func SanitizeOutput(op string) string {
@@ -49,27 +48,26 @@ func ListDirFiles(inDir string) []string {
 	rv := []string{}
 	for _, file := range files {
 		fullPath := filepath.Join(inDir, file.Name())
-		fmt.Println("fullPath", fullPath)
+		common.Tracef(common.LOGCH_RUN, "fullPath %s", fullPath)
 		rv = append(rv, fullPath)
 	}
 	return rv
 }
 
 func CompileAndRunSrc(inSrc string) string {
-	fmt.Println("input file:")
-	fmt.Println(string(inSrc))
+	common.Debugf(common.LOGCH_RUN, "input file:\n%s", inSrc)
 
 	tokens := pocket.Tokenize(string(inSrc))
-	fmt.Println("final tokens:\n", spew.Sdump(tokens))
+	common.Tracef(common.LOGCH_TOKENIZE, "final tokens:\n%s", common.Lazy(func() string { return spew.Sdump(tokens) }))
 
 	parsed := pocket.Parse(tokens)
-	fmt.Println("final parsed:\n", common.PrettyPrint(parsed))
+	common.Debugf(common.LOGCH_PARSE, "final parsed:\n%s", common.LazyTree(parsed))
 
 	xformed := xform.Xform(parsed)
-	fmt.Println("final xformed:\n", common.PrettyPrint(xformed))
+	common.Debugf(common.LOGCH_XFORM, "final xformed:\n%s", common.LazyTree(xformed))
 
 	genned := goback.Generate(parsed)
-	fmt.Println("final generated:\n", genned)
+	common.Debugf(common.LOGCH_GEN, "final generated:\n%s", genned)
 
 	err := ioutil.WriteFile("./outcode/out.go", []byte(genned), 0644)
 	if err != nil {
@@ -86,3 +84,23 @@ func CompileAndRunFile(inPath string) (output string) {
 	}
 	return CompileAndRunSrc(string(dat))
 }
+
+// runs the source with the tree-walking interpreter instead of the go toolchain
+func InterpretSrc(inSrc string) string {
+	tokens := pocket.Tokenize(inSrc)
+	parsed := pocket.Parse(tokens)
+	xformed := xform.Xform(parsed)
+	common.Debugf(common.LOGCH_XFORM, "final xformed:\n%s", common.LazyTree(xformed))
+
+	out := &bytes.Buffer{}
+	interp.Run(parsed, out)
+	return out.String()
+}
+
+func InterpretFile(inPath string) string {
+	dat, err := ioutil.ReadFile(inPath)
+	if err != nil {
+		panic(err)
+	}
+	return InterpretSrc(string(dat))
+}
@@ -0,0 +1,59 @@
+package pktest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"pocket-lang/backend/goback"
+	"pocket-lang/backend/interp"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	"regexp"
+)
+
+// RunTests compiles the test blocks of src and runs them, with the
+// interpreter or through a generated test main. The output is the report,
+// and the exit code is 1 if any test failed. Sources without tests give an
+// empty result.
+func RunTests(src string, interpret bool) (rv Result) {
+	var code Nod
+	var tests []TestDef
+	defer func() {
+		if r := recover(); r != nil {
+			rv.Err = fmt.Sprint(r)
+		}
+	}()
+	code, tests = xform.XformTests(pocket.Parse(pocket.Tokenize(src)))
+	if len(tests) == 0 {
+		return rv
+	}
+
+	if interpret {
+		out := &bytes.Buffer{}
+		if interp.RunTests(code, tests, out) > 0 {
+			rv.ExitCode = 1
+		}
+		rv.Output = out.String()
+		return rv
+	}
+
+	genned := goback.Generate(code)
+	dir, err := ioutil.TempDir("", "pktest")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	exec := goback.BuildAndRunTests(genned, goback.GenerateTestMain(tests), dir)
+	rv.Output, rv.Stderr, rv.ExitCode = exec.Stdout, exec.Stderr, exec.ExitCode
+	return rv
+}
+
+var testTimings = regexp.MustCompile(`\(\d+\.\d+ms\)`)
+
+// blanks out the timings in a test report, so reports can be compared
+func SanitizeTestReport(report string) string {
+	return testTimings.ReplaceAllString(SanitizeOutput(report), "(-ms)")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"pocket-lang/frontend/pocket"
+	"pocket-lang/frontend/pocket/xform"
+	"strings"
+	"testing"
+)
+
+func TestExplainType(t *testing.T) {
+	src := "main func\n    x string : 'a'\n    x : 1\n    print(x)\n"
+	prov, err := xform.XformWithProvenance(pocket.Parse(pocket.Tokenize(src)))
+	want := "couldn't find a valid type for literal at 3:9: inferred int from literal at 3:9, but required string by declaration at 2:5"
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+
+	nods := prov.NodesAt(3, 9)
+	if len(nods) == 0 {
+		t.Fatal("nothing found at 3:9")
+	}
+	explained := prov.Explain(nods[0])
+	for _, line := range []string{
+		"round 1, marPosPrimitiveLiterals: void -> int, literal at 3:9",
+		"marNegDeclaredType: duck -> string, declaration at 2:5",
+		"marNegVarAssign: duck -> string, assignment at 3:5",
+	} {
+		if !strings.Contains(explained, line) {
+			t.Errorf("expected the explanation to have %q, got\n%s", line, explained)
+		}
+	}
+
+	nods = prov.NodesAt(2, 5)
+	if len(nods) == 0 || !strings.HasPrefix(prov.Explain(nods[0]), "assignment at 2:5: ") {
+		t.Error("expected the assignment at 2:5")
+	}
+}
+
+// normal builds say where both sides of a type error came from too
+func TestTypeErrorSummary(t *testing.T) {
+	src := "main func\n    x string : 'a'\n    x : 1\n    print(x)\n"
+	want := "couldn't find a valid type for literal at 3:9: inferred int from literal at 3:9, but required string by declaration at 2:5"
+	defer func() {
+		if r := recover(); r == nil || r != want {
+			t.Errorf("expected %q, got %v", want, r)
+		}
+	}()
+	xform.XformOldSolve(pocket.Parse(pocket.Tokenize(src)))
+}
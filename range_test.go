@@ -0,0 +1,32 @@
+package main
+
+import (
+	. "pocket-lang/backend/goback"
+	"reflect"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	if r := P__range(0, 3, 1, false); !reflect.DeepEqual(r, []int{0, 1, 2}) {
+		t.Error("bad exclusive range", r)
+	}
+	if r := P__range(3, 0, -1, true); !reflect.DeepEqual(r, []int{3, 2, 1, 0}) {
+		t.Error("bad descending range", r)
+	}
+	if r := P__slice([]int{1, 2, 3, 4}, -3, -1, 1, true, "here"); !reflect.DeepEqual(r, []int{2, 3, 4}) {
+		t.Error("bad negative slice", r)
+	}
+	if s := P__str_slice("héllo", 1, 2, 1, true, "here"); s != "él" {
+		t.Error("bad string slice", s)
+	}
+}
+
+func TestIndexOutOfRange(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r != "index 5 out of range [0:3] at line 2 col 7" {
+			t.Error("unexpected panic", r)
+		}
+	}()
+	P__index(3, 5, "line 2 col 7")
+}
@@ -0,0 +1,346 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"pocket-lang/backend/goback"
+	"pocket-lang/backend/interp"
+	"pocket-lang/frontend/pocket"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	xformbase "pocket-lang/xform"
+	"strings"
+)
+
+// the variable an expression's value is stored in
+const valueVarName = "_repl"
+
+// Session holds the parsed, never transformed top level of everything typed
+// so far. Each input is tokenized and parsed on its own and its units are
+// added to the top level. Statements go into an implicit main func. Since
+// the solver works on whole programs, every input transforms a fresh copy of
+// the top level, earlier statements included, so solving gets slower as a
+// session grows. Only the new statements run though: main's variables and
+// the static zones carry over from one run to the next in state.
+type Session struct {
+	top   Nod // NT_TOPLEVEL
+	main  Nod // the implicit main func, part of top
+	state *interp.State
+	out   io.Writer
+}
+
+func NewSession(out io.Writer) *Session {
+	top := parseSrc("main func\n    pass\n")
+	return &Session{
+		top:   top,
+		main:  NodGetChildList(top)[0],
+		state: interp.NewState(),
+		out:   out,
+	}
+}
+
+func Run(in io.Reader, out io.Writer) {
+	s := NewSession(out)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, ">>> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		input := scanner.Text()
+		if opensBlock(input) {
+			// indented blocks end with an empty line
+			for {
+				fmt.Fprint(out, "... ")
+				if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+					break
+				}
+				input += "\n" + scanner.Text()
+			}
+		}
+		if strings.TrimSpace(input) == ":quit" {
+			return
+		}
+		s.Eval(input)
+	}
+}
+
+// whether line is the header of an indented block, rather than a one line
+// func or class whose body follows a colon
+func opensBlock(line string) (rv bool) {
+	if strings.HasPrefix(strings.TrimSpace(line), ":") {
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rv = false
+		}
+	}()
+	toks := pocket.Tokenize(line)
+	if len(toks) == 0 {
+		return false
+	}
+	switch toks[0].Type {
+	case pocket.TK_IF, pocket.TK_ELSE, pocket.TK_WHILE, pocket.TK_LOOP, pocket.TK_FOR:
+		return true
+	}
+	if len(toks) < 2 || !(toks[1].Type == pocket.TK_CLASS || (toks[1].Type == pocket.TK_ALPHANUM && toks[1].Data == "func")) {
+		return false
+	}
+	depth := 0
+	for _, tok := range toks[2:] {
+		switch tok.Type {
+		case pocket.TK_PARENL, pocket.TK_BRACKL, pocket.TK_CURLYL:
+			depth++
+		case pocket.TK_PARENR, pocket.TK_BRACKR, pocket.TK_CURLYR:
+			depth--
+		case pocket.TK_COLON:
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// evaluates one input, reporting errors instead of panicking
+func (s *Session) Eval(input string) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(s.out, "error:", r)
+		}
+	}()
+
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return
+	}
+	for _, cmd := range []string{":type", ":ast", ":go"} {
+		if strings.HasPrefix(trimmed, cmd+" ") {
+			s.evalCommand(cmd, strings.TrimSpace(strings.TrimPrefix(trimmed, cmd)))
+			return
+		}
+	}
+	if strings.HasPrefix(trimmed, ":") {
+		panic("unknown command " + strings.Fields(trimmed)[0])
+	}
+
+	if units, ok := tryParse(input); ok && len(NodGetChildList(units)) > 0 {
+		s.addDefinitions(NodGetChildList(units))
+		return
+	}
+
+	stmts, stmtErr := tryParseStatements(input)
+	if stmtErr != nil || isValueLike(stmts) {
+		// the solved program is kept, so an expression is only solved once
+		if code, assign, ok := s.trySolveValue(input); ok {
+			s.evalExpression(code, assign)
+			return
+		}
+	}
+	if stmtErr == nil {
+		s.runStatements(stmts)
+	} else {
+		panic(stmtErr)
+	}
+}
+
+// calls are statements, but also have a value worth showing
+func isValueLike(stmts []Nod) bool {
+	if len(stmts) != 1 {
+		return false
+	}
+	nt := stmts[0].NodeType
+	if nt != NT_RECEIVERCALL && nt != NT_RECEIVERCALL_CMD && nt != NT_RECEIVERCALL_METHOD {
+		return false
+	}
+	base := NodGetChildOrNil(stmts[0], NTR_RECEIVERCALL_BASE)
+	if base != nil {
+		if name, ok := base.Data.(string); ok && name == "print" {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Session) addDefinitions(units []Nod) {
+	// redefining a func or class replaces the old one
+	existing := NodGetChildList(s.top)
+	for _, unit := range units {
+		if unitName(unit) == "main" {
+			panic("main is implicit in the repl, type statements directly")
+		}
+		replaced := false
+		for ndx, old := range existing {
+			if old != s.main && unitName(old) == unitName(unit) {
+				existing[ndx] = unit
+				replaced = true
+			}
+		}
+		if !replaced {
+			existing = append(existing, unit)
+		}
+	}
+	candidate := NodNewChildList(NT_TOPLEVEL, copyNodes(existing))
+	// make sure everything still compiles before keeping it
+	xform.Xform(NodDeepCopyDownwards(candidate))
+	s.top = candidate
+	s.main = findMain(candidate)
+	for _, unit := range units {
+		// a redefined class's static zone starts over
+		delete(s.state.Statics, unitName(unit))
+		fmt.Fprintln(s.out, "defined", unitName(unit))
+	}
+}
+
+func unitName(unit Nod) string {
+	if unit.NodeType == NT_FUNCDEF {
+		return NodGetChild(unit, NTR_FUNCDEF_NAME).Data.(string)
+	} else if unit.NodeType == NT_CLASSDEF {
+		return NodGetChild(unit, NTR_CLASSDEF_NAME).Data.(string)
+	}
+	return ""
+}
+
+func findMain(top Nod) Nod {
+	for _, unit := range NodGetChildList(top) {
+		if unit.NodeType == NT_FUNCDEF && unitName(unit) == "main" {
+			return unit
+		}
+	}
+	panic("no main func")
+}
+
+func (s *Session) mainStatements() []Nod {
+	return NodGetChildList(NodGetChild(s.main, NTR_FUNCDEF_CODE))
+}
+
+// returns a transformable copy of the top level, with stmts added to main
+func (s *Session) buildProgram(stmts []Nod) Nod {
+	units := []Nod{}
+	for _, unit := range NodGetChildList(s.top) {
+		if unit == s.main {
+			body := append(copyNodes(s.mainStatements()), copyNodes(stmts)...)
+			unit = NodDeepCopyDownwards(unit)
+			NodRemoveChild(unit, NTR_FUNCDEF_CODE)
+			NodSetChild(unit, NTR_FUNCDEF_CODE, NodNewChildList(NT_IMPERATIVE, body))
+		} else {
+			unit = NodDeepCopyDownwards(unit)
+		}
+		units = append(units, unit)
+	}
+	return NodNewChildList(NT_TOPLEVEL, units)
+}
+
+// runs stmts after main's earlier statements, which already ran
+func (s *Session) run(stmts []Nod) {
+	code := s.buildProgram(stmts)
+	xform.Xform(code)
+	interp.RunResuming(code, len(s.mainStatements()), s.state, s.out)
+}
+
+func (s *Session) runStatements(stmts []Nod) {
+	s.run(stmts)
+	// keep the statements only once they ran fine
+	body := NodGetChild(s.main, NTR_FUNCDEF_CODE)
+	NodSetOutList(body, append(s.mainStatements(), copyNodes(stmts)...))
+}
+
+// runs a solved program from trySolveValue, printing the value and its type
+func (s *Session) evalExpression(code Nod, assign Nod) {
+	typ := typeOfAssign(assign)
+	interp.RunResuming(code, len(s.mainStatements()), s.state, s.out)
+	fmt.Fprintln(s.out, formatValue(s.state.Locals[valueVarName]), "#", typ)
+	delete(s.state.Locals, valueVarName)
+}
+
+// solves expr like solveExpression, failing rather than panicking when it
+// isn't an expression or has no value
+func (s *Session) trySolveValue(expr string) (code Nod, assign Nod, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	code, assign = s.solveExpression(expr)
+	return code, assign, typeOfAssign(assign) != "void"
+}
+
+// xforms a program assigning expr to the value var, returning the assignment
+func (s *Session) solveExpression(expr string) (Nod, Nod) {
+	stmts, err := tryParseStatements(valueVarName + " : " + expr)
+	if err != nil {
+		panic(err)
+	}
+	code := s.buildProgram(stmts)
+	xform.Xform(code)
+	mainStmts := NodGetChildList(NodGetChild(findMain(code), NTR_FUNCDEF_CODE))
+	return code, mainStmts[len(mainStmts)-1]
+}
+
+func typeOfAssign(assign Nod) string {
+	return DypeString(NodGetChildOrNil(NodGetChild(assign, NTR_VARDEF), NTR_TYPE))
+}
+
+func (s *Session) evalCommand(cmd string, expr string) {
+	code, assign := s.solveExpression(expr)
+	if cmd == ":type" {
+		fmt.Fprintln(s.out, typeOfAssign(assign))
+		return
+	}
+	if cmd == ":ast" {
+		fmt.Fprintln(s.out, PrettyPrint(NodGetChild(assign, NTR_VARASSIGN_VALUE)))
+		return
+	}
+	preparer := &goback.Preparer{Xformer: &xformbase.Xformer{}}
+	preparer.Prepare(code)
+	genned := goback.GenerateValue(NodGetChild(assign, NTR_VARASSIGN_VALUE))
+	fmt.Fprintln(s.out, genned)
+}
+
+func formatValue(val interface{}) string {
+	if str, ok := val.(string); ok {
+		return "'" + strings.Replace(str, "'", "\\'", -1) + "'"
+	}
+	return fmt.Sprint(val)
+}
+
+func copyNodes(nodes []Nod) []Nod {
+	rv := []Nod{}
+	for _, n := range nodes {
+		rv = append(rv, NodDeepCopyDownwards(n))
+	}
+	return rv
+}
+
+func parseSrc(src string) Nod {
+	return pocket.Parse(pocket.Tokenize(src))
+}
+
+func tryParse(src string) (rv Nod, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	return parseSrc(src), true
+}
+
+// parses src as the body of a func
+func tryParseStatements(src string) (stmts []Nod, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	lines := strings.Split(src, "\n")
+	for ndx, line := range lines {
+		lines[ndx] = "    " + line
+	}
+	top := parseSrc("_repl_main func\n" + strings.Join(lines, "\n") + "\n")
+	body := NodGetChild(NodGetChildList(top)[0], NTR_FUNCDEF_CODE)
+	return NodGetChildList(body), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"pocket-lang/pktest"
+	"pocket-lang/repl"
+	"strings"
+	"testing"
+)
+
+func TestReplSession(t *testing.T) {
+	input := "double func(x int) int\n    return x * 2\n\n" +
+		"half func(x int) int: x / 2\n" +
+		"double(3)\n" +
+		"print('once')\n" +
+		"n : 1\n" +
+		"n : n + 1\n" +
+		"n\n" +
+		"l : [1, 2]\n" +
+		"l[0] : half(8)\n" +
+		"l\n" +
+		"Counter class\n    pragma static\n        count : 5\n\n" +
+		"(@Counter).count : (@Counter).count + 1\n" +
+		"print((@Counter).count)\n" +
+		"print(l[5])\n" +
+		"n\n"
+	want := "defined double\n" +
+		"defined half\n" +
+		"6 # int\n" +
+		"once\n" +
+		"2 # int\n" +
+		"[4 2] # list(int)\n" +
+		"defined Counter\n" +
+		"6\n" +
+		"error: index 5 out of range [0:2] at line 2 col 12\n" +
+		"2 # int\n"
+
+	out := &bytes.Buffer{}
+	repl.Run(strings.NewReader(input), out)
+	got := strings.NewReplacer(">>> ", "", "... ", "").Replace(out.String())
+	if got = strings.TrimSuffix(got, "\n"); got != want {
+		t.Errorf("session differs:\n%s", pktest.UnifiedDiff(want, got))
+	}
+}
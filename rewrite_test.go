@@ -0,0 +1,92 @@
+package main
+
+import (
+	"pocket-lang/dump"
+	. "pocket-lang/frontend/pocket/common"
+	"pocket-lang/frontend/pocket/xform"
+	. "pocket-lang/parse"
+	"strings"
+	"testing"
+)
+
+// the statements of main, after stage
+func mainStatements(t *testing.T, src string, stage string) []Nod {
+	for _, unit := range NodGetChildList(dump.Compile(src, stage)) {
+		if unit.NodeType == NT_FUNCDEF && NodGetChild(unit, NTR_FUNCDEF_NAME).Data == "main" {
+			return NodGetChildList(NodGetChild(unit, NTR_FUNCDEF_CODE))
+		}
+	}
+	t.Fatal("no main")
+	return nil
+}
+
+func TestRewriteRules(t *testing.T) {
+	src := "main func\n    x : 1\n    y : x\n    print('len')\n"
+	stmts := mainStatements(t, src, "parse")
+	root := NodNewChildList(NT_IMPERATIVE, stmts)
+
+	rules := `
+# x : 1 -> x : -1, but only for literals
+rule negateLiterals
+    a@VARASSIGN(VARASSIGN_VALUE: lit@LIT_INT, !TYPE_DECL) when !isZero(lit)
+    > a(VARASSIGN_VALUE: NEGOP(RECEIVERCALL_ARG: lit))
+
+# print('len') -> print(['len', 'len'])
+rule doubleLen
+    c@RECEIVERCALL_CMD(RECEIVERCALL_ARG: arg@LIT_STRING = 'len')
+    > c(RECEIVERCALL_ARG: arg as LIT_LIST([0]: copy arg, [1]: LIT_STRING = arg))
+`
+	n, err := xform.ApplyRewriteRules(root, rules, xform.RewriteFuncs{
+		Preds: map[string]func(Nod) bool{"isZero": func(n Nod) bool { return n.Data == 0 }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("expected 2 rewrites, got", n)
+	}
+	stmts = NodGetChildList(root)
+	if neg := NodGetChild(stmts[0], NTR_VARASSIGN_VALUE); neg.NodeType != NT_NEGOP || NodGetChild(neg, NTR_RECEIVERCALL_ARG).Data != 1 {
+		t.Error("expected the assign of 1 to become -1, got", PrettyPrint(stmts[0]))
+	}
+	if NodGetChild(stmts[1], NTR_VARASSIGN_VALUE).NodeType == NT_NEGOP {
+		t.Error("expected the assign of x to be left alone, got", PrettyPrint(stmts[1]))
+	}
+	args := NodGetChild(stmts[2], NTR_RECEIVERCALL_ARG)
+	if args.NodeType != NT_LIT_LIST || len(NodGetChildList(args)) != 2 || NodGetChildList(args)[1].Data != "len" {
+		t.Error("expected print to get a list of two, got", PrettyPrint(stmts[2]))
+	}
+}
+
+func TestRewriteRuleErrors(t *testing.T) {
+	cases := map[string]string{
+		"rule r\n    VARASSIGN > NOPE":                       "line 2: unknown node type NOPE",
+		"rule r\n    VARASSIGN(VAR_NAME: v) > w":             "line 2: w isn't bound by the pattern",
+		"rule r\n    v > v":                                  "line 2: rule r has to match a node type at the top",
+		"rule r\n    VARASSIGN when odd(v) > v":              "line 2: unknown predicate odd",
+		"rule r\n    VARASSIGN(VAR_NAME v) > v":              "line 2: expected ':', found 'v'",
+		"rule r\n    VARASSIGN(VARNAME: v)\n    > v":         "line 2: unknown edge label VARNAME",
+		"rule r\n    VARASSIGN = 'x\n":                       "line 2: unterminated string",
+		"rule r\n    LIT_LIST([x]: v) > v":                   "line 2: expected a list index, found 'x'",
+		"rule r\n    v@VARASSIGN > v\nrule s\n    VARASSIGN": "line 4: expected '>', found the end",
+	}
+	for src, msg := range cases {
+		_, err := xform.ApplyRewriteRules(NodNew(NT_IMPERATIVE), src, xform.RewriteFuncs{})
+		if err == nil || !strings.HasSuffix(err.Error(), msg) {
+			t.Errorf("compiling %q: expected %q, got %v", src, msg, err)
+		}
+	}
+}
+
+func TestDesugarArithAssign(t *testing.T) {
+	stmts := mainStatements(t, "main func\n    x : 1\n    x +: 2\n", "desugar")
+	assign := stmts[1]
+	op := NodGetChild(assign, NTR_VARASSIGN_VALUE)
+	if assign.NodeType != NT_VARASSIGN || op.NodeType != NT_ADDOP ||
+		NodGetChild(op, NTR_BINOP_LEFT).NodeType != NT_VAR_GETTER || NodGetChild(op, NTR_BINOP_RIGHT).Data != 2 {
+		t.Error("expected x : x + 2, got", PrettyPrint(assign))
+	}
+	if NodGetChild(NodGetChild(op, NTR_BINOP_LEFT), NTR_VAR_NAME) == NodGetChild(assign, NTR_VAR_NAME) {
+		t.Error("expected the var getter to have its own copy of the name")
+	}
+}
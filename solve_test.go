@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"pocket-lang/frontend/pocket"
+	"pocket-lang/frontend/pocket/xform"
+	"strings"
+	"testing"
+)
+
+// a program of n classes, each with a func that builds and loops over one,
+// all called from main
+func generateSolveProgram(n int) string {
+	b := &strings.Builder{}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(b, "Point%d class\n    x int\n    y int\n\n", i)
+		fmt.Fprintf(b, "sum%d func(a int) int\n    p : Point%d{x: a, y: %d}\n    b : p.x + p.y\n", i, i, i)
+		b.WriteString("    for j in 0..2\n        b : b + j\n    return b * 2\n\n")
+	}
+	b.WriteString("main func\n    total : 0\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(b, "    total : total + sum%d(%d)\n", i, i)
+	}
+	b.WriteString("    print(total)\n")
+	return b.String()
+}
+
+// go test -run NONE -bench Solve
+func BenchmarkSolve(b *testing.B) {
+	for _, n := range []int{10, 30, 100} {
+		src := generateSolveProgram(n)
+		b.Run(fmt.Sprintf("units=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				root := pocket.Parse(pocket.Tokenize(src))
+				b.StartTimer()
+				xform.XformOldSolve(root)
+			}
+		})
+	}
+}
@@ -25,6 +25,14 @@ func (tkzr *Tokenizer) CurrRune() rune {
 	return rune(tkzr.Input[tkzr.Pos])
 }
 
+// returns the rune offset runes ahead of the current one, or 0 if past the end
+func (tkzr *Tokenizer) PeekRune(offset int) rune {
+	if tkzr.Pos+offset >= len(tkzr.Input) {
+		return 0
+	}
+	return rune(tkzr.Input[tkzr.Pos+offset])
+}
+
 func (tkzr *Tokenizer) EndBufedToken(tokType int) {
 	tkzr.EmitTokenObject(&types.Token{
 		Data:           tkzr.Tokbuf.String(),
@@ -66,6 +66,11 @@ func (x *Xformer) Replace(what Nod, with Nod) {
 		}
 	}
 	with.In = append(with.In, toAddToWithIn...)
+
+	// the replacement inherits the source location of what it replaced
+	if with.Loc == nil {
+		with.Loc = what.Loc
+	}
 }
 
 func (x *Xformer) Replace2(what Nod, with func(old Nod) Nod) {
@@ -86,6 +91,9 @@ func (x *Xformer) Replace2(what Nod, with func(old Nod) Nod) {
 	what.In = nil
 
 	newNod := with(what)
+	if newNod.Loc == nil {
+		newNod.Loc = what.Loc
+	}
 
 	newNod.In = dummyNod.In
 	for _, incomingEdge := range newNod.In {